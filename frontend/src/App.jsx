@@ -5,6 +5,11 @@ import DeviceDetail from "./components/DeviceDetail";
 import UserManagement from "./pages/UserManagement";
 import Alerts from "./pages/Alerts";
 import Login from "./pages/Login";
+import ResetPassword from "./pages/ResetPassword";
+import AcceptInvite from "./pages/AcceptInvite";
+import Settings from "./pages/Settings";
+import SSOCallback from "./pages/SSOCallback";
+import AuditLog from "./pages/AuditLog";
 import { fetchCameras, API_BASE } from "./api";
 import DatePicker from "react-datepicker";
 import "react-datepicker/dist/react-datepicker.css";
@@ -178,7 +183,7 @@ function Dashboard({ token }) {
 
 export default function App() {
   // Usa el AuthContext (así tu login, logout y protección están centralizados)
-  const { token, setToken, logout } = useContext(AuthContext);
+  const { token, refreshToken, login, logout } = useContext(AuthContext);
 
   useEffect(() => {
     console.log("API Base URL:", API_BASE);
@@ -188,10 +193,22 @@ export default function App() {
     logout(); // Usa el método del contexto, así limpia todo (incluyendo localStorage)
   }, [logout]);
 
-  if (!token || isTokenExpired(token)) {
+  // Enlaces recibidos por email (no requieren sesión)
+  if (window.location.pathname === "/reset-password") {
+    return <ResetPassword />;
+  }
+  if (window.location.pathname === "/accept-invite") {
+    return <AcceptInvite />;
+  }
+  if (window.location.pathname === "/sso/callback") {
+    return <SSOCallback />;
+  }
+
+  // Con un refresh token, el contexto renueva el access token vencido
+  if (!token || (isTokenExpired(token) && !refreshToken)) {
     return (
       <div className="min-h-screen bg-flowforge-dark flex items-center justify-center">
-        <Login onLogin={setToken} />
+        <Login onLogin={login} />
       </div>
     );
   }
@@ -204,6 +221,8 @@ export default function App() {
           <Route path="/" element={<Dashboard token={token} />} />
           <Route path="/users" element={<UserManagement token={token} />} />
           <Route path="/alerts" element={<Alerts token={token} />} />
+          <Route path="/settings" element={<Settings />} />
+          <Route path="/audit" element={<AuditLog />} />
           {/* ---- Nueva ruta para termómetros de zonas ---- */}
           <Route path="/zonas" element={<DeviceZones />} />
         </Routes>
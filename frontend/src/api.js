@@ -6,16 +6,157 @@ export const API_BASE =
   "http://localhost:5000/api";
 
 // --- LOGIN ---
-export async function loginUser(email, password) {
+// Retorna { token, refresh_token, expires_in } o, con 2FA,
+// { mfa_required, mfa_enrollment_required, mfa_token }
+export async function loginUser(email, password, companyId) {
   const res = await fetch(`${API_BASE}/login`, {
     method: "POST",
     headers: { "Content-Type": "application/json" },
-    body: JSON.stringify({ email, password }),
+    body: JSON.stringify({ email, password, company_id: companyId }),
   });
-  if (!res.ok) throw new Error("Credenciales inválidas");
+  if (!res.ok) {
+    // 423: cuenta bloqueada; 429: demasiados intentos, el mensaje indica cuánto esperar
+    const data = await res.json().catch(() => ({}));
+    if (res.status === 423 || res.status === 429) throw new Error(data.error);
+    throw new Error("Credenciales inválidas");
+  }
+  return await res.json();
+}
+
+// --- RENOVAR SESIÓN ---
+// El refresh token es de un solo uso: guardar siempre el nuevo que retorna
+export async function refreshSession(refreshToken) {
+  const res = await fetch(`${API_BASE}/refresh`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ refresh_token: refreshToken }),
+  });
+  if (!res.ok) throw new Error("Tu sesión expiró. Por favor, inicia sesión nuevamente.");
+  return await res.json();
+}
+
+// --- LOGOUT ---
+export async function logoutSession(token) {
+  const res = await fetch(`${API_BASE}/logout`, {
+    method: "POST",
+    headers: { Authorization: `Bearer ${token}` },
+  });
+  if (!res.ok) throw new Error("Error al cerrar sesión");
+  return await res.json();
+}
+
+// --- CONTRASEÑAS ---
+async function passwordRequest(path, body, token) {
+  const headers = { "Content-Type": "application/json" };
+  if (token) headers.Authorization = `Bearer ${token}`;
+  const res = await fetch(`${API_BASE}/password/${path}`, {
+    method: "POST",
+    headers,
+    body: JSON.stringify(body),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Error al procesar la contraseña");
+  return data;
+}
+
+// Retorna tokens nuevos: el cambio cierra todas las sesiones anteriores
+export function changePassword(currentPassword, newPassword, token) {
+  return passwordRequest("change", { current_password: currentPassword, new_password: newPassword }, token);
+}
+
+export function requestPasswordReset(email, companyId) {
+  return passwordRequest("forgot", { email, company_id: companyId || undefined });
+}
+
+export function resetPassword(resetToken, newPassword) {
+  return passwordRequest("reset", { token: resetToken, new_password: newPassword });
+}
+
+// --- AUTENTICACIÓN DE DOS FACTORES ---
+async function mfaRequest(path, method, body, token) {
+  const headers = { "Content-Type": "application/json" };
+  if (token) headers.Authorization = `Bearer ${token}`;
+  const res = await fetch(`${API_BASE}/${path}`, {
+    method,
+    headers,
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Error en la verificación de dos factores");
+  return data;
+}
+
+// Segundo paso del login: code es el código de la app o uno de recuperación.
+// Si el desafío era de activación, la respuesta incluye recovery_codes.
+export function loginMFA(mfaToken, code) {
+  return mfaRequest("login/mfa", "POST", { mfa_token: mfaToken, code });
+}
+
+// Genera el secreto de un admin al que su empresa le exige 2FA para entrar
+export function startLoginMFAEnrollment(mfaToken) {
+  return mfaRequest("login/mfa/enroll", "POST", { mfa_token: mfaToken });
+}
+
+export function fetchMFAStatus(token) {
+  return mfaRequest("mfa", "GET", null, token);
+}
+
+// Retorna { secret, otpauth_url, qr_code }
+export function startMFAEnrollment(token) {
+  return mfaRequest("mfa/enroll", "POST", null, token);
+}
+
+// Retorna { recovery_codes }
+export function verifyMFAEnrollment(code, token) {
+  return mfaRequest("mfa/enroll/verify", "POST", { code }, token);
+}
+
+export function regenerateRecoveryCodes(code, token) {
+  return mfaRequest("mfa/recovery-codes", "POST", { code }, token);
+}
+
+export function disableMFA(password, code, token) {
+  return mfaRequest("mfa/disable", "POST", { password, code }, token);
+}
+
+// Quita el bloqueo por intentos fallidos de un usuario
+export function unlockUser(userId, token) {
+  return mfaRequest(`users/${userId}/unlock`, "POST", null, token);
+}
+
+export function resetUserMFA(userId, token) {
+  return mfaRequest(`users/${userId}/mfa`, "DELETE", null, token);
+}
+
+export function fetchSecurityPolicy(token) {
+  return mfaRequest("company/security", "GET", null, token);
+}
+
+export function updateSecurityPolicy(policy, token) {
+  return mfaRequest("company/security", "PUT", policy, token);
+}
+
+// --- SSO (OpenID Connect) ---
+// Retorna { enabled, enforce_sso } de la empresa; no requiere sesión
+export async function fetchSSOStatus(companyId) {
+  const res = await fetch(`${API_BASE}/sso/${companyId}`);
+  if (!res.ok) return { enabled: false, enforce_sso: false };
   return await res.json();
 }
 
+// URL que inicia el login en el proveedor de la empresa; vuelve a /sso/callback
+export function ssoLoginURL(companyId) {
+  return `${API_BASE}/sso/${companyId}/login`;
+}
+
+export function fetchSSOConfig(token) {
+  return mfaRequest("company/sso", "GET", null, token);
+}
+
+export function updateSSOConfig(config, token) {
+  return mfaRequest("company/sso", "PUT", config, token);
+}
+
 // --- LISTA DE CÁMARAS ---
 export async function fetchCameras(token) {
   const res = await fetch(`${API_BASE}/cameras`, {
@@ -48,6 +189,45 @@ export async function fetchCameraStatus(cameraId, token, desde, hasta) {
   return await res.json();
 }
 
+// --- SERIE AGREGADA DE UNA ZONA (para gráficos de rangos largos) ---
+// bucket: "5m", "1h", "1d"... (vacío = automático); fns: ["avg", "min", "max", "p95"]
+export async function fetchZoneAggregate(cameraId, zoneId, token, { desde, hasta, bucket, fns } = {}) {
+  const params = new URLSearchParams();
+  if (desde) params.set("desde", desde);
+  if (hasta) params.set("hasta", hasta);
+  if (bucket) params.set("bucket", bucket);
+  if (fns && fns.length) params.set("fn", fns.join(","));
+  const res = await fetch(`${API_BASE}/cameras/${cameraId}/zones/${zoneId}/aggregate?${params}`, {
+    headers: { Authorization: `Bearer ${token}` },
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Error al obtener la serie agregada");
+  return data;
+}
+
+// Descarga el historial de la cámara como Blob. format: "csv", "xlsx" o "parquet";
+// con bucket exporta estadísticas por intervalo (fns, por defecto avg,min,max).
+// tz y unit reemplazan la zona horaria y la unidad de la empresa.
+export async function exportCameraReadings(cameraId, token, { format, desde, hasta, zoneId, bucket, fns, tz, unit } = {}) {
+  const params = new URLSearchParams();
+  if (format) params.set("format", format);
+  if (desde) params.set("desde", desde);
+  if (hasta) params.set("hasta", hasta);
+  if (zoneId) params.set("zone_id", zoneId);
+  if (bucket) params.set("bucket", bucket);
+  if (fns && fns.length) params.set("fn", fns.join(","));
+  if (tz) params.set("tz", tz);
+  if (unit) params.set("unit", unit);
+  const res = await fetch(`${API_BASE}/cameras/${cameraId}/export?${params}`, {
+    headers: { Authorization: `Bearer ${token}` },
+  });
+  if (!res.ok) {
+    const data = await res.json().catch(() => ({}));
+    throw new Error(data.error || "Error al exportar las lecturas");
+  }
+  return await res.blob();
+}
+
 // --- RESUMEN DASHBOARD ---
 export async function fetchCameraSummary(cameraId, token) {
   const res = await fetch(`${API_BASE}/cameras/${cameraId}/summary`, {
@@ -68,6 +248,112 @@ export async function fetchCameraSummary(cameraId, token) {
   return await res.json();
 }
 
+// --- STREAM EN VIVO (SSE) ---
+// Recibe las lecturas nuevas ("reading"), los cambios de estado de las zonas ("zone")
+// y las transiciones de alertas ("alert") de la cámara. EventSource no permite el
+// header Authorization, así que el stream se lee con fetch; al cortarse reconecta
+// con Last-Event-ID y el servidor reenvía lo perdido. "reset" indica que se
+// perdieron demasiados eventos: hay que recargar el estado.
+// handlers: { reading, zone, alert, reset, error }. Retorna la función para cerrar.
+export function subscribeCameraStream(cameraId, token, handlers) {
+  const controller = new AbortController();
+  let lastEventId = "";
+  let retry = 3000;
+
+  const dispatch = (block) => {
+    let name = "message";
+    let data = "";
+    block.split("\n").forEach((line) => {
+      const idx = line.indexOf(":");
+      const field = idx < 0 ? line : line.slice(0, idx);
+      const value = idx < 0 ? "" : line.slice(idx + 1).replace(/^ /, "");
+      if (field === "event") name = value;
+      else if (field === "id") lastEventId = value;
+      else if (field === "retry") retry = Number(value) || retry;
+      else if (field === "data") data += data ? `\n${value}` : value;
+    });
+    const handler = handlers[name];
+    if (!handler || !data) return;
+    try {
+      handler(JSON.parse(data));
+    } catch {
+      handler(data);
+    }
+  };
+
+  const connect = async () => {
+    while (!controller.signal.aborted) {
+      try {
+        const headers = { Authorization: `Bearer ${token}` };
+        if (lastEventId) headers["Last-Event-ID"] = lastEventId;
+        const res = await fetch(`${API_BASE}/stream?camera_id=${cameraId}`, {
+          headers,
+          signal: controller.signal,
+        });
+        if (!res.ok) {
+          const data = await res.json().catch(() => ({}));
+          throw new Error(data.error || "Error al conectar el stream en vivo");
+        }
+        const reader = res.body.getReader();
+        const decoder = new TextDecoder();
+        let buffer = "";
+        for (;;) {
+          const { value, done } = await reader.read();
+          if (done) break;
+          buffer += decoder.decode(value, { stream: true });
+          let end;
+          while ((end = buffer.indexOf("\n\n")) >= 0) {
+            dispatch(buffer.slice(0, end));
+            buffer = buffer.slice(end + 2);
+          }
+        }
+      } catch (err) {
+        if (controller.signal.aborted) return;
+        if (handlers.error) handlers.error(err);
+      }
+      await new Promise((resolve) => setTimeout(resolve, retry));
+    }
+  };
+  connect();
+  return () => controller.abort();
+}
+
+// --- LISTADOS PAGINADOS ---
+// Los listados responden { items, next_cursor }; fetchAllPages sigue los cursores
+// (con el máximo por página) y retorna todos los elementos
+async function fetchAllPages(path, token, errorMsg) {
+  const items = [];
+  let cursor = "";
+  do {
+    const params = new URLSearchParams({ limit: "1000" });
+    if (cursor) params.set("cursor", cursor);
+    const res = await fetch(`${API_BASE}${path}?${params}`, {
+      headers: { Authorization: `Bearer ${token}` },
+    });
+    if (!res.ok) throw new Error(errorMsg);
+    const data = await res.json();
+    items.push(...data.items);
+    cursor = data.next_cursor;
+  } while (cursor);
+  return items;
+}
+
+// Lecturas crudas, de la más nueva a la más antigua. filters: { camera_id, zone_id,
+// desde, hasta, min_temp, max_temp, limit }. Retorna { items, next_cursor }
+export async function fetchCameraReadings(filters, cursor, token) {
+  const params = new URLSearchParams();
+  Object.entries(filters).forEach(([k, v]) => {
+    if (v !== undefined && v !== null && v !== "") params.set(k, v);
+  });
+  if (cursor) params.set("cursor", cursor);
+  const res = await fetch(`${API_BASE}/camera-readings?${params}`, {
+    headers: { Authorization: `Bearer ${token}` },
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Error al obtener lecturas");
+  return data;
+}
+
 // --- EMPRESAS ---
 export async function fetchCompanies(token) {
   const res = await fetch(`${API_BASE}/companies`, {
@@ -77,35 +363,74 @@ export async function fetchCompanies(token) {
   return await res.json();
 }
 
-// --- USUARIOS ---
-export async function fetchUsers(token) {
-  const res = await fetch(`${API_BASE}/users`, {
+// --- ZONA HORARIA Y UNIDADES DE LA EMPRESA ---
+// Retorna { time_zone, temperature_unit }
+export async function fetchCompanyLocale(token) {
+  const res = await fetch(`${API_BASE}/company/locale`, {
     headers: { Authorization: `Bearer ${token}` },
   });
-  if (!res.ok) throw new Error("Error al obtener usuarios");
+  if (!res.ok) throw new Error("Error al obtener la configuración de la empresa");
   return await res.json();
 }
 
-export async function createUser(user, token) {
-  const res = await fetch(`${API_BASE}/users`, {
-    method: "POST",
+export async function updateCompanyLocale(locale, token) {
+  const res = await fetch(`${API_BASE}/company/locale`, {
+    method: "PUT",
     headers: {
       Authorization: `Bearer ${token}`,
       "Content-Type": "application/json",
     },
-    body: JSON.stringify(user),
+    body: JSON.stringify(locale),
   });
-  if (!res.ok) throw new Error("Error al crear usuario");
-  return await res.json();
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Error al guardar la configuración de la empresa");
+  return data;
+}
+
+// --- USUARIOS ---
+export async function fetchUsers(token) {
+  return fetchAllPages(`/users`, token, "Error al obtener usuarios");
+}
+
+// --- INVITACIONES ---
+async function invitationRequest(path, method, token, body) {
+  const headers = {};
+  if (token) headers.Authorization = `Bearer ${token}`;
+  if (body) headers["Content-Type"] = "application/json";
+  const res = await fetch(`${API_BASE}/invitations${path}`, {
+    method,
+    headers,
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Error al procesar la invitación");
+  return data;
+}
+
+export function fetchInvitations(token) {
+  return invitationRequest("", "GET", token);
+}
+
+export function createInvitation(invitation, token) {
+  return invitationRequest("", "POST", token, invitation);
+}
+
+export function resendInvitation(invitationId, token) {
+  return invitationRequest(`/${invitationId}/resend`, "POST", token);
+}
+
+export function revokeInvitation(invitationId, token) {
+  return invitationRequest(`/${invitationId}`, "DELETE", token);
+}
+
+// Retorna los tokens de la primera sesión del invitado
+export function acceptInvitation(inviteToken, password) {
+  return invitationRequest("/accept", "POST", null, { token: inviteToken, password });
 }
 
 // --- ALERTAS DE ZONA ---
 export async function fetchZoneAlerts(token) {
-  const res = await fetch(`${API_BASE}/zone-alerts`, {
-    headers: { Authorization: `Bearer ${token}` },
-  });
-  if (!res.ok) throw new Error("Error al obtener alertas de zona");
-  return await res.json();
+  return fetchAllPages(`/zone-alerts`, token, "Error al obtener alertas de zona");
 }
 
 export async function createZoneAlert(alert, token) {
@@ -145,11 +470,7 @@ export async function deleteZoneAlert(alertId, token) {
 
 // --- ALERTAS DE DISPOSITIVO ---
 export async function fetchDeviceAlerts(token) {
-  const res = await fetch(`${API_BASE}/device-alerts`, {
-    headers: { Authorization: `Bearer ${token}` },
-  });
-  if (!res.ok) throw new Error("Error al obtener alertas de dispositivo");
-  return await res.json();
+  return fetchAllPages(`/device-alerts`, token, "Error al obtener alertas de dispositivo");
 }
 
 export async function createDeviceAlert(alert, token) {
@@ -189,9 +510,75 @@ export async function deleteDeviceAlert(alertId, token) {
 
 // --- HISTORIAL EVENTOS ALERTA DE ZONA ---
 export async function fetchZoneAlertEvents(zoneId, token) {
-  const res = await fetch(`${API_BASE}/zones/${zoneId}/alert-events`, {
+  return fetchAllPages(`/zones/${zoneId}/alert-events`, token, "Error al obtener eventos de alerta de zona");
+}
+
+// --- REGISTRO DE AUDITORÍA ---
+// filters: { action, target_type, target_id, actor_id, desde, hasta } (fechas RFC3339)
+function auditQuery(filters) {
+  const params = new URLSearchParams();
+  Object.entries(filters).forEach(([k, v]) => {
+    if (v) params.set(k, v);
+  });
+  return params;
+}
+
+// Retorna { items, next_cursor }; pasar next_cursor para la página siguiente
+export async function fetchAuditLog(filters, cursor, token) {
+  const params = auditQuery(filters);
+  if (cursor) params.set("cursor", cursor);
+  const res = await fetch(`${API_BASE}/audit-log?${params}`, {
     headers: { Authorization: `Bearer ${token}` },
   });
-  if (!res.ok) throw new Error("Error al obtener eventos de alerta de zona");
-  return await res.json();
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Error al obtener el registro de auditoría");
+  return data;
+}
+
+// Descarga el CSV con los mismos filtros
+export async function exportAuditLog(filters, token) {
+  const res = await fetch(`${API_BASE}/audit-log/export?${auditQuery(filters)}`, {
+    headers: { Authorization: `Bearer ${token}` },
+  });
+  if (!res.ok) throw new Error("Error al exportar el registro de auditoría");
+  return await res.blob();
+}
+
+// --- IMPORTACIÓN DE LECTURAS ---
+// mapping: { timestamp, temperature, camera_id, zone_id, camera, zone, time_format, time_zone, unit, delimiter }
+export async function uploadImport(file, mapping, token) {
+  const form = new FormData();
+  form.append("file", file);
+  if (mapping) form.append("mapping", JSON.stringify(mapping));
+  const res = await fetch(`${API_BASE}/imports`, {
+    method: "POST",
+    headers: { Authorization: `Bearer ${token}` },
+    body: form,
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Error al subir el archivo");
+  return data;
+}
+
+export async function fetchImports(token) {
+  return fetchAllPages("/imports", token, "Error al obtener las importaciones");
+}
+
+// Estado y avance (progress, en %) de una importación
+export async function fetchImport(importId, token) {
+  const res = await fetch(`${API_BASE}/imports/${importId}`, {
+    headers: { Authorization: `Bearer ${token}` },
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Error al obtener la importación");
+  return data;
+}
+
+// Descarga el CSV de líneas rechazadas
+export async function downloadImportErrors(importId, token) {
+  const res = await fetch(`${API_BASE}/imports/${importId}/errors`, {
+    headers: { Authorization: `Bearer ${token}` },
+  });
+  if (!res.ok) throw new Error("Error al descargar el reporte de errores");
+  return await res.blob();
 }
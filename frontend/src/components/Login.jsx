@@ -34,8 +34,7 @@ export default function Login() {
     setError("");
     try {
       const resp = await loginUser(email, password, company);
-      // resp puede ser token directamente o { token } según API, acá asumimos token directo
-      login({ token: resp, company, user: email });
+      login({ token: resp.token, refreshToken: resp.refresh_token, company, user: email });
       navigate("/"); // Redirige al dashboard raíz
     } catch (err) {
       // Mostrar mensaje de error real si viene
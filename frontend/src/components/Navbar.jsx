@@ -1,9 +1,11 @@
-import React from "react";
+import React, { useContext } from "react";
 import { NavLink } from "react-router-dom";
+import { AuthContext, isAdminToken } from "../context/AuthContext";
 import Logo from "../assets/INSTRUMINING-logo.svg";
 import UserAvatarAlt from "./UserAvatarAlt";  // Cambio aquí para importar el nuevo avatar
 
 export default function Navbar({ onLogout }) {
+  const { token } = useContext(AuthContext);
   return (
     <nav className="w-full flex items-center justify-between px-8 py-4 border-b border-flowforge-border bg-flowforge-dark">
       {/* Logo estilo INSTRUMINING */}
@@ -58,6 +60,16 @@ export default function Navbar({ onLogout }) {
         >
           Usuarios
         </NavLink>
+        {isAdminToken(token) && (
+          <NavLink
+            to="/audit"
+            className={({ isActive }) =>
+              "hover:text-white" + (isActive ? " text-white font-bold" : "")
+            }
+          >
+            Auditoría
+          </NavLink>
+        )}
         <NavLink
           to="/settings"
           className={({ isActive }) =>
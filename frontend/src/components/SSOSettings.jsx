@@ -0,0 +1,115 @@
+import React, { useEffect, useState } from "react";
+import { fetchSSOConfig, updateSSOConfig } from "../api";
+
+const inputClass =
+  "bg-flowforge-panel text-white border border-flowforge-border rounded-lg px-3 py-2";
+
+const emptyConfig = {
+  enabled: false,
+  issuer: "",
+  client_id: "",
+  client_secret: "",
+  allowed_domains: "",
+  role_claim: "",
+  role_mapping: "",
+  default_role: "viewer",
+  enforce_sso: false,
+};
+
+// Configuración OpenID Connect de la empresa (solo admins)
+export default function SSOSettings({ token }) {
+  const [config, setConfig] = useState(emptyConfig);
+  const [hasSecret, setHasSecret] = useState(false);
+  const [redirectURL, setRedirectURL] = useState("");
+  const [message, setMessage] = useState("");
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+
+  const apply = (resp) => {
+    setConfig({ ...emptyConfig, ...(resp.config || {}), client_secret: "" });
+    setHasSecret(!!resp.has_client_secret);
+    setRedirectURL(resp.redirect_url || "");
+  };
+
+  useEffect(() => {
+    fetchSSOConfig(token).then(apply).catch((err) => setError(err.message));
+  }, [token]);
+
+  const set = (field) => (e) =>
+    setConfig({ ...config, [field]: e.target.type === "checkbox" ? e.target.checked : e.target.value });
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setError("");
+    setMessage("");
+    setLoading(true);
+    try {
+      apply(await updateSSOConfig(config, token));
+      setMessage("Configuración SSO guardada.");
+    } catch (err) {
+      setError(err.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <section className="mt-10">
+      <h2 className="text-lg font-semibold mb-2">Inicio de sesión con SSO (OpenID Connect)</h2>
+      {redirectURL && (
+        <p className="text-sm text-[#B6BDC9] mb-4">
+          Registra esta redirect URI en tu proveedor: <code className="text-cyan-200">{redirectURL}</code>
+        </p>
+      )}
+      <form onSubmit={handleSubmit} className="flex flex-col gap-3">
+        {error && <div className="text-red-400">{error}</div>}
+        {message && <div className="text-green-400">{message}</div>}
+        <label className="flex items-center gap-3">
+          <input type="checkbox" checked={config.enabled} onChange={set("enabled")} />
+          Habilitar SSO
+        </label>
+        <input className={inputClass} placeholder="Issuer (https://login.ejemplo.com)" value={config.issuer} onChange={set("issuer")} />
+        <input className={inputClass} placeholder="Client ID" value={config.client_id} onChange={set("client_id")} />
+        <input
+          className={inputClass}
+          type="password"
+          placeholder={hasSecret ? "Client secret (vacío: conservar el actual)" : "Client secret"}
+          value={config.client_secret}
+          autoComplete="off"
+          onChange={set("client_secret")}
+        />
+        <input
+          className={inputClass}
+          placeholder="Dominios permitidos (separados por coma; vacío: todos)"
+          value={config.allowed_domains}
+          onChange={set("allowed_domains")}
+        />
+        <input className={inputClass} placeholder='Claim de roles (p. ej. "groups")' value={config.role_claim} onChange={set("role_claim")} />
+        <textarea
+          className={inputClass}
+          rows={3}
+          placeholder='Mapeo de roles, p. ej. {"ops": "operator", "it-admins": "admin"}'
+          value={config.role_mapping}
+          onChange={set("role_mapping")}
+        />
+        <select className={inputClass} value={config.default_role} onChange={set("default_role")}>
+          <option value="">Sin rol por defecto (rechazar)</option>
+          <option value="viewer">viewer</option>
+          <option value="operator">operator</option>
+          <option value="admin">admin</option>
+        </select>
+        <label className="flex items-center gap-3">
+          <input type="checkbox" checked={config.enforce_sso} onChange={set("enforce_sso")} />
+          Exigir SSO (desactiva el login con contraseña)
+        </label>
+        <button
+          type="submit"
+          disabled={loading}
+          className="px-4 py-2 rounded bg-flowforge-accent text-flowforge-dark font-bold disabled:opacity-50"
+        >
+          {loading ? "Guardando..." : "Guardar SSO"}
+        </button>
+      </form>
+    </section>
+  );
+}
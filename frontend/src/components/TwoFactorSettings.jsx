@@ -0,0 +1,200 @@
+import React, { useEffect, useState } from "react";
+import {
+  fetchMFAStatus,
+  startMFAEnrollment,
+  verifyMFAEnrollment,
+  regenerateRecoveryCodes,
+  disableMFA,
+  fetchSecurityPolicy,
+  updateSecurityPolicy,
+} from "../api";
+import { isAdminToken } from "../context/AuthContext";
+
+const inputClass =
+  "bg-flowforge-panel text-white border border-flowforge-border rounded-lg px-3 py-2";
+const buttonClass =
+  "px-4 py-2 rounded bg-flowforge-accent text-flowforge-dark font-bold disabled:opacity-50";
+
+export default function TwoFactorSettings({ token }) {
+  const [status, setStatus] = useState(null);
+  const [policy, setPolicy] = useState(null);
+  const [enrollment, setEnrollment] = useState(null);
+  const [recoveryCodes, setRecoveryCodes] = useState(null);
+  const [code, setCode] = useState("");
+  const [password, setPassword] = useState("");
+  const [message, setMessage] = useState("");
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+  const isAdmin = isAdminToken(token);
+
+  const load = () => {
+    fetchMFAStatus(token).then(setStatus).catch((err) => setError(err.message));
+    if (isAdmin) fetchSecurityPolicy(token).then(setPolicy).catch(() => setPolicy(null));
+  };
+
+  useEffect(() => {
+    load();
+    // eslint-disable-next-line
+  }, [token]);
+
+  const run = async (action, onDone) => {
+    setError("");
+    setMessage("");
+    setLoading(true);
+    try {
+      onDone(await action());
+      setCode("");
+      setPassword("");
+    } catch (err) {
+      setError(err.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleStart = () => run(() => startMFAEnrollment(token), setEnrollment);
+
+  const handleVerify = (e) => {
+    e.preventDefault();
+    run(
+      () => verifyMFAEnrollment(code, token),
+      (resp) => {
+        setEnrollment(null);
+        setRecoveryCodes(resp.recovery_codes);
+        setMessage("Autenticación de dos factores activada.");
+        load();
+      }
+    );
+  };
+
+  const handleRegenerate = (e) => {
+    e.preventDefault();
+    run(
+      () => regenerateRecoveryCodes(code, token),
+      (resp) => {
+        setRecoveryCodes(resp.recovery_codes);
+        setMessage("Se generaron códigos nuevos; los anteriores ya no sirven.");
+        load();
+      }
+    );
+  };
+
+  const handleDisable = (e) => {
+    e.preventDefault();
+    run(
+      () => disableMFA(password, code, token),
+      () => {
+        setRecoveryCodes(null);
+        setMessage("Autenticación de dos factores desactivada.");
+        load();
+      }
+    );
+  };
+
+  const handlePolicy = (required) =>
+    run(() => updateSecurityPolicy({ require_mfa_for_admins: required }, token), setPolicy);
+
+  return (
+    <section className="mt-10 flex flex-col gap-4">
+      <h2 className="text-lg font-semibold">Verificación en dos pasos</h2>
+      {error && <div className="text-red-400">{error}</div>}
+      {message && <div className="text-green-400">{message}</div>}
+
+      {recoveryCodes && (
+        <div className="flex flex-col gap-2">
+          <p className="text-[#B6BDC9]">
+            Guarda estos códigos de recuperación; se muestran una sola vez y cada uno sirve una vez.
+          </p>
+          <ul className="grid grid-cols-2 gap-2 font-mono">
+            {recoveryCodes.map((c) => (
+              <li key={c} className="bg-flowforge-panel rounded px-3 py-1 text-center">{c}</li>
+            ))}
+          </ul>
+        </div>
+      )}
+
+      {status && !status.enabled && !enrollment && (
+        <div className="flex flex-col gap-2">
+          <p className="text-[#B6BDC9]">
+            {status.required
+              ? "Tu empresa exige 2FA para tu rol; se te pedirá activarlo al iniciar sesión."
+              : "Protege tu cuenta con un código de tu app de autenticación además de la contraseña."}
+          </p>
+          <button type="button" onClick={handleStart} disabled={loading} className={buttonClass}>
+            Activar 2FA
+          </button>
+        </div>
+      )}
+
+      {enrollment && (
+        <form onSubmit={handleVerify} className="flex flex-col gap-3 items-start">
+          <p className="text-[#B6BDC9]">Escanea el código con tu app o ingresa la clave manualmente.</p>
+          <img src={enrollment.qr_code} alt="Código QR de 2FA" className="w-48 h-48 bg-white rounded-lg p-2" />
+          <code className="break-all text-sm text-cyan-200">{enrollment.secret}</code>
+          <input
+            className={inputClass}
+            placeholder="Código de 6 dígitos"
+            value={code}
+            inputMode="numeric"
+            autoComplete="one-time-code"
+            onChange={(e) => setCode(e.target.value)}
+            required
+          />
+          <button type="submit" disabled={loading} className={buttonClass}>
+            Verificar y activar
+          </button>
+        </form>
+      )}
+
+      {status && status.enabled && (
+        <div className="flex flex-col gap-3">
+          <p className="text-[#B6BDC9]">
+            2FA activo. Códigos de recuperación disponibles: {status.recovery_codes_remaining}.
+          </p>
+          <form onSubmit={handleRegenerate} className="flex gap-3">
+            <input
+              className={inputClass}
+              placeholder="Código de la app"
+              value={code}
+              inputMode="numeric"
+              autoComplete="one-time-code"
+              onChange={(e) => setCode(e.target.value)}
+              required
+            />
+            <button type="submit" disabled={loading} className={buttonClass}>
+              Generar códigos nuevos
+            </button>
+          </form>
+          {!status.required && (
+            <form onSubmit={handleDisable} className="flex flex-col gap-3">
+              <input
+                className={inputClass}
+                type="password"
+                placeholder="Contraseña"
+                value={password}
+                autoComplete="current-password"
+                onChange={(e) => setPassword(e.target.value)}
+                required
+              />
+              <button type="submit" disabled={loading || !code} className="px-4 py-2 rounded border border-red-400 text-red-400 font-bold disabled:opacity-50">
+                Desactivar 2FA
+              </button>
+            </form>
+          )}
+        </div>
+      )}
+
+      {isAdmin && policy && (
+        <label className="flex items-center gap-3 mt-4">
+          <input
+            type="checkbox"
+            checked={policy.require_mfa_for_admins}
+            disabled={loading}
+            onChange={(e) => handlePolicy(e.target.checked)}
+          />
+          Exigir 2FA a los administradores de la empresa
+        </label>
+      )}
+    </section>
+  );
+}
@@ -1,6 +1,31 @@
 // src/context/AuthContext.jsx
 
 import React, { createContext, useContext, useState, useEffect } from "react";
+import { refreshSession, logoutSession } from "../api";
+
+// Se renueva el access token un minuto antes de que venza
+const REFRESH_MARGIN_MS = 60 * 1000;
+
+// Milisegundos hasta el vencimiento del JWT (0 si no se puede leer)
+function msUntilExpiry(token) {
+  try {
+    const payload = JSON.parse(atob(token.split(".")[1]));
+    return Math.max(0, payload.exp * 1000 - Date.now());
+  } catch {
+    return 0;
+  }
+}
+
+// isAdminToken indica si el rol del token es admin o superior. Solo sirve para
+// mostrar u ocultar opciones; el backend valida los permisos.
+export function isAdminToken(token) {
+  try {
+    const { role } = JSON.parse(atob(token.split(".")[1]));
+    return role === "admin" || role === "super-admin";
+  } catch {
+    return false;
+  }
+}
 
 // Creamos el contexto de autenticación
 export const AuthContext = createContext(); // <--- AHORA exporta explícitamente
@@ -9,6 +34,7 @@ export const AuthContext = createContext(); // <--- AHORA exporta explícitament
 export function AuthProvider({ children }) {
   // Estado inicial seguro: toma de localStorage si existe (permite persistencia de sesión)
   const [token, setToken] = useState(() => localStorage.getItem("token") || null);
+  const [refreshToken, setRefreshToken] = useState(() => localStorage.getItem("refreshToken") || null);
   const [company, setCompany] = useState(() => localStorage.getItem("company") || "");
   const [user, setUser] = useState(() => localStorage.getItem("user") || "");
 
@@ -17,31 +43,56 @@ export function AuthProvider({ children }) {
     if (token) localStorage.setItem("token", token);
     else localStorage.removeItem("token");
 
+    if (refreshToken) localStorage.setItem("refreshToken", refreshToken);
+    else localStorage.removeItem("refreshToken");
+
     if (company) localStorage.setItem("company", company);
     else localStorage.removeItem("company");
 
     if (user) localStorage.setItem("user", user);
     else localStorage.removeItem("user");
-  }, [token, company, user]);
+  }, [token, refreshToken, company, user]);
+
+  // Renueva el access token antes de que venza; si el refresh falla (sesión
+  // revocada o vencida) se cierra la sesión local
+  useEffect(() => {
+    if (!refreshToken) return;
+    const delay = token ? Math.max(0, msUntilExpiry(token) - REFRESH_MARGIN_MS) : 0;
+    const timer = setTimeout(() => {
+      refreshSession(refreshToken)
+        .then((resp) => {
+          setToken(resp.token);
+          setRefreshToken(resp.refresh_token);
+        })
+        .catch(() => {
+          setToken(null);
+          setRefreshToken(null);
+        });
+    }, delay);
+    return () => clearTimeout(timer);
+  }, [token, refreshToken]);
 
   // Función de login (recibe objeto o valores individuales)
-  const login = ({ token, company, user }) => {
+  const login = ({ token, refreshToken, company, user }) => {
     setToken(token);
+    setRefreshToken(refreshToken || null);
     setCompany(company || "");
     setUser(user || "");
     // El useEffect anterior se encarga de sincronizar el localStorage
   };
 
-  // Función de logout
+  // Función de logout: revoca la sesión en el servidor y limpia la local
   const logout = () => {
+    if (token) logoutSession(token).catch(() => {});
     setToken(null);
+    setRefreshToken(null);
     setCompany("");
     setUser("");
     // El useEffect limpia localStorage automáticamente
   };
 
   // Valor que entrega el contexto a los hijos
-  const value = { token, company, user, login, logout, setToken };
+  const value = { token, refreshToken, company, user, login, logout, setToken };
 
   return (
     <AuthContext.Provider value={value}>
@@ -0,0 +1,80 @@
+import React, { useContext, useState } from "react";
+import { acceptInvitation } from "../api";
+import { AuthContext } from "../context/AuthContext";
+import Logo from "../assets/INSTRUMINING-logo.svg";
+
+const inputClass =
+  "bg-[#1F2937] text-white rounded-2xl px-6 py-5 placeholder-gray-400 focus:outline-none focus:ring-2 focus:ring-[#72B1FF] transition text-lg";
+
+// Página del enlace de invitación: el invitado elige su contraseña y entra directo
+export default function AcceptInvite() {
+  const { login } = useContext(AuthContext);
+  const inviteToken = new URLSearchParams(window.location.search).get("token");
+  const [password, setPassword] = useState("");
+  const [confirm, setConfirm] = useState("");
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setError("");
+    if (password !== confirm) {
+      setError("Las contraseñas no coinciden");
+      return;
+    }
+    setLoading(true);
+    try {
+      const resp = await acceptInvitation(inviteToken, password);
+      if (resp.token) {
+        login({ token: resp.token, refreshToken: resp.refresh_token });
+      }
+      window.location.replace("/");
+    } catch (err) {
+      setError(err.message);
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="min-h-screen flex flex-col items-center justify-center bg-[#0A0D12] px-6 text-white">
+      <div className="mb-10 flex justify-center w-full max-w-sm">
+        <img src={Logo} alt="INSTRUMINING Logo" className="h-20 object-contain" />
+      </div>
+      <h1 className="text-3xl font-bold mb-10">Activa tu cuenta</h1>
+
+      <form onSubmit={handleSubmit} className="w-full max-w-sm flex flex-col gap-6">
+        {!inviteToken && <div className="text-red-600 font-semibold text-center">El enlace de invitación está incompleto</div>}
+        {error && (
+          <div role="alert" className="text-red-600 font-semibold text-center mb-2">
+            {error}
+          </div>
+        )}
+        <input
+          className={inputClass}
+          type="password"
+          placeholder="Contraseña (mín. 10 caracteres, letras y números)"
+          value={password}
+          autoComplete="new-password"
+          onChange={(e) => setPassword(e.target.value)}
+          required
+        />
+        <input
+          className={inputClass}
+          type="password"
+          placeholder="Repite la contraseña"
+          value={confirm}
+          autoComplete="new-password"
+          onChange={(e) => setConfirm(e.target.value)}
+          required
+        />
+        <button
+          className="w-full bg-[#A9E7FF] text-black font-extrabold rounded-2xl px-6 py-5 mt-4 hover:bg-[#8ed1ff] transition disabled:opacity-50 disabled:cursor-not-allowed text-lg"
+          type="submit"
+          disabled={loading || !inviteToken}
+        >
+          {loading ? "Activando..." : "Activar cuenta"}
+        </button>
+      </form>
+    </div>
+  );
+}
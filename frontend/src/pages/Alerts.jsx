@@ -92,6 +92,7 @@ export default function Alerts({ token }) {
       await createZoneAlert(
         {
           zone_id: String(zoneId),
+          camera_id: Number(selectedCamera),
           upper_thresh: Number(cfg.upper),
           lower_thresh: Number(cfg.lower),
           recipient: correo,
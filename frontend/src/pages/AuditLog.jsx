@@ -0,0 +1,117 @@
+// src/pages/AuditLog.jsx
+import React, { useContext, useEffect, useState } from "react";
+import { fetchAuditLog, exportAuditLog } from "../api";
+import { AuthContext } from "../context/AuthContext";
+
+const inputClass =
+  "bg-flowforge-panel text-white border border-flowforge-border rounded-lg px-3 py-2";
+const buttonClass =
+  "px-4 py-2 rounded bg-flowforge-accent text-flowforge-dark font-bold disabled:opacity-50";
+
+const emptyFilters = { action: "", target_type: "", desde: "", hasta: "" };
+
+// Las fechas del formulario (datetime-local) se envían en RFC3339
+function toQuery(filters) {
+  const q = { ...filters };
+  ["desde", "hasta"].forEach((k) => {
+    if (q[k]) q[k] = new Date(q[k]).toISOString();
+  });
+  return q;
+}
+
+export default function AuditLog() {
+  const { token } = useContext(AuthContext);
+  const [filters, setFilters] = useState(emptyFilters);
+  const [entries, setEntries] = useState([]);
+  const [cursor, setCursor] = useState("");
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+
+  const load = async (next) => {
+    setError("");
+    setLoading(true);
+    try {
+      const page = await fetchAuditLog(toQuery(filters), next, token);
+      setEntries((prev) => (next ? [...prev, ...page.items] : page.items));
+      setCursor(page.next_cursor || "");
+    } catch (err) {
+      setError(err.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  useEffect(() => {
+    load("");
+    // eslint-disable-next-line
+  }, [token]);
+
+  const handleSearch = (e) => {
+    e.preventDefault();
+    load("");
+  };
+
+  const handleExport = async () => {
+    setError("");
+    try {
+      const blob = await exportAuditLog(toQuery(filters), token);
+      const url = URL.createObjectURL(blob);
+      const a = document.createElement("a");
+      a.href = url;
+      a.download = "auditoria.csv";
+      a.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      setError(err.message);
+    }
+  };
+
+  const setFilter = (key) => (e) => setFilters({ ...filters, [key]: e.target.value });
+
+  return (
+    <div className="p-8 flex flex-col gap-6">
+      <h1 className="text-2xl font-bold">Registro de auditoría</h1>
+      <form onSubmit={handleSearch} className="flex flex-wrap gap-3 items-end">
+        <input className={inputClass} placeholder="Acción (ej: user.updated)" value={filters.action} onChange={setFilter("action")} />
+        <input className={inputClass} placeholder="Tipo (ej: zone_alert)" value={filters.target_type} onChange={setFilter("target_type")} />
+        <input className={inputClass} type="datetime-local" value={filters.desde} onChange={setFilter("desde")} />
+        <input className={inputClass} type="datetime-local" value={filters.hasta} onChange={setFilter("hasta")} />
+        <button type="submit" disabled={loading} className={buttonClass}>Buscar</button>
+        <button type="button" onClick={handleExport} className="px-4 py-2 rounded border border-flowforge-border font-bold">
+          Exportar CSV
+        </button>
+      </form>
+      {error && <div className="text-red-400">{error}</div>}
+      <table className="w-full text-sm">
+        <thead className="text-left text-[#B6BDC9]">
+          <tr>
+            <th className="py-2">Fecha</th>
+            <th>Usuario</th>
+            <th>Acción</th>
+            <th>Objeto</th>
+            <th>IP</th>
+            <th>Cambios</th>
+          </tr>
+        </thead>
+        <tbody>
+          {entries.map((e) => (
+            <tr key={e.id} className="border-t border-flowforge-border align-top">
+              <td className="py-2 whitespace-nowrap">{new Date(e.created_at).toLocaleString()}</td>
+              <td>{e.actor_email || "sistema"}</td>
+              <td className="font-mono">{e.action}</td>
+              <td>{e.target_type ? `${e.target_type} ${e.target_id}` : "-"}</td>
+              <td>{e.ip}</td>
+              <td className="font-mono text-xs break-all">{e.changes || e.details}</td>
+            </tr>
+          ))}
+        </tbody>
+      </table>
+      {!loading && entries.length === 0 && <p className="text-[#B6BDC9]">Sin registros para estos filtros.</p>}
+      {cursor && (
+        <button type="button" onClick={() => load(cursor)} disabled={loading} className={buttonClass}>
+          Cargar más
+        </button>
+      )}
+    </div>
+  );
+}
@@ -1,7 +1,7 @@
 // src/pages/DeviceZones.jsx
 
-import React, { useEffect, useState } from "react";
-import { fetchCameraSummary } from "../api";
+import React, { useCallback, useEffect, useState } from "react";
+import { fetchCameraSummary, subscribeCameraStream } from "../api";
 import ZoneCard from "../components/ZoneCard"; // ¡Importación correcta!
 
 function getZoneStatus(lastTime) {
@@ -34,7 +34,7 @@ export default function DeviceZones({ cameraId, token }) {
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState("");
 
-  useEffect(() => {
+  const loadSummary = useCallback(() => {
     if (!cameraId || !token) return;
     setLoading(true);
     setError("");
@@ -52,6 +52,32 @@ export default function DeviceZones({ cameraId, token }) {
       .finally(() => setLoading(false));
   }, [cameraId, token]);
 
+  useEffect(() => {
+    loadSummary();
+  }, [loadSummary]);
+
+  // Actualización en vivo: cada lectura nueva y cada cambio de estado de una zona
+  useEffect(() => {
+    if (!cameraId || !token) return;
+    const upsert = (zoneId, changes) =>
+      setZonas((prev) => {
+        const exists = prev.some((z) => z.zone_id === zoneId);
+        const next = exists
+          ? prev.map((z) => (z.zone_id === zoneId ? { ...z, ...changes } : z))
+          : [...prev, { zone_id: zoneId, ...changes }];
+        return next.sort((a, b) => a.zone_id - b.zone_id);
+      });
+    return subscribeCameraStream(cameraId, token, {
+      zone: (z) => upsert(z.zone_id, z),
+      reading: (r) => {
+        // Igual que en DeviceDetail, se ignoran los valores de error del sensor
+        if (Number(r.Temperature) < 6450)
+          upsert(r.ZoneID, { last_temp: r.Temperature, last_time: r.Timestamp });
+      },
+      reset: loadSummary,
+    });
+  }, [cameraId, token, loadSummary]);
+
   if (loading) {
     return (
       <div className="flex items-center gap-2 text-[#8C92A4] text-sm">
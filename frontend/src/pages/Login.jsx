@@ -1,5 +1,12 @@
 import React, { useEffect, useState } from "react";
-import { loginUser, fetchCompanies } from "../api";
+import {
+  loginUser,
+  loginMFA,
+  startLoginMFAEnrollment,
+  fetchCompanies,
+  fetchSSOStatus,
+  ssoLoginURL,
+} from "../api";
 import Logo from "../assets/INSTRUMINING-logo.svg";
 
 export default function Login({ onLogin }) {
@@ -10,6 +17,12 @@ export default function Login({ onLogin }) {
   const [error, setError] = useState("");
   const [loadingCompanies, setLoadingCompanies] = useState(true);
   const [loading, setLoading] = useState(false);
+  // Segundo factor: desafío del primer paso, secreto a registrar y códigos de recuperación
+  const [challenge, setChallenge] = useState(null);
+  const [enrollment, setEnrollment] = useState(null);
+  const [code, setCode] = useState("");
+  const [recoveryCodes, setRecoveryCodes] = useState(null);
+  const [pendingSession, setPendingSession] = useState(null);
 
   // Trae las empresas solo una vez al montar
   useEffect(() => {
@@ -22,28 +35,151 @@ export default function Login({ onLogin }) {
       .finally(() => setLoadingCompanies(false));
   }, []);
 
+  // Login SSO de la empresa elegida (si lo exige, se ocultan email y contraseña)
+  const [sso, setSSO] = useState({ enabled: false, enforce_sso: false });
+  useEffect(() => {
+    if (!companyId) {
+      setSSO({ enabled: false, enforce_sso: false });
+      return;
+    }
+    fetchSSOStatus(companyId).then(setSSO);
+  }, [companyId]);
+
   // Mejor UX: submit solo si todo está ok
   const canSubmit = companyId && email && password && !loading && !loadingCompanies;
 
+  const finishLogin = (resp) =>
+    // El contexto guarda ambos tokens y renueva el access token antes de que venza
+    onLogin({ token: resp.token, refreshToken: resp.refresh_token, company: companyId, user: email });
+
+  const showError = (err) => {
+    const msg =
+      err?.message?.replace(/^Error:\s*/, "") ||
+      "Error al iniciar sesión. Intenta nuevamente.";
+    setError(msg);
+  };
+
   const handleSubmit = async (e) => {
     e.preventDefault();
     setError("");
     setLoading(true);
     try {
-      const token = await loginUser(email, password, companyId);
-      // Puedes agregar aquí más datos si usas AuthContext, por ejemplo: onLogin({ token, company: companyId, user: email });
-      localStorage.setItem("token", token);
-      onLogin(token); // O pásale el objeto si tu contexto lo requiere
+      const resp = await loginUser(email, password, companyId);
+      if (resp.mfa_required) {
+        setChallenge(resp);
+        if (resp.mfa_enrollment_required) {
+          // La empresa exige 2FA a los admins: hay que activarlo antes de entrar
+          setEnrollment(await startLoginMFAEnrollment(resp.mfa_token));
+        }
+        return;
+      }
+      finishLogin(resp);
     } catch (err) {
-      const msg =
-        err?.message?.replace(/^Error:\s*/, "") ||
-        "Error al iniciar sesión. Intenta nuevamente.";
-      setError(msg);
+      showError(err);
     } finally {
       setLoading(false);
     }
   };
 
+  const handleCode = async (e) => {
+    e.preventDefault();
+    setError("");
+    setLoading(true);
+    try {
+      const resp = await loginMFA(challenge.mfa_token, code);
+      if (resp.recovery_codes) {
+        // Se muestran una sola vez; la sesión se abre cuando el usuario los guardó
+        setRecoveryCodes(resp.recovery_codes);
+        setPendingSession(resp);
+        return;
+      }
+      finishLogin(resp);
+    } catch (err) {
+      showError(err);
+      setCode("");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const restart = () => {
+    setChallenge(null);
+    setEnrollment(null);
+    setCode("");
+    setError("");
+  };
+
+  if (recoveryCodes) {
+    return (
+      <div className="min-h-screen flex flex-col items-center justify-center bg-[#0A0D12] px-6 text-white">
+        <h1 className="text-3xl font-bold mb-4">Códigos de recuperación</h1>
+        <p className="max-w-sm text-center text-[#B6BDC9] mb-6">
+          Guárdalos en un lugar seguro. Cada uno sirve una sola vez si pierdes acceso a tu app de autenticación.
+        </p>
+        <ul className="grid grid-cols-2 gap-3 font-mono text-lg mb-8">
+          {recoveryCodes.map((c) => (
+            <li key={c} className="bg-[#1F2937] rounded-lg px-4 py-2 text-center">{c}</li>
+          ))}
+        </ul>
+        <button
+          className="w-full max-w-sm bg-[#A9E7FF] text-black font-extrabold rounded-2xl px-6 py-5 hover:bg-[#8ed1ff] transition text-lg"
+          onClick={() => finishLogin(pendingSession)}
+        >
+          Ya los guardé, continuar
+        </button>
+      </div>
+    );
+  }
+
+  if (challenge) {
+    return (
+      <div className="min-h-screen flex flex-col items-center justify-center bg-[#0A0D12] px-6 text-white">
+        <div className="mb-10 flex justify-center w-full max-w-sm">
+          <img src={Logo} alt="INSTRUMINING Logo" className="h-20 object-contain" />
+        </div>
+        <h1 className="text-3xl font-bold mb-6">Verificación en dos pasos</h1>
+        {enrollment && (
+          <div className="w-full max-w-sm flex flex-col items-center gap-3 mb-6 text-center">
+            <p className="text-[#B6BDC9]">
+              Tu empresa exige 2FA. Escanea el código con tu app de autenticación o ingresa la clave manualmente.
+            </p>
+            <img src={enrollment.qr_code} alt="Código QR de 2FA" className="w-48 h-48 bg-white rounded-lg p-2" />
+            <code className="break-all text-sm text-cyan-200">{enrollment.secret}</code>
+          </div>
+        )}
+        <form onSubmit={handleCode} className="w-full max-w-sm flex flex-col gap-6" aria-label="Formulario 2FA">
+          {error && (
+            <div role="alert" className="text-red-600 font-semibold text-center mb-2">
+              {error}
+            </div>
+          )}
+          <input
+            className="bg-[#1F2937] text-white rounded-2xl px-6 py-5 placeholder-gray-400 focus:outline-none focus:ring-2 focus:ring-[#72B1FF] transition text-lg tracking-widest text-center"
+            placeholder={enrollment ? "Código de 6 dígitos" : "Código de 6 dígitos o de recuperación"}
+            value={code}
+            autoComplete="one-time-code"
+            inputMode={enrollment ? "numeric" : "text"}
+            onChange={(e) => setCode(e.target.value)}
+            required
+            autoFocus
+            aria-label="Código de verificación"
+          />
+          <button
+            className="w-full bg-[#A9E7FF] text-black font-extrabold rounded-2xl px-6 py-5 hover:bg-[#8ed1ff] transition disabled:opacity-50 disabled:cursor-not-allowed text-lg"
+            type="submit"
+            disabled={!code || loading}
+            aria-busy={loading}
+          >
+            {loading ? "Verificando..." : enrollment ? "Activar y entrar" : "Verificar"}
+          </button>
+          <button type="button" onClick={restart} className="text-center text-sm text-[#72B1FF] hover:underline">
+            Volver
+          </button>
+        </form>
+      </div>
+    );
+  }
+
   return (
     <div className="min-h-screen flex flex-col items-center justify-center bg-[#0A0D12] px-6 text-white">
       <div className="mb-10 flex justify-center w-full max-w-sm">
@@ -79,37 +215,53 @@ export default function Login({ onLogin }) {
           ))}
         </select>
 
-        <input
-          className="bg-[#1F2937] text-white rounded-2xl px-6 py-5 placeholder-gray-400 focus:outline-none focus:ring-2 focus:ring-[#72B1FF] transition text-lg"
-          type="email"
-          placeholder="Email"
-          value={email}
-          autoComplete="username"
-          onChange={(e) => setEmail(e.target.value)}
-          required
-          aria-label="Email"
-        />
-
-        <input
-          className="bg-[#1F2937] text-white rounded-2xl px-6 py-5 placeholder-gray-400 focus:outline-none focus:ring-2 focus:ring-[#72B1FF] transition text-lg"
-          type="password"
-          placeholder="Password"
-          value={password}
-          autoComplete="current-password"
-          onChange={(e) => setPassword(e.target.value)}
-          required
-          aria-label="Password"
-        />
+        {sso.enabled && (
+          <a
+            href={ssoLoginURL(companyId)}
+            className="w-full text-center bg-[#1F2937] text-white font-bold rounded-2xl px-6 py-5 hover:bg-[#2b3646] transition text-lg"
+          >
+            Iniciar sesión con SSO
+          </a>
+        )}
 
-        <button
-          className="w-full bg-[#A9E7FF] text-black font-extrabold rounded-2xl px-6 py-5 mt-4 hover:bg-[#8ed1ff] transition disabled:opacity-50 disabled:cursor-not-allowed text-lg"
-          type="submit"
-          disabled={!canSubmit}
-          aria-busy={loading}
-          aria-disabled={!canSubmit}
-        >
-          {loading ? "Signing in..." : "Iniciar Sesión"}
-        </button>
+        {!sso.enforce_sso && (
+          <>
+            <input
+              className="bg-[#1F2937] text-white rounded-2xl px-6 py-5 placeholder-gray-400 focus:outline-none focus:ring-2 focus:ring-[#72B1FF] transition text-lg"
+              type="email"
+              placeholder="Email"
+              value={email}
+              autoComplete="username"
+              onChange={(e) => setEmail(e.target.value)}
+              required
+              aria-label="Email"
+            />
+
+            <input
+              className="bg-[#1F2937] text-white rounded-2xl px-6 py-5 placeholder-gray-400 focus:outline-none focus:ring-2 focus:ring-[#72B1FF] transition text-lg"
+              type="password"
+              placeholder="Password"
+              value={password}
+              autoComplete="current-password"
+              onChange={(e) => setPassword(e.target.value)}
+              required
+              aria-label="Password"
+            />
+
+            <button
+              className="w-full bg-[#A9E7FF] text-black font-extrabold rounded-2xl px-6 py-5 mt-4 hover:bg-[#8ed1ff] transition disabled:opacity-50 disabled:cursor-not-allowed text-lg"
+              type="submit"
+              disabled={!canSubmit}
+              aria-busy={loading}
+              aria-disabled={!canSubmit}
+            >
+              {loading ? "Signing in..." : "Iniciar Sesión"}
+            </button>
+            <a href="/reset-password" className="text-center text-sm text-[#72B1FF] hover:underline">
+              ¿Olvidaste tu contraseña?
+            </a>
+          </>
+        )}
       </form>
     </div>
   );
@@ -0,0 +1,100 @@
+import React, { useState } from "react";
+import { requestPasswordReset, resetPassword } from "../api";
+import Logo from "../assets/INSTRUMINING-logo.svg";
+
+const inputClass =
+  "bg-[#1F2937] text-white rounded-2xl px-6 py-5 placeholder-gray-400 focus:outline-none focus:ring-2 focus:ring-[#72B1FF] transition text-lg";
+const buttonClass =
+  "w-full bg-[#A9E7FF] text-black font-extrabold rounded-2xl px-6 py-5 mt-4 hover:bg-[#8ed1ff] transition disabled:opacity-50 disabled:cursor-not-allowed text-lg";
+
+// Sin token en la URL pide el enlace por email; con ?token=... fija la nueva contraseña
+export default function ResetPassword() {
+  const resetToken = new URLSearchParams(window.location.search).get("token");
+  const [email, setEmail] = useState("");
+  const [password, setPassword] = useState("");
+  const [confirm, setConfirm] = useState("");
+  const [message, setMessage] = useState("");
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setError("");
+    setMessage("");
+    if (resetToken && password !== confirm) {
+      setError("Las contraseñas no coinciden");
+      return;
+    }
+    setLoading(true);
+    try {
+      const resp = resetToken
+        ? await resetPassword(resetToken, password)
+        : await requestPasswordReset(email);
+      setMessage(resp.message);
+    } catch (err) {
+      setError(err.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="min-h-screen flex flex-col items-center justify-center bg-[#0A0D12] px-6 text-white">
+      <div className="mb-10 flex justify-center w-full max-w-sm">
+        <img src={Logo} alt="INSTRUMINING Logo" className="h-20 object-contain" />
+      </div>
+      <h1 className="text-3xl font-bold mb-10">
+        {resetToken ? "Nueva contraseña" : "Restablecer contraseña"}
+      </h1>
+
+      <form onSubmit={handleSubmit} className="w-full max-w-sm flex flex-col gap-6">
+        {error && (
+          <div role="alert" className="text-red-600 font-semibold text-center mb-2">
+            {error}
+          </div>
+        )}
+        {message && <div className="text-green-400 font-semibold text-center mb-2">{message}</div>}
+
+        {resetToken ? (
+          <>
+            <input
+              className={inputClass}
+              type="password"
+              placeholder="Nueva contraseña (mín. 10 caracteres, letras y números)"
+              value={password}
+              autoComplete="new-password"
+              onChange={(e) => setPassword(e.target.value)}
+              required
+            />
+            <input
+              className={inputClass}
+              type="password"
+              placeholder="Repite la contraseña"
+              value={confirm}
+              autoComplete="new-password"
+              onChange={(e) => setConfirm(e.target.value)}
+              required
+            />
+          </>
+        ) : (
+          <input
+            className={inputClass}
+            type="email"
+            placeholder="Email"
+            value={email}
+            autoComplete="username"
+            onChange={(e) => setEmail(e.target.value)}
+            required
+          />
+        )}
+
+        <button className={buttonClass} type="submit" disabled={loading || !!message}>
+          {loading ? "Enviando..." : resetToken ? "Guardar contraseña" : "Enviar enlace"}
+        </button>
+        <a href="/" className="text-center text-sm text-[#72B1FF] hover:underline">
+          Volver a iniciar sesión
+        </a>
+      </form>
+    </div>
+  );
+}
@@ -0,0 +1,47 @@
+import React, { useContext, useEffect, useState } from "react";
+import { AuthContext } from "../context/AuthContext";
+import Logo from "../assets/INSTRUMINING-logo.svg";
+
+// Vuelta del login SSO: el API deja los tokens (o el error) en el fragmento de la URL
+export default function SSOCallback() {
+  const { login } = useContext(AuthContext);
+  const [error, setError] = useState("");
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    // El fragmento no debe quedar en el historial del navegador
+    window.history.replaceState(null, "", window.location.pathname);
+    if (params.get("token")) {
+      login({
+        token: params.get("token"),
+        refreshToken: params.get("refresh_token"),
+        company: params.get("company_id"),
+        user: params.get("email"),
+      });
+      window.location.replace("/");
+      return;
+    }
+    setError(params.get("error") || "No se pudo iniciar sesión con SSO");
+    // eslint-disable-next-line
+  }, []);
+
+  return (
+    <div className="min-h-screen flex flex-col items-center justify-center bg-[#0A0D12] px-6 text-white">
+      <div className="mb-10 flex justify-center w-full max-w-sm">
+        <img src={Logo} alt="INSTRUMINING Logo" className="h-20 object-contain" />
+      </div>
+      {error ? (
+        <>
+          <div role="alert" className="text-red-600 font-semibold text-center mb-6">
+            {error}
+          </div>
+          <a href="/" className="text-[#72B1FF] hover:underline">
+            Volver al inicio de sesión
+          </a>
+        </>
+      ) : (
+        <p className="text-[#B6BDC9]">Iniciando sesión...</p>
+      )}
+    </div>
+  );
+}
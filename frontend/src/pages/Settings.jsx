@@ -0,0 +1,89 @@
+import React, { useContext, useState } from "react";
+import { changePassword } from "../api";
+import { AuthContext, isAdminToken } from "../context/AuthContext";
+import TwoFactorSettings from "../components/TwoFactorSettings";
+import SSOSettings from "../components/SSOSettings";
+
+const inputClass =
+  "bg-flowforge-panel text-white border border-flowforge-border rounded-lg px-3 py-2";
+
+export default function Settings() {
+  const { token, company, user, login } = useContext(AuthContext);
+  const [current, setCurrent] = useState("");
+  const [password, setPassword] = useState("");
+  const [confirm, setConfirm] = useState("");
+  const [message, setMessage] = useState("");
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setError("");
+    setMessage("");
+    if (password !== confirm) {
+      setError("Las contraseñas no coinciden");
+      return;
+    }
+    setLoading(true);
+    try {
+      const resp = await changePassword(current, password, token);
+      // Las demás sesiones quedan cerradas; esta continúa con los tokens nuevos
+      login({ token: resp.token, refreshToken: resp.refresh_token, company, user });
+      setMessage("Contraseña actualizada. Se cerraron tus otras sesiones.");
+      setCurrent("");
+      setPassword("");
+      setConfirm("");
+    } catch (err) {
+      setError(err.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <main className="max-w-xl mx-auto py-10 px-4">
+      <h1 className="text-2xl font-bold mb-6">Configuración</h1>
+      <h2 className="text-lg font-semibold mb-4">Cambiar contraseña</h2>
+      <form onSubmit={handleSubmit} className="flex flex-col gap-4">
+        {error && <div className="text-red-400">{error}</div>}
+        {message && <div className="text-green-400">{message}</div>}
+        <input
+          className={inputClass}
+          type="password"
+          placeholder="Contraseña actual"
+          value={current}
+          autoComplete="current-password"
+          onChange={(e) => setCurrent(e.target.value)}
+          required
+        />
+        <input
+          className={inputClass}
+          type="password"
+          placeholder="Nueva contraseña (mín. 10 caracteres, letras y números)"
+          value={password}
+          autoComplete="new-password"
+          onChange={(e) => setPassword(e.target.value)}
+          required
+        />
+        <input
+          className={inputClass}
+          type="password"
+          placeholder="Repite la nueva contraseña"
+          value={confirm}
+          autoComplete="new-password"
+          onChange={(e) => setConfirm(e.target.value)}
+          required
+        />
+        <button
+          type="submit"
+          disabled={loading}
+          className="px-4 py-2 rounded bg-flowforge-accent text-flowforge-dark font-bold disabled:opacity-50"
+        >
+          {loading ? "Guardando..." : "Cambiar contraseña"}
+        </button>
+      </form>
+      <TwoFactorSettings token={token} />
+      {isAdminToken(token) && <SSOSettings token={token} />}
+    </main>
+  );
+}
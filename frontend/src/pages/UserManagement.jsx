@@ -1,16 +1,21 @@
 import React, { useEffect, useState } from "react";
-import { fetchUsers, createUser } from "../api";
+import {
+  fetchUsers,
+  fetchInvitations,
+  createInvitation,
+  resendInvitation,
+  revokeInvitation,
+} from "../api";
 
 export default function UserManagement({ token }) {
   const [users, setUsers] = useState([]);
+  const [invitations, setInvitations] = useState([]);
   const [loading, setLoading] = useState(true);
   const [showAdd, setShowAdd] = useState(false);
   const [newUser, setNewUser] = useState({
     name: "",
     email: "",
-    role: "Viewer",
-    status: "Active",
-    password: "",
+    role: "viewer",
   });
   const [error, setError] = useState("");
   const [success, setSuccess] = useState("");
@@ -25,27 +30,37 @@ export default function UserManagement({ token }) {
     fetchUsers(token)
       .then(setUsers)
       .finally(() => setLoading(false));
+    // Solo las invitaciones sin aceptar; las aceptadas ya aparecen como usuarios
+    fetchInvitations(token)
+      .then((list) => setInvitations(list.filter((i) => i.state === "pending" || i.state === "expired")))
+      .catch(() => setInvitations([]));
   }
 
-  // Toggle para status (switch)
-  const handleToggleStatus = () =>
-    setNewUser({ ...newUser, status: newUser.status === "Active" ? "Inactive" : "Active" });
+  const handleInvitationAction = async (action, invitation, message) => {
+    setError("");
+    setSuccess("");
+    try {
+      await action(invitation.id, token);
+      setSuccess(message);
+      loadUsers();
+    } catch (err) {
+      setError(err.message);
+    }
+  };
 
   const handleAddUser = async (e) => {
     e.preventDefault();
     setError("");
     setSuccess("");
     try {
-      await createUser(newUser, token);
+      await createInvitation(newUser, token);
       setShowAdd(false);
       setNewUser({
         name: "",
         email: "",
-        role: "Viewer",
-        status: "Active",
-        password: "",
+        role: "viewer",
       });
-      setSuccess("Usuario creado correctamente.");
+      setSuccess(`Invitación enviada a ${newUser.email}.`);
       loadUsers();
     } catch (err) {
       setError(err.message);
@@ -66,7 +81,7 @@ export default function UserManagement({ token }) {
           className="bg-[#C1E7FF] hover:bg-[#A2d8f9] text-[#1A202C] font-bold rounded-lg px-5 py-2 text-base transition shadow"
           onClick={() => setShowAdd(true)}
         >
-          Add User
+          Invite User
         </button>
       </div>
 
@@ -76,7 +91,10 @@ export default function UserManagement({ token }) {
           className="max-w-2xl mx-auto bg-[#181B20] rounded-xl shadow-xl px-7 py-6 mb-8 border border-[#26272d]"
           onSubmit={handleAddUser}
         >
-          <h2 className="text-xl font-bold mb-6 text-white tracking-tight">Add User</h2>
+          <h2 className="text-xl font-bold mb-6 text-white tracking-tight">Invite User</h2>
+          <p className="text-[#6A7382] text-sm -mt-4 mb-6">
+            The user will receive an email to set their own password.
+          </p>
           <div className="flex flex-col gap-5">
             <div>
               <label className="block text-[#B6BDC9] text-base font-semibold mb-1">Name</label>
@@ -99,17 +117,6 @@ export default function UserManagement({ token }) {
                 required
               />
             </div>
-            <div>
-              <label className="block text-[#B6BDC9] text-base font-semibold mb-1">Password</label>
-              <input
-                className="w-full bg-[#23272F] text-white rounded-lg px-4 py-2 border-none focus:ring-2 focus:ring-cyan-400 text-base placeholder-[#495066]"
-                placeholder="Set a secure password"
-                type="password"
-                value={newUser.password}
-                onChange={(e) => setNewUser({ ...newUser, password: e.target.value })}
-                required
-              />
-            </div>
             <div>
               <label className="block text-[#B6BDC9] text-base font-semibold mb-1">Role</label>
               <select
@@ -118,40 +125,14 @@ export default function UserManagement({ token }) {
                 onChange={(e) => setNewUser({ ...newUser, role: e.target.value })}
                 required
               >
-                <option value="Administrator">Administrator</option>
-                <option value="Manager">Manager</option>
-                <option value="Operator">Operator</option>
-                <option value="Viewer">Viewer</option>
+                <option value="admin">Administrator</option>
+                <option value="operator">Operator</option>
+                <option value="viewer">Viewer</option>
               </select>
             </div>
-            <div className="flex items-center mt-1">
-              <div>
-                <span className="block text-[#B6BDC9] text-base font-semibold mb-1">Account Status</span>
-                <span className="text-[#6A7382] text-xs">Activate or deactivate the user account.</span>
-              </div>
-              {/* Toggle visual */}
-              <div className="ml-8 flex items-center">
-                <span className="mr-2 font-medium text-[#B6BDC9] text-sm">
-                  {newUser.status === "Active" ? "Active" : "Inactive"}
-                </span>
-                <button
-                  type="button"
-                  onClick={handleToggleStatus}
-                  className={`relative w-9 h-5 rounded-full transition-colors focus:outline-none ${
-                    newUser.status === "Active" ? "bg-cyan-400" : "bg-[#33363C]"
-                  }`}
-                >
-                  <span
-                    className={`absolute left-1 top-0.5 w-4 h-4 bg-white rounded-full shadow transition-transform ${
-                      newUser.status === "Active" ? "translate-x-4" : ""
-                    }`}
-                  ></span>
-                </button>
-              </div>
-            </div>
             <div className="flex gap-4 mt-6">
               <button className="bg-cyan-400 text-black font-bold rounded-lg px-5 py-2 text-base" type="submit">
-                Create User
+                Send Invitation
               </button>
               <button
                 className="bg-[#181B20] border border-[#2B3139] text-[#B6BDC9] rounded-lg px-5 py-2 text-base"
@@ -161,11 +142,47 @@ export default function UserManagement({ token }) {
                 Cancel
               </button>
             </div>
-            {error && <div className="text-red-400 mt-2 text-base">{error}</div>}
-            {success && <div className="text-green-400 mt-2 text-base">{success}</div>}
           </div>
         </form>
       )}
+      {error && <div className="text-red-400 mb-4 text-base">{error}</div>}
+      {success && <div className="text-green-400 mb-4 text-base">{success}</div>}
+
+      {/* Invitaciones pendientes */}
+      {invitations.length > 0 && (
+        <div className="bg-[#16181C] rounded-xl overflow-hidden shadow text-base mb-8">
+          <h2 className="px-5 pt-4 pb-2 text-lg font-bold text-white">Pending Invitations</h2>
+          <table className="w-full">
+            <tbody className="text-white font-medium">
+              {invitations.map((inv) => (
+                <tr key={inv.id} className="border-b border-[#23242d]">
+                  <td className="py-3 px-5 text-cyan-200">{inv.email}</td>
+                  <td className="py-3 px-5">{inv.role}</td>
+                  <td className="py-3 px-5 text-[#8C92A4]">
+                    {inv.state === "expired"
+                      ? "Expired"
+                      : `Expires ${new Date(inv.expires_at).toLocaleString()}`}
+                  </td>
+                  <td className="py-3 px-5">
+                    <button
+                      className="text-[#B6BDC9] hover:text-cyan-200 font-bold mr-5 text-sm"
+                      onClick={() => handleInvitationAction(resendInvitation, inv, `Invitación reenviada a ${inv.email}.`)}
+                    >
+                      Resend
+                    </button>
+                    <button
+                      className="text-[#B6BDC9] hover:text-red-400 font-bold text-sm"
+                      onClick={() => handleInvitationAction(revokeInvitation, inv, "Invitación revocada.")}
+                    >
+                      Revoke
+                    </button>
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </div>
+      )}
 
       {/* Tabla de usuarios */}
       <div className="bg-[#16181C] rounded-xl overflow-hidden shadow text-base">
@@ -0,0 +1,306 @@
+// aggregate/aggregate.go
+
+// Package aggregate calcula estadísticas de temperatura por intervalos de tiempo
+// (buckets) para los gráficos, de modo que el cliente no reciba todas las lecturas.
+// Los buckets se alinean a la época Unix en UTC, así un mismo instante cae siempre
+// en el mismo bucket sin importar el rango consultado.
+package aggregate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Func es una función de agregación: avg, min, max o un percentil pNN (p50, p95...).
+// La cantidad de lecturas de cada bucket siempre se incluye en Point.Count.
+type Func string
+
+const (
+	Avg Func = "avg"
+	Min Func = "min"
+	Max Func = "max"
+)
+
+// Límites de los buckets
+const (
+	MinBucket   = time.Minute
+	MaxBuckets  = 5000 // buckets por consulta, para acotar la respuesta
+	TargetCount = 200  // buckets aproximados que busca AutoBucket
+)
+
+// niceBuckets son los tamaños que elige AutoBucket, de menor a mayor
+var niceBuckets = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+	24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+var (
+	ErrInvalidFunc   = errors.New("función de agregación inválida")
+	ErrInvalidBucket = errors.New("bucket inválido")
+)
+
+// ParseFuncs interpreta una lista separada por comas ("avg,min,max,p95"). Sin
+// funciones retorna solo avg. Ignora repetidas.
+func ParseFuncs(s string) ([]Func, error) {
+	if strings.TrimSpace(s) == "" {
+		return []Func{Avg}, nil
+	}
+	var funcs []Func
+	seen := map[Func]bool{}
+	for _, part := range strings.Split(s, ",") {
+		fn := Func(strings.ToLower(strings.TrimSpace(part)))
+		switch fn {
+		case Avg, Min, Max:
+		default:
+			if _, ok := fn.Percentile(); !ok {
+				return nil, fmt.Errorf("%w: %q (use avg, min, max o p1..p99)", ErrInvalidFunc, part)
+			}
+		}
+		if !seen[fn] {
+			seen[fn] = true
+			funcs = append(funcs, fn)
+		}
+	}
+	return funcs, nil
+}
+
+// Percentile retorna la fracción (0.95 para p95) si la función es un percentil
+func (f Func) Percentile() (float64, bool) {
+	s, ok := strings.CutPrefix(string(f), "p")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 99 || strconv.Itoa(n) != s {
+		return 0, false
+	}
+	return float64(n) / 100, true
+}
+
+// ParseBucket interpreta el tamaño del bucket: una duración de Go ("5m", "1h30m")
+// o días ("1d", "7d"). Debe ser múltiplo de un minuto.
+func ParseBucket(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d < MinBucket || d%time.Minute != 0 {
+		return 0, fmt.Errorf("%w: %q (ej: 1m, 5m, 1h, 1d)", ErrInvalidBucket, s)
+	}
+	return d, nil
+}
+
+// FormatBucket es la inversa de ParseBucket: "5m", "1h", "1d"
+func FormatBucket(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	case d%time.Hour == 0:
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	case d%time.Minute == 0:
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	}
+	return d.String()
+}
+
+// AutoBucket elige el menor tamaño de niceBuckets que deja el rango en unos
+// TargetCount buckets como máximo
+func AutoBucket(from, to time.Time) time.Duration {
+	span := to.Sub(from)
+	for _, b := range niceBuckets {
+		if span/b <= TargetCount {
+			return b
+		}
+	}
+	return niceBuckets[len(niceBuckets)-1]
+}
+
+// Truncate retorna el inicio del bucket que contiene t, contado desde la época Unix.
+// time.Time.Truncate cuenta desde el año 1: los buckets de 7 días empezarían en lunes
+// y no en jueves como la época.
+func Truncate(t time.Time, bucket time.Duration) time.Time {
+	if bucket <= 0 {
+		return t.UTC()
+	}
+	ns := t.UnixNano()
+	rem := ns % int64(bucket)
+	if rem < 0 {
+		rem += int64(bucket)
+	}
+	return time.Unix(0, ns-rem).UTC()
+}
+
+// CheckRange valida que el rango tenga sentido para el bucket
+func CheckRange(from, to time.Time, bucket time.Duration) error {
+	if !from.Before(to) {
+		return errors.New("desde debe ser anterior a hasta")
+	}
+	if n := to.Sub(Truncate(from, bucket)) / bucket; n >= MaxBuckets {
+		return fmt.Errorf("%w: el rango tiene %d buckets de %s (máximo %d); use un bucket mayor",
+			ErrInvalidBucket, n+1, FormatBucket(bucket), MaxBuckets)
+	}
+	return nil
+}
+
+// Point son las estadísticas de un bucket con lecturas. Los buckets sin lecturas
+// no se incluyen.
+type Point struct {
+	Start  time.Time        `json:"start"`
+	Count  int              `json:"count"`
+	Values map[Func]float64 `json:"values"`
+}
+
+// Series agrega las lecturas de q (una consulta sobre camera_readings ya filtrada
+// por empresa, cámara y zona) en [from, to), en buckets de tamaño bucket. Las
+// lecturas se recorren en orden, guardando en memoria solo las del bucket actual.
+func Series(q *gorm.DB, from, to time.Time, bucket time.Duration, funcs []Func) ([]Point, error) {
+	rows, err := q.Select("timestamp, temperature").
+		Where("timestamp >= ? AND timestamp < ?", from.UTC(), to.UTC()).
+		Order("timestamp").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []Point
+	var start time.Time
+	var values []float64
+	flush := func() {
+		if len(values) > 0 {
+			points = append(points, Compute(start, values, funcs))
+		}
+		values = values[:0]
+	}
+	for rows.Next() {
+		var ts time.Time
+		var temp float64
+		if err := rows.Scan(&ts, &temp); err != nil {
+			return nil, err
+		}
+		if b := Truncate(ts, bucket); !b.Equal(start) {
+			flush()
+			start = b
+		}
+		values = append(values, temp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return points, nil
+}
+
+// Compute calcula las funciones sobre los valores de un bucket
+func Compute(start time.Time, values []float64, funcs []Func) Point {
+	p := Point{Start: start, Count: len(values), Values: make(map[Func]float64, len(funcs))}
+	var sorted []float64
+	for _, fn := range funcs {
+		switch fn {
+		case Avg:
+			sum := 0.0
+			for _, v := range values {
+				sum += v
+			}
+			p.Values[fn] = sum / float64(len(values))
+		case Min:
+			p.Values[fn] = extreme(values, math.Min)
+		case Max:
+			p.Values[fn] = extreme(values, math.Max)
+		default:
+			q, _ := fn.Percentile()
+			if sorted == nil {
+				sorted = append([]float64(nil), values...)
+				sort.Float64s(sorted)
+			}
+			p.Values[fn] = percentile(sorted, q)
+		}
+	}
+	return p
+}
+
+// HasPercentile indica si alguna función es un percentil. Los percentiles no se
+// pueden combinar entre buckets, así que requieren las lecturas originales.
+func HasPercentile(funcs []Func) bool {
+	for _, fn := range funcs {
+		if _, ok := fn.Percentile(); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats son los acumulados de un bucket que sí se pueden combinar (los que guardan
+// las tablas de rollup): cantidad, suma, mínimo y máximo
+type Stats struct {
+	Count int
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+// Add suma una lectura
+func (s *Stats) Add(v float64) {
+	s.Merge(Stats{Count: 1, Sum: v, Min: v, Max: v})
+}
+
+// Merge combina los acumulados de otro bucket
+func (s *Stats) Merge(o Stats) {
+	if o.Count == 0 {
+		return
+	}
+	if s.Count == 0 {
+		*s = o
+		return
+	}
+	s.Count += o.Count
+	s.Sum += o.Sum
+	s.Min = math.Min(s.Min, o.Min)
+	s.Max = math.Max(s.Max, o.Max)
+}
+
+// Point calcula avg, min y max a partir de los acumulados; ignora los percentiles
+func (s Stats) Point(start time.Time, funcs []Func) Point {
+	p := Point{Start: start, Count: s.Count, Values: make(map[Func]float64, len(funcs))}
+	for _, fn := range funcs {
+		switch fn {
+		case Avg:
+			p.Values[fn] = s.Sum / float64(s.Count)
+		case Min:
+			p.Values[fn] = s.Min
+		case Max:
+			p.Values[fn] = s.Max
+		}
+	}
+	return p
+}
+
+// percentile interpola linealmente entre las dos posiciones más cercanas, igual
+// que percentile_cont de Postgres
+func percentile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+func extreme(values []float64, pick func(a, b float64) float64) float64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		m = pick(m, v)
+	}
+	return m
+}
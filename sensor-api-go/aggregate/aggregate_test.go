@@ -0,0 +1,131 @@
+package aggregate
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParse(t *testing.T) {
+	funcs, err := ParseFuncs("avg, MAX,p95,avg")
+	if err != nil || len(funcs) != 3 || funcs[1] != Max || funcs[2] != "p95" {
+		t.Errorf("ParseFuncs: %v, %v", funcs, err)
+	}
+	if funcs, _ := ParseFuncs(""); len(funcs) != 1 || funcs[0] != Avg {
+		t.Errorf("Sin funciones se usa avg: %v", funcs)
+	}
+	for _, bad := range []string{"sum", "p0", "p100", "p095", "p"} {
+		if _, err := ParseFuncs(bad); !errors.Is(err, ErrInvalidFunc) {
+			t.Errorf("%q debe ser inválida: %v", bad, err)
+		}
+	}
+
+	for s, want := range map[string]time.Duration{"5m": 5 * time.Minute, "1h30m": 90 * time.Minute, "1d": 24 * time.Hour} {
+		if d, err := ParseBucket(s); err != nil || d != want {
+			t.Errorf("ParseBucket(%q) = %v, %v", s, d, err)
+		}
+		if back, err := ParseBucket(FormatBucket(want)); err != nil || back != want {
+			t.Errorf("FormatBucket(%v) no se puede volver a leer: %v", want, err)
+		}
+	}
+	for _, bad := range []string{"30s", "90s", "0d", "-5m", "x"} {
+		if _, err := ParseBucket(bad); !errors.Is(err, ErrInvalidBucket) {
+			t.Errorf("%q debe ser inválido: %v", bad, err)
+		}
+	}
+	if got := FormatBucket(90 * time.Minute); got != "90m" {
+		t.Errorf("FormatBucket(90m) = %q", got)
+	}
+}
+
+func TestTruncateEpochAligned(t *testing.T) {
+	week := 7 * 24 * time.Hour
+	for _, tc := range []struct {
+		t      time.Time
+		bucket time.Duration
+		want   time.Time
+	}{
+		// 1970-01-01 fue jueves: los buckets semanales empiezan en jueves
+		{time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC), week, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC), week, time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 3, 6, 15, 47, 12, 0, time.FixedZone("CLT", -3*3600)), time.Hour, time.Date(2024, 3, 6, 18, 0, 0, 0, time.UTC)},
+		{time.Date(1969, 12, 31, 23, 30, 0, 0, time.UTC), time.Hour, time.Date(1969, 12, 31, 23, 0, 0, 0, time.UTC)},
+	} {
+		if got := Truncate(tc.t, tc.bucket); !got.Equal(tc.want) || got.Location() != time.UTC {
+			t.Errorf("Truncate(%s, %s) = %s, esperado %s", tc.t, tc.bucket, got, tc.want)
+		}
+	}
+}
+
+func TestAutoBucketAndRange(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for span, want := range map[time.Duration]time.Duration{
+		time.Hour:           time.Minute,
+		24 * time.Hour:      15 * time.Minute,
+		7 * 24 * time.Hour:  time.Hour,
+		90 * 24 * time.Hour: 12 * time.Hour,
+	} {
+		if got := AutoBucket(from, from.Add(span)); got != want {
+			t.Errorf("AutoBucket(%v) = %v, esperado %v", span, got, want)
+		}
+	}
+	if err := CheckRange(from, from, time.Minute); err == nil {
+		t.Error("Un rango vacío debe rechazarse")
+	}
+	if err := CheckRange(from, from.Add(30*24*time.Hour), time.Minute); !errors.Is(err, ErrInvalidBucket) {
+		t.Errorf("Demasiados buckets deben rechazarse: %v", err)
+	}
+}
+
+func TestSeries(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	db.AutoMigrate(&models.CameraReading{})
+
+	company := uuid.New()
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var readings []models.CameraReading
+	// Bucket 10:00 con 1..10, bucket 10:05 vacío, bucket 10:10 con 20
+	for i := 1; i <= 10; i++ {
+		readings = append(readings, models.CameraReading{CompanyID: company, CameraID: 1, ZoneID: 1, Temperature: float64(i), Timestamp: base.Add(time.Duration(i*20) * time.Second)})
+	}
+	readings = append(readings,
+		models.CameraReading{CompanyID: company, CameraID: 1, ZoneID: 1, Temperature: 20, Timestamp: base.Add(11 * time.Minute)},
+		models.CameraReading{CompanyID: company, CameraID: 1, ZoneID: 2, Temperature: 99, Timestamp: base.Add(time.Minute)},
+		models.CameraReading{CompanyID: company, CameraID: 1, ZoneID: 1, Temperature: 99, Timestamp: base.Add(15 * time.Minute)}, // fuera del rango
+	)
+	db.Create(&readings)
+
+	q := db.Model(&models.CameraReading{}).Where("company_id = ? AND camera_id = ? AND zone_id = ?", company, 1, 1)
+	// Desde con otra zona horaria: los buckets igual se alinean en UTC
+	from := base.In(time.FixedZone("UTC-3", -3*3600)).Add(2 * time.Second)
+	points, err := Series(q, from, base.Add(15*time.Minute), 5*time.Minute, []Func{Avg, Min, Max, "p95", "p50"})
+	if err != nil {
+		t.Fatalf("Series: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("Esperados 2 buckets con datos, fueron %d: %+v", len(points), points)
+	}
+	first, second := points[0], points[1]
+	if !first.Start.Equal(base) || first.Count != 10 || !second.Start.Equal(base.Add(10*time.Minute)) || second.Count != 1 {
+		t.Errorf("Buckets inesperados: %+v", points)
+	}
+	want := map[Func]float64{Avg: 5.5, Min: 1, Max: 10, "p95": 9.55, "p50": 5.5}
+	for fn, v := range want {
+		if math.Abs(first.Values[fn]-v) > 1e-9 {
+			t.Errorf("%s = %v, esperado %v", fn, first.Values[fn], v)
+		}
+	}
+	if second.Values["p95"] != 20 || second.Values[Min] != 20 {
+		t.Errorf("Un bucket con una lectura: %+v", second.Values)
+	}
+}
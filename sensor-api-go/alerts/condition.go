@@ -0,0 +1,104 @@
+// alerts/condition.go
+
+package alerts
+
+import (
+	"time"
+
+	"sensor-api-go/models"
+)
+
+// Rule son las condiciones de una alerta, independientes de si es de zona o de dispositivo
+type Rule struct {
+	Upper          float64
+	Lower          float64
+	Hysteresis     float64       // banda de despeje para resolver
+	MinDuration    time.Duration // tiempo continuo fuera de rango antes de disparar
+	MinConsecutive int           // lecturas seguidas fuera de rango antes de disparar
+}
+
+func zoneAlertRule(za models.ZoneAlert) Rule {
+	return Rule{
+		Upper:          za.UpperThresh,
+		Lower:          za.LowerThresh,
+		Hysteresis:     za.Hysteresis,
+		MinDuration:    time.Duration(za.MinDurationSec) * time.Second,
+		MinConsecutive: za.MinConsecutive,
+	}
+}
+
+// consecutive retorna el mínimo efectivo de lecturas seguidas (al menos una)
+func (r Rule) consecutive() int {
+	if r.MinConsecutive < 1 {
+		return 1
+	}
+	return r.MinConsecutive
+}
+
+// OutOfRange indica si una temperatura está fuera de los umbrales
+func (r Rule) OutOfRange(temp float64) bool {
+	return temp > r.Upper || temp < r.Lower
+}
+
+// Cleared indica si una temperatura volvió a la banda de despeje (umbrales menos la histéresis)
+func (r Rule) Cleared(temp float64) bool {
+	return temp <= r.Upper-r.Hysteresis && temp >= r.Lower+r.Hysteresis
+}
+
+// Evaluate decide la condición de la regla a partir de las lecturas recientes,
+// ordenadas de la más nueva a la más antigua.
+//
+// Para disparar, las últimas lecturas deben estar fuera de rango en forma continua
+// al menos MinConsecutive lecturas y MinDuration de tiempo. Una alerta disparada
+// solo se resuelve cuando la última lectura entra en la banda de despeje; dentro
+// de la histéresis se mantiene el estado, evitando que la alerta "aletee".
+func Evaluate(rule Rule, state string, readings []models.CameraReading) Condition {
+	if len(readings) == 0 {
+		return ConditionUnknown
+	}
+	latest := readings[0]
+
+	if state == models.AlertStateFiring {
+		switch {
+		case rule.Cleared(latest.Temperature):
+			return ConditionInRange
+		case rule.OutOfRange(latest.Temperature):
+			return ConditionOutOfRange
+		default:
+			return ConditionUnknown
+		}
+	}
+
+	streak := 0
+	durationMet := rule.MinDuration <= 0
+	for _, r := range readings {
+		if !rule.OutOfRange(r.Temperature) {
+			break
+		}
+		streak++
+		if latest.Timestamp.Sub(r.Timestamp) >= rule.MinDuration {
+			durationMet = true
+		}
+	}
+
+	switch {
+	case streak == 0:
+		return ConditionInRange
+	case streak >= rule.consecutive() && durationMet:
+		return ConditionOutOfRange
+	default:
+		// Fuera de rango, pero aún no cumple la duración/cantidad mínima
+		return ConditionUnknown
+	}
+}
+
+// classify retorna el tipo de evento ("upper", "lower" u "ok") y el umbral cruzado
+func classify(rule Rule, temp float64) (string, float64) {
+	switch {
+	case temp > rule.Upper:
+		return "upper", rule.Upper
+	case temp < rule.Lower:
+		return "lower", rule.Lower
+	}
+	return "ok", 0
+}
@@ -0,0 +1,52 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+)
+
+// series arma lecturas de la más nueva a la más antigua, una cada 10 segundos
+func series(temps ...float64) []models.CameraReading {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	readings := make([]models.CameraReading, len(temps))
+	for i, temp := range temps {
+		readings[i] = models.CameraReading{Temperature: temp, Timestamp: base.Add(-time.Duration(i) * 10 * time.Second)}
+	}
+	return readings
+}
+
+func TestEvaluate(t *testing.T) {
+	simple := Rule{Upper: 50, Lower: 10}
+	hyst := Rule{Upper: 50, Lower: 10, Hysteresis: 2}
+	consecutive := Rule{Upper: 50, Lower: 10, MinConsecutive: 3}
+	duration := Rule{Upper: 50, Lower: 10, MinDuration: 30 * time.Second}
+
+	cases := []struct {
+		name     string
+		rule     Rule
+		state    string
+		readings []models.CameraReading
+		want     Condition
+	}{
+		{"sin lecturas", simple, models.AlertStateOK, nil, ConditionUnknown},
+		{"una lectura alta basta sin condiciones", simple, models.AlertStateOK, series(51), ConditionOutOfRange},
+		{"lectura baja", simple, models.AlertStateOK, series(9), ConditionOutOfRange},
+		{"en rango", simple, models.AlertStateOK, series(30), ConditionInRange},
+		{"un pico aislado no alcanza N lecturas", consecutive, models.AlertStateOK, series(60, 30, 30), ConditionUnknown},
+		{"N lecturas seguidas disparan", consecutive, models.AlertStateOK, series(60, 55, 52, 30), ConditionOutOfRange},
+		{"racha cortada no dispara", consecutive, models.AlertStateOK, series(60, 30, 55), ConditionUnknown},
+		{"duración insuficiente", duration, models.AlertStateOK, series(60, 60, 30), ConditionUnknown},
+		{"duración cumplida", duration, models.AlertStateOK, series(60, 60, 60, 60), ConditionOutOfRange},
+		{"disparada dentro de la histéresis se mantiene", hyst, models.AlertStateFiring, series(49), ConditionUnknown},
+		{"disparada en la banda de despeje se resuelve", hyst, models.AlertStateFiring, series(47.5), ConditionInRange},
+		{"disparada sigue fuera de rango", hyst, models.AlertStateFiring, series(55), ConditionOutOfRange},
+	}
+
+	for _, tc := range cases {
+		if got := Evaluate(tc.rule, tc.state, tc.readings); got != tc.want {
+			t.Errorf("%s: esperado %v, pero fue %v", tc.name, tc.want, got)
+		}
+	}
+}
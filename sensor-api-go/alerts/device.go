@@ -0,0 +1,290 @@
+// alerts/device.go
+
+package alerts
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"sensor-api-go/models"
+	"sensor-api-go/stream"
+	"sensor-api-go/tenant"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// zoneSeries son las lecturas recientes de una zona, de la más nueva a la más antigua
+type zoneSeries struct {
+	zone     models.Zone
+	readings []models.CameraReading
+}
+
+// CheckDeviceAlerts evalúa una vez todas las alertas de dispositivo configuradas
+func CheckDeviceAlerts(db *gorm.DB, notify Notifier, now time.Time) {
+	var alerts []models.DeviceAlert
+	if err := db.Find(&alerts).Error; err != nil {
+		log.Printf("[ALERT WORKER] Error obteniendo alertas de dispositivo: %v", err)
+		return
+	}
+	for _, da := range alerts {
+		if err := checkDeviceAlert(db, notify, da, now); err != nil {
+			log.Printf("[ALERT WORKER] Error evaluando alerta de dispositivo %s: %v", da.ID, err)
+		}
+	}
+}
+
+func deviceAlertRule(da models.DeviceAlert) Rule {
+	return Rule{
+		Upper:          da.UpperThresh,
+		Lower:          da.LowerThresh,
+		Hysteresis:     da.Hysteresis,
+		MinDuration:    time.Duration(da.MinDurationSec) * time.Second,
+		MinConsecutive: da.MinConsecutive,
+	}
+}
+
+func checkDeviceAlert(db *gorm.DB, notify Notifier, da models.DeviceAlert, now time.Time) error {
+	var deviceZones []models.Zone
+	if err := db.Scopes(tenant.Scope(da.CompanyID)).Where("device_id = ?", da.DeviceID).Order("name").Find(&deviceZones).Error; err != nil {
+		return err
+	}
+	rule := deviceAlertRule(da)
+
+	series := make([]zoneSeries, 0, len(deviceZones))
+	for _, z := range deviceZones {
+		if z.CameraID == nil || z.ZoneIndex == nil {
+			continue // zona creada a mano, sin lecturas asociadas
+		}
+		readings, err := recentZoneReadings(db, z, rule)
+		if err != nil {
+			return err
+		}
+		if len(readings) > 0 {
+			series = append(series, zoneSeries{zone: z, readings: readings})
+		}
+	}
+	if len(series) == 0 {
+		// Sin lecturas en ninguna zona del dispositivo
+		return nil
+	}
+
+	var cond Condition
+	var reading models.CameraReading
+	var triggerZone *uuid.UUID
+	switch da.Mode {
+	case models.DeviceAlertModeMax, models.DeviceAlertModeAvg:
+		aggregated := aggregateSeries(series, da.Mode)
+		cond = Evaluate(rule, da.State, aggregated)
+		reading = aggregated[0]
+	default:
+		cond, reading, triggerZone = evaluateAnyZone(rule, da.State, series)
+	}
+
+	newState, transition := Next(da.State, cond, da.LastNotifiedAt, time.Duration(da.ReminderMinutes)*time.Minute, now)
+	if transition == "" {
+		return nil
+	}
+	eventType, threshold := classify(rule, reading.Temperature)
+
+	deviceName := da.DeviceID.String()
+	var device models.Device
+	if err := db.First(&device, "id = ?", da.DeviceID).Error; err == nil && device.Name != "" {
+		deviceName = device.Name
+	}
+
+	subject, body := deviceAlertMessage(transition, da, deviceName, reading)
+	sendErr := notify(da.Recipient, subject, body)
+	if sendErr != nil {
+		log.Printf("[ALERT WORKER] Error enviando alerta de dispositivo (%s): %v", transition, sendErr)
+	} else {
+		log.Printf("[ALERT WORKER] Alerta de dispositivo %s enviada: %s -> %s", transition, deviceName, da.Recipient)
+	}
+
+	event := models.DeviceAlertEvent{
+		ID:          uuid.New(),
+		CompanyID:   da.CompanyID,
+		AlertID:     da.ID,
+		DeviceID:    da.DeviceID,
+		ZoneID:      triggerZone,
+		CameraID:    reading.CameraID,
+		Mode:        modeOrDefault(da.Mode),
+		Temperature: reading.Temperature,
+		Threshold:   threshold,
+		Type:        eventType,
+		Transition:  transition,
+		Timestamp:   reading.Timestamp,
+		Recipient:   da.Recipient,
+		Sent:        sendErr == nil,
+		Error:       errorString(sendErr),
+	}
+
+	// Si el envío falla solo queda el intento en el historial: el estado y
+	// last_notified_at no avanzan, así el próximo ciclo vuelve a intentarlo.
+	// Los reintentos actualizan el mismo evento fallido en vez de agregar uno por ciclo.
+	if sendErr != nil {
+		pending, err := pendingFailure(db, &models.DeviceAlertEvent{}, da.ID, transition)
+		if err != nil {
+			return err
+		}
+		if pending == uuid.Nil {
+			return db.Create(&event).Error
+		}
+		event.ID = pending
+		return db.Save(&event).Error
+	}
+	updates := map[string]interface{}{"state": newState, "last_notified_at": now}
+	if newState != da.State {
+		updates["state_changed_at"] = now
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.DeviceAlert{}).Where("id = ?", da.ID).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return stream.RecordAlert(tx, da.CompanyID, stream.Alert{
+			Kind: "device", AlertID: da.ID, EventID: event.ID, ZoneID: triggerZone, DeviceID: &event.DeviceID,
+			CameraID: event.CameraID, Transition: transition, State: newState, Type: eventType,
+			Temperature: event.Temperature, Threshold: threshold, Timestamp: event.Timestamp,
+		})
+	})
+}
+
+// evaluateAnyZone evalúa cada zona por separado: la alerta dispara si alguna zona
+// está fuera de rango y se resuelve solo cuando todas vuelven a la banda de despeje.
+// Retorna la lectura representativa (la zona más alejada del umbral al disparar).
+func evaluateAnyZone(rule Rule, state string, series []zoneSeries) (Condition, models.CameraReading, *uuid.UUID) {
+	allInRange := true
+	var worst *zoneSeries
+	worstDeviation := 0.0
+	for i := range series {
+		s := &series[i]
+		switch Evaluate(rule, state, s.readings) {
+		case ConditionOutOfRange:
+			temp := s.readings[0].Temperature
+			deviation := max(temp-rule.Upper, rule.Lower-temp)
+			if worst == nil || deviation > worstDeviation {
+				worst, worstDeviation = s, deviation
+			}
+			allInRange = false
+		case ConditionUnknown:
+			allInRange = false
+		}
+	}
+
+	if worst != nil {
+		zoneID := worst.zone.ID
+		return ConditionOutOfRange, worst.readings[0], &zoneID
+	}
+
+	// Lectura más reciente entre todas las zonas, para el evento de resolución
+	latest := series[0].readings[0]
+	for _, s := range series[1:] {
+		if s.readings[0].Timestamp.After(latest.Timestamp) {
+			latest = s.readings[0]
+		}
+	}
+	if allInRange {
+		return ConditionInRange, latest, nil
+	}
+	return ConditionUnknown, latest, nil
+}
+
+// aggregateSeries combina las zonas en una serie sintética (máximo o promedio).
+// En cada instante con lecturas se toma, por zona, la última lectura conocida hasta ese instante.
+func aggregateSeries(series []zoneSeries, mode string) []models.CameraReading {
+	var stamps []time.Time
+	seen := map[int64]bool{}
+	for _, s := range series {
+		for _, r := range s.readings {
+			if key := r.Timestamp.UnixNano(); !seen[key] {
+				seen[key] = true
+				stamps = append(stamps, r.Timestamp)
+			}
+		}
+	}
+	sort.Slice(stamps, func(i, j int) bool { return stamps[i].After(stamps[j]) })
+
+	cameraID := series[0].readings[0].CameraID
+	out := make([]models.CameraReading, 0, len(stamps))
+	for _, t := range stamps {
+		var values []float64
+		for _, s := range series {
+			for _, r := range s.readings {
+				if !r.Timestamp.After(t) {
+					values = append(values, r.Temperature)
+					break
+				}
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		agg := values[0]
+		if mode == models.DeviceAlertModeAvg {
+			sum := 0.0
+			for _, v := range values {
+				sum += v
+			}
+			agg = sum / float64(len(values))
+		} else {
+			for _, v := range values[1:] {
+				agg = max(agg, v)
+			}
+		}
+		out = append(out, models.CameraReading{CameraID: cameraID, Temperature: agg, Timestamp: t})
+	}
+	return out
+}
+
+func modeOrDefault(mode string) string {
+	if mode == "" {
+		return models.DeviceAlertModeAny
+	}
+	return mode
+}
+
+func deviceAlertMessage(transition string, da models.DeviceAlert, deviceName string, reading models.CameraReading) (string, string) {
+	var subject, title, reason string
+	switch transition {
+	case models.TransitionResolved:
+		subject = fmt.Sprintf("[RESUELTA] Dispositivo %s volvió al rango normal", deviceName)
+		title = "Alerta de dispositivo resuelta"
+		reason = "Temperatura nuevamente dentro de los umbrales"
+	case models.TransitionReminder:
+		subject = fmt.Sprintf("[RECORDATORIO] Dispositivo %s sigue fuera de umbral", deviceName)
+		title = "¡La alerta de dispositivo sigue activa!"
+		reason = motivo(reading.Temperature, da.UpperThresh, da.LowerThresh)
+	default:
+		subject = fmt.Sprintf("[ALERTA] Dispositivo %s fuera de umbral", deviceName)
+		title = "¡Alerta de temperatura en dispositivo!"
+		reason = motivo(reading.Temperature, da.UpperThresh, da.LowerThresh)
+	}
+
+	body := fmt.Sprintf(`
+                <b>%s</b><br/>
+                <ul>
+                  <li><b>Dispositivo:</b> %s</li>
+                  <li><b>Modo:</b> %s</li>
+                  <li><b>Temperatura:</b> %.2f°C</li>
+                  <li><b>Umbral superior:</b> %.2f°C</li>
+                  <li><b>Umbral inferior:</b> %.2f°C</li>
+                  <li><b>Fecha/Hora:</b> %s</li>
+                </ul>
+                <b>Motivo:</b> %s
+            `,
+		title,
+		deviceName,
+		modeOrDefault(da.Mode),
+		reading.Temperature,
+		da.UpperThresh,
+		da.LowerThresh,
+		reading.Timestamp.Format(time.RFC3339),
+		reason,
+	)
+	return subject, body
+}
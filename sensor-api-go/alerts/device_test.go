@@ -0,0 +1,55 @@
+package alerts
+
+import (
+	"testing"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+)
+
+func TestAggregateSeries(t *testing.T) {
+	zones := []zoneSeries{
+		{zone: models.Zone{ID: uuid.New()}, readings: series(40, 20)},
+		{zone: models.Zone{ID: uuid.New()}, readings: series(60, 30)},
+	}
+
+	maxSeries := aggregateSeries(zones, models.DeviceAlertModeMax)
+	if len(maxSeries) != 2 || maxSeries[0].Temperature != 60 || maxSeries[1].Temperature != 30 {
+		t.Errorf("Serie máxima inesperada: %+v", maxSeries)
+	}
+
+	avgSeries := aggregateSeries(zones, models.DeviceAlertModeAvg)
+	if len(avgSeries) != 2 || avgSeries[0].Temperature != 50 || avgSeries[1].Temperature != 25 {
+		t.Errorf("Serie promedio inesperada: %+v", avgSeries)
+	}
+
+	rule := Rule{Upper: 55, Lower: 0}
+	if got := Evaluate(rule, models.AlertStateOK, maxSeries); got != ConditionOutOfRange {
+		t.Errorf("Modo max: esperado fuera de rango, pero fue %v", got)
+	}
+	if got := Evaluate(rule, models.AlertStateOK, avgSeries); got != ConditionInRange {
+		t.Errorf("Modo avg: esperado en rango, pero fue %v", got)
+	}
+}
+
+func TestEvaluateAnyZone(t *testing.T) {
+	hot := zoneSeries{zone: models.Zone{ID: uuid.New()}, readings: series(70)}
+	warm := zoneSeries{zone: models.Zone{ID: uuid.New()}, readings: series(56)}
+	ok := zoneSeries{zone: models.Zone{ID: uuid.New()}, readings: series(30)}
+	rule := Rule{Upper: 55, Lower: 0, Hysteresis: 2}
+
+	cond, reading, zoneID := evaluateAnyZone(rule, models.AlertStateOK, []zoneSeries{ok, warm, hot})
+	if cond != ConditionOutOfRange || reading.Temperature != 70 || zoneID == nil || *zoneID != hot.zone.ID {
+		t.Errorf("Esperada la zona más caliente como disparadora: cond=%v temp=%v", cond, reading.Temperature)
+	}
+
+	// Disparada: una zona dentro de la histéresis impide resolver
+	inBand := zoneSeries{zone: models.Zone{ID: uuid.New()}, readings: series(54)}
+	if cond, _, _ := evaluateAnyZone(rule, models.AlertStateFiring, []zoneSeries{ok, inBand}); cond != ConditionUnknown {
+		t.Errorf("Esperado mantener el estado, pero fue %v", cond)
+	}
+	if cond, _, _ := evaluateAnyZone(rule, models.AlertStateFiring, []zoneSeries{ok}); cond != ConditionInRange {
+		t.Errorf("Esperado resolver con todas las zonas en rango, pero fue %v", cond)
+	}
+}
@@ -0,0 +1,44 @@
+// alerts/state.go
+
+package alerts
+
+import (
+	"time"
+
+	"sensor-api-go/models"
+)
+
+// Condition es lo que dicen las lecturas recientes sobre una regla
+type Condition int
+
+const (
+	ConditionUnknown    Condition = iota // sin datos suficientes: se mantiene el estado
+	ConditionOutOfRange                  // la regla debe estar disparada
+	ConditionInRange                     // la regla puede resolverse
+)
+
+// Next aplica la máquina de estados OK → FIRING → RESOLVED y retorna el nuevo
+// estado y la transición a notificar ("" si no corresponde notificar nada).
+//
+//   - OK/RESOLVED + fuera de rango → FIRING (notifica "firing")
+//   - FIRING + fuera de rango      → FIRING (notifica "reminder" si pasó el intervalo)
+//   - FIRING + en rango            → RESOLVED (notifica "resolved")
+func Next(state string, cond Condition, lastNotified *time.Time, reminder time.Duration, now time.Time) (string, string) {
+	if state == "" {
+		state = models.AlertStateOK
+	}
+	switch cond {
+	case ConditionOutOfRange:
+		if state != models.AlertStateFiring {
+			return models.AlertStateFiring, models.TransitionFiring
+		}
+		if reminder > 0 && (lastNotified == nil || now.Sub(*lastNotified) >= reminder) {
+			return models.AlertStateFiring, models.TransitionReminder
+		}
+	case ConditionInRange:
+		if state == models.AlertStateFiring {
+			return models.AlertStateResolved, models.TransitionResolved
+		}
+	}
+	return state, ""
+}
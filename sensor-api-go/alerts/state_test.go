@@ -0,0 +1,42 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+)
+
+func TestNext(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-5 * time.Minute)
+	old := now.Add(-2 * time.Hour)
+
+	cases := []struct {
+		name         string
+		state        string
+		cond         Condition
+		lastNotified *time.Time
+		reminder     time.Duration
+		wantState    string
+		wantNotify   string
+	}{
+		{"ok fuera de rango dispara", models.AlertStateOK, ConditionOutOfRange, nil, 0, models.AlertStateFiring, models.TransitionFiring},
+		{"estado vacío se trata como OK", "", ConditionOutOfRange, nil, 0, models.AlertStateFiring, models.TransitionFiring},
+		{"resuelta vuelve a disparar", models.AlertStateResolved, ConditionOutOfRange, &old, 0, models.AlertStateFiring, models.TransitionFiring},
+		{"disparada sin recordatorio no reenvía", models.AlertStateFiring, ConditionOutOfRange, &recent, 0, models.AlertStateFiring, ""},
+		{"recordatorio antes de tiempo", models.AlertStateFiring, ConditionOutOfRange, &recent, time.Hour, models.AlertStateFiring, ""},
+		{"recordatorio vencido", models.AlertStateFiring, ConditionOutOfRange, &old, time.Hour, models.AlertStateFiring, models.TransitionReminder},
+		{"disparada vuelve al rango", models.AlertStateFiring, ConditionInRange, &recent, 0, models.AlertStateResolved, models.TransitionResolved},
+		{"ok en rango no notifica", models.AlertStateOK, ConditionInRange, nil, 0, models.AlertStateOK, ""},
+		{"resuelta en rango no notifica", models.AlertStateResolved, ConditionInRange, &old, 0, models.AlertStateResolved, ""},
+		{"sin datos mantiene disparada", models.AlertStateFiring, ConditionUnknown, &old, time.Hour, models.AlertStateFiring, ""},
+	}
+
+	for _, tc := range cases {
+		state, notify := Next(tc.state, tc.cond, tc.lastNotified, tc.reminder, now)
+		if state != tc.wantState || notify != tc.wantNotify {
+			t.Errorf("%s: esperado (%s, %q), pero fue (%s, %q)", tc.name, tc.wantState, tc.wantNotify, state, notify)
+		}
+	}
+}
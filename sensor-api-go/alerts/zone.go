@@ -0,0 +1,238 @@
+// alerts/zone.go
+
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sensor-api-go/models"
+	"sensor-api-go/stream"
+	"sensor-api-go/tenant"
+	"sensor-api-go/zones"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Notifier envía una notificación; en producción es utils.SendEmail
+type Notifier func(to, subject, body string) error
+
+// CheckZoneAlerts evalúa una vez todas las alertas de zona configuradas
+func CheckZoneAlerts(db *gorm.DB, notify Notifier, now time.Time) {
+	var alerts []models.ZoneAlert
+	if err := db.Find(&alerts).Error; err != nil {
+		log.Printf("[ALERT WORKER] Error obteniendo alertas: %v", err)
+		return
+	}
+	for _, za := range alerts {
+		if err := checkZoneAlert(db, notify, za, now); err != nil {
+			log.Printf("[ALERT WORKER] Error evaluando alerta %s: %v", za.ID, err)
+		}
+	}
+}
+
+func checkZoneAlert(db *gorm.DB, notify Notifier, za models.ZoneAlert, now time.Time) error {
+	rule := zoneAlertRule(za)
+	// La zona debe ser de la misma empresa que la alerta
+	var zone models.Zone
+	if err := db.Scopes(tenant.Scope(za.CompanyID)).First(&zone, "id = ?", za.ZoneID).Error; err != nil {
+		return fmt.Errorf("zona %s no registrada: %w", za.ZoneID, err)
+	}
+	readings, err := recentZoneReadings(db, zone, rule)
+	if err != nil {
+		return err
+	}
+	if len(readings) == 0 {
+		// Si no hay lecturas para esa zona, ignorar
+		return nil
+	}
+	reading := readings[0]
+	cond := Evaluate(rule, za.State, readings)
+
+	newState, transition := Next(za.State, cond, za.LastNotifiedAt, time.Duration(za.ReminderMinutes)*time.Minute, now)
+	if transition == "" {
+		return nil
+	}
+
+	eventType, threshold := classify(rule, reading.Temperature)
+
+	// Intenta enviar la notificación; el intento se registra aunque falle el envío
+	subject, body := zoneAlertMessage(transition, za, reading)
+	sendErr := notify(za.Recipient, subject, body)
+	if sendErr != nil {
+		log.Printf("[ALERT WORKER] Error enviando alerta (%s): %v", transition, sendErr)
+	} else {
+		log.Printf("[ALERT WORKER] Alerta %s enviada: Cámara %d Zona %d -> %s", transition, reading.CameraID, reading.ZoneID, za.Recipient)
+	}
+
+	event := models.ZoneAlertEvent{
+		ID:          uuid.New(),
+		CompanyID:   za.CompanyID,
+		AlertID:     za.ID,
+		ZoneID:      za.ZoneID,
+		CameraID:    reading.CameraID,
+		Temperature: reading.Temperature,
+		Threshold:   threshold,
+		Type:        eventType,
+		Transition:  transition,
+		Timestamp:   reading.Timestamp,
+		Recipient:   za.Recipient,
+		Sent:        sendErr == nil,
+		Error:       errorString(sendErr),
+	}
+
+	// Si el envío falla solo queda el intento en el historial: el estado y
+	// last_notified_at no avanzan, así el próximo ciclo vuelve a intentarlo.
+	// Los reintentos actualizan el mismo evento fallido en vez de agregar uno por ciclo.
+	if sendErr != nil {
+		pending, err := pendingFailure(db, &models.ZoneAlertEvent{}, za.ID, transition)
+		if err != nil {
+			return err
+		}
+		if pending == uuid.Nil {
+			return db.Create(&event).Error
+		}
+		event.ID = pending
+		return db.Save(&event).Error
+	}
+	updates := map[string]interface{}{"state": newState, "last_notified_at": now}
+	if newState != za.State {
+		updates["state_changed_at"] = now
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ZoneAlert{}).Where("id = ?", za.ID).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return stream.RecordAlert(tx, za.CompanyID, stream.Alert{
+			Kind: "zone", AlertID: za.ID, EventID: event.ID, ZoneID: &event.ZoneID, DeviceID: &zone.DeviceID,
+			CameraID: event.CameraID, Transition: transition, State: newState, Type: eventType,
+			Temperature: event.Temperature, Threshold: threshold, Timestamp: event.Timestamp,
+		})
+	})
+}
+
+// recentZoneReadings trae, de la más nueva a la más antigua, las lecturas que
+// necesita Evaluate: al menos MinConsecutive lecturas y todas las de la ventana
+// MinDuration, más la primera anterior a la ventana (para saber si la condición
+// se cumplía ya al inicio de la ventana)
+func recentZoneReadings(db *gorm.DB, zone models.Zone, rule Rule) ([]models.CameraReading, error) {
+	base, err := zones.Readings(db, zone)
+	if err != nil {
+		return nil, err
+	}
+	// Session permite reutilizar la condición base en varias consultas
+	base = base.Session(&gorm.Session{})
+
+	var readings []models.CameraReading
+	err = base.
+		Order("timestamp DESC").
+		Limit(rule.consecutive()).
+		Find(&readings).Error
+	if err != nil || len(readings) == 0 || rule.MinDuration <= 0 {
+		return readings, err
+	}
+
+	cutoff := readings[0].Timestamp.Add(-rule.MinDuration)
+	var window []models.CameraReading
+	err = base.Where("timestamp >= ?", cutoff).
+		Order("timestamp DESC").
+		Find(&window).Error
+	if err != nil {
+		return nil, err
+	}
+	var before models.CameraReading
+	err = base.Where("timestamp < ?", cutoff).
+		Order("timestamp DESC").
+		First(&before).Error
+	if err == nil {
+		window = append(window, before)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Ambas listas son prefijos del mismo orden descendente: la más larga contiene a la otra
+	if len(window) > len(readings) {
+		return window, nil
+	}
+	return readings, nil
+}
+
+func zoneAlertMessage(transition string, za models.ZoneAlert, reading models.CameraReading) (string, string) {
+	var subject, title, reason string
+	switch transition {
+	case models.TransitionResolved:
+		subject = fmt.Sprintf("[RESUELTA] Cámara %d Zona %d volvió al rango normal", reading.CameraID, reading.ZoneID)
+		title = "Alerta de temperatura resuelta"
+		reason = "Temperatura nuevamente dentro de los umbrales"
+	case models.TransitionReminder:
+		subject = fmt.Sprintf("[RECORDATORIO] Cámara %d Zona %d sigue fuera de umbral", reading.CameraID, reading.ZoneID)
+		title = "¡La alerta de temperatura sigue activa!"
+		reason = motivo(reading.Temperature, za.UpperThresh, za.LowerThresh)
+	default:
+		subject = fmt.Sprintf("[ALERTA] Cámara %d Zona %d fuera de umbral", reading.CameraID, reading.ZoneID)
+		title = "¡Alerta de temperatura!"
+		reason = motivo(reading.Temperature, za.UpperThresh, za.LowerThresh)
+	}
+
+	body := fmt.Sprintf(`
+                <b>%s</b><br/>
+                <ul>
+                  <li><b>Cámara:</b> %d</li>
+                  <li><b>Zona:</b> %d</li>
+                  <li><b>Temperatura:</b> %.2f°C</li>
+                  <li><b>Umbral superior:</b> %.2f°C</li>
+                  <li><b>Umbral inferior:</b> %.2f°C</li>
+                  <li><b>Fecha/Hora:</b> %s</li>
+                </ul>
+                <b>Motivo:</b> %s
+            `,
+		title,
+		reading.CameraID,
+		reading.ZoneID,
+		reading.Temperature,
+		za.UpperThresh,
+		za.LowerThresh,
+		reading.Timestamp.Format(time.RFC3339),
+		reason,
+	)
+	return subject, body
+}
+
+func motivo(temp, upper, lower float64) string {
+	if temp > upper {
+		return "Temperatura sobre el umbral permitido"
+	}
+	if temp < lower {
+		return "Temperatura bajo el umbral permitido"
+	}
+	return "Anomalía detectada"
+}
+
+// pendingFailure retorna el evento fallido que se sigue reintentando: el último de
+// la alerta, si no se envió y es de la misma transición. Si no hay, uuid.Nil.
+func pendingFailure(db *gorm.DB, model interface{}, alertID uuid.UUID, transition string) (uuid.UUID, error) {
+	var last struct {
+		ID         uuid.UUID
+		Sent       bool
+		Transition string
+	}
+	err := db.Model(model).Select("id", "sent", "transition").Where("alert_id = ?", alertID).
+		Order("timestamp DESC, sent DESC").Limit(1).Scan(&last).Error
+	if err != nil || last.Sent || last.Transition != transition {
+		return uuid.Nil, err
+	}
+	return last.ID, nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
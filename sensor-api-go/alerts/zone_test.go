@@ -0,0 +1,151 @@
+package alerts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sensor-api-go/ingest"
+	"sensor-api-go/models"
+	"sensor-api-go/zones"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newAlertsTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	if err := db.AutoMigrate(&models.CameraReading{}, &models.Device{}, &models.Zone{},
+		&models.ZoneAlert{}, &models.ZoneAlertEvent{}, &models.StreamEvent{}); err != nil {
+		t.Fatalf("No se pudo migrar: %v", err)
+	}
+	return db
+}
+
+var testCompanyID = uuid.MustParse("6f1c2a4e-0000-4000-8000-000000000001")
+
+func saveReading(t *testing.T, db *gorm.DB, camera, zone int, temp float64, ts time.Time) {
+	t.Helper()
+	in := ingest.ReadingInput{CompanyID: testCompanyID, CameraID: &camera, ZoneID: &zone, Temperature: &temp, Timestamp: &ts}
+	results, err := ingest.Save(db, []ingest.ReadingInput{in})
+	if err != nil {
+		t.Fatalf("Error guardando lectura: %v", err)
+	}
+	if accepted, _ := ingest.Count(results); accepted != 1 {
+		t.Fatalf("Lectura rechazada: %+v", results)
+	}
+}
+
+func TestCheckZoneAlerts_ResolvesZoneThroughRegistry(t *testing.T) {
+	db := newAlertsTestDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// La misma zona 2 en dos cámaras: solo la cámara 1 está fuera de umbral
+	saveReading(t, db, 1, 2, 80, now.Add(-time.Minute))
+	saveReading(t, db, 2, 2, 20, now.Add(-time.Minute))
+
+	zone, err := zones.Lookup(db, testCompanyID, 1, 2)
+	if err != nil {
+		t.Fatalf("La ingesta no registró la zona: %v", err)
+	}
+	other, err := zones.Lookup(db, testCompanyID, 2, 2)
+	if err != nil || other.ID == zone.ID || other.DeviceID == zone.DeviceID {
+		t.Fatalf("Cada cámara debe tener su propia zona y dispositivo: %+v %+v", zone, other)
+	}
+
+	alert := models.ZoneAlert{ID: uuid.New(), CompanyID: testCompanyID, ZoneID: zone.ID, UpperThresh: 50, LowerThresh: 0,
+		Recipient: "ops@example.com", State: models.AlertStateOK}
+	quiet := models.ZoneAlert{ID: uuid.New(), CompanyID: testCompanyID, ZoneID: other.ID, UpperThresh: 50, LowerThresh: 0,
+		Recipient: "quiet@example.com", State: models.AlertStateOK}
+	db.Create(&alert)
+	db.Create(&quiet)
+
+	var sent []string
+	notify := func(to, subject, body string) error {
+		sent = append(sent, to)
+		return nil
+	}
+
+	CheckZoneAlerts(db, notify, now)
+	if len(sent) != 1 || sent[0] != "ops@example.com" {
+		t.Fatalf("Esperada una notificación de disparo para la cámara 1, se enviaron %v", sent)
+	}
+	CheckZoneAlerts(db, notify, now.Add(10*time.Second))
+	if len(sent) != 1 {
+		t.Fatalf("No debe repetir la notificación mientras sigue disparada, se enviaron %v", sent)
+	}
+
+	saveReading(t, db, 1, 2, 25, now.Add(15*time.Second))
+	CheckZoneAlerts(db, notify, now.Add(20*time.Second))
+	if len(sent) != 2 {
+		t.Fatalf("Esperada la notificación de resolución, se enviaron %v", sent)
+	}
+
+	var events []models.ZoneAlertEvent
+	db.Where("alert_id = ?", alert.ID).Order("timestamp").Find(&events)
+	if len(events) != 2 || events[0].Transition != models.TransitionFiring || events[1].Transition != models.TransitionResolved {
+		t.Errorf("Historial inesperado: %+v", events)
+	}
+	// Cada transición queda también para el stream en vivo
+	var streamed []models.StreamEvent
+	db.Order("id").Find(&streamed)
+	if len(streamed) != 2 || streamed[0].CameraID != 1 || !strings.Contains(streamed[1].Data, `"state":"RESOLVED"`) {
+		t.Errorf("Eventos del stream inesperados: %+v", streamed)
+	}
+}
+
+func TestCheckZoneAlerts_RetriesFailedNotification(t *testing.T) {
+	db := newAlertsTestDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	saveReading(t, db, 1, 1, 80, now.Add(-time.Minute))
+	zone, _ := zones.Lookup(db, testCompanyID, 1, 1)
+	alert := models.ZoneAlert{ID: uuid.New(), CompanyID: testCompanyID, ZoneID: zone.ID, UpperThresh: 50,
+		Recipient: "ops@example.com", State: models.AlertStateOK}
+	db.Create(&alert)
+
+	fail := true
+	sent := 0
+	notify := func(to, subject, body string) error {
+		if fail {
+			return errors.New("smtp caído")
+		}
+		sent++
+		return nil
+	}
+
+	CheckZoneAlerts(db, notify, now)
+	db.First(&alert, "id = ?", alert.ID)
+	if alert.State != models.AlertStateOK || alert.LastNotifiedAt != nil {
+		t.Fatalf("Un envío fallido no debe avanzar el estado: %+v", alert)
+	}
+	// Mientras el correo siga caído los reintentos no agregan eventos
+	CheckZoneAlerts(db, notify, now.Add(10*time.Second))
+	var failed int64
+	db.Model(&models.ZoneAlertEvent{}).Where("alert_id = ?", alert.ID).Count(&failed)
+	if failed != 1 {
+		t.Fatalf("Los reintentos fallidos deben quedar en un solo evento, hay %d", failed)
+	}
+
+	fail = false
+	CheckZoneAlerts(db, notify, now.Add(20*time.Second))
+	db.First(&alert, "id = ?", alert.ID)
+	if sent != 1 || alert.State != models.AlertStateFiring || alert.LastNotifiedAt == nil {
+		t.Fatalf("El ciclo siguiente debe reintentar el disparo: enviados %d, alerta %+v", sent, alert)
+	}
+
+	var events []models.ZoneAlertEvent
+	db.Where("alert_id = ?", alert.ID).Order("sent").Find(&events)
+	if len(events) != 2 || events[0].Sent || events[0].Error == "" || !events[1].Sent {
+		t.Errorf("El historial debe tener el intento fallido y el reintento: %+v", events)
+	}
+	var streamed int64
+	db.Model(&models.StreamEvent{}).Count(&streamed)
+	if streamed != 1 {
+		t.Errorf("Solo la transición efectiva va al stream, hay %d eventos", streamed)
+	}
+}
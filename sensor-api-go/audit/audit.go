@@ -0,0 +1,75 @@
+// audit/audit.go
+
+// Package audit registra en models.AuditLog las acciones relevantes para la seguridad
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Acciones registradas
+const (
+	ActionAccountLocked   = "account.locked"
+	ActionAccountUnlocked = "account.unlocked"
+	ActionUserProvisioned = "user.provisioned" // creado en su primer login SSO
+	ActionLogin           = "auth.login"       // login completo (contraseña, 2FA o SSO)
+)
+
+// Entry describe una acción a registrar. Details y Changes se guardan como JSON.
+type Entry struct {
+	CompanyID  uuid.UUID
+	ActorID    *uuid.UUID
+	ActorEmail string
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	Details    map[string]interface{}
+	Changes    map[string]Change
+
+	// Datos de la solicitud HTTP; los completa Middleware
+	Method    string
+	Path      string
+	Status    int
+	RequestID string
+}
+
+// Record inserta la entrada en el registro de auditoría
+func Record(db *gorm.DB, e Entry) error {
+	row := models.AuditLog{
+		ID:         uuid.New(),
+		CompanyID:  e.CompanyID,
+		ActorID:    e.ActorID,
+		ActorEmail: e.ActorEmail,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.IP,
+		Method:     e.Method,
+		Path:       e.Path,
+		Status:     e.Status,
+		RequestID:  e.RequestID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if len(e.Details) > 0 {
+		details, err := json.Marshal(e.Details)
+		if err != nil {
+			return err
+		}
+		row.Details = string(details)
+	}
+	if len(e.Changes) > 0 {
+		changes, err := json.Marshal(e.Changes)
+		if err != nil {
+			return err
+		}
+		row.Changes = string(changes)
+	}
+	return db.Create(&row).Error
+}
@@ -0,0 +1,78 @@
+// audit/diff.go
+
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Change es el valor de un campo antes y después de la acción
+type Change struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// redacted reemplaza el valor de los campos sensibles; solo se registra que cambiaron
+const redacted = "[oculto]"
+
+// sensitive son fragmentos de nombres de campo cuyo valor nunca se guarda
+var sensitive = []string{"password", "secret", "token", "hash", "key"}
+
+// Diff compara dos versiones de un objeto (nil si no existía o ya no existe) usando
+// su representación JSON y retorna solo los campos que cambiaron.
+func Diff(before, after interface{}) (map[string]Change, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]Change{}
+	for k, bv := range b {
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(av, bv) {
+			changes[k] = redact(k, Change{Before: bv, After: av})
+		}
+	}
+	for k, av := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = redact(k, Change{After: av})
+		}
+	}
+	return changes, nil
+}
+
+// fields convierte el objeto en un mapa campo -> valor
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return map[string]interface{}{}, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func redact(field string, ch Change) Change {
+	name := strings.ToLower(field)
+	for _, s := range sensitive {
+		if strings.Contains(name, s) {
+			if ch.Before != nil {
+				ch.Before = redacted
+			}
+			if ch.After != nil {
+				ch.After = redacted
+			}
+			return ch
+		}
+	}
+	return ch
+}
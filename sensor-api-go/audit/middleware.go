@@ -0,0 +1,133 @@
+// audit/middleware.go
+
+package audit
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Claves del contexto de gin que usan Describe y Skip
+const (
+	targetKey = "audit_target"
+	skipKey   = "audit_skip"
+)
+
+// Target describe la acción de una solicitud para el registro de auditoría.
+// Todos los campos son opcionales: lo que falte se toma de la solicitud y del token.
+type Target struct {
+	Action string // por defecto "<Type>.<created|updated|deleted>" o "MÉTODO /ruta"
+	Type   string
+	ID     string
+	Before interface{} // versión anterior del objeto (nil al crear)
+	After  interface{} // versión nueva del objeto (nil al eliminar)
+
+	// Para solicitudes sin token (login), quién actúa y en qué empresa
+	CompanyID  *uuid.UUID
+	ActorID    *uuid.UUID
+	ActorEmail string
+	Details    map[string]interface{}
+}
+
+// Describe indica al middleware qué objeto modificó el handler y cómo estaba antes
+func Describe(c *gin.Context, t Target) {
+	c.Set(targetKey, t)
+}
+
+// Skip evita el registro automático cuando el handler ya registró la acción por su cuenta
+func Skip(c *gin.Context) {
+	c.Set(skipKey, true)
+}
+
+// verbs da el nombre de acción por defecto según el método
+var verbs = map[string]string{
+	http.MethodPost:   "created",
+	http.MethodPut:    "updated",
+	http.MethodPatch:  "updated",
+	http.MethodDelete: "deleted",
+}
+
+// Middleware registra cada solicitud exitosa que modifica datos (POST, PUT, PATCH,
+// DELETE) hecha por un usuario, y todas las que el handler describe con Describe
+// (como el login). Las solicitudes de dispositivos (API keys) no se registran.
+func Middleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.GetBool(skipKey) {
+			return
+		}
+		// Describe se llama solo después de guardar el cambio, así que se registra
+		// aunque la respuesta sea un error posterior (por ejemplo, al enviar un email)
+		var target Target
+		if v, ok := c.Get(targetKey); ok {
+			target = v.(Target)
+		} else if _, mutating := verbs[c.Request.Method]; !mutating || c.GetString("user_id") == "" ||
+			c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+
+		entry, ok := entryFor(c, target)
+		if !ok {
+			return
+		}
+		if err := Record(db, entry); err != nil {
+			log.Printf("[ERROR] No se pudo registrar la auditoría de %s %s: %v", c.Request.Method, c.FullPath(), err)
+		}
+	}
+}
+
+// entryFor arma la entrada con los datos del token, la solicitud y lo descrito por el handler
+func entryFor(c *gin.Context, t Target) (Entry, bool) {
+	companyID := t.CompanyID
+	if companyID == nil {
+		id, err := uuid.Parse(c.GetString("company_id"))
+		if err != nil {
+			return Entry{}, false
+		}
+		companyID = &id
+	}
+	actorID, actorEmail := t.ActorID, t.ActorEmail
+	if actorID == nil {
+		if id, err := uuid.Parse(c.GetString("user_id")); err == nil {
+			actorID = &id
+			actorEmail = c.GetString("email")
+		}
+	}
+
+	path := c.FullPath()
+	action := t.Action
+	if action == "" && t.Type != "" && verbs[c.Request.Method] != "" {
+		action = t.Type + "." + verbs[c.Request.Method]
+	}
+	if action == "" {
+		action = c.Request.Method + " " + path
+	}
+
+	entry := Entry{
+		CompanyID:  *companyID,
+		ActorID:    actorID,
+		ActorEmail: actorEmail,
+		Action:     action,
+		TargetType: t.Type,
+		TargetID:   t.ID,
+		IP:         c.ClientIP(),
+		Details:    t.Details,
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.Path,
+		Status:     c.Writer.Status(),
+		RequestID:  c.GetString("request_id"),
+	}
+	if t.Before != nil || t.After != nil {
+		changes, err := Diff(t.Before, t.After)
+		if err != nil {
+			log.Printf("[WARN] No se pudo calcular el cambio auditado de %s: %v", action, err)
+		}
+		entry.Changes = changes
+	}
+	return entry, true
+}
@@ -1,16 +1,35 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sensor-api-go/config"
+	"sensor-api-go/csvimport"
+	"sensor-api-go/devseed"
+	"sensor-api-go/loginguard"
+	"sensor-api-go/middleware"
+	"sensor-api-go/migrations"
 	"sensor-api-go/models"
+	"sensor-api-go/mqttingest"
+	"sensor-api-go/rollup"
 	"sensor-api-go/routes"
+	"sensor-api-go/sessions"
+	"sensor-api-go/stream"
+	"sensor-api-go/utils"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -19,22 +38,222 @@ func main() {
 	_ = godotenv.Load(rootEnv) // No falla si no está
 	_ = godotenv.Load(".env")  // Tampoco falla si no está
 
+	// --dev: base SQLite local (sensor-dev.db si no hay DB_DRIVER), migraciones
+	// automáticas y datos de demostración
+	dev := flag.Bool("dev", false, "desarrollo local: SQLite, migraciones automáticas y datos de demostración")
+	flag.Parse()
+
 	// Carga config desde variables de entorno (estructura propia)
-	cfg := config.LoadConfig()
+	load := config.LoadConfig
+	if *dev {
+		load = config.LoadDevConfig
+	}
+	cfg := load()
 	db := config.SetupDB(cfg)
 
-	// --------- Migración automática de modelos ---------
-	// Agrega aquí todos los modelos nuevos
-	if err := db.AutoMigrate(
-		&models.ZoneAlertEvent{}, // <-- Nuevo modelo historial de alertas
-		// Agrega aquí otros modelos si los tienes, ejemplo:
-		// &models.Device{}, &models.Zone{}, &models.User{}, ...
-	); err != nil {
-		log.Fatalf("[FATAL] Error en AutoMigrate: %v", err)
+	// Modo de ejecución: "serve" (API, por defecto), "mqtt-ingest", "rollup", "import" o "migrate"
+	args := flag.Args()
+	mode := "serve"
+	if len(args) > 0 {
+		mode = args[0]
+	}
+	if mode == "migrate" {
+		runMigrate(db, args[1:])
+		return
+	}
+
+	if *dev {
+		if cfg.IsProduction() {
+			log.Fatalf("[FATAL] --dev no se puede usar con APP_ENV=production")
+		}
+		setupDev(db)
+	}
+
+	// --------- El esquema debe estar al día (ver "migrate up") ---------
+	if err := migrations.Check(db, migrations.All); err != nil {
+		log.Fatalf("[FATAL] %v", err)
 	}
 	// ---------------------------------------------------
 
+	switch mode {
+	case "serve":
+		if *dev {
+			// En desarrollo no hay un proceso aparte para los rollups
+			go rollup.Run(context.Background(), db)
+		}
+		runServer(cfg, db)
+	case "mqtt-ingest":
+		runMQTTIngest(db)
+	case "rollup":
+		runRollup(db)
+	case "import":
+		runImport(db, args[1:])
+	default:
+		log.Fatalf("[FATAL] Modo desconocido %q. Uso: app [--dev] [serve|mqtt-ingest|rollup|import|migrate]", mode)
+	}
+}
+
+// setupDev aplica las migraciones pendientes y carga los datos de demostración
+func setupDev(db *gorm.DB) {
+	done, err := migrations.Up(db, migrations.All)
+	if err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+	for _, m := range done {
+		log.Printf("[DEV] Migración aplicada %d %s", m.Version, m.Name)
+	}
+	if err := devseed.Seed(db, time.Now()); err != nil {
+		log.Fatalf("[FATAL] No se pudieron cargar los datos de demostración: %v", err)
+	}
+}
+
+// runMigrate aplica ("up"), revierte ("down [n]", por defecto 1) o lista ("status")
+// las migraciones del esquema
+func runMigrate(db *gorm.DB, args []string) {
+	cmd := "status"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "up":
+		done, err := migrations.Up(db, migrations.All)
+		for _, m := range done {
+			log.Printf("[MIGRATE] Aplicada %d %s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+		if len(done) == 0 {
+			log.Println("[MIGRATE] El esquema ya está al día")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("[FATAL] Cantidad de migraciones inválida: %q", args[1])
+			}
+			steps = n
+		}
+		done, err := migrations.Down(db, migrations.All, steps)
+		for _, m := range done {
+			log.Printf("[MIGRATE] Revertida %d %s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	case "status":
+		states, err := migrations.Status(db, migrations.All)
+		if err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+		for _, s := range states {
+			applied := "pendiente"
+			if s.AppliedAt != nil {
+				applied = "aplicada " + s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Unknown {
+				applied += " (desconocida para este binario)"
+			}
+			fmt.Printf("%4d  %-40s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		log.Fatalf("[FATAL] Comando de migración desconocido %q. Uso: app migrate [up|down [n]|status]", cmd)
+	}
+}
+
+// runMQTTIngest suscribe al broker MQTT y guarda las lecturas hasta recibir SIGINT/SIGTERM
+func runMQTTIngest(db *gorm.DB) {
+	mqttCfg := config.LoadMQTTConfig()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := mqttingest.Run(ctx, mqttCfg, db); err != nil {
+		log.Fatalf("[FATAL] Ingesta MQTT: %v", err)
+	}
+}
+
+// runRollup mantiene los rollups de lecturas y aplica la retención hasta recibir SIGINT/SIGTERM
+func runRollup(db *gorm.DB) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("[ROLLUP] Iniciado. Agregando lecturas cada %s", rollup.Interval)
+	rollup.Run(ctx, db)
+}
+
+// runImport importa un CSV de lecturas históricas para una empresa, mostrando el
+// avance. Uso: app import --company <uuid> [--mapping '<json>'] archivo.csv
+func runImport(db *gorm.DB, args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	company := fs.String("company", "", "ID de la empresa dueña de las lecturas")
+	mappingJSON := fs.String("mapping", "", `mapeo de columnas en JSON, ej: {"timestamp": "Fecha", "temperature": "Temp", "camera": 1, "zone": 2}`)
+	fs.Parse(args)
+	companyID, err := uuid.Parse(*company)
+	if err != nil || fs.NArg() != 1 {
+		log.Fatalf("[FATAL] Uso: app import --company <uuid> [--mapping '<json>'] archivo.csv")
+	}
+	mapping, err := csvimport.ParseMapping(*mappingJSON)
+	if err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+	path := fs.Arg(0)
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+	job, err := csvimport.NewJob(db, companyID, nil, filepath.Base(path), info.Size(), mapping)
+	if err != nil {
+		log.Fatalf("[FATAL] No se pudo crear la importación: %v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("[IMPORT] Importación %s de %s (%d bytes)", job.ID, path, info.Size())
+	var last time.Time
+	progress := func(j models.ImportJob) {
+		if time.Since(last) >= 2*time.Second || j.Status != models.ImportRunning {
+			last = time.Now()
+			log.Printf("[IMPORT] %5.1f%%  %d filas: %d importadas, %d repetidas, %d rechazadas",
+				j.Progress(), j.Rows, j.Imported, j.Duplicates, j.Rejected)
+		}
+	}
+	if err := csvimport.Run(ctx, db, &job, f, progress); err != nil {
+		log.Fatalf("[FATAL] Importación fallida: %v", err)
+	}
+	if job.Rejected > 0 {
+		log.Printf("[IMPORT] Reporte de líneas rechazadas: GET /api/imports/%s/errors", job.ID)
+	}
+}
+
+func runServer(cfg *config.Config, db *gorm.DB) {
+	// ----------- Llaves JWT: en producción no se acepta el secreto por defecto -----------
+	if err := cfg.JWT.Validate(cfg.IsProduction()); err != nil {
+		log.Fatalf("[FATAL] Configuración JWT inválida: %v", err)
+	}
+	if err := utils.ConfigureJWT(cfg.JWT); err != nil {
+		log.Fatalf("[FATAL] No se pudieron cargar las llaves JWT: %v", err)
+	}
+	if len(cfg.JWT.Secrets) == 0 && len(cfg.JWT.KeyFiles) == 0 {
+		log.Println("[WARN] JWT_SECRET no definido, se usa el secreto de desarrollo (no usar en producción)")
+	}
+	sessions.Configure(cfg.JWT)
+	if err := sessions.Purge(db, time.Now()); err != nil {
+		log.Printf("[WARN] No se pudieron limpiar las sesiones vencidas: %v", err)
+	}
+	if err := loginguard.Purge(db, time.Now()); err != nil {
+		log.Printf("[WARN] No se pudieron limpiar los intentos de login antiguos: %v", err)
+	}
+	// Falla las importaciones interrumpidas al iniciar y después periódicamente
+	go csvimport.Watch(context.Background(), db)
+
 	r := gin.Default()
+	r.Use(middleware.RequestID())
 
 	// ----------- CORS dinámico según entorno -----------
 	allowOrigins := []string{"http://localhost:5173"}
@@ -49,14 +268,17 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     allowOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "Last-Event-ID", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "Link", middleware.RequestIDHeader},
 		AllowCredentials: true,
 	}))
 	// ---------------------------------------------------
 
 	// ----------- Setea todas las rutas y API -----------
-	routes.SetupRoutes(r, db)
+	// El hub del stream en vivo lee las lecturas y alertas nuevas de la base
+	hub := stream.NewHub(db)
+	go hub.Run(context.Background())
+	routes.SetupRoutes(r, db, hub)
 
 	// ----------- Puerto dinámico: local y nube -----------
 	port := os.Getenv("PORT")
@@ -4,53 +4,285 @@ import (
     "fmt"
     "os"
     "log"
+    "strconv"
+    "strings"
+    "time"
     "gorm.io/driver/postgres"
+    "gorm.io/driver/sqlite"
     "gorm.io/gorm"
 )
 
+// Drivers de base de datos soportados (DB_DRIVER)
+const (
+    DriverPostgres = "postgres"
+    DriverSQLite   = "sqlite"
+)
+
+// DevDBPath es el archivo SQLite que usa el modo --dev si no se define DB_PATH
+const DevDBPath = "sensor-dev.db"
+
 type Config struct {
+    DBDriver   string // DB_DRIVER: "postgres" (por defecto) o "sqlite"
+    DBPath     string // DB_PATH: archivo SQLite, o ":memory:" para una base en memoria
+    DBSSLMode  string // DB_SSLMODE: sslmode de Postgres, por defecto "require"
     DBHost     string
     DBPort     string
     DBUser     string
     DBPassword string
     DBName     string
+    Env        string // APP_ENV: "production" activa las validaciones estrictas
+    JWT        JWTConfig
+}
+
+// DefaultJWTSecret es el secreto de desarrollo; nunca se acepta en producción
+const DefaultJWTSecret = "mi_super_secreto"
+
+// MinJWTSecretLength es el largo mínimo de un secreto HS256 en producción
+const MinJWTSecretLength = 32
+
+// JWTConfig son las llaves para firmar y validar tokens. Puede haber varias activas
+// a la vez, identificadas por kid: se firma con ActiveKID y se valida con cualquiera,
+// lo que permite rotar sin invalidar las sesiones emitidas con la llave anterior.
+type JWTConfig struct {
+    ActiveKID  string
+    Secrets    map[string]string // kid -> secreto HS256
+    KeyFiles   map[string]string // kid -> archivo PEM con llave privada RSA (RS256) o Ed25519 (EdDSA)
+    TTL        time.Duration     // duración del access token (corta: se renueva con el refresh token)
+    RefreshTTL time.Duration     // inactividad máxima: cada refresh token vence tras este plazo
+    SessionTTL time.Duration     // duración máxima de una sesión, aunque se renueve
+}
+
+// IsProduction indica si se ejecuta con APP_ENV=production
+func (c *Config) IsProduction() bool {
+    return strings.EqualFold(c.Env, "production")
+}
+
+// Validate revisa la configuración JWT; en producción exige llaves propias
+func (j JWTConfig) Validate(production bool) error {
+    if len(j.Secrets) == 0 && len(j.KeyFiles) == 0 {
+        if production {
+            return fmt.Errorf("no hay llaves JWT configuradas (JWT_SECRET, JWT_SECRETS o JWT_PRIVATE_KEYS)")
+        }
+        return nil
+    }
+    if _, ok := j.Secrets[j.ActiveKID]; !ok {
+        if _, ok := j.KeyFiles[j.ActiveKID]; !ok {
+            return fmt.Errorf("JWT_ACTIVE_KID %q no corresponde a ninguna llave configurada", j.ActiveKID)
+        }
+    }
+    if production {
+        for kid, secret := range j.Secrets {
+            if secret == DefaultJWTSecret {
+                return fmt.Errorf("la llave JWT %q usa el secreto por defecto", kid)
+            }
+            if len(secret) < MinJWTSecretLength {
+                return fmt.Errorf("la llave JWT %q es muy corta (mínimo %d caracteres)", kid, MinJWTSecretLength)
+            }
+        }
+    }
+    return nil
+}
+
+// loadJWTConfig lee las llaves JWT del entorno:
+//   JWT_SECRET=secreto                      (una llave HS256 con kid "default")
+//   JWT_SECRETS=k2:secreto2,k1:secreto1     (varias llaves HS256)
+//   JWT_PRIVATE_KEYS=k3:/ruta/llave.pem     (llaves RS256/EdDSA, se publican en el JWKS)
+//   JWT_ACTIVE_KID=k2                       (llave con la que se firma; por defecto la primera)
+//   JWT_TTL=15m                             (duración de los access tokens)
+//   JWT_REFRESH_TTL=168h                    (vigencia de cada refresh token)
+//   JWT_SESSION_TTL=720h                    (duración máxima de una sesión)
+func loadJWTConfig() JWTConfig {
+    cfg := JWTConfig{
+        ActiveKID:  os.Getenv("JWT_ACTIVE_KID"),
+        Secrets:    map[string]string{},
+        KeyFiles:   map[string]string{},
+        TTL:        15 * time.Minute,
+        RefreshTTL: 7 * 24 * time.Hour,
+        SessionTTL: 30 * 24 * time.Hour,
+    }
+    first := ""
+    if secret := os.Getenv("JWT_SECRET"); secret != "" {
+        cfg.Secrets["default"] = secret
+        first = "default"
+    }
+    for _, list := range []struct {
+        env  string
+        dest map[string]string
+    }{{"JWT_SECRETS", cfg.Secrets}, {"JWT_PRIVATE_KEYS", cfg.KeyFiles}} {
+        for _, item := range strings.Split(os.Getenv(list.env), ",") {
+            kid, value, ok := strings.Cut(strings.TrimSpace(item), ":")
+            if !ok || kid == "" || value == "" {
+                if strings.TrimSpace(item) != "" {
+                    log.Printf("[WARN] %s: entrada inválida (se espera kid:valor)", list.env)
+                }
+                continue
+            }
+            list.dest[kid] = value
+            if first == "" {
+                first = kid
+            }
+        }
+    }
+    if cfg.ActiveKID == "" {
+        cfg.ActiveKID = first
+    }
+    for _, env := range []struct {
+        name string
+        dest *time.Duration
+    }{{"JWT_TTL", &cfg.TTL}, {"JWT_REFRESH_TTL", &cfg.RefreshTTL}, {"JWT_SESSION_TTL", &cfg.SessionTTL}} {
+        if d, err := time.ParseDuration(os.Getenv(env.name)); err == nil && d > 0 {
+            *env.dest = d
+        }
+    }
+    return cfg
 }
 
 func LoadConfig() *Config {
+    return loadConfig(false)
+}
+
+// LoadDevConfig es LoadConfig para desarrollo local: sin DB_DRIVER usa SQLite en
+// DevDBPath, así que no hace falta una base de datos externa
+func LoadDevConfig() *Config {
+    return loadConfig(true)
+}
+
+func loadConfig(dev bool) *Config {
     dbPort := os.Getenv("DB_PORT")
     if dbPort == "" {
         dbPort = "5432"
     }
 
     cfg := &Config{
+        DBDriver:   strings.ToLower(os.Getenv("DB_DRIVER")),
+        DBPath:     os.Getenv("DB_PATH"),
+        DBSSLMode:  os.Getenv("DB_SSLMODE"),
         DBHost:     os.Getenv("DB_HOST"),
         DBPort:     dbPort,
         DBUser:     os.Getenv("DB_USER"),
         DBPassword: os.Getenv("DB_PASSWORD"),
         DBName:     os.Getenv("DB_NAME"),
+        Env:        os.Getenv("APP_ENV"),
+        JWT:        loadJWTConfig(),
+    }
+    if cfg.DBDriver == "" {
+        cfg.DBDriver = DriverPostgres
+        if dev {
+            cfg.DBDriver = DriverSQLite
+        }
+    }
+    if cfg.DBDriver == DriverSQLite && cfg.DBPath == "" && dev {
+        cfg.DBPath = DevDBPath
+    }
+    if cfg.DBSSLMode == "" {
+        cfg.DBSSLMode = "require"
     }
 
     // Debug/log en desarrollo para detectar problemas de configuración
     if os.Getenv("APP_DEBUG") == "1" {
-        log.Printf("[DEBUG] Config: %+v", cfg)
+        log.Printf("[DEBUG] Config: driver=%s path=%s host=%s port=%s user=%s db=%s env=%s jwt_kid=%s",
+            cfg.DBDriver, cfg.DBPath, cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBName, cfg.Env, cfg.JWT.ActiveKID)
     }
 
     // Validación mínima: Detener si alguna variable crítica falta
-    if cfg.DBHost == "" || cfg.DBUser == "" || cfg.DBPassword == "" || cfg.DBName == "" {
-        log.Fatalf("ERROR: Faltan variables de entorno para la conexión a la base de datos. Revisar DB_HOST, DB_USER, DB_PASSWORD, DB_NAME, DB_PORT.")
+    switch cfg.DBDriver {
+    case DriverPostgres:
+        if cfg.DBHost == "" || cfg.DBUser == "" || cfg.DBPassword == "" || cfg.DBName == "" {
+            log.Fatalf("ERROR: Faltan variables de entorno para la conexión a la base de datos. Revisar DB_HOST, DB_USER, DB_PASSWORD, DB_NAME, DB_PORT (o usar DB_DRIVER=sqlite).")
+        }
+    case DriverSQLite:
+        if cfg.DBPath == "" {
+            log.Fatalf("ERROR: DB_DRIVER=sqlite requiere DB_PATH (archivo o :memory:).")
+        }
+    default:
+        log.Fatalf("ERROR: DB_DRIVER %q no soportado; use %s o %s.", cfg.DBDriver, DriverPostgres, DriverSQLite)
     }
 
     return cfg
 }
 
 func SetupDB(cfg *Config) *gorm.DB {
+    if cfg.DBDriver == DriverSQLite {
+        return setupSQLite(cfg.DBPath)
+    }
     dsn := fmt.Sprintf(
-        "host=%s user=%s password=%s dbname=%s port=%s sslmode=require",
-        cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort,
+        "host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+        cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort, cfg.DBSSLMode,
     )
     db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
     if err != nil {
-        panic(fmt.Sprintf("Failed to connect to database: %v\nDSN: %s", err, dsn))
+        panic(fmt.Sprintf("Failed to connect to database: %v\nhost=%s db=%s", err, cfg.DBHost, cfg.DBName))
+    }
+    return db
+}
+
+// setupSQLite abre la base local. SQLite guarda las fechas como texto con su zona
+// horaria y las compara como texto, así que el proceso completo trabaja en UTC.
+func setupSQLite(path string) *gorm.DB {
+    time.Local = time.UTC
+    dsn := path + "?_busy_timeout=5000"
+    if path != ":memory:" {
+        dsn += "&_journal_mode=WAL"
+    }
+    db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+    if err != nil {
+        panic(fmt.Sprintf("Failed to open SQLite database %s: %v", path, err))
+    }
+    if path == ":memory:" {
+        // Cada conexión a :memory: es una base distinta; se usa una sola
+        sqlDB, err := db.DB()
+        if err != nil {
+            panic(err)
+        }
+        sqlDB.SetMaxOpenConns(1)
     }
     return db
 }
+
+// MQTTConfig agrupa la configuración del servicio de ingesta MQTT
+type MQTTConfig struct {
+    BrokerURL     string
+    ClientID      string
+    Username      string
+    Password      string
+    Topics        []string
+    BatchSize     int
+    FlushInterval time.Duration
+    CompanyID     string // empresa por defecto si el topic no trae "tenant"
+}
+
+func LoadMQTTConfig() *MQTTConfig {
+    cfg := &MQTTConfig{
+        BrokerURL:     os.Getenv("MQTT_BROKER_URL"),
+        ClientID:      os.Getenv("MQTT_CLIENT_ID"),
+        Username:      os.Getenv("MQTT_USERNAME"),
+        Password:      os.Getenv("MQTT_PASSWORD"),
+        CompanyID:     os.Getenv("MQTT_COMPANY_ID"),
+        Topics:        []string{"tenant/+/camera/+/zone/+"},
+        BatchSize:     200,
+        FlushInterval: 2 * time.Second,
+    }
+    if cfg.ClientID == "" {
+        // ClientID fijo: con sesión persistente el broker guarda los mensajes QoS 1 mientras estamos caídos
+        cfg.ClientID = "sensor-api-ingest"
+    }
+    if topics := os.Getenv("MQTT_TOPICS"); topics != "" {
+        cfg.Topics = nil
+        for _, t := range strings.Split(topics, ",") {
+            if t = strings.TrimSpace(t); t != "" {
+                cfg.Topics = append(cfg.Topics, t)
+            }
+        }
+    }
+    if n, err := strconv.Atoi(os.Getenv("MQTT_BATCH_SIZE")); err == nil && n > 0 {
+        cfg.BatchSize = n
+    }
+    if d, err := time.ParseDuration(os.Getenv("MQTT_FLUSH_INTERVAL")); err == nil && d > 0 {
+        cfg.FlushInterval = d
+    }
+
+    if cfg.BrokerURL == "" {
+        log.Fatalf("ERROR: Falta MQTT_BROKER_URL (ej: tcp://localhost:1883) para la ingesta MQTT.")
+    }
+    return cfg
+}
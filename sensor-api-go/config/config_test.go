@@ -0,0 +1,48 @@
+package config
+
+import "testing"
+
+func TestJWTConfigValidate(t *testing.T) {
+	strong := "0123456789abcdef0123456789abcdef"
+	cases := []struct {
+		name       string
+		cfg        JWTConfig
+		production bool
+		wantErr    bool
+	}{
+		{"desarrollo sin llaves", JWTConfig{}, false, false},
+		{"producción sin llaves", JWTConfig{}, true, true},
+		{"producción con secreto por defecto", JWTConfig{ActiveKID: "default", Secrets: map[string]string{"default": DefaultJWTSecret}}, true, true},
+		{"producción con secreto corto", JWTConfig{ActiveKID: "k1", Secrets: map[string]string{"k1": "corto"}}, true, true},
+		{"producción con secreto fuerte", JWTConfig{ActiveKID: "k1", Secrets: map[string]string{"k1": strong}}, true, false},
+		{"kid activo inexistente", JWTConfig{ActiveKID: "k9", Secrets: map[string]string{"k1": strong}}, false, true},
+		{"llave asimétrica activa", JWTConfig{ActiveKID: "rsa", KeyFiles: map[string]string{"rsa": "/ruta/llave.pem"}}, true, false},
+	}
+	for _, tc := range cases {
+		err := tc.cfg.Validate(tc.production)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: error = %v, se esperaba error: %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestLoadJWTConfig(t *testing.T) {
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_SECRETS", "k2:dos:con:dos-puntos, k1:uno")
+	t.Setenv("JWT_PRIVATE_KEYS", "rsa:/ruta/llave.pem")
+	t.Setenv("JWT_ACTIVE_KID", "")
+	t.Setenv("JWT_TTL", "2h")
+	t.Setenv("JWT_REFRESH_TTL", "")
+	t.Setenv("JWT_SESSION_TTL", "48h")
+
+	cfg := loadJWTConfig()
+	if cfg.ActiveKID != "k2" || cfg.Secrets["k2"] != "dos:con:dos-puntos" || cfg.Secrets["k1"] != "uno" {
+		t.Errorf("Secretos mal leídos: %+v", cfg)
+	}
+	if cfg.KeyFiles["rsa"] != "/ruta/llave.pem" || cfg.TTL.Hours() != 2 {
+		t.Errorf("Llaves o TTL mal leídos: %+v", cfg)
+	}
+	if cfg.RefreshTTL.Hours() != 7*24 || cfg.SessionTTL.Hours() != 48 {
+		t.Errorf("Duraciones de sesión mal leídas: refresh %v, sesión %v", cfg.RefreshTTL, cfg.SessionTTL)
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sensor-api-go/aggregate"
+	"sensor-api-go/rollup"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AggregateResponse es la serie agregada de una zona
+type AggregateResponse struct {
+	CameraID int               `json:"camera_id"`
+	ZoneID   int               `json:"zone_id"`
+	Desde    time.Time         `json:"desde"`
+	Hasta    time.Time         `json:"hasta"`
+	Bucket   string            `json:"bucket"`
+	Funcs    []aggregate.Func  `json:"fn"`
+	Source   string            `json:"source"` // "raw" o la resolución de rollup: "1m", "1h", "1d"
+	Points   []aggregate.Point `json:"points"`
+}
+
+// AggregateZoneReadings retorna estadísticas por bucket de las lecturas de una zona:
+// GET /cameras/:camera_id/zones/:zone_id/aggregate?bucket=5m&fn=avg,min,max,p95&desde=&hasta=
+// Sin bucket se elige uno según el rango (unos 200 puntos); sin rango, las últimas 24 horas.
+// El rango se alinea a los buckets y los datos salen del rollup adecuado (paquete rollup).
+func AggregateZoneReadings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cameraID, err := strconv.Atoi(c.Param("camera_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "camera_id inválido"})
+			return
+		}
+		zoneID, err := strconv.Atoi(c.Param("zone_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "zone_id inválido"})
+			return
+		}
+		desde, hasta, err := timeRange(c, 24*time.Hour)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		funcs, err := aggregate.ParseFuncs(c.Query("fn"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		bucket := aggregate.AutoBucket(desde, hasta)
+		if s := c.Query("bucket"); s != "" {
+			if bucket, err = aggregate.ParseBucket(s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if err := aggregate.CheckRange(desde, hasta, bucket); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		result, err := rollup.Series(db, rollup.Query{
+			CompanyID: companyID,
+			CameraID:  cameraID,
+			ZoneID:    zoneID,
+			From:      desde,
+			To:        hasta,
+			Bucket:    bucket,
+			Funcs:     funcs,
+		}, time.Now())
+		if errors.Is(err, rollup.ErrRawExpired) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron agregar las lecturas"})
+			return
+		}
+		c.JSON(http.StatusOK, AggregateResponse{
+			CameraID: cameraID,
+			ZoneID:   zoneID,
+			Desde:    result.From,
+			Hasta:    result.To,
+			Bucket:   aggregate.FormatBucket(bucket),
+			Funcs:    funcs,
+			Source:   result.Source,
+			Points:   result.Points,
+		})
+	}
+}
+
+// timeRange lee desde/hasta (RFC3339) de la query, en UTC. Sin hasta usa el momento
+// actual y sin desde, hasta menos def.
+func timeRange(c *gin.Context, def time.Duration) (desde, hasta time.Time, err error) {
+	hasta = time.Now().UTC()
+	if s := c.Query("hasta"); s != "" {
+		if hasta, err = time.Parse(time.RFC3339, s); err != nil {
+			return desde, hasta, fmt.Errorf("hasta inválido; use formato RFC3339")
+		}
+	}
+	desde = hasta.Add(-def)
+	if s := c.Query("desde"); s != "" {
+		if desde, err = time.Parse(time.RFC3339, s); err != nil {
+			return desde, hasta, fmt.Errorf("desde inválido; use formato RFC3339")
+		}
+	}
+	return desde.UTC(), hasta.UTC(), nil
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+)
+
+func TestAggregateZoneReadings(t *testing.T) {
+	f := newTenantFixture(t)
+	base := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+	for i, temp := range []float64{10, 20, 30} {
+		f.db.Create(&models.CameraReading{CompanyID: f.a, CameraID: 1, ZoneID: 1, Temperature: temp, Timestamp: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	path := "/api/cameras/1/zones/1/aggregate"
+	w := f.do(f.tokenA, "GET", path+"?bucket=1h&fn=avg,max,p50&desde="+base.Format(time.RFC3339), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Agregado: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var resp AggregateResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	// El bucket de hace dos horas y el de la lectura del fixture (hace un minuto)
+	if resp.Bucket != "1h" || len(resp.Points) != 2 {
+		t.Fatalf("Respuesta inesperada: %s", w.Body.String())
+	}
+	if p := resp.Points[0]; !p.Start.Equal(base) || p.Count != 3 || p.Values["avg"] != 20 || p.Values["max"] != 30 || p.Values["p50"] != 20 {
+		t.Errorf("Primer bucket inesperado: %+v", p)
+	}
+
+	// Sin bucket se elige según el rango: 24 horas por defecto, en buckets de 15 minutos
+	json.Unmarshal(f.do(f.tokenA, "GET", path, "").Body.Bytes(), &resp)
+	if resp.Bucket != "15m" || len(resp.Funcs) != 1 || resp.Funcs[0] != "avg" {
+		t.Errorf("Bucket automático inesperado: %s %v", resp.Bucket, resp.Funcs)
+	}
+
+	// B tiene su propia cámara 1 zona 1 y no ve las lecturas de A
+	json.Unmarshal(f.do(f.tokenB, "GET", path+"?bucket=1d", "").Body.Bytes(), &resp)
+	if len(resp.Points) != 1 || resp.Points[0].Count != 1 || resp.Points[0].Values["avg"] != 40 {
+		t.Errorf("B debe ver solo su lectura: %+v", resp.Points)
+	}
+
+	for _, query := range []string{"?bucket=10s", "?fn=sum", "?desde=ayer", "?bucket=1m&desde=2020-01-01T00:00:00Z", "?desde=2020-01-02T00:00:00Z&hasta=2020-01-01T00:00:00Z"} {
+		if w := f.do(f.tokenA, "GET", path+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: esperado 400, fue %d", query, w.Code)
+		}
+	}
+}
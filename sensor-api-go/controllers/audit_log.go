@@ -0,0 +1,126 @@
+// controllers/audit_log.go
+
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sensor-api-go/export"
+	"sensor-api-go/models"
+	"sensor-api-go/pagination"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// auditFilters aplica los filtros de la consulta: actor_id, action, target_type,
+// target_id, request_id y el rango desde/hasta (RFC3339)
+func auditFilters(c *gin.Context, tdb *gorm.DB) (*gorm.DB, error) {
+	q := tdb.Model(&models.AuditLog{})
+	if s := c.Query("actor_id"); s != "" {
+		actorID, err := uuid.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("actor_id inválido")
+		}
+		q = q.Where("actor_id = ?", actorID)
+	}
+	for _, col := range []string{"action", "target_type", "target_id", "request_id"} {
+		if s := c.Query(col); s != "" {
+			q = q.Where(col+" = ?", s)
+		}
+	}
+	for param, cond := range map[string]string{"desde": "created_at >= ?", "hasta": "created_at < ?"} {
+		if s := c.Query(param); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("%s inválido; use formato RFC3339", param)
+			}
+			q = q.Where(cond, t.UTC())
+		}
+	}
+	return q, nil
+}
+
+// GET /api/audit-log
+func ListAuditLog(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		q, err := auditFilters(c, tdb)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		entries, next, ok := findPage(c, q, "created_at", "id", func(e models.AuditLog) pagination.Cursor {
+			return pagination.Cursor{Time: e.CreatedAt, ID: e.ID.String()}
+		})
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": entries, "next_cursor": next})
+	}
+}
+
+// auditCSVHeader son las columnas de la exportación
+var auditCSVHeader = []string{
+	"created_at", "actor_id", "actor_email", "action", "target_type", "target_id",
+	"method", "path", "status", "ip", "request_id", "changes", "details",
+}
+
+// GET /api/audit-log/export
+// Exporta en CSV todas las entradas que cumplen los filtros, de la más antigua a
+// la más nueva. Las filas se leen y escriben de a una, sin cargar todo en memoria.
+func ExportAuditLog(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tdb, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		q, err := auditFilters(c, tdb)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rows, err := q.Order("created_at ASC").Order("id ASC").Rows()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo exportar el registro de auditoría"})
+			return
+		}
+		defer rows.Close()
+
+		filename := fmt.Sprintf("auditoria-%s-%s.csv", companyID, time.Now().UTC().Format("20060102"))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Status(http.StatusOK)
+
+		w := csv.NewWriter(c.Writer)
+		w.Write(auditCSVHeader)
+		for rows.Next() {
+			var e models.AuditLog
+			if err := db.ScanRows(rows, &e); err != nil {
+				log.Printf("[ERROR] Exportación de auditoría interrumpida: %v", err)
+				break
+			}
+			actorID := ""
+			if e.ActorID != nil {
+				actorID = e.ActorID.String()
+			}
+			// Los textos pueden venir del usuario (emails, IDs, rutas): se escapan
+			// para que Excel no los interprete como fórmulas
+			w.Write([]string{
+				e.CreatedAt.UTC().Format(time.RFC3339), actorID, export.CSVText(e.ActorEmail), export.CSVText(e.Action),
+				export.CSVText(e.TargetType), export.CSVText(e.TargetID), export.CSVText(e.Method), export.CSVText(e.Path),
+				strconv.Itoa(e.Status), export.CSVText(e.IP), export.CSVText(e.RequestID), export.CSVText(e.Changes), export.CSVText(e.Details),
+			})
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+)
+
+type auditPage struct {
+	Items      []models.AuditLog `json:"items"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+func listAudit(t *testing.T, f *tenantFixture, token, query string) auditPage {
+	t.Helper()
+	w := f.do(token, "GET", "/api/audit-log"+query, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Audit log: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var page auditPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Respuesta inválida: %v", err)
+	}
+	return page
+}
+
+func TestAuditLog_RecordsMutationsAndLogins(t *testing.T) {
+	f := newTenantFixture(t)
+	setTestPassword(t, f, f.userA, "Actual2024clave")
+	passwordLogin(t, f, f.userA, "Actual2024clave")
+
+	alertPath := "/api/zone-alerts/" + f.alertA.ID.String()
+	body := fmt.Sprintf(`{"zone_id": "%s", "upper_thresh": 60, "lower_thresh": 0, "recipient": "a@example.com"}`, f.zoneA.ID)
+	w := f.do(f.tokenA, "PUT", alertPath, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Editar alerta: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	requestID := w.Header().Get("X-Request-ID")
+	if requestID == "" {
+		t.Fatal("La respuesta debe traer X-Request-ID")
+	}
+	if w := f.do(f.tokenA, "PUT", "/api/zone-alerts/no-es-uuid", body); w.Code != http.StatusBadRequest {
+		t.Fatalf("Editar alerta inválida: esperado 400, fue %d", w.Code)
+	}
+	if w := f.do(f.tokenA, "DELETE", alertPath, ""); w.Code != http.StatusOK {
+		t.Fatalf("Eliminar alerta: esperado 200, fue %d", w.Code)
+	}
+
+	logins := listAudit(t, f, f.tokenA, "?action=auth.login").Items
+	if len(logins) != 1 || logins[0].ActorEmail != f.userA.Email || logins[0].TargetID != f.userA.ID.String() {
+		t.Errorf("El login debe quedar registrado con su actor: %+v", logins)
+	}
+
+	entries := listAudit(t, f, f.tokenA, "?target_type=zone_alert").Items
+	if len(entries) != 2 {
+		t.Fatalf("Esperadas 2 entradas de la alerta (la solicitud fallida no cuenta), fueron %d: %+v", len(entries), entries)
+	}
+	deleted, updated := entries[0], entries[1]
+	if deleted.Action != "zone_alert.deleted" || updated.Action != "zone_alert.updated" {
+		t.Errorf("Acciones inesperadas: %s, %s", deleted.Action, updated.Action)
+	}
+	if updated.RequestID != requestID || updated.Method != "PUT" || updated.Path != alertPath || updated.Status != http.StatusOK {
+		t.Errorf("Datos de la solicitud incompletos: %+v", updated)
+	}
+	if updated.ActorID == nil || *updated.ActorID != f.userA.ID || updated.CompanyID != f.a {
+		t.Errorf("Actor o empresa incorrectos: %+v", updated)
+	}
+	var changes map[string]struct {
+		Before interface{} `json:"before"`
+		After  interface{} `json:"after"`
+	}
+	json.Unmarshal([]byte(updated.Changes), &changes)
+	if ch := changes["UpperThresh"]; ch.Before != 50.0 || ch.After != 60.0 {
+		t.Errorf("El cambio de umbral debe tener antes y después: %s", updated.Changes)
+	}
+	if _, ok := changes["Recipient"]; ok {
+		t.Errorf("Solo se registran los campos que cambiaron: %s", updated.Changes)
+	}
+
+	// Otra empresa no ve el registro de A
+	if page := listAudit(t, f, f.tokenB, ""); len(page.Items) != 0 {
+		t.Errorf("B no debe ver entradas de A: %+v", page.Items)
+	}
+
+	// El registro no se puede editar ni borrar
+	if err := f.db.Model(&updated).Update("action", "otra").Error; err == nil {
+		t.Error("Editar una entrada de auditoría debe fallar")
+	}
+	if err := f.db.Delete(&updated).Error; err == nil {
+		t.Error("Borrar una entrada de auditoría debe fallar")
+	}
+}
+
+func TestAuditLog_DiffRedactsSecrets(t *testing.T) {
+	f := newTenantFixture(t)
+	body := `{"enabled": true, "issuer": "https://login.acme.com", "client_id": "instrumining", "client_secret": "Secreto2024idp"}`
+	if w := f.do(f.tokenA, "PUT", "/api/company/sso", body); w.Code != http.StatusOK {
+		t.Fatalf("Configurar SSO: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	entries := listAudit(t, f, f.tokenA, "?action=company.sso_updated").Items
+	if len(entries) != 1 {
+		t.Fatalf("Esperada 1 entrada, fueron %d", len(entries))
+	}
+	if strings.Contains(entries[0].Changes, "Secreto2024idp") || !strings.Contains(entries[0].Changes, "login.acme.com") {
+		t.Errorf("El client_secret no debe quedar en el registro: %s", entries[0].Changes)
+	}
+}
+
+func TestAuditLog_PaginationAndExport(t *testing.T) {
+	f := newTenantFixture(t)
+	for i := 0; i < 3; i++ {
+		if w := f.do(f.tokenA, "POST", "/api/logout", ""); i == 0 && w.Code != http.StatusOK {
+			t.Fatalf("Logout: esperado 200, fue %d", w.Code)
+		}
+		f.tokenA, _ = f.login(t, f.userA)
+	}
+
+	w := f.do(f.tokenA, "GET", "/api/audit-log?limit=2", "")
+	var first auditPage
+	json.Unmarshal(w.Body.Bytes(), &first)
+	if len(first.Items) != 2 || first.NextCursor == "" || !strings.Contains(w.Header().Get("Link"), `rel="next"`) {
+		t.Fatalf("Primera página: %d entradas, cursor %q, Link %q", len(first.Items), first.NextCursor, w.Header().Get("Link"))
+	}
+	second := listAudit(t, f, f.tokenA, "?limit=2&cursor="+first.NextCursor)
+	if len(second.Items) != 1 || second.NextCursor != "" || second.Items[0].ID == first.Items[1].ID {
+		t.Errorf("Segunda página inesperada: %+v", second)
+	}
+	if w := f.do(f.tokenA, "GET", "/api/audit-log?limit=5000", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("limit mayor al máximo: esperado 400, fue %d", w.Code)
+	}
+	if w := f.do(f.tokenA, "GET", "/api/audit-log?cursor=xyz", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Cursor inválido: esperado 400, fue %d", w.Code)
+	}
+
+	w = f.do(f.tokenA, "GET", "/api/audit-log/export?action=POST+/api/logout", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("Exportación: esperado CSV, fue %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV inválido: %v", err)
+	}
+	if len(records) != 4 || records[0][0] != "created_at" || records[1][3] != "POST /api/logout" {
+		t.Errorf("CSV inesperado: %v", records)
+	}
+}
+
+func TestAuditLog_ExportEscapesFormulas(t *testing.T) {
+	f := newTenantFixture(t)
+	f.db.Create(&models.AuditLog{
+		ID: uuid.New(), CompanyID: f.a, ActorEmail: "=HYPERLINK(\"http://x\")@example.com", Action: "user.updated",
+		TargetType: "user", TargetID: "+1", Path: "-x", CreatedAt: time.Now(),
+	})
+	w := f.do(f.tokenA, "GET", "/api/audit-log/export?action=user.updated", "")
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("CSV inesperado (%v): %v", err, records)
+	}
+	if got := records[1]; got[2] != `'=HYPERLINK("http://x")@example.com` || got[5] != "'+1" || got[7] != "'-x" || got[3] != "user.updated" {
+		t.Errorf("Los textos que parecen fórmulas deben quedar escapados: %v", got)
+	}
+}
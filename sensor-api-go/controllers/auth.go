@@ -1,10 +1,19 @@
 package controllers
 
 import (
+    "errors"
+    "log"
+    "math"
     "net/http"
+    "strconv"
+    "time"
+    "sensor-api-go/audit"
+    "sensor-api-go/loginguard"
     "sensor-api-go/models"
+    "sensor-api-go/sessions"
     "sensor-api-go/utils"
     "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
     "gorm.io/gorm"
     "golang.org/x/crypto/bcrypt"
 )
@@ -22,21 +31,170 @@ func Login(db *gorm.DB) gin.HandlerFunc {
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
             return
         }
+        now := time.Now()
+        ip := c.ClientIP()
+        if err := loginguard.CheckIP(db, ip, now); err != nil {
+            respondLoginBlocked(c, err)
+            return
+        }
         var user models.User
         // Ahora busca por email **y** company_id
         if err := db.Where("email = ? AND company_id = ?", input.Email, input.CompanyID).First(&user).Error; err != nil {
+            companyID, _ := uuid.Parse(input.CompanyID)
+            recordLoginFailure(db, companyID, input.Email, ip, nil)
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Email, empresa o contraseña incorrectos"})
             return
         }
+        // Durante la demora o el bloqueo ni siquiera se verifica la contraseña
+        if err := loginguard.CheckAccount(user, now); err != nil {
+            respondLoginBlocked(c, err)
+            return
+        }
         if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+            recordLoginFailure(db, user.CompanyID, user.Email, ip, &user)
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Email, empresa o contraseña incorrectos"})
             return
         }
-        token, err := utils.GenerateJWT(user.ID.String(), user.Email, user.CompanyID.String(), user.Role)
+        if user.Status != "Active" {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Usuario inactivo"})
+            return
+        }
+        enforced, err := ssoEnforced(db, user.CompanyID)
+        if err != nil {
+            log.Printf("[ERROR] No se pudo leer la configuración SSO de %s: %v", user.CompanyID, err)
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+            return
+        }
+        if enforced {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Su empresa exige iniciar sesión con SSO", "code": "sso_required"})
+            return
+        }
+        // Con 2FA (propio u obligatorio por la empresa) el login sigue en /login/mfa.
+        // Los fallos se limpian recién cuando el login termina: si no, repetir la
+        // contraseña reiniciaría el contador de códigos 2FA incorrectos.
+        if challenged := respondMFAChallenge(c, db, user); challenged {
+            return
+        }
+        clearLoginFailures(db, user)
+        session, refreshToken, err := sessions.Start(db, user, c.Request.UserAgent(), c.ClientIP())
         if err != nil {
+            log.Printf("[ERROR] No se pudo abrir la sesión de %s: %v", user.ID, err)
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
             return
         }
-        c.JSON(http.StatusOK, gin.H{"token": token})
+        describeLogin(c, user, session, "password")
+        respondTokens(c, user, session, refreshToken)
+    }
+}
+
+type RefreshInput struct {
+    RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Refresh canjea el refresh token por un access token nuevo y un refresh token nuevo.
+// El refresh token anterior queda usado; volver a presentarlo revoca la sesión.
+func Refresh(db *gorm.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var input RefreshInput
+        if err := c.ShouldBindJSON(&input); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        session, user, refreshToken, err := sessions.Refresh(db, input.RefreshToken, time.Now())
+        switch {
+        case errors.Is(err, sessions.ErrInvalidToken), errors.Is(err, sessions.ErrTokenReused),
+            errors.Is(err, sessions.ErrSessionRevoked), errors.Is(err, sessions.ErrUserInactive):
+            c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+            return
+        case err != nil:
+            log.Printf("[ERROR] No se pudo renovar la sesión: %v", err)
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo renovar la sesión"})
+            return
+        }
+        respondTokens(c, user, session, refreshToken)
+    }
+}
+
+// Logout revoca la sesión del token usado en la solicitud
+func Logout(db *gorm.DB) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        sessionID, err := uuid.Parse(c.GetString("session_id"))
+        if err != nil {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Token sin sesión"})
+            return
+        }
+        if err := sessions.Revoke(db, sessionID, sessions.ReasonLogout); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo cerrar la sesión"})
+            return
+        }
+        c.JSON(http.StatusOK, gin.H{"message": "Sesión cerrada"})
+    }
+}
+
+// recordLoginFailure registra el intento fallido; un error al registrarlo no cambia la respuesta
+func recordLoginFailure(db *gorm.DB, companyID uuid.UUID, email, ip string, user *models.User) {
+    if err := loginguard.Fail(db, companyID, email, ip, user, time.Now()); err != nil {
+        log.Printf("[ERROR] No se pudo registrar el intento fallido de %s: %v", email, err)
+    }
+}
+
+// clearLoginFailures limpia los fallos seguidos tras un login completo; un error al
+// limpiarlos no cambia la respuesta
+func clearLoginFailures(db *gorm.DB, user models.User) {
+    if err := loginguard.Succeed(db, user); err != nil {
+        log.Printf("[WARN] No se pudieron limpiar los intentos fallidos de %s: %v", user.ID, err)
+    }
+}
+
+// describeLogin deja el login completo en el registro de auditoría; method indica
+// cómo se autenticó ("password", "mfa" o "sso")
+func describeLogin(c *gin.Context, user models.User, session models.Session, method string) {
+    audit.Describe(c, audit.Target{
+        Action:     audit.ActionLogin,
+        Type:       "user",
+        ID:         user.ID.String(),
+        CompanyID:  &user.CompanyID,
+        ActorID:    &user.ID,
+        ActorEmail: user.Email,
+        Details:    map[string]interface{}{"method": method, "session_id": session.ID.String()},
+    })
+}
+
+// respondLoginBlocked responde 423 si la cuenta está bloqueada y 429 si debe esperar
+func respondLoginBlocked(c *gin.Context, err error) {
+    var blocked *loginguard.BlockedError
+    if !errors.As(err, &blocked) {
+        log.Printf("[ERROR] No se pudieron verificar los intentos de login: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+        return
+    }
+    c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+    status := http.StatusTooManyRequests
+    if blocked.Locked {
+        status = http.StatusLocked
+    }
+    c.JSON(status, gin.H{"error": blocked.Error()})
+}
+
+// respondTokens emite el access token de la sesión junto al refresh token en claro
+func respondTokens(c *gin.Context, user models.User, session models.Session, refreshToken string) {
+    respondTokensWith(c, user, session, refreshToken, nil)
+}
+
+// respondTokensWith agrega campos a la respuesta de tokens (p. ej. códigos de recuperación)
+func respondTokensWith(c *gin.Context, user models.User, session models.Session, refreshToken string, extra gin.H) {
+    token, err := utils.GenerateJWT(user.ID.String(), user.Email, user.CompanyID.String(), user.Role, session.ID.String())
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+        return
+    }
+    resp := gin.H{
+        "token":         token,
+        "refresh_token": refreshToken,
+        "expires_in":    int(utils.AccessTTL().Seconds()),
+    }
+    for k, v := range extra {
+        resp[k] = v
     }
+    c.JSON(http.StatusOK, resp)
 }
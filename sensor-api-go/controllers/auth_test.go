@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"sensor-api-go/models"
+	"sensor-api-go/sessions"
+
+	"github.com/google/uuid"
+)
+
+func decodeTokens(t *testing.T, body []byte) (string, string) {
+	t.Helper()
+	var resp struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Token == "" || resp.RefreshToken == "" {
+		t.Fatalf("Respuesta sin tokens: %s", body)
+	}
+	return resp.Token, resp.RefreshToken
+}
+
+func TestRefresh_RotatesAndDetectsReuse(t *testing.T) {
+	f := newTenantFixture(t)
+	_, refresh := f.login(t, f.userA)
+	body := fmt.Sprintf(`{"refresh_token": "%s"}`, refresh)
+
+	w := f.do("", "POST", "/api/refresh", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Refresh: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	token, next := decodeTokens(t, w.Body.Bytes())
+	if next == refresh {
+		t.Fatal("El refresh token debe rotar")
+	}
+	if w := f.do(token, "GET", "/api/users", ""); w.Code != http.StatusOK {
+		t.Errorf("El access token renovado debe ser válido, fue %d", w.Code)
+	}
+
+	// Reusar el token anterior revoca la sesión completa, incluido el token nuevo
+	if w := f.do("", "POST", "/api/refresh", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("Reuso de refresh token: esperado 401, fue %d", w.Code)
+	}
+	if w := f.do("", "POST", "/api/refresh", fmt.Sprintf(`{"refresh_token": "%s"}`, next)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Refresh en sesión revocada: esperado 401, fue %d", w.Code)
+	}
+	if w := f.do(token, "GET", "/api/users", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Access token de sesión revocada: esperado 401, fue %d", w.Code)
+	}
+	var session models.Session
+	f.db.Where("user_id = ? AND revoked_reason = ?", f.userA.ID, sessions.ReasonTokenReuse).First(&session)
+	if session.RevokedAt == nil {
+		t.Error("La sesión debe quedar revocada por reuso")
+	}
+}
+
+func TestLogout_RevokesSession(t *testing.T) {
+	f := newTenantFixture(t)
+	token, refresh := f.login(t, f.userA)
+
+	if w := f.do(token, "POST", "/api/logout", ""); w.Code != http.StatusOK {
+		t.Fatalf("Logout: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	if w := f.do(token, "GET", "/api/users", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Token después del logout: esperado 401, fue %d", w.Code)
+	}
+	if w := f.do("", "POST", "/api/refresh", fmt.Sprintf(`{"refresh_token": "%s"}`, refresh)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Refresh después del logout: esperado 401, fue %d", w.Code)
+	}
+	// Las demás sesiones del usuario siguen activas
+	if w := f.do(f.tokenA, "GET", "/api/users", ""); w.Code != http.StatusOK {
+		t.Errorf("Otra sesión del usuario: esperado 200, fue %d", w.Code)
+	}
+}
+
+func TestUserDeactivationAndDeletion_RevokeSessions(t *testing.T) {
+	f := newTenantFixture(t)
+	viewer := models.User{ID: uuid.New(), CompanyID: f.a, Name: "V", Email: "v@example.com", Password: "x", Role: "viewer", Status: "Active"}
+	f.db.Create(&viewer)
+	token, refresh := f.login(t, viewer)
+
+	if w := f.do(f.tokenA, "PUT", "/api/users/"+viewer.ID.String(), `{"status": "Inactive"}`); w.Code != http.StatusOK {
+		t.Fatalf("Desactivar: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	if w := f.do(token, "GET", "/api/camera-readings", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Token de usuario desactivado: esperado 401, fue %d", w.Code)
+	}
+	if w := f.do("", "POST", "/api/refresh", fmt.Sprintf(`{"refresh_token": "%s"}`, refresh)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Refresh de usuario desactivado: esperado 401, fue %d", w.Code)
+	}
+
+	// Reactivado, puede volver a entrar; eliminarlo corta la sesión nueva
+	f.do(f.tokenA, "PUT", "/api/users/"+viewer.ID.String(), `{"status": "Active"}`)
+	viewer.Status = "Active"
+	token, _ = f.login(t, viewer)
+	if w := f.do(f.tokenA, "DELETE", "/api/users/"+viewer.ID.String(), ""); w.Code != http.StatusOK {
+		t.Fatalf("Eliminar: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	if w := f.do(token, "GET", "/api/camera-readings", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Token de usuario eliminado: esperado 401, fue %d", w.Code)
+	}
+}
@@ -1,32 +1,92 @@
 package controllers
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"sensor-api-go/aggregate"
 	"sensor-api-go/models"
+	"sensor-api-go/pagination"
+	"sensor-api-go/rollup"
+	"sensor-api-go/zones"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-// --- HANDLER: Traer lecturas de cámaras (clásico) ---
+// --- HANDLER: Lecturas de cámaras, paginadas por cursor ---
+// GET /api/camera-readings?camera_id=&zone_id=&desde=&hasta=&min_temp=&max_temp=&limit=&cursor=
+// De la más nueva a la más antigua; el cursor de next_cursor (o el Link rel="next")
+// pide la página siguiente con los mismos filtros.
 func GetCameraReadings(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var readings []models.CameraReading
-		if err := db.Find(&readings).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron obtener las lecturas"})
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
 			return
 		}
-		c.JSON(http.StatusOK, readings)
+		q, err := readingFilters(c, tdb)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		readings, next, ok := findPage(c, q, "timestamp", "id", func(r models.CameraReading) pagination.Cursor {
+			return pagination.Cursor{Time: r.Timestamp, ID: strconv.FormatUint(uint64(r.ID), 10)}
+		})
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": readings, "next_cursor": next})
+	}
+}
+
+// readingFilters aplica los filtros de lecturas: camera_id, zone_id, el rango
+// desde/hasta (RFC3339, hasta excluido) y la temperatura min_temp/max_temp (incluidas)
+func readingFilters(c *gin.Context, tdb *gorm.DB) (*gorm.DB, error) {
+	q := tdb.Model(&models.CameraReading{})
+	for _, param := range []string{"camera_id", "zone_id"} {
+		if s := c.Query(param); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s inválido", param)
+			}
+			q = q.Where(param+" = ?", n)
+		}
+	}
+	for _, f := range [][2]string{{"desde", "timestamp >= ?"}, {"hasta", "timestamp < ?"}} {
+		param, cond := f[0], f[1]
+		if s := c.Query(param); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("%s inválido; use formato RFC3339", param)
+			}
+			q = q.Where(cond, t.UTC())
+		}
+	}
+	for _, f := range [][2]string{{"min_temp", "temperature >= ?"}, {"max_temp", "temperature <= ?"}} {
+		param, cond := f[0], f[1]
+		if s := c.Query(param); s != "" {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("%s inválido", param)
+			}
+			q = q.Where(cond, v)
+		}
 	}
+	return q, nil
 }
 
 // --- HANDLER: Listar cámaras únicas ---
 func ListUniqueCameras(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
 		var cameraIDs []int
-		if err := db.Raw("SELECT DISTINCT camera_id FROM camera_readings").Scan(&cameraIDs).Error; err != nil {
+		if err := tdb.Model(&models.CameraReading{}).Distinct("camera_id").Order("camera_id").Pluck("camera_id", &cameraIDs).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron obtener las cámaras"})
 			return
 		}
@@ -42,8 +102,12 @@ func ListZonasByCamera(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "camera_id inválido"})
 			return
 		}
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
 		var zonas []int
-		if err := db.Raw("SELECT DISTINCT zone_id FROM camera_readings WHERE camera_id = ?", cameraID).Scan(&zonas).Error; err != nil {
+		if err := cameraZones(tdb, cameraID, &zonas); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron obtener las zonas"})
 			return
 		}
@@ -51,9 +115,19 @@ func ListZonasByCamera(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// cameraZones obtiene los zone_id distintos de una cámara, en orden
+func cameraZones(tdb *gorm.DB, cameraID int, zonas *[]int) error {
+	return tdb.Model(&models.CameraReading{}).
+		Where("camera_id = ?", cameraID).
+		Distinct("zone_id").
+		Order("zone_id").
+		Pluck("zone_id", zonas).Error
+}
+
 // --- NUEVO: Dashboard resumen rápido ---
 type ZoneStatus struct {
 	ZoneID   int                    `json:"zone_id"`
+	ZoneUUID *uuid.UUID             `json:"zone_uuid,omitempty"` // id en el registro de zonas, usado por las alertas
 	LastTemp *float64               `json:"last_temp,omitempty"`
 	LastTime *time.Time             `json:"last_time,omitempty"`
 	State    string                 `json:"state"`
@@ -75,41 +149,24 @@ func CameraSummaryDashboard(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "camera_id inválido"})
 			return
 		}
-		var zonas []int
-		err = db.Raw(`
-            SELECT DISTINCT zone_id 
-            FROM camera_readings 
-            WHERE camera_id = ?
-            ORDER BY zone_id
-        `, cameraID).Scan(&zonas).Error
+		tdb, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		zonas, last, err := lastZoneReadings(tdb, cameraID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		registered, err := zones.ByCamera(db, companyID, cameraID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		now := time.Now()
 		zonasStatus := make([]ZoneStatus, 0, len(zonas))
 		for _, z := range zonas {
-			var last models.CameraReading
-			db.Where("camera_id = ? AND zone_id = ?", cameraID, z).
-				Order("timestamp DESC").
-				Limit(1).
-				First(&last)
-			var lastTemp *float64
-			var lastTime *time.Time
-			if last.Temperature >= minTemp && last.Temperature <= maxTemp {
-				lastTemp = &last.Temperature
-				lastTime = &last.Timestamp
-			}
-			state := "Inactivo"
-			if lastTime != nil && lastTime.After(time.Now().Add(-10*time.Minute)) {
-				state = "Activo"
-			}
-			zonasStatus = append(zonasStatus, ZoneStatus{
-				ZoneID:   z,
-				LastTemp: lastTemp,
-				LastTime: lastTime,
-				State:    state,
-				Readings: nil,
-			})
+			zonasStatus = append(zonasStatus, zoneStatus(z, registered, last[z], now))
 		}
 		resp := CameraDashboard{
 			CameraID: cameraID,
@@ -119,6 +176,46 @@ func CameraSummaryDashboard(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// lastZoneReadings retorna las zonas de la cámara, en orden, y la última lectura de cada una
+func lastZoneReadings(tdb *gorm.DB, cameraID int) ([]int, map[int]models.CameraReading, error) {
+	var zonas []int
+	if err := cameraZones(tdb, cameraID, &zonas); err != nil {
+		return nil, nil, err
+	}
+	last := make(map[int]models.CameraReading, len(zonas))
+	for _, z := range zonas {
+		var r models.CameraReading
+		tdb.Where("camera_id = ? AND zone_id = ?", cameraID, z).
+			Order("timestamp DESC").
+			Limit(1).
+			First(&r)
+		last[z] = r
+	}
+	return zonas, last, nil
+}
+
+// zoneStatus es el estado de la zona según su última lectura: Activo si tiene una
+// lectura válida de los últimos 10 minutos. El stream en vivo usa la misma regla.
+func zoneStatus(z int, registered map[int]models.Zone, last models.CameraReading, now time.Time) ZoneStatus {
+	var lastTemp *float64
+	var lastTime *time.Time
+	if last.Temperature >= minTemp && last.Temperature <= maxTemp {
+		lastTemp = &last.Temperature
+		lastTime = &last.Timestamp
+	}
+	state := "Inactivo"
+	if lastTime != nil && lastTime.After(now.Add(-10*time.Minute)) {
+		state = "Activo"
+	}
+	return ZoneStatus{
+		ZoneID:   z,
+		ZoneUUID: registeredID(registered, z),
+		LastTemp: lastTemp,
+		LastTime: lastTime,
+		State:    state,
+	}
+}
+
 // --- Dashboard histórico con rango de fechas ---
 func CameraStatusDashboard(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -140,43 +237,59 @@ func CameraStatusDashboard(db *gorm.DB) gin.HandlerFunc {
 		} else {
 			hasta = time.Now()
 		}
+		// Las lecturas se guardan en UTC; SQLite compara fechas como texto
+		desde, hasta = desde.UTC(), hasta.UTC()
+		tdb, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
 		var zonas []int
-		err = db.Raw(`
-            SELECT DISTINCT zone_id 
-            FROM camera_readings 
-            WHERE camera_id = ?
-            ORDER BY zone_id
-        `, cameraID).Scan(&zonas).Error
+		if err := cameraZones(tdb, cameraID, &zonas); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		registered, err := zones.ByCamera(db, companyID, cameraID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		// Los rangos largos, o anteriores a la retención de lecturas, se entregan
+		// promediados por bucket desde los rollups en vez de lectura por lectura
+		var company models.Company
+		if err := db.First(&company, "id = ?", companyID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		summarize := hasta.Sub(desde) > maxRawDashboardRange || desde.Before(rollup.RawCutoff(company, time.Now()))
 		zonasStatus := make([]ZoneStatus, 0, len(zonas))
 		for _, z := range zonas {
 			var readings []models.CameraReading
-			db.Where("camera_id = ? AND zone_id = ? AND timestamp >= ? AND timestamp <= ?", cameraID, z, desde, hasta).
-				Order("timestamp").
-				Find(&readings)
-			var lastTemp *float64
-			var lastTime *time.Time
-			if len(readings) > 0 {
-				lr := readings[len(readings)-1]
-				if lr.Temperature >= minTemp && lr.Temperature <= maxTemp {
-					lastTemp = &lr.Temperature
-					lastTime = &lr.Timestamp
+			if summarize {
+				readings, err = summarizedReadings(db, companyID, cameraID, z, desde, hasta)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
 				}
+			} else {
+				tdb.Where("camera_id = ? AND zone_id = ? AND timestamp >= ? AND timestamp <= ?", cameraID, z, desde, hasta).
+					Order("timestamp").
+					Find(&readings)
 			}
-			state := "Inactivo"
-			if lastTime != nil && lastTime.After(time.Now().Add(-10*time.Minute)) {
-				state = "Activo"
+			if summarize {
+				// El estado usa la última lectura real, no el inicio del último bucket
+				var last models.CameraReading
+				if tdb.Where("camera_id = ? AND zone_id = ? AND timestamp <= ?", cameraID, z, hasta).
+					Order("timestamp DESC").Limit(1).Find(&last).RowsAffected > 0 && !last.Timestamp.Before(desde) {
+					readings = append(readings, last)
+				}
+			}
+			var lr models.CameraReading
+			if len(readings) > 0 {
+				lr = readings[len(readings)-1]
 			}
-			zonasStatus = append(zonasStatus, ZoneStatus{
-				ZoneID:   z,
-				LastTemp: lastTemp,
-				LastTime: lastTime,
-				State:    state,
-				Readings: readings,
-			})
+			status := zoneStatus(z, registered, lr, time.Now())
+			status.Readings = readings
+			zonasStatus = append(zonasStatus, status)
 		}
 		resp := CameraDashboard{
 			CameraID: cameraID,
@@ -185,3 +298,43 @@ func CameraStatusDashboard(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, resp)
 	}
 }
+
+// maxRawDashboardRange es el rango máximo que el dashboard entrega lectura por lectura
+const maxRawDashboardRange = 48 * time.Hour
+
+// summarizedReadings retorna el promedio de cada bucket (elegido según el rango)
+// como lecturas, para que el frontend las grafique igual que las originales
+func summarizedReadings(db *gorm.DB, companyID uuid.UUID, cameraID, zoneID int, desde, hasta time.Time) ([]models.CameraReading, error) {
+	result, err := rollup.Series(db, rollup.Query{
+		CompanyID: companyID,
+		CameraID:  cameraID,
+		ZoneID:    zoneID,
+		From:      desde,
+		To:        hasta,
+		Bucket:    aggregate.AutoBucket(desde, hasta),
+		Funcs:     []aggregate.Func{aggregate.Avg},
+	}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	readings := make([]models.CameraReading, 0, len(result.Points))
+	for _, p := range result.Points {
+		readings = append(readings, models.CameraReading{
+			CompanyID:   companyID,
+			CameraID:    cameraID,
+			ZoneID:      zoneID,
+			Temperature: p.Values[aggregate.Avg],
+			Timestamp:   p.Start,
+		})
+	}
+	return readings, nil
+}
+
+// registeredID retorna el UUID registrado de la zona, o nil si aún no está en el registro
+func registeredID(registered map[int]models.Zone, zoneIndex int) *uuid.UUID {
+	zone, ok := registered[zoneIndex]
+	if !ok {
+		return nil
+	}
+	return &zone.ID
+}
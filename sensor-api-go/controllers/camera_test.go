@@ -21,7 +21,7 @@ func TestGetCameraReadings_EmptyDB(t *testing.T) {
 
     // Prepara el router y endpoint
     r := gin.Default()
-    r.GET("/api/camera-readings", GetCameraReadings(db))
+    r.GET("/api/camera-readings", asCompany(testCompanyID), GetCameraReadings(db))
 
     // Ejecuta request HTTP de prueba
     req, _ := http.NewRequest("GET", "/api/camera-readings", nil)
@@ -33,9 +33,9 @@ func TestGetCameraReadings_EmptyDB(t *testing.T) {
         t.Errorf("Esperado status 200, pero fue %d", w.Code)
     }
 
-    // Opcional: puedes chequear que devuelva un array vacío
+    // Opcional: puedes chequear que devuelva una página vacía
     body := w.Body.String()
-    esperado := "[]"
+    esperado := `{"items":[],"next_cursor":""}`
     if body != esperado && body != esperado+"\n" {
         t.Errorf("Esperado body vacío %v, pero fue: %v", esperado, body)
     }
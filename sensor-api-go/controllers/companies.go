@@ -10,7 +10,8 @@ import (
 func ListCompanies(db *gorm.DB) gin.HandlerFunc {
     return func(c *gin.Context) {
         var companies []models.Company
-        if err := db.Find(&companies).Error; err != nil {
+        // Endpoint público (selector del login): solo id y nombre
+        if err := db.Select("id", "name").Find(&companies).Error; err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron obtener las empresas"})
             return
         }
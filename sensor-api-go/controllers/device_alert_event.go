@@ -0,0 +1,37 @@
+// controllers/device_alert_event.go
+
+package controllers
+
+import (
+	"net/http"
+	"sensor-api-go/models"
+	"sensor-api-go/pagination"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GET /api/devices/:device_id/alert-events (paginado por cursor, del más nuevo al más antiguo)
+func ListDeviceAlertEvents(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deviceIDStr := c.Param("device_id")
+		deviceID, err := uuid.Parse(deviceIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "device_id inválido"})
+			return
+		}
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		q := tdb.Model(&models.DeviceAlertEvent{}).Where("device_id = ?", deviceID)
+		events, next, ok := findPage(c, q, "timestamp", "id", func(e models.DeviceAlertEvent) pagination.Cursor {
+			return pagination.Cursor{Time: e.Timestamp, ID: e.ID.String()}
+		})
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": events, "next_cursor": next})
+	}
+}
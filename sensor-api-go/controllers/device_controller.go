@@ -3,54 +3,61 @@ package controllers
 import (
     "net/http"
     "fmt"
+    "sort"
     "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
     "gorm.io/gorm"
     "sensor-api-go/models"
 )
 
 func GetDevicesWithZones(db *gorm.DB) gin.HandlerFunc {
     return func(c *gin.Context) {
-        // --- Logging de usuario/empresa para debug multiempresa ---
-        userID, _ := c.Get("user_id")
-        companyID, _ := c.Get("company_id")
-        fmt.Printf("user_id: %v, company_id: %v\n", userID, companyID)
+        tdb, _, ok := tenantDB(c, db)
+        if !ok {
+            return
+        }
 
         // Estructura para devolver
+        type ZoneInfo struct {
+            ID        uuid.UUID `json:"id"`
+            ZoneIndex int       `json:"zone_index"`
+            Name      string    `json:"name"`
+        }
         type DeviceWithZones struct {
-            CameraID int   `json:"camera_id"`
-            Zones    []int `json:"zones"`
+            DeviceID    uuid.UUID  `json:"device_id"`
+            Name        string     `json:"name"`
+            CameraID    int        `json:"camera_id"`
+            Zones       []int      `json:"zones"`        // zone_id numéricos, como en camera_readings
+            ZoneDetails []ZoneInfo `json:"zone_details"` // zonas del registro con su UUID
         }
 
-        var devices []DeviceWithZones
-
-        // --- Filtrado multiempresa: descomenta si tu modelo tiene company_id ---
-        // baseQuery := db.Where("company_id = ?", companyID)
-        baseQuery := db // Deja así si no usas company_id en CameraReading
-
-        // Obtener dispositivos únicos (cameras)
-        var cameraIDs []int
-        if err := baseQuery.Model(&models.CameraReading{}).Distinct("camera_id").Pluck("camera_id", &cameraIDs).Error; err != nil {
-            fmt.Printf("Error al obtener camera_ids: %v\n", err)
+        // Dispositivos y zonas de la empresa desde el registro (cámara, zona) -> UUID
+        var registered []models.Device
+        if err := tdb.Where("camera_id IS NOT NULL").
+            Preload("Zones", "zone_index IS NOT NULL").
+            Order("camera_id").
+            Find(&registered).Error; err != nil {
+            fmt.Printf("Error al obtener dispositivos: %v\n", err)
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
             return
         }
 
-        // Para cada cámara obtener zonas únicas
-        for _, camID := range cameraIDs {
-            var zones []int
-            if err := baseQuery.Model(&models.CameraReading{}).
-                Where("camera_id = ?", camID).
-                Distinct("zone_id").
-                Pluck("zone_id", &zones).Error; err != nil {
-                fmt.Printf("Error al obtener zones para camera_id %d: %v\n", camID, err)
-                c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-                return
-            }
+        devices := make([]DeviceWithZones, 0, len(registered))
+        for _, d := range registered {
+            sort.Slice(d.Zones, func(i, j int) bool { return *d.Zones[i].ZoneIndex < *d.Zones[j].ZoneIndex })
 
-            devices = append(devices, DeviceWithZones{
-                CameraID: camID,
-                Zones:    zones,
-            })
+            item := DeviceWithZones{
+                DeviceID:    d.ID,
+                Name:        d.Name,
+                CameraID:    *d.CameraID,
+                Zones:       make([]int, 0, len(d.Zones)),
+                ZoneDetails: make([]ZoneInfo, 0, len(d.Zones)),
+            }
+            for _, z := range d.Zones {
+                item.Zones = append(item.Zones, *z.ZoneIndex)
+                item.ZoneDetails = append(item.ZoneDetails, ZoneInfo{ID: z.ID, ZoneIndex: *z.ZoneIndex, Name: z.Name})
+            }
+            devices = append(devices, item)
         }
 
         c.JSON(http.StatusOK, devices)
@@ -0,0 +1,159 @@
+// controllers/device_key.go
+
+package controllers
+
+import (
+	"net/http"
+	"sensor-api-go/audit"
+	"sensor-api-go/models"
+	"sensor-api-go/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DeviceKeyInput struct {
+	Name      string `json:"name" binding:"required"`
+	CameraIDs []int  `json:"camera_ids" binding:"required,min=1,dive,gt=0"`
+}
+
+// deviceKeyResponse expone las cámaras como arreglo y, solo al crear o rotar, la key en claro
+type deviceKeyResponse struct {
+	models.DeviceAPIKey
+	CameraIDs []int  `json:"camera_ids"`
+	Key       string `json:"key,omitempty"`
+}
+
+func newDeviceKeyResponse(k models.DeviceAPIKey, plain string) deviceKeyResponse {
+	return deviceKeyResponse{DeviceAPIKey: k, CameraIDs: k.Cameras(), Key: plain}
+}
+
+// GET /api/device-keys
+func ListDeviceKeys(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		companyID, err := uuid.Parse(c.GetString("company_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "company_id inválido en el token"})
+			return
+		}
+		var keys []models.DeviceAPIKey
+		if err := db.Where("company_id = ?", companyID).Order("created_at DESC").Find(&keys).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron obtener las API keys"})
+			return
+		}
+		resp := make([]deviceKeyResponse, len(keys))
+		for i, k := range keys {
+			resp[i] = newDeviceKeyResponse(k, "")
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// POST /api/device-keys
+func CreateDeviceKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input DeviceKeyInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		companyID, err := uuid.Parse(c.GetString("company_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "company_id inválido en el token"})
+			return
+		}
+		createdBy, _ := uuid.Parse(c.GetString("user_id"))
+
+		plain, prefix, hash, err := utils.GenerateAPIKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo generar la API key"})
+			return
+		}
+		key := models.DeviceAPIKey{
+			ID:        uuid.New(),
+			CompanyID: companyID,
+			Name:      input.Name,
+			Prefix:    prefix,
+			KeyHash:   hash,
+			CreatedBy: createdBy,
+		}
+		key.SetCameras(input.CameraIDs)
+		if err := db.Create(&key).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear la API key"})
+			return
+		}
+		audit.Describe(c, audit.Target{Type: "device_key", ID: key.ID.String(), After: newDeviceKeyResponse(key, "")})
+		// La key en claro solo se entrega en esta respuesta
+		c.JSON(http.StatusOK, newDeviceKeyResponse(key, plain))
+	}
+}
+
+// POST /api/device-keys/:id/rotate
+// Genera un nuevo secreto para la key, manteniendo nombre y cámaras. El secreto anterior deja de funcionar.
+func RotateDeviceKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := findCompanyDeviceKey(c, db)
+		if !ok {
+			return
+		}
+		if key.RevokedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "La API key está revocada"})
+			return
+		}
+		plain, prefix, hash, err := utils.GenerateAPIKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo generar la API key"})
+			return
+		}
+		before := newDeviceKeyResponse(key, "")
+		key.Prefix = prefix
+		key.KeyHash = hash
+		key.LastUsedAt = nil
+		key.LastUsedIP = ""
+		if err := db.Save(&key).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo rotar la API key"})
+			return
+		}
+		audit.Describe(c, audit.Target{Action: "device_key.rotated", Type: "device_key", ID: key.ID.String(), Before: before, After: newDeviceKeyResponse(key, "")})
+		c.JSON(http.StatusOK, newDeviceKeyResponse(key, plain))
+	}
+}
+
+// DELETE /api/device-keys/:id
+// Revoca la key; el registro se conserva para trazabilidad.
+func RevokeDeviceKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := findCompanyDeviceKey(c, db)
+		if !ok {
+			return
+		}
+		before := newDeviceKeyResponse(key, "")
+		if key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+			if err := db.Save(&key).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo revocar la API key"})
+				return
+			}
+		}
+		audit.Describe(c, audit.Target{Action: "device_key.revoked", Type: "device_key", ID: key.ID.String(), Before: before, After: newDeviceKeyResponse(key, "")})
+		c.JSON(http.StatusOK, gin.H{"message": "API key revocada correctamente"})
+	}
+}
+
+// findCompanyDeviceKey busca la key del parámetro :id dentro de la empresa del token
+func findCompanyDeviceKey(c *gin.Context, db *gorm.DB) (models.DeviceAPIKey, bool) {
+	var key models.DeviceAPIKey
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return key, false
+	}
+	if err := db.First(&key, "id = ? AND company_id = ?", keyID, c.GetString("company_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key no encontrada"})
+		return key, false
+	}
+	return key, true
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"sensor-api-go/aggregate"
+	"sensor-api-go/export"
+	"sensor-api-go/models"
+	"sensor-api-go/rollup"
+	"sensor-api-go/zones"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ExportCameraReadings descarga el historial de una cámara:
+// GET /cameras/:camera_id/export?format=csv|xlsx|parquet&desde=&hasta=&zone_id=&bucket=&fn=&tz=&unit=
+// Sin rango, las últimas 24 horas. Sin bucket exporta cada lectura, ordenadas por
+// fecha; con bucket (ej: 1h) exporta las estadísticas fn (avg,min,max por defecto)
+// de cada zona, igual que el endpoint aggregate. Las fechas salen en la zona horaria
+// de la empresa y las temperaturas en su unidad (tz y unit las reemplazan).
+func ExportCameraReadings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cameraID, err := strconv.Atoi(c.Param("camera_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "camera_id inválido"})
+			return
+		}
+		format, err := export.ParseFormat(c.Query("format"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		desde, hasta, err := timeRange(c, 24*time.Hour)
+		if err == nil && !desde.Before(hasta) {
+			err = errors.New("desde debe ser anterior a hasta")
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		zoneID := 0
+		if s := c.Query("zone_id"); s != "" {
+			if zoneID, err = strconv.Atoi(s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "zone_id inválido"})
+				return
+			}
+		}
+
+		tdb, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var company models.Company
+		if err := db.First(&company, "id = ?", companyID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Empresa no encontrada"})
+			return
+		}
+		cols, err := exportColumns(c, company)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		registered, err := zones.ByCamera(db, companyID, cameraID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron obtener las zonas"})
+			return
+		}
+		zoneName := func(z int) string {
+			return registered[z].Name
+		}
+
+		var bucket time.Duration
+		if s := c.Query("bucket"); s != "" {
+			if bucket, err = aggregate.ParseBucket(s); err == nil {
+				err = aggregate.CheckRange(desde, hasta, bucket)
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if cols.Funcs, err = aggregate.ParseFuncs(c.DefaultQuery("fn", "avg,min,max")); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		filename := fmt.Sprintf("camara-%d-%s-%s.%s", cameraID, desde.Format("20060102T1504"), hasta.Format("20060102T1504"), format)
+		start := func() (export.Writer, error) {
+			c.Header("Content-Type", format.ContentType())
+			c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+			c.Status(http.StatusOK)
+			return export.NewWriter(c.Writer, format, cols)
+		}
+
+		if bucket == 0 {
+			exportRawReadings(c, tdb, company, cameraID, zoneID, desde, hasta, format, zoneName, start)
+			return
+		}
+
+		zoneIDs := []int{zoneID}
+		if zoneID == 0 {
+			if zoneIDs, err = exportZones(tdb, cameraID, registered); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron obtener las zonas"})
+				return
+			}
+		}
+		series := func(z int) (rollup.Result, error) {
+			return rollup.Series(db, rollup.Query{
+				CompanyID: companyID, CameraID: cameraID, ZoneID: z,
+				From: desde, To: hasta, Bucket: bucket, Funcs: cols.Funcs,
+			}, time.Now())
+		}
+		// La primera zona se calcula antes de responder: si el rango ya no está en
+		// las lecturas originales (percentiles) todavía se puede devolver un 400
+		var first rollup.Result
+		if len(zoneIDs) > 0 {
+			first, err = series(zoneIDs[0])
+			if errors.Is(err, rollup.ErrRawExpired) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron agregar las lecturas"})
+				return
+			}
+		}
+		w, err := start()
+		if err != nil {
+			log.Printf("[ERROR] Exportación de lecturas: %v", err)
+			return
+		}
+		// Un error de escritura (el cliente se desconectó) termina la exportación: no
+		// tiene sentido seguir consultando las zonas que faltan
+	zoneLoop:
+		for i, z := range zoneIDs {
+			result := first
+			if i > 0 {
+				if result, err = series(z); err != nil {
+					log.Printf("[ERROR] Exportación de lecturas interrumpida (cámara %d, zona %d): %v", cameraID, z, err)
+					break
+				}
+			}
+			for _, p := range result.Points {
+				values := make([]float64, len(cols.Funcs))
+				for j, fn := range cols.Funcs {
+					values[j] = p.Values[fn]
+				}
+				row := export.Row{Time: p.Start, CameraID: cameraID, ZoneID: z, ZoneName: zoneName(z), Count: p.Count, Values: values}
+				if err := w.Write(row); err != nil {
+					log.Printf("[ERROR] Exportación de lecturas interrumpida: %v", err)
+					break zoneLoop
+				}
+			}
+		}
+		if err := w.Close(); err != nil {
+			log.Printf("[ERROR] Exportación de lecturas: %v", err)
+		}
+	}
+}
+
+// exportRawReadings escribe cada lectura del rango, leyendo las filas de a una
+func exportRawReadings(c *gin.Context, tdb *gorm.DB, company models.Company, cameraID, zoneID int, desde, hasta time.Time,
+	format export.Format, zoneName func(int) string, start func() (export.Writer, error)) {
+	if desde.Before(rollup.RawCutoff(company, time.Now())) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "el rango incluye lecturas que ya se eliminaron por la retención de la empresa; use bucket=1h o mayor"})
+		return
+	}
+	q := tdb.Model(&models.CameraReading{}).
+		Where("camera_id = ? AND timestamp >= ? AND timestamp < ?", cameraID, desde, hasta)
+	if zoneID != 0 {
+		q = q.Where("zone_id = ?", zoneID)
+	}
+	if format == export.XLSX {
+		var n int64
+		if err := q.Count(&n).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron contar las lecturas"})
+			return
+		}
+		if n > export.MaxXLSXRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("el rango tiene %d lecturas y Excel admite %d por hoja; use un bucket, un rango menor o format=csv/parquet", n, export.MaxXLSXRows)})
+			return
+		}
+	}
+	rows, err := q.Select("camera_id", "zone_id", "temperature", "timestamp").Order("timestamp ASC").Order("id ASC").Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron exportar las lecturas"})
+		return
+	}
+	defer rows.Close()
+
+	w, err := start()
+	if err != nil {
+		log.Printf("[ERROR] Exportación de lecturas: %v", err)
+		return
+	}
+	for rows.Next() {
+		var r models.CameraReading
+		if err := tdb.ScanRows(rows, &r); err != nil {
+			log.Printf("[ERROR] Exportación de lecturas interrumpida: %v", err)
+			break
+		}
+		row := export.Row{Time: r.Timestamp, CameraID: r.CameraID, ZoneID: r.ZoneID, ZoneName: zoneName(r.ZoneID), Values: []float64{r.Temperature}}
+		if err := w.Write(row); err != nil {
+			log.Printf("[ERROR] Exportación de lecturas interrumpida: %v", err)
+			break
+		}
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("[ERROR] Exportación de lecturas: %v", err)
+	}
+}
+
+// exportColumns lee tz y unit de la query, o los de la empresa si no vienen
+func exportColumns(c *gin.Context, company models.Company) (export.Columns, error) {
+	loc, err := export.LoadLocation(c.DefaultQuery("tz", company.TimeZone))
+	if err != nil {
+		return export.Columns{}, err
+	}
+	unitName := c.DefaultQuery("unit", company.TemperatureUnit)
+	if unitName == "" {
+		unitName = string(export.Celsius)
+	}
+	unit, err := export.ParseUnit(unitName)
+	if err != nil {
+		return export.Columns{}, err
+	}
+	return export.Columns{Unit: unit, Location: loc}, nil
+}
+
+// exportZones son las zonas de la cámara: las que tienen lecturas y las registradas
+// (cuyas lecturas pueden estar solo en los rollups)
+func exportZones(tdb *gorm.DB, cameraID int, registered map[int]models.Zone) ([]int, error) {
+	var withReadings []int
+	if err := cameraZones(tdb, cameraID, &withReadings); err != nil {
+		return nil, err
+	}
+	seen := map[int]bool{}
+	for _, z := range withReadings {
+		seen[z] = true
+	}
+	for z := range registered {
+		seen[z] = true
+	}
+	ids := make([]int, 0, len(seen))
+	for z := range seen {
+		ids = append(ids, z)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
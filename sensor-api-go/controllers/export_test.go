@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportCameraReadings(t *testing.T) {
+	f := newTenantFixture(t)
+	f.db.Model(&models.Zone{}).Where("id = ?", f.zoneA.ID).Update("name", "Antecámara")
+	if w := f.do(f.tokenA, "PUT", "/api/company/locale", `{"time_zone": "America/Santiago", "temperature_unit": "f"}`); w.Code != http.StatusOK {
+		t.Fatalf("Configurar empresa: %d %s", w.Code, w.Body.String())
+	}
+	if w := f.do(f.tokenA, "PUT", "/api/company/locale", `{"time_zone": "Local"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Zona horaria Local: esperado 400, fue %d", w.Code)
+	}
+
+	w := f.do(f.tokenA, "GET", "/api/cameras/1/export", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") ||
+		!strings.Contains(w.Header().Get("Content-Disposition"), "camara-1-") {
+		t.Fatalf("Exportación CSV: %d %v", w.Code, w.Header())
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Esperados encabezado y 2 lecturas de A: %v %v", records, err)
+	}
+	loc, _ := time.LoadLocation("America/Santiago")
+	at, err := time.Parse(time.RFC3339, records[1][0])
+	_, offset := at.Zone()
+	_, want := at.In(loc).Zone()
+	if err != nil || offset != want {
+		t.Errorf("La fecha debe estar en la zona de la empresa: %q", records[1][0])
+	}
+	byZone := map[string][]string{records[1][2]: records[1], records[2][2]: records[2]}
+	if z := byZone["1"]; z == nil || z[3] != "Antecámara" || z[4] != "86" || z[5] != "°F" {
+		t.Errorf("Filas inesperadas: %v", records)
+	}
+
+	// La unidad de la query reemplaza la de la empresa; B solo ve sus lecturas
+	w = f.do(f.tokenB, "GET", "/api/cameras/1/export?unit=C&zone_id=1", "")
+	records, _ = csv.NewReader(w.Body).ReadAll()
+	if len(records) != 2 || records[1][4] != "40" || records[1][5] != "°C" {
+		t.Errorf("Exportación de B: %v", records)
+	}
+
+	w = f.do(f.tokenA, "GET", "/api/cameras/1/export?format=csv&bucket=1h&fn=avg,max&unit=C", "")
+	records, _ = csv.NewReader(w.Body).ReadAll()
+	if len(records) < 3 || records[0][4] != "lecturas" || records[0][6] != "temperatura_max" {
+		t.Errorf("Exportación agregada: %v", records)
+	}
+
+	w = f.do(f.tokenA, "GET", "/api/cameras/1/export?format=xlsx", "")
+	book, err := excelize.OpenReader(bytes.NewReader(w.Body.Bytes()))
+	if w.Code != http.StatusOK || err != nil {
+		t.Fatalf("Exportación xlsx: %d %v", w.Code, err)
+	}
+	defer book.Close()
+	if rows, _ := book.GetRows("Lecturas"); len(rows) != 3 || rows[0][0] != "fecha (America/Santiago)" {
+		t.Errorf("Hoja inesperada: %v", rows)
+	}
+
+	for _, q := range []string{"format=pdf", "unit=K", "tz=Marte", "bucket=30s", "fn=sum&bucket=1h", "desde=2024-01-02T00:00:00Z&hasta=2024-01-01T00:00:00Z"} {
+		if w := f.do(f.tokenA, "GET", "/api/cameras/1/export?"+q, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: esperado 400, fue %d", q, w.Code)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/csvimport"
+	"sensor-api-go/models"
+	"sensor-api-go/pagination"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxImportBytes es el tamaño máximo de un CSV subido por el API; los archivos más
+// grandes se importan con "app import"
+const MaxImportBytes = 200 << 20
+
+// Límites de las importaciones en segundo plano del proceso: como máximo
+// MaxConcurrentImports corren a la vez y el resto espera en estado pending, hasta
+// MaxQueuedImports en total; pasado ese límite se responde 429
+const (
+	MaxConcurrentImports = 2
+	MaxQueuedImports     = 20
+)
+
+var (
+	importSlots = make(chan struct{}, MaxConcurrentImports)
+	importQueue = make(chan struct{}, MaxQueuedImports)
+)
+
+// ImportJobResponse es una importación con su avance en porcentaje
+type ImportJobResponse struct {
+	models.ImportJob
+	Progress float64 `json:"progress"`
+}
+
+func importResponse(job models.ImportJob) ImportJobResponse {
+	return ImportJobResponse{ImportJob: job, Progress: job.Progress()}
+}
+
+// CreateImport recibe un CSV (multipart: file y, opcional, mapping en JSON) y lo
+// importa en segundo plano. Responde 202 con el job; el avance se consulta en
+// GET /imports/:id y las líneas rechazadas en GET /imports/:id/errors.
+func CreateImport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxImportBytes)
+		header, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "El archivo supera el máximo de 200 MB; use el comando app import"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Falta el archivo CSV (campo file)"})
+			return
+		}
+		mapping, err := csvimport.ParseMapping(c.PostForm("mapping"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		select {
+		case importQueue <- struct{}{}:
+		default:
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Hay demasiadas importaciones en curso; intente más tarde"})
+			return
+		}
+		queued := true
+		defer func() {
+			if queued {
+				<-importQueue
+			}
+		}()
+
+		// El archivo se copia a disco: el job sigue después de responder
+		path, err := saveUpload(header)
+		if err != nil {
+			log.Printf("[ERROR] No se pudo guardar el archivo a importar: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo guardar el archivo"})
+			return
+		}
+		var createdBy *uuid.UUID
+		if id, err := uuid.Parse(c.GetString("user_id")); err == nil {
+			createdBy = &id
+		}
+		job, err := csvimport.NewJob(db, companyID, createdBy, filepath.Base(header.Filename), header.Size, mapping)
+		if err != nil {
+			os.Remove(path)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear la importación"})
+			return
+		}
+		audit.Describe(c, audit.Target{Action: "readings.import_started", Type: "import_job", ID: job.ID.String(), After: job})
+
+		// El lugar en la cola pasa al job, que lo libera al terminar
+		queued = false
+		go runImport(db, job, path)
+		c.JSON(http.StatusAccepted, importResponse(job))
+	}
+}
+
+func saveUpload(header *multipart.FileHeader) (string, error) {
+	src, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	dst, err := os.CreateTemp("", "import-*.csv")
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, src); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
+
+// runImport espera un lugar entre las importaciones concurrentes, procesa el archivo
+// guardado y lo borra al terminar
+func runImport(db *gorm.DB, job models.ImportJob, path string) {
+	defer func() { <-importQueue }()
+	defer os.Remove(path)
+	// Mientras espera, el job se mantiene vivo para que csvimport.Watch no lo falle
+	heartbeat := time.NewTicker(csvimport.HeartbeatInterval)
+	for waiting := true; waiting; {
+		select {
+		case importSlots <- struct{}{}:
+			waiting = false
+		case <-heartbeat.C:
+			if err := csvimport.Touch(db, job.ID); err != nil {
+				log.Printf("[WARN] Importación %s: %v", job.ID, err)
+			}
+		}
+	}
+	heartbeat.Stop()
+	defer func() { <-importSlots }()
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("[ERROR] Importación %s: %v", job.ID, err)
+		err := db.Model(&job).Updates(map[string]interface{}{"status": models.ImportFailed, "error": "no se pudo leer el archivo"}).Error
+		if err != nil {
+			log.Printf("[ERROR] No se pudo marcar como fallida la importación %s: %v", job.ID, err)
+		}
+		return
+	}
+	defer f.Close()
+	if err := csvimport.Run(context.Background(), db, &job, f, nil); err != nil {
+		log.Printf("[WARN] Importación %s fallida: %v", job.ID, err)
+		return
+	}
+	log.Printf("[INFO] Importación %s: %d lecturas, %d repetidas, %d rechazadas", job.ID, job.Imported, job.Duplicates, job.Rejected)
+}
+
+// ListImports lista las importaciones de la empresa, paginadas por cursor
+func ListImports(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		jobs, next, ok := findPage(c, tdb.Model(&models.ImportJob{}), "created_at", "id", func(j models.ImportJob) pagination.Cursor {
+			return pagination.Cursor{Time: j.CreatedAt, ID: j.ID.String()}
+		})
+		if !ok {
+			return
+		}
+		items := make([]ImportJobResponse, len(jobs))
+		for i, j := range jobs {
+			items[i] = importResponse(j)
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items, "next_cursor": next})
+	}
+}
+
+// findImport carga la importación :id de la empresa del token; responde 404 si no existe
+func findImport(c *gin.Context, db *gorm.DB) (models.ImportJob, bool) {
+	var job models.ImportJob
+	tdb, _, ok := tenantDB(c, db)
+	if !ok {
+		return job, false
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil || tdb.First(&job, "id = ?", id).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Importación no encontrada"})
+		return job, false
+	}
+	return job, true
+}
+
+// GetImport retorna el estado y el avance de una importación
+func GetImport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := findImport(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, importResponse(job))
+	}
+}
+
+// ImportErrorReport descarga en CSV las líneas rechazadas de una importación
+func ImportErrorReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := findImport(c, db)
+		if !ok {
+			return
+		}
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="errores-importacion-%s.csv"`, job.ID))
+		c.Status(http.StatusOK)
+		if err := csvimport.WriteErrorReport(db, job.ID, c.Writer); err != nil {
+			log.Printf("[ERROR] Reporte de errores de la importación %s: %v", job.ID, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+)
+
+// upload sube data como CSV con el mapeo dado
+func (f *tenantFixture) upload(token, data, mapping string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "historial.csv")
+	part.Write([]byte(data))
+	if mapping != "" {
+		form.WriteField("mapping", mapping)
+	}
+	form.Close()
+	req, _ := http.NewRequest("POST", "/api/imports", &body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportReadings(t *testing.T) {
+	f := newTenantFixture(t)
+	// El job corre en otra goroutine: con una sola conexión comparte la base en memoria
+	sqlDB, _ := f.db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	if w := f.upload(f.tokenA, "a,b\n", `{"unit": "K"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Mapeo inválido: esperado 400, fue %d", w.Code)
+	}
+
+	data := "timestamp,camera_id,zone_id,temperature\n" +
+		"2024-03-01T10:00:00Z,1,1,4.5\n" +
+		"2024-03-01T10:00:00Z,1,1,4.5\n" +
+		"2024-03-01T10:05:00Z,1,1,abc\n" +
+		"2024-03-01T10:10:00Z,1,2,5\n"
+	w := f.upload(f.tokenA, data, "")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Subir importación: %d %s", w.Code, w.Body.String())
+	}
+	var job ImportJobResponse
+	json.Unmarshal(w.Body.Bytes(), &job)
+
+	path := "/api/imports/" + job.ID.String()
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != models.ImportDone && job.Status != models.ImportFailed {
+		if time.Now().After(deadline) {
+			t.Fatalf("La importación no terminó: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		w = f.do(f.tokenA, "GET", path, "")
+		json.Unmarshal(w.Body.Bytes(), &job)
+	}
+	if job.Status != models.ImportDone || job.Imported != 2 || job.Duplicates != 1 || job.Rejected != 1 || job.Progress != 100 {
+		t.Fatalf("Importación inesperada: %+v", job)
+	}
+	var count int64
+	f.db.Model(&models.CameraReading{}).Where("company_id = ? AND timestamp < ?", f.a, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)).Count(&count)
+	if count != 2 {
+		t.Errorf("Esperadas 2 lecturas importadas en A, hay %d", count)
+	}
+
+	w = f.do(f.tokenA, "GET", path+"/errors", "")
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if w.Code != http.StatusOK || err != nil || len(rows) != 2 || rows[1][0] != "4" {
+		t.Errorf("Reporte de errores: %d %v %v", w.Code, rows, err)
+	}
+	if items := decodeItems(t, f.do(f.tokenA, "GET", "/api/imports", "")); len(items) != 1 {
+		t.Errorf("Esperada 1 importación en el listado, hay %d", len(items))
+	}
+
+	// Otra empresa no ve la importación
+	if w := f.do(f.tokenB, "GET", path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Importación de otra empresa: esperado 404, fue %d", w.Code)
+	}
+	if w := f.do(f.tokenB, "GET", path+"/errors", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Reporte de otra empresa: esperado 404, fue %d", w.Code)
+	}
+	if items := decodeItems(t, f.do(f.tokenB, "GET", "/api/imports", "")); len(items) != 0 {
+		t.Errorf("B no debe ver importaciones de A: %v", items)
+	}
+}
+
+func TestImportQueueLimit(t *testing.T) {
+	f := newTenantFixture(t)
+	// Simula la cola llena de importaciones en curso
+	for i := 0; i < MaxQueuedImports; i++ {
+		importQueue <- struct{}{}
+	}
+	w := f.upload(f.tokenA, "timestamp,camera_id,zone_id,temperature\n", "")
+	for i := 0; i < MaxQueuedImports; i++ {
+		<-importQueue
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Con la cola llena: esperado 429, fue %d", w.Code)
+	}
+	var count int64
+	f.db.Model(&models.ImportJob{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Una importación rechazada no debe crear el job, hay %d", count)
+	}
+}
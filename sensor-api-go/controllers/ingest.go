@@ -0,0 +1,108 @@
+// controllers/ingest.go
+
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sensor-api-go/ingest"
+	"sensor-api-go/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// POST /api/ingest/readings
+// Acepta una lectura, un arreglo de lecturas o {"readings": [...]}
+func IngestReadings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el cuerpo de la solicitud"})
+			return
+		}
+		inputs, err := parseReadingsBody(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido: " + err.Error()})
+			return
+		}
+		if len(inputs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No se enviaron lecturas"})
+			return
+		}
+		if len(inputs) > ingest.MaxBatchSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Lote demasiado grande", "max_batch_size": ingest.MaxBatchSize})
+			return
+		}
+
+		// La empresa sale de la credencial, nunca del cuerpo
+		companyID, err := uuid.Parse(c.GetString("company_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No se pudo determinar la empresa de la credencial"})
+			return
+		}
+		for i := range inputs {
+			inputs[i].CompanyID = companyID
+		}
+
+		var hooks []ingest.Hook
+		if v, ok := c.Get("device_key"); ok {
+			hooks = append(hooks, deviceKeyCameraHook(v.(models.DeviceAPIKey)))
+		}
+
+		results, err := ingest.Save(db, inputs, hooks...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron guardar las lecturas"})
+			return
+		}
+
+		accepted, rejected := ingest.Count(results)
+		status := http.StatusOK
+		if accepted == 0 {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{
+			"accepted": accepted,
+			"rejected": rejected,
+			"results":  results,
+		})
+	}
+}
+
+// deviceKeyCameraHook rechaza lecturas de cámaras no asociadas a la API key
+func deviceKeyCameraHook(key models.DeviceAPIKey) ingest.Hook {
+	return func(r *models.CameraReading) error {
+		if !key.AllowsCamera(r.CameraID) {
+			return fmt.Errorf("la API key no está autorizada para la cámara %d", r.CameraID)
+		}
+		return nil
+	}
+}
+
+func parseReadingsBody(raw []byte) ([]ingest.ReadingInput, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var inputs []ingest.ReadingInput
+		err := json.Unmarshal(raw, &inputs)
+		return inputs, err
+	}
+
+	var envelope struct {
+		Readings []ingest.ReadingInput `json:"readings"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Readings != nil {
+		return envelope.Readings, nil
+	}
+
+	var single ingest.ReadingInput
+	if err := json.Unmarshal(raw, &single); err != nil {
+		return nil, err
+	}
+	return []ingest.ReadingInput{single}, nil
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sensor-api-go/middleware"
+	"sensor-api-go/models"
+	"sensor-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var testCompanyID = uuid.MustParse("6f1c2a4e-0000-4000-8000-000000000001")
+
+type ingestResponse struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+	Results  []struct {
+		Index  int    `json:"index"`
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"results"`
+}
+
+func newIngestTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	if err := db.AutoMigrate(&models.CameraReading{}, &models.Device{}, &models.Zone{}); err != nil {
+		t.Fatalf("No se pudo migrar: %v", err)
+	}
+	return db
+}
+
+// asCompany simula la autenticación dejando la empresa en el contexto, como los middlewares
+func asCompany(companyID uuid.UUID) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("company_id", companyID.String())
+		c.Next()
+	}
+}
+
+func postIngest(db *gorm.DB, body string) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.POST("/api/ingest/readings", asCompany(testCompanyID), IngestReadings(db))
+	req, _ := http.NewRequest("POST", "/api/ingest/readings", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIngestReadings_BatchPartial(t *testing.T) {
+	db := newIngestTestDB(t)
+
+	body := `[
+		{"camera_id": 1, "zone_id": 2, "temperature": 31.5, "timestamp": "2025-01-01T10:00:00Z"},
+		{"camera_id": 1, "zone_id": 2, "timestamp": "2025-01-01T10:00:10Z"},
+		{"camera_id": 0, "zone_id": 1, "temperature": 20, "timestamp": "2025-01-01T10:00:20Z"}
+	]`
+	w := postIngest(db, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Esperado status 200, pero fue %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp ingestResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Respuesta no es JSON válido: %v", err)
+	}
+	if resp.Accepted != 1 || resp.Rejected != 2 {
+		t.Errorf("Esperado 1 aceptada y 2 rechazadas, pero fue %d/%d", resp.Accepted, resp.Rejected)
+	}
+	if resp.Results[0].Status != "accepted" || resp.Results[1].Status != "rejected" {
+		t.Errorf("Resultados por ítem inesperados: %+v", resp.Results)
+	}
+
+	var count int64
+	db.Model(&models.CameraReading{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Esperada 1 lectura guardada, pero hay %d", count)
+	}
+}
+
+func TestIngestReadings_SingleAndEnvelope(t *testing.T) {
+	db := newIngestTestDB(t)
+
+	single := `{"camera_id": 3, "zone_id": 1, "temperature": 40.1, "timestamp": "2025-01-01T10:00:00Z"}`
+	if w := postIngest(db, single); w.Code != http.StatusOK {
+		t.Fatalf("Lectura individual: esperado 200, pero fue %d: %s", w.Code, w.Body.String())
+	}
+
+	envelope := `{"readings": [{"camera_id": 3, "zone_id": 2, "temperature": 38, "timestamp": "2025-01-01T10:00:00Z"}]}`
+	if w := postIngest(db, envelope); w.Code != http.StatusOK {
+		t.Fatalf("Lote con envoltorio: esperado 200, pero fue %d: %s", w.Code, w.Body.String())
+	}
+
+	var count int64
+	db.Model(&models.CameraReading{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Esperadas 2 lecturas guardadas, pero hay %d", count)
+	}
+}
+
+func TestIngestReadings_AllRejected(t *testing.T) {
+	db := newIngestTestDB(t)
+
+	w := postIngest(db, `{"camera_id": 1, "zone_id": 1, "temperature": 9999, "timestamp": "2025-01-01T10:00:00Z"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Esperado status 422, pero fue %d", w.Code)
+	}
+}
+
+func TestIngestReadings_DeviceKeyCameraScope(t *testing.T) {
+	db := newIngestTestDB(t)
+	if err := db.AutoMigrate(&models.DeviceAPIKey{}); err != nil {
+		t.Fatalf("No se pudo migrar: %v", err)
+	}
+
+	plain, prefix, hash, err := utils.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("No se pudo generar la key: %v", err)
+	}
+	key := models.DeviceAPIKey{ID: uuid.New(), CompanyID: uuid.New(), Name: "gateway", Prefix: prefix, KeyHash: hash}
+	key.SetCameras([]int{7})
+	db.Create(&key)
+
+	r := gin.Default()
+	r.POST("/api/ingest/readings", middleware.DeviceKeyAuthMiddleware(db), IngestReadings(db))
+
+	body := `[
+		{"camera_id": 7, "zone_id": 1, "temperature": 30, "timestamp": "2025-01-01T10:00:00Z"},
+		{"camera_id": 8, "zone_id": 1, "temperature": 30, "timestamp": "2025-01-01T10:00:00Z"}
+	]`
+
+	// Sin key: 401
+	req, _ := http.NewRequest("POST", "/api/ingest/readings", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Esperado status 401 sin API key, pero fue %d", w.Code)
+	}
+
+	// Con key: solo la cámara 7 es aceptada
+	req, _ = http.NewRequest("POST", "/api/ingest/readings", strings.NewReader(body))
+	req.Header.Set("X-API-Key", plain)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Esperado status 200, pero fue %d: %s", w.Code, w.Body.String())
+	}
+	var resp ingestResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Accepted != 1 || resp.Results[1].Status != "rejected" {
+		t.Errorf("Esperada solo la cámara 7 aceptada: %+v", resp)
+	}
+	var reading models.CameraReading
+	db.First(&reading)
+	if reading.CompanyID != key.CompanyID {
+		t.Errorf("La lectura debe quedar en la empresa de la API key, quedó en %s", reading.CompanyID)
+	}
+
+	var stored models.DeviceAPIKey
+	db.First(&stored, "id = ?", key.ID)
+	if stored.LastUsedAt == nil {
+		t.Errorf("Esperado last_used_at registrado")
+	}
+}
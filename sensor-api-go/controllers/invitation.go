@@ -0,0 +1,305 @@
+// controllers/invitation.go
+
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/middleware"
+	"sensor-api-go/models"
+	"sensor-api-go/rbac"
+	"sensor-api-go/sessions"
+	"sensor-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Vigencia de un enlace de invitación; reenviarla lo renueva
+const invitationTTL = 72 * time.Hour
+
+type InvitationInput struct {
+	Name  string `json:"name" binding:"required"`
+	Email string `json:"email" binding:"required"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// invitationResponse agrega el estado calculado de la invitación
+type invitationResponse struct {
+	models.Invitation
+	State string `json:"state"`
+}
+
+func newInvitationResponse(inv models.Invitation) invitationResponse {
+	return invitationResponse{Invitation: inv, State: inv.State(time.Now())}
+}
+
+// CreateInvitation crea un usuario pendiente ("Invited") en la empresa del token y le
+// envía por email un enlace firmado para que elija su contraseña
+func CreateInvitation(db *gorm.DB, send Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input InvitationInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		role, status, err := assignableRole(c, input.Role)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error(), "code": roleErrorCode(status)})
+			return
+		}
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		email := strings.TrimSpace(input.Email)
+		var existing int64
+		if err := db.Model(&models.User{}).Where("email = ?", email).Count(&existing).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Ya existe un usuario con ese email"})
+			return
+		}
+
+		now := time.Now()
+		user := models.User{ID: uuid.New(), CompanyID: companyID, Name: input.Name, Email: email, Role: role, Status: "Invited"}
+		inviter, _ := uuid.Parse(c.GetString("user_id"))
+		inv := models.Invitation{
+			ID: uuid.New(), CompanyID: companyID, UserID: user.ID, Email: email, Role: role,
+			InvitedBy: inviter, TokenID: uuid.New(), ExpiresAt: now.Add(invitationTTL), SentAt: now,
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			return tx.Create(&inv).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear la invitación"})
+			return
+		}
+		audit.Describe(c, audit.Target{Type: "invitation", ID: inv.ID.String(), After: newInvitationResponse(inv)})
+		if err := sendInvitation(send, inv, user.Name); err != nil {
+			log.Printf("[ERROR] No se pudo enviar la invitación %s: %v", inv.ID, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Invitación creada, pero no se pudo enviar el correo; reenvíela", "invitation": newInvitationResponse(inv)})
+			return
+		}
+		c.JSON(http.StatusOK, newInvitationResponse(inv))
+	}
+}
+
+func sendInvitation(send Mailer, inv models.Invitation, name string) error {
+	token, err := utils.GenerateInviteToken(inv.ID.String(), inv.TokenID.String(), inv.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/accept-invite?token=%s", frontendURL(), token)
+	body := fmt.Sprintf(`<p>Hola %s,</p>
+<p>Te invitaron a INSTRUMINING con el rol %s. Para activar tu cuenta, elige tu contraseña en este enlace (válido hasta el %s):</p>
+<p><a href="%s">%s</a></p>`, name, inv.Role, inv.ExpiresAt.Format("02-01-2006 15:04 MST"), link, link)
+	return send(inv.Email, "Invitación a INSTRUMINING", body)
+}
+
+// ListInvitations retorna las invitaciones de la empresa del token, con su estado
+func ListInvitations(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var invitations []models.Invitation
+		if err := tdb.Order("created_at DESC").Find(&invitations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		out := make([]invitationResponse, len(invitations))
+		for i, inv := range invitations {
+			out[i] = newInvitationResponse(inv)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// findInvitation busca la invitación en la empresa del token y verifica que quien
+// hace la solicitud pueda administrar el rol invitado
+func findInvitation(c *gin.Context, db *gorm.DB) (models.Invitation, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de invitación inválido"})
+		return models.Invitation{}, false
+	}
+	tdb, _, ok := tenantDB(c, db)
+	if !ok {
+		return models.Invitation{}, false
+	}
+	var inv models.Invitation
+	if err := tdb.First(&inv, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitación no encontrada"})
+		return models.Invitation{}, false
+	}
+	if !rbac.CanManage(c.GetString("role"), inv.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No puede administrar invitaciones con un rol superior al suyo", "code": middleware.ErrCodePermissionDenied})
+		return models.Invitation{}, false
+	}
+	return inv, true
+}
+
+// ResendInvitation envía un enlace nuevo (el anterior deja de valer) y renueva el vencimiento
+func ResendInvitation(db *gorm.DB, send Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inv, ok := findInvitation(c, db)
+		if !ok {
+			return
+		}
+		if state := inv.State(time.Now()); state != models.InvitationPending && state != models.InvitationExpired {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("La invitación está %s", state)})
+			return
+		}
+		var user models.User
+		if err := db.First(&user, "id = ? AND status = ?", inv.UserID, "Invited").Error; err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "El usuario invitado ya no está pendiente"})
+			return
+		}
+		before := newInvitationResponse(inv)
+		now := time.Now()
+		inv.TokenID = uuid.New()
+		inv.ExpiresAt = now.Add(invitationTTL)
+		inv.SentAt = now
+		if err := db.Save(&inv).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo reenviar la invitación"})
+			return
+		}
+		audit.Describe(c, audit.Target{Action: "invitation.resent", Type: "invitation", ID: inv.ID.String(), Before: before, After: newInvitationResponse(inv)})
+		if err := sendInvitation(send, inv, user.Name); err != nil {
+			log.Printf("[ERROR] No se pudo reenviar la invitación %s: %v", inv.ID, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "No se pudo enviar el correo"})
+			return
+		}
+		c.JSON(http.StatusOK, newInvitationResponse(inv))
+	}
+}
+
+// RevokeInvitation anula una invitación no aceptada y elimina al usuario pendiente
+func RevokeInvitation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inv, ok := findInvitation(c, db)
+		if !ok {
+			return
+		}
+		if inv.AcceptedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "La invitación ya fue aceptada; desactive al usuario"})
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&inv).UpdateColumn("revoked_at", time.Now()).Error; err != nil {
+				return err
+			}
+			return tx.Where("id = ? AND status = ?", inv.UserID, "Invited").Delete(&models.User{}).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo revocar la invitación"})
+			return
+		}
+		audit.Describe(c, audit.Target{Action: "invitation.revoked", Type: "invitation", ID: inv.ID.String()})
+		c.JSON(http.StatusOK, gin.H{"message": "Invitación revocada"})
+	}
+}
+
+type AcceptInvitationInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// AcceptInvitation fija la contraseña del invitado, lo activa y abre su primera sesión.
+// Si la empresa le exige 2FA, en lugar de la sesión responde el desafío como el login.
+func AcceptInvitation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input AcceptInvitationInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		invalid := gin.H{"error": "La invitación es inválida o ya venció"}
+		invitationID, tokenID, err := utils.ValidateInviteToken(input.Token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, invalid)
+			return
+		}
+		var inv models.Invitation
+		if err := db.First(&inv, "id = ?", invitationID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, invalid)
+			return
+		}
+		if inv.TokenID.String() != tokenID || inv.State(time.Now()) != models.InvitationPending {
+			c.JSON(http.StatusBadRequest, invalid)
+			return
+		}
+		var user models.User
+		if err := db.First(&user, "id = ? AND status = ?", inv.UserID, "Invited").Error; err != nil {
+			c.JSON(http.StatusBadRequest, invalid)
+			return
+		}
+		// Con SSO obligatorio la contraseña no sirve para entrar: el invitado debe
+		// ingresar por /sso/login y la invitación queda pendiente
+		enforced, err := ssoEnforced(db, user.CompanyID)
+		if err != nil {
+			log.Printf("[ERROR] No se pudo leer la configuración SSO de %s: %v", user.CompanyID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo aceptar la invitación"})
+			return
+		}
+		if enforced {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Su empresa exige iniciar sesión con SSO", "code": "sso_required"})
+			return
+		}
+		hashed, err := utils.HashPassword(input.Password, user.Email)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Se acepta una sola vez y solo con el último enlace enviado
+			res := tx.Model(&models.Invitation{}).
+				Where("id = ? AND token_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", inv.ID, inv.TokenID).
+				UpdateColumn("accepted_at", time.Now())
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return tx.Model(&user).UpdateColumns(map[string]interface{}{"password": hashed, "status": "Active"}).Error
+		})
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusBadRequest, invalid)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo aceptar la invitación"})
+			return
+		}
+		user.Status = "Active"
+		audit.Describe(c, audit.Target{
+			Action: "invitation.accepted", Type: "invitation", ID: inv.ID.String(),
+			CompanyID: &user.CompanyID, ActorID: &user.ID, ActorEmail: user.Email,
+		})
+		if challenged := respondMFAChallenge(c, db, user); challenged {
+			return
+		}
+		session, refreshToken, err := sessions.Start(db, user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			log.Printf("[ERROR] No se pudo abrir la sesión de %s: %v", user.ID, err)
+			c.JSON(http.StatusOK, gin.H{"message": "Cuenta activada; inicie sesión"})
+			return
+		}
+		respondTokens(c, user, session, refreshToken)
+	}
+}
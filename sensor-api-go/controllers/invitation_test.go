@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+)
+
+var inviteTokenRe = regexp.MustCompile(`accept-invite\?token=([\w.-]+)`)
+
+func inviteLink(t *testing.T, f *tenantFixture) string {
+	t.Helper()
+	if len(f.mails) == 0 {
+		t.Fatal("No se envió ningún correo")
+	}
+	match := inviteTokenRe.FindStringSubmatch(f.mails[len(f.mails)-1].body)
+	if match == nil {
+		t.Fatalf("El correo no tiene el enlace: %s", f.mails[len(f.mails)-1].body)
+	}
+	return match[1]
+}
+
+func createInvitation(t *testing.T, f *tenantFixture, email, role string) invitationResponse {
+	t.Helper()
+	w := f.do(f.tokenA, "POST", "/api/invitations", fmt.Sprintf(`{"name": "Invitado", "email": "%s", "role": "%s"}`, email, role))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Crear invitación: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var inv invitationResponse
+	json.Unmarshal(w.Body.Bytes(), &inv)
+	return inv
+}
+
+func TestInvitation_Accept(t *testing.T) {
+	f := newTenantFixture(t)
+	inv := createInvitation(t, f, "nuevo@example.com", "operator")
+	if inv.State != models.InvitationPending || inv.CompanyID != f.a {
+		t.Errorf("Invitación mal creada: %+v", inv)
+	}
+	var user models.User
+	f.db.First(&user, "email = ?", "nuevo@example.com")
+	if user.Status != "Invited" || user.Role != "operator" {
+		t.Errorf("El usuario invitado debe quedar pendiente: %+v", user)
+	}
+	if w := f.do(f.tokenA, "POST", "/api/invitations", `{"name": "X", "email": "nuevo@example.com", "role": "viewer"}`); w.Code != http.StatusConflict {
+		t.Errorf("Email repetido: esperado 409, fue %d", w.Code)
+	}
+
+	link := inviteLink(t, f)
+	body := `{"token": "%s", "password": "%s"}`
+	if w := f.do("", "POST", "/api/invitations/accept", fmt.Sprintf(body, link, "debil")); w.Code != http.StatusBadRequest {
+		t.Errorf("Contraseña débil: esperado 400, fue %d", w.Code)
+	}
+	// Un access token no sirve como invitación
+	if w := f.do("", "POST", "/api/invitations/accept", fmt.Sprintf(body, f.tokenA, "Bienvenido2025")); w.Code != http.StatusBadRequest {
+		t.Errorf("Access token como invitación: esperado 400, fue %d", w.Code)
+	}
+
+	w := f.do("", "POST", "/api/invitations/accept", fmt.Sprintf(body, link, "Bienvenido2025"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Aceptar: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	token, _ := decodeTokens(t, w.Body.Bytes())
+	if w := f.do(token, "GET", "/api/camera-readings", ""); w.Code != http.StatusOK {
+		t.Errorf("El invitado debe quedar con sesión, fue %d", w.Code)
+	}
+	f.db.First(&user, "email = ?", "nuevo@example.com")
+	if user.Status != "Active" {
+		t.Errorf("Esperado estado Active, fue %q", user.Status)
+	}
+	if w := f.do("", "POST", "/api/invitations/accept", fmt.Sprintf(body, link, "OtraClave2025")); w.Code != http.StatusBadRequest {
+		t.Errorf("Aceptar dos veces: esperado 400, fue %d", w.Code)
+	}
+}
+
+func TestInvitation_ResendRevokeAndExpiry(t *testing.T) {
+	f := newTenantFixture(t)
+	inv := createInvitation(t, f, "pendiente@example.com", "viewer")
+	first := inviteLink(t, f)
+
+	w := f.do(f.tokenA, "POST", "/api/invitations/"+inv.ID.String()+"/resend", "")
+	if w.Code != http.StatusOK || len(f.mails) != 2 {
+		t.Fatalf("Reenviar: esperado 200 y un segundo correo, fue %d (%d correos)", w.Code, len(f.mails))
+	}
+	second := inviteLink(t, f)
+	body := `{"token": "%s", "password": "Bienvenido2025"}`
+	if w := f.do("", "POST", "/api/invitations/accept", fmt.Sprintf(body, first)); w.Code != http.StatusBadRequest {
+		t.Errorf("Enlace reemplazado: esperado 400, fue %d", w.Code)
+	}
+
+	// Otra empresa no ve ni revoca la invitación
+	if got := decodeList(t, f.do(f.tokenB, "GET", "/api/invitations", "")); len(got) != 0 {
+		t.Errorf("B no debe ver invitaciones de A: %v", got)
+	}
+	if w := f.do(f.tokenB, "DELETE", "/api/invitations/"+inv.ID.String(), ""); w.Code != http.StatusNotFound {
+		t.Errorf("Revocar ajena: esperado 404, fue %d", w.Code)
+	}
+
+	// Vencida: no se puede aceptar, pero sí reenviar
+	f.db.Model(&models.Invitation{}).Where("id = ?", inv.ID).UpdateColumn("expires_at", time.Now().Add(-time.Minute))
+	list := decodeList(t, f.do(f.tokenA, "GET", "/api/invitations", ""))
+	if len(list) != 1 || list[0]["state"] != models.InvitationExpired {
+		t.Errorf("Esperado estado expired: %v", list)
+	}
+	if w := f.do("", "POST", "/api/invitations/accept", fmt.Sprintf(body, second)); w.Code != http.StatusBadRequest {
+		t.Errorf("Invitación vencida: esperado 400, fue %d", w.Code)
+	}
+
+	if w := f.do(f.tokenA, "DELETE", "/api/invitations/"+inv.ID.String(), ""); w.Code != http.StatusOK {
+		t.Fatalf("Revocar: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var count int64
+	f.db.Model(&models.User{}).Where("email = ?", "pendiente@example.com").Count(&count)
+	if count != 0 {
+		t.Error("Revocar debe eliminar al usuario pendiente")
+	}
+	if w := f.do(f.tokenA, "POST", "/api/invitations/"+inv.ID.String()+"/resend", ""); w.Code != http.StatusConflict {
+		t.Errorf("Reenviar revocada: esperado 409, fue %d", w.Code)
+	}
+}
+
+func TestInvitation_AcceptRequiresMFAByPolicy(t *testing.T) {
+	f := newTenantFixture(t)
+	if w := f.do(f.tokenA, "PUT", "/api/company/security", `{"require_mfa_for_admins": true}`); w.Code != http.StatusOK {
+		t.Fatalf("Exigir 2FA: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	createInvitation(t, f, "admin2@example.com", "admin")
+	w := f.do("", "POST", "/api/invitations/accept", fmt.Sprintf(`{"token": "%s", "password": "Bienvenido2025"}`, inviteLink(t, f)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Aceptar: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["mfa_enrollment_required"] != true || resp["mfa_token"] == nil || resp["token"] != nil {
+		t.Errorf("Con 2FA obligatorio se espera el desafío de enrolamiento y ningún token: %s", w.Body.String())
+	}
+	var user models.User
+	f.db.First(&user, "email = ?", "admin2@example.com")
+	if user.Status != "Active" {
+		t.Errorf("La invitación igual debe quedar aceptada, estado %q", user.Status)
+	}
+}
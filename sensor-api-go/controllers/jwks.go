@@ -0,0 +1,19 @@
+// controllers/jwks.go
+
+package controllers
+
+import (
+	"net/http"
+	"sensor-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /.well-known/jwks.json
+// Publica las llaves públicas (RS256/EdDSA) para que otros servicios validen los tokens
+func JWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"keys": utils.JWKS()})
+	}
+}
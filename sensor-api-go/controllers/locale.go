@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"net/http"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/export"
+	"sensor-api-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LocaleInput es la zona horaria y la unidad de temperatura de la empresa, que usan
+// las exportaciones de lecturas. Los campos omitidos no cambian.
+type LocaleInput struct {
+	TimeZone        *string `json:"time_zone"`        // nombre IANA, ej: America/Santiago
+	TemperatureUnit *string `json:"temperature_unit"` // "C" o "F"
+}
+
+func localeResponse(company models.Company) gin.H {
+	return gin.H{"time_zone": company.TimeZone, "temperature_unit": company.TemperatureUnit}
+}
+
+// GetLocale retorna la zona horaria y la unidad de la empresa del token
+func GetLocale(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var company models.Company
+		if err := db.First(&company, "id = ?", companyID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Empresa no encontrada"})
+			return
+		}
+		c.JSON(http.StatusOK, localeResponse(company))
+	}
+}
+
+// UpdateLocale cambia la zona horaria y/o la unidad de la empresa del token
+func UpdateLocale(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input LocaleInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var before models.Company
+		if err := db.First(&before, "id = ?", companyID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Empresa no encontrada"})
+			return
+		}
+		after := before
+		if input.TimeZone != nil {
+			loc, err := export.LoadLocation(*input.TimeZone)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			after.TimeZone = loc.String()
+		}
+		if input.TemperatureUnit != nil {
+			unit, err := export.ParseUnit(*input.TemperatureUnit)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			after.TemperatureUnit = string(unit)
+		}
+		err := db.Model(&models.Company{}).Where("id = ?", companyID).UpdateColumns(map[string]interface{}{
+			"time_zone":        after.TimeZone,
+			"temperature_unit": after.TemperatureUnit,
+		}).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo guardar la configuración"})
+			return
+		}
+		audit.Describe(c, audit.Target{Action: "company.locale_updated", Type: "company", ID: companyID.String(), Before: before, After: after})
+		c.JSON(http.StatusOK, localeResponse(after))
+	}
+}
@@ -0,0 +1,55 @@
+// controllers/lockout.go
+
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/loginguard"
+	"sensor-api-go/middleware"
+	"sensor-api-go/models"
+	"sensor-api-go/rbac"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UnlockUser quita el bloqueo por intentos fallidos de un usuario de la empresa del token
+func UnlockUser(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuario inválido"})
+			return
+		}
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var user models.User
+		if err := tdb.First(&user, "id = ?", uid).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		if !rbac.CanManage(c.GetString("role"), user.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No puede modificar usuarios con un rol superior al suyo", "code": middleware.ErrCodePermissionDenied})
+			return
+		}
+		actorID, _ := uuid.Parse(c.GetString("user_id"))
+		err = loginguard.Unlock(db, user, actorID, c.GetString("email"), c.ClientIP(), time.Now())
+		if errors.Is(err, loginguard.ErrNotLocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": "El usuario no está bloqueado"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo desbloquear el usuario"})
+			return
+		}
+		audit.Skip(c) // loginguard.Unlock ya dejó el registro
+		c.JSON(http.StatusOK, gin.H{"message": "Usuario desbloqueado"})
+	}
+}
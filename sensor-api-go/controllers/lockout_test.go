@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/loginguard"
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+)
+
+func TestLogin_LockoutAndUnlock(t *testing.T) {
+	f := newTenantFixture(t)
+	setTestPassword(t, f, f.userA, "Actual2024clave")
+	login := func(password string) *http.Response {
+		w := f.do("", "POST", "/api/login", fmt.Sprintf(`{"email": "%s", "password": "%s", "company_id": "%s"}`, f.userA.Email, password, f.a))
+		return w.Result()
+	}
+
+	for i := 0; i < loginguard.DelayAfter; i++ {
+		if resp := login("incorrecta"); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("Intento %d: esperado 401, fue %d", i+1, resp.StatusCode)
+		}
+	}
+	// Durante la demora se rechaza incluso la contraseña correcta
+	resp := login("Actual2024clave")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("Durante la demora: esperado 429 con Retry-After, fue %d", resp.StatusCode)
+	}
+
+	// Simula que ya pasó la demora y que solo falta un intento para el bloqueo
+	past := time.Now().Add(-time.Hour)
+	f.db.Model(&models.User{}).Where("id = ?", f.userA.ID).
+		UpdateColumns(map[string]interface{}{"failed_logins": loginguard.LockAfter - 1, "last_failed_login_at": past})
+	if resp := login("incorrecta"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Último intento: esperado 401, fue %d", resp.StatusCode)
+	}
+	if resp := login("Actual2024clave"); resp.StatusCode != http.StatusLocked {
+		t.Fatalf("Cuenta bloqueada: esperado 423, fue %d", resp.StatusCode)
+	}
+	var count int64
+	f.db.Model(&models.AuditLog{}).Where("action = ? AND company_id = ?", audit.ActionAccountLocked, f.a).Count(&count)
+	if count != 1 {
+		t.Errorf("El bloqueo debe quedar en la auditoría, hay %d registros", count)
+	}
+
+	// Otra empresa no puede desbloquearlo; un admin de la misma sí
+	if w := f.do(f.tokenB, "POST", "/api/users/"+f.userA.ID.String()+"/unlock", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Desbloquear usuario de otra empresa: esperado 404, fue %d", w.Code)
+	}
+	admin := models.User{ID: uuid.New(), CompanyID: f.a, Name: "Admin 2", Email: "admin2@example.com", Password: "x", Role: "admin", Status: "Active"}
+	f.db.Create(&admin)
+	adminToken, _ := f.login(t, admin)
+	if w := f.do(adminToken, "POST", "/api/users/"+f.userA.ID.String()+"/unlock", ""); w.Code != http.StatusOK {
+		t.Fatalf("Desbloquear: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	if w := f.do(adminToken, "POST", "/api/users/"+f.userA.ID.String()+"/unlock", ""); w.Code != http.StatusConflict {
+		t.Errorf("Desbloquear usuario sin bloqueo: esperado 409, fue %d", w.Code)
+	}
+	if resp := login("Actual2024clave"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Tras desbloquear el login debe funcionar, fue %d", resp.StatusCode)
+	}
+	var unlock models.AuditLog
+	f.db.Where("action = ?", audit.ActionAccountUnlocked).First(&unlock)
+	if unlock.ActorID == nil || *unlock.ActorID != admin.ID || unlock.ActorEmail != admin.Email {
+		t.Errorf("El desbloqueo debe registrar al admin: %+v", unlock)
+	}
+}
+
+func TestLoginMFA_FailedCodesCount(t *testing.T) {
+	f := newTenantFixture(t)
+	setTestPassword(t, f, f.userA, "Actual2024clave")
+	_, recovery := enrollMFA(t, f, f.tokenA)
+	ch := passwordLogin(t, f, f.userA, "Actual2024clave")
+	body := fmt.Sprintf(`{"mfa_token": "%s", "code": "000000"}`, ch.MFAToken)
+	for i := 0; i < loginguard.DelayAfter; i++ {
+		if w := f.do("", "POST", "/api/login/mfa", body); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Código %d: esperado 401, fue %d", i+1, w.Code)
+		}
+	}
+	if w := f.do("", "POST", "/api/login/mfa", body); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Los códigos 2FA fallidos deben aplicar la demora: esperado 429, fue %d", w.Code)
+	}
+
+	// Pasada la demora, repetir la contraseña no reinicia el contador de códigos
+	past := time.Now().Add(-time.Hour)
+	f.db.Model(&models.User{}).Where("id = ?", f.userA.ID).UpdateColumn("last_failed_login_at", past)
+	ch = passwordLogin(t, f, f.userA, "Actual2024clave")
+	var user models.User
+	f.db.First(&user, "id = ?", f.userA.ID)
+	if !ch.MFARequired || user.FailedLogins != loginguard.DelayAfter {
+		t.Fatalf("La contraseña sola no debe limpiar los fallos: desafío %v, fallos %d", ch.MFARequired, user.FailedLogins)
+	}
+	body = fmt.Sprintf(`{"mfa_token": "%s", "code": "%s"}`, ch.MFAToken, recovery[0])
+	if w := f.do("", "POST", "/api/login/mfa", body); w.Code != http.StatusOK {
+		t.Fatalf("Login 2FA correcto: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	f.db.First(&user, "id = ?", f.userA.ID)
+	if user.FailedLogins != 0 {
+		t.Errorf("El login completo debe limpiar los fallos, quedan %d", user.FailedLogins)
+	}
+}
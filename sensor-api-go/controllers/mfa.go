@@ -0,0 +1,555 @@
+// controllers/mfa.go
+
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/loginguard"
+	"sensor-api-go/mfa"
+	"sensor-api-go/middleware"
+	"sensor-api-go/models"
+	"sensor-api-go/rbac"
+	"sensor-api-go/sessions"
+	"sensor-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var errInvalidMFACode = errors.New("código inválido")
+
+// mfaRequiredByPolicy indica si la empresa exige 2FA al rol del usuario
+func mfaRequiredByPolicy(db *gorm.DB, user models.User) (bool, error) {
+	if !rbac.IsAdmin(user.Role) {
+		return false, nil
+	}
+	var company models.Company
+	err := db.Select("id", "require_mfa_for_admins").First(&company, "id = ?", user.CompanyID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return company.RequireMFAForAdmins, err
+}
+
+// respondMFAChallenge responde el desafío de 2FA si el usuario lo necesita. Retorna
+// true si ya respondió (desafío o error) y el login no debe continuar.
+func respondMFAChallenge(c *gin.Context, db *gorm.DB, user models.User) bool {
+	enroll := false
+	if !user.MFAEnabled {
+		required, err := mfaRequiredByPolicy(db, user)
+		if err != nil {
+			log.Printf("[ERROR] No se pudo leer la política de 2FA de %s: %v", user.CompanyID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+			return true
+		}
+		if !required {
+			return false
+		}
+		enroll = true
+	}
+	challenge, err := utils.GenerateMFAChallenge(user.ID.String(), enroll)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return true
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"mfa_required":            true,
+		"mfa_enrollment_required": enroll,
+		"mfa_token":               challenge,
+		"expires_in":              int(utils.MFAChallengeTTL.Seconds()),
+	})
+	return true
+}
+
+// challengedUser valida el token del desafío y retorna el usuario (activo)
+func challengedUser(c *gin.Context, db *gorm.DB, token string) (models.User, bool, bool) {
+	userID, enroll, err := utils.ValidateMFAChallenge(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El desafío 2FA es inválido o venció; inicie sesión nuevamente"})
+		return models.User{}, false, false
+	}
+	var user models.User
+	if err := db.First(&user, "id = ? AND status = ?", userID, "Active").Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "El desafío 2FA es inválido o venció; inicie sesión nuevamente"})
+		return models.User{}, false, false
+	}
+	return user, enroll, true
+}
+
+type MFALoginInput struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // código TOTP o de recuperación
+}
+
+// LoginMFA completa el login con el código de la app (o uno de recuperación). Si el
+// desafío es de activación, el código confirma el secreto y se entregan los códigos
+// de recuperación junto con los tokens.
+func LoginMFA(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input MFALoginInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user, enroll, ok := challengedUser(c, db, input.MFAToken)
+		if !ok {
+			return
+		}
+		now := time.Now()
+		if err := loginguard.CheckIP(db, c.ClientIP(), now); err != nil {
+			respondLoginBlocked(c, err)
+			return
+		}
+		if err := loginguard.CheckAccount(user, now); err != nil {
+			respondLoginBlocked(c, err)
+			return
+		}
+
+		var extra gin.H
+		if enroll {
+			if user.MFASecret == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Primero genere el secreto en /api/login/mfa/enroll"})
+				return
+			}
+			codes, err := activateMFA(db, user, input.Code)
+			if err != nil {
+				respondMFAError(c, err)
+				return
+			}
+			extra = gin.H{"recovery_codes": codes}
+		} else {
+			if !user.MFAEnabled {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "El desafío 2FA es inválido o venció; inicie sesión nuevamente"})
+				return
+			}
+			if err := verifySecondFactor(db, user, input.Code); err != nil {
+				// Los códigos fallidos cuentan igual que las contraseñas incorrectas
+				if errors.Is(err, errInvalidMFACode) {
+					recordLoginFailure(db, user.CompanyID, user.Email, c.ClientIP(), &user)
+				}
+				respondMFAError(c, err)
+				return
+			}
+		}
+		clearLoginFailures(db, user)
+
+		session, refreshToken, err := sessions.Start(db, user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			log.Printf("[ERROR] No se pudo abrir la sesión de %s: %v", user.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+			return
+		}
+		describeLogin(c, user, session, "mfa")
+		respondTokensWith(c, user, session, refreshToken, extra)
+	}
+}
+
+type MFAChallengeInput struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// LoginMFAEnroll genera el secreto de un admin que debe activar 2FA para entrar
+func LoginMFAEnroll(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input MFAChallengeInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user, enroll, ok := challengedUser(c, db, input.MFAToken)
+		if !ok {
+			return
+		}
+		if !enroll || user.MFAEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "La autenticación de dos factores ya está activa"})
+			return
+		}
+		startEnrollment(c, db, user)
+	}
+}
+
+// currentUser carga el usuario del token, dentro de su empresa
+func currentUser(c *gin.Context, db *gorm.DB) (models.User, bool) {
+	tdb, _, ok := tenantDB(c, db)
+	if !ok {
+		return models.User{}, false
+	}
+	var user models.User
+	if err := tdb.First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// GetMFAStatus informa si el usuario tiene 2FA, si su empresa se lo exige y cuántos
+// códigos de recuperación le quedan
+func GetMFAStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		required, err := mfaRequiredByPolicy(db, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var remaining int64
+		if err := db.Model(&models.MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":                  user.MFAEnabled,
+			"enrolled_at":              user.MFAEnrolledAt,
+			"required":                 required,
+			"recovery_codes_remaining": remaining,
+		})
+	}
+}
+
+// StartMFAEnrollment genera un secreto nuevo para el usuario del token. No se activa
+// hasta verificar un código con VerifyMFAEnrollment.
+func StartMFAEnrollment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		if user.MFAEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "La autenticación de dos factores ya está activa"})
+			return
+		}
+		startEnrollment(c, db, user)
+	}
+}
+
+func startEnrollment(c *gin.Context, db *gorm.DB, user models.User) {
+	enrollment, err := mfa.NewEnrollment(user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo generar el secreto"})
+		return
+	}
+	if err := db.Model(&user).UpdateColumns(map[string]interface{}{"mfa_secret": enrollment.Secret, "mfa_last_step": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo guardar el secreto"})
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+type MFACodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// VerifyMFAEnrollment activa 2FA con el primer código de la app y retorna los códigos
+// de recuperación (se muestran una sola vez)
+func VerifyMFAEnrollment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input MFACodeInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		if user.MFAEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "La autenticación de dos factores ya está activa"})
+			return
+		}
+		if user.MFASecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Primero genere el secreto en /api/mfa/enroll"})
+			return
+		}
+		codes, err := activateMFA(db, user, input.Code)
+		if err != nil {
+			respondMFAError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// RegenerateRecoveryCodes reemplaza los códigos de recuperación; exige un código TOTP
+func RegenerateRecoveryCodes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input MFACodeInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		if !user.MFAEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "La autenticación de dos factores no está activa"})
+			return
+		}
+		if err := verifyTOTP(db, user, input.Code); err != nil {
+			respondMFAError(c, err)
+			return
+		}
+		var codes []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			codes, err = replaceRecoveryCodes(tx, user.ID)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron generar los códigos"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+type DisableMFAInput struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// DisableMFA desactiva 2FA con la contraseña y un código. No se permite si la
+// empresa lo exige para el rol del usuario.
+func DisableMFA(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input DisableMFAInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		if !user.MFAEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "La autenticación de dos factores no está activa"})
+			return
+		}
+		required, err := mfaRequiredByPolicy(db, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if required {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Su empresa exige 2FA para su rol", "code": middleware.ErrCodePermissionDenied})
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "La contraseña es incorrecta"})
+			return
+		}
+		if err := verifySecondFactor(db, user, input.Code); err != nil {
+			respondMFAError(c, err)
+			return
+		}
+		if err := clearMFA(db, user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo desactivar 2FA"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Autenticación de dos factores desactivada"})
+	}
+}
+
+// ResetUserMFA permite a un admin quitar el 2FA de un usuario que perdió su teléfono
+// y sus códigos de recuperación. El usuario deberá activarlo de nuevo si es obligatorio.
+func ResetUserMFA(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuario inválido"})
+			return
+		}
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var user models.User
+		if err := tdb.First(&user, "id = ?", uid).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		if !rbac.CanManage(c.GetString("role"), user.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No puede modificar usuarios con un rol superior al suyo", "code": middleware.ErrCodePermissionDenied})
+			return
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := clearMFA(tx, user.ID); err != nil {
+				return err
+			}
+			// Quien haya entrado con el segundo factor perdido no conserva la sesión
+			return sessions.RevokeAllForUser(tx, user.ID, sessions.ReasonMFAReset)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo restablecer 2FA"})
+			return
+		}
+		audit.Describe(c, audit.Target{Action: "user.mfa_reset", Type: "user", ID: user.ID.String()})
+		c.JSON(http.StatusOK, gin.H{"message": "2FA restablecido; el usuario deberá activarlo nuevamente"})
+	}
+}
+
+// activateMFA verifica el primer código contra el secreto pendiente, activa 2FA y
+// genera los códigos de recuperación
+func activateMFA(db *gorm.DB, user models.User, code string) ([]string, error) {
+	step, ok := mfa.Verify(user.MFASecret, code, user.MFALastStep, time.Now())
+	if !ok {
+		return nil, errInvalidMFACode
+	}
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&models.User{}).
+			Where("id = ? AND mfa_enabled = ? AND mfa_secret = ?", user.ID, false, user.MFASecret).
+			UpdateColumns(map[string]interface{}{"mfa_enabled": true, "mfa_enrolled_at": now, "mfa_last_step": step})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInvalidMFACode // otro proceso activó o cambió el secreto
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// verifySecondFactor acepta un código TOTP o un código de recuperación sin usar
+func verifySecondFactor(db *gorm.DB, user models.User, code string) error {
+	if mfa.LooksLikeRecoveryCode(code) {
+		res := db.Model(&models.MFARecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, mfa.HashRecoveryCode(code)).
+			UpdateColumn("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInvalidMFACode
+		}
+		return nil
+	}
+	return verifyTOTP(db, user, code)
+}
+
+// verifyTOTP valida el código y registra su paso para que no se pueda reusar
+func verifyTOTP(db *gorm.DB, user models.User, code string) error {
+	step, ok := mfa.Verify(user.MFASecret, code, user.MFALastStep, time.Now())
+	if !ok {
+		return errInvalidMFACode
+	}
+	res := db.Model(&models.User{}).
+		Where("id = ? AND mfa_last_step < ?", user.ID, step).
+		UpdateColumn("mfa_last_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errInvalidMFACode // el mismo código se usó en paralelo
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	plain, hashes, err := mfa.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	rows := make([]models.MFARecoveryCode, len(hashes))
+	for i, h := range hashes {
+		rows[i] = models.MFARecoveryCode{ID: uuid.New(), UserID: userID, CodeHash: h, CreatedAt: now}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+func clearMFA(db *gorm.DB, userID uuid.UUID) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+			"mfa_enabled": false, "mfa_secret": "", "mfa_enrolled_at": nil, "mfa_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error
+	})
+}
+
+func respondMFAError(c *gin.Context, err error) {
+	if errors.Is(err, errInvalidMFACode) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Código de verificación inválido"})
+		return
+	}
+	log.Printf("[ERROR] Verificación 2FA: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo verificar el código"})
+}
+
+// SecurityPolicyInput son las políticas de seguridad editables de la empresa
+type SecurityPolicyInput struct {
+	RequireMFAForAdmins *bool `json:"require_mfa_for_admins" binding:"required"`
+}
+
+// GetSecurityPolicy retorna las políticas de seguridad de la empresa del token
+func GetSecurityPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var company models.Company
+		if err := db.First(&company, "id = ?", companyID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Empresa no encontrada"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"require_mfa_for_admins": company.RequireMFAForAdmins})
+	}
+}
+
+// UpdateSecurityPolicy cambia las políticas de seguridad de la empresa del token.
+// Los admins sin 2FA deberán activarlo en su próximo login.
+func UpdateSecurityPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input SecurityPolicyInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var before models.Company
+		if err := db.First(&before, "id = ?", companyID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Empresa no encontrada"})
+			return
+		}
+		res := db.Model(&models.Company{}).Where("id = ?", companyID).
+			UpdateColumn("require_mfa_for_admins", *input.RequireMFAForAdmins)
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo guardar la política"})
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Empresa no encontrada"})
+			return
+		}
+		after := before
+		after.RequireMFAForAdmins = *input.RequireMFAForAdmins
+		audit.Describe(c, audit.Target{Action: "company.security_updated", Type: "company", ID: companyID.String(), Before: before, After: after})
+		c.JSON(http.StatusOK, gin.H{"require_mfa_for_admins": *input.RequireMFAForAdmins})
+	}
+}
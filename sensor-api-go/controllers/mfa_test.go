@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+)
+
+type mfaChallenge struct {
+	MFARequired           bool   `json:"mfa_required"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required"`
+	MFAToken              string `json:"mfa_token"`
+}
+
+// passwordLogin hace el primer paso del login y retorna el desafío (vacío si no hay 2FA)
+func passwordLogin(t *testing.T, f *tenantFixture, user models.User, password string) mfaChallenge {
+	t.Helper()
+	w := f.do("", "POST", "/api/login", fmt.Sprintf(`{"email": "%s", "password": "%s", "company_id": "%s"}`, user.Email, password, user.CompanyID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Login: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var ch mfaChallenge
+	json.Unmarshal(w.Body.Bytes(), &ch)
+	return ch
+}
+
+// enrollMFA activa 2FA para el usuario del token y retorna el secreto y los códigos
+func enrollMFA(t *testing.T, f *tenantFixture, token string) (string, []string) {
+	t.Helper()
+	w := f.do(token, "POST", "/api/mfa/enroll", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Enroll: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var enrollment struct {
+		Secret string `json:"secret"`
+		URL    string `json:"otpauth_url"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &enrollment)
+	if enrollment.Secret == "" || enrollment.URL == "" {
+		t.Fatalf("Enroll sin secreto: %s", w.Body.String())
+	}
+	code, _ := totp.GenerateCode(enrollment.Secret, time.Now())
+	w = f.do(token, "POST", "/api/mfa/enroll/verify", fmt.Sprintf(`{"code": "%s"}`, code))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Verify: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return enrollment.Secret, resp.RecoveryCodes
+}
+
+func TestMFA_EnrollAndLogin(t *testing.T) {
+	f := newTenantFixture(t)
+	setTestPassword(t, f, f.userA, "Actual2024clave")
+	if ch := passwordLogin(t, f, f.userA, "Actual2024clave"); ch.MFARequired {
+		t.Fatal("Sin 2FA el login no debe pedir un segundo factor")
+	}
+
+	secret, recovery := enrollMFA(t, f, f.tokenA)
+	if len(recovery) != 10 {
+		t.Fatalf("Esperados 10 códigos de recuperación, fueron %d", len(recovery))
+	}
+
+	ch := passwordLogin(t, f, f.userA, "Actual2024clave")
+	if !ch.MFARequired || ch.MFAEnrollmentRequired || ch.MFAToken == "" {
+		t.Fatalf("Con 2FA el login debe retornar un desafío: %+v", ch)
+	}
+	if w := f.do("", "POST", "/api/login/mfa", fmt.Sprintf(`{"mfa_token": "%s", "code": "000000"}`, ch.MFAToken)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Código incorrecto: esperado 401, fue %d", w.Code)
+	}
+
+	// El código de la activación ya se usó; el del paso siguiente entra por el desfase permitido
+	code, _ := totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+	body := fmt.Sprintf(`{"mfa_token": "%s", "code": "%s"}`, ch.MFAToken, code)
+	w := f.do("", "POST", "/api/login/mfa", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Login con código: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	token, _ := decodeTokens(t, w.Body.Bytes())
+	if w := f.do(token, "GET", "/api/users", ""); w.Code != http.StatusOK {
+		t.Errorf("El token del login con 2FA debe ser válido, fue %d", w.Code)
+	}
+	if w := f.do("", "POST", "/api/login/mfa", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("Reuso del mismo código: esperado 401, fue %d", w.Code)
+	}
+
+	// Un código de recuperación sirve una sola vez
+	body = fmt.Sprintf(`{"mfa_token": "%s", "code": "%s"}`, ch.MFAToken, recovery[0])
+	if w := f.do("", "POST", "/api/login/mfa", body); w.Code != http.StatusOK {
+		t.Errorf("Login con código de recuperación: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	if w := f.do("", "POST", "/api/login/mfa", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("Reuso de código de recuperación: esperado 401, fue %d", w.Code)
+	}
+	w = f.do(f.tokenA, "GET", "/api/mfa", "")
+	var status struct {
+		Enabled   bool  `json:"enabled"`
+		Remaining int64 `json:"recovery_codes_remaining"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if !status.Enabled || status.Remaining != 9 {
+		t.Errorf("Estado 2FA inesperado: %s", w.Body.String())
+	}
+}
+
+func TestMFA_DisableAndAdminReset(t *testing.T) {
+	f := newTenantFixture(t)
+	setTestPassword(t, f, f.userA, "Actual2024clave")
+	_, recovery := enrollMFA(t, f, f.tokenA)
+
+	if w := f.do(f.tokenA, "POST", "/api/mfa/disable", fmt.Sprintf(`{"password": "incorrecta", "code": "%s"}`, recovery[0])); w.Code != http.StatusUnauthorized {
+		t.Errorf("Desactivar con contraseña incorrecta: esperado 401, fue %d", w.Code)
+	}
+	if w := f.do(f.tokenA, "POST", "/api/mfa/disable", fmt.Sprintf(`{"password": "Actual2024clave", "code": "%s"}`, recovery[0])); w.Code != http.StatusOK {
+		t.Fatalf("Desactivar: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	if ch := passwordLogin(t, f, f.userA, "Actual2024clave"); ch.MFARequired {
+		t.Error("Tras desactivar 2FA el login no debe pedir un segundo factor")
+	}
+
+	// Un admin de otra empresa no puede restablecer el 2FA
+	enrollMFA(t, f, f.tokenA)
+	if w := f.do(f.tokenB, "DELETE", "/api/users/"+f.userA.ID.String()+"/mfa", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Restablecer 2FA de otra empresa: esperado 404, fue %d", w.Code)
+	}
+	admin := models.User{ID: uuid.New(), CompanyID: f.a, Name: "Admin 2", Email: "admin2@example.com", Password: "x", Role: "admin", Status: "Active"}
+	f.db.Create(&admin)
+	adminToken, _ := f.login(t, admin)
+	if w := f.do(adminToken, "DELETE", "/api/users/"+f.userA.ID.String()+"/mfa", ""); w.Code != http.StatusOK {
+		t.Fatalf("Restablecer 2FA: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	if w := f.do(f.tokenA, "GET", "/api/mfa", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Las sesiones del usuario deben quedar revocadas, fue %d", w.Code)
+	}
+	if ch := passwordLogin(t, f, f.userA, "Actual2024clave"); ch.MFARequired {
+		t.Error("Tras restablecer 2FA el login no debe pedir un segundo factor")
+	}
+}
+
+func TestMFA_PolicyForcesAdminEnrollment(t *testing.T) {
+	f := newTenantFixture(t)
+	setTestPassword(t, f, f.userA, "Actual2024clave")
+	operator := models.User{ID: uuid.New(), CompanyID: f.a, Name: "Op", Email: "op@example.com", Role: "operator", Status: "Active"}
+	f.db.Create(&operator)
+	setTestPassword(t, f, operator, "Operador2024x")
+
+	if w := f.do(f.tokenA, "PUT", "/api/company/security", `{"require_mfa_for_admins": true}`); w.Code != http.StatusOK {
+		t.Fatalf("Política: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	if ch := passwordLogin(t, f, operator, "Operador2024x"); ch.MFARequired {
+		t.Error("La política solo aplica a admins")
+	}
+
+	ch := passwordLogin(t, f, f.userA, "Actual2024clave")
+	if !ch.MFARequired || !ch.MFAEnrollmentRequired {
+		t.Fatalf("Un admin sin 2FA debe activarlo al entrar: %+v", ch)
+	}
+	// El desafío no sirve como sesión ni para saltarse la activación
+	if w := f.do("", "POST", "/api/login/mfa", fmt.Sprintf(`{"mfa_token": "%s", "code": "000000"}`, ch.MFAToken)); w.Code != http.StatusBadRequest {
+		t.Errorf("Código sin secreto generado: esperado 400, fue %d", w.Code)
+	}
+	w := f.do("", "POST", "/api/login/mfa/enroll", fmt.Sprintf(`{"mfa_token": "%s"}`, ch.MFAToken))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Enroll en login: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &enrollment)
+	code, _ := totp.GenerateCode(enrollment.Secret, time.Now())
+	w = f.do("", "POST", "/api/login/mfa", fmt.Sprintf(`{"mfa_token": "%s", "code": "%s"}`, ch.MFAToken, code))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Activación en login: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	token, _ := decodeTokens(t, w.Body.Bytes())
+	var resp struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.RecoveryCodes) != 10 {
+		t.Errorf("La activación debe entregar los códigos de recuperación: %s", w.Body.String())
+	}
+	if w := f.do(token, "POST", "/api/mfa/disable", fmt.Sprintf(`{"password": "Actual2024clave", "code": "%s"}`, resp.RecoveryCodes[0])); w.Code != http.StatusForbidden {
+		t.Errorf("Desactivar 2FA exigido por la política: esperado 403, fue %d", w.Code)
+	}
+	if ch := passwordLogin(t, f, f.userA, "Actual2024clave"); !ch.MFARequired || ch.MFAEnrollmentRequired {
+		t.Errorf("Con 2FA activo el login debe pedir el código: %+v", ch)
+	}
+}
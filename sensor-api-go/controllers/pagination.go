@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"net/http"
+
+	"sensor-api-go/pagination"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// findPage lee limit y cursor de la solicitud, trae la página de q ordenada por
+// (timeCol, idCol) descendente y agrega el encabezado Link. Si falla ya respondió
+// al cliente (400 o 500) y retorna ok = false. La respuesta de los listados es
+// {"items": [...], "next_cursor": "..."}.
+func findPage[T any](c *gin.Context, q *gorm.DB, timeCol, idCol string, key func(T) pagination.Cursor) (items []T, next string, ok bool) {
+	page, err := pagination.FromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, "", false
+	}
+	if err := q.Scopes(page.Scope(timeCol, idCol)).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo obtener el listado"})
+		return nil, "", false
+	}
+	if items == nil {
+		items = []T{}
+	}
+	items, next = pagination.Trim(page, items, key)
+	pagination.SetNextLink(c, next)
+	return items, next, true
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+)
+
+type readingsPage struct {
+	Items      []models.CameraReading `json:"items"`
+	NextCursor string                 `json:"next_cursor"`
+}
+
+func TestCameraReadings_FiltersAndCursor(t *testing.T) {
+	f := newTenantFixture(t)
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var readings []models.CameraReading
+	for i := 0; i < 5; i++ {
+		// Dos lecturas por instante: el cursor debe desempatar por id
+		at := base.Add(time.Duration(i) * time.Minute)
+		readings = append(readings,
+			models.CameraReading{CompanyID: f.a, CameraID: 7, ZoneID: 1, Temperature: float64(10 + i), Timestamp: at},
+			models.CameraReading{CompanyID: f.a, CameraID: 7, ZoneID: 2, Temperature: float64(20 + i), Timestamp: at})
+	}
+	readings = append(readings, models.CameraReading{CompanyID: f.b, CameraID: 7, ZoneID: 1, Temperature: 12, Timestamp: base})
+	f.db.Create(&readings)
+
+	var seen []models.CameraReading
+	cursor := ""
+	for pages := 0; ; pages++ {
+		w := f.do(f.tokenA, "GET", "/api/camera-readings?camera_id=7&limit=3&cursor="+url.QueryEscape(cursor), "")
+		var page readingsPage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("Página %d: %d %s", pages, w.Code, w.Body.String())
+		}
+		seen = append(seen, page.Items...)
+		if page.NextCursor == "" {
+			if w.Header().Get("Link") != "" {
+				t.Errorf("La última página no debe tener Link: %q", w.Header().Get("Link"))
+			}
+			break
+		}
+		if !strings.Contains(w.Header().Get("Link"), `rel="next"`) {
+			t.Errorf("Falta el Link rel=next: %q", w.Header().Get("Link"))
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 10 {
+		t.Fatalf("Esperadas 10 lecturas de A en la cámara 7, fueron %d", len(seen))
+	}
+	ids := map[uint]bool{}
+	for i, r := range seen {
+		if ids[r.ID] || r.CompanyID != f.a {
+			t.Errorf("Lectura %d repetida o ajena: %+v", r.ID, r)
+		}
+		ids[r.ID] = true
+		if i > 0 && (r.Timestamp.After(seen[i-1].Timestamp) || r.Timestamp.Equal(seen[i-1].Timestamp) && r.ID > seen[i-1].ID) {
+			t.Errorf("Orden incorrecto en la posición %d", i)
+		}
+	}
+
+	query := fmt.Sprintf("?camera_id=7&zone_id=2&desde=%s&hasta=%s&min_temp=21&max_temp=23",
+		base.Format(time.RFC3339), base.Add(3*time.Minute).Format(time.RFC3339))
+	var filtered readingsPage
+	json.Unmarshal(f.do(f.tokenA, "GET", "/api/camera-readings"+query, "").Body.Bytes(), &filtered)
+	if len(filtered.Items) != 2 || filtered.Items[0].Temperature != 22 || filtered.Items[1].Temperature != 21 {
+		t.Errorf("Filtros: esperadas 22 y 21, fue %+v", filtered.Items)
+	}
+
+	for _, q := range []string{"zone_id=x", "desde=ayer", "min_temp=NaN", "limit=5000", "limit=0", "cursor=xyz"} {
+		if w := f.do(f.tokenA, "GET", "/api/camera-readings?"+q, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: esperado 400, fue %d", q, w.Code)
+		}
+	}
+}
+
+func TestListPagination_UsersAndAlerts(t *testing.T) {
+	f := newTenantFixture(t)
+	for i := 0; i < 2; i++ {
+		body := fmt.Sprintf(`{"name": "U%d", "email": "u%d@example.com", "role": "viewer"}`, i, i)
+		if w := f.do(f.tokenA, "POST", "/api/invitations", body); w.Code != http.StatusOK {
+			t.Fatalf("Invitar usuario: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := f.do(f.tokenA, "GET", "/api/users?limit=2", "")
+	var page struct {
+		Items      []map[string]interface{} `json:"items"`
+		NextCursor string                   `json:"next_cursor"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("Primera página de usuarios: %s", w.Body.String())
+	}
+	for _, u := range page.Items {
+		if u["Password"] != "" {
+			t.Errorf("El listado no debe incluir la contraseña: %v", u)
+		}
+	}
+	rest := decodeItems(t, f.do(f.tokenA, "GET", "/api/users?limit=2&cursor="+url.QueryEscape(page.NextCursor), ""))
+	if len(rest) != 1 || rest[0]["ID"] == page.Items[0]["ID"] || rest[0]["ID"] == page.Items[1]["ID"] {
+		t.Errorf("Segunda página de usuarios: %v", rest)
+	}
+
+	alerts := decodeItems(t, f.do(f.tokenA, "GET", "/api/zone-alerts?limit=1", ""))
+	if len(alerts) != 1 || alerts[0]["ID"] != f.alertA.ID.String() {
+		t.Errorf("Alertas de A: %v", alerts)
+	}
+}
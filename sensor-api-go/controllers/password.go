@@ -0,0 +1,240 @@
+// controllers/password.go
+
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"sensor-api-go/models"
+	"sensor-api-go/sessions"
+	"sensor-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Mailer envía un correo; en producción es utils.SendEmail
+type Mailer func(to, subject, body string) error
+
+// Runner ejecuta una tarea sin que la solicitud la espere; en producción es Background
+type Runner func(task func())
+
+// Background corre la tarea en una goroutine aparte
+func Background(task func()) {
+	go task()
+}
+
+const (
+	// Vigencia del enlace de restablecimiento
+	passwordResetTTL = time.Hour
+	// Máximo de enlaces enviados a una misma cuenta por hora (además del límite por IP)
+	maxPasswordResetsPerHour = 3
+)
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword cambia la contraseña del usuario del token. Revoca todas sus sesiones
+// y abre una nueva, cuyos tokens se retornan.
+func ChangePassword(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input ChangePasswordInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var user models.User
+		if err := tdb.First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "La contraseña actual es incorrecta"})
+			return
+		}
+		if input.NewPassword == input.CurrentPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "La nueva contraseña debe ser distinta de la actual"})
+			return
+		}
+		hashed, err := utils.HashPassword(input.NewPassword, user.Email)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := setPassword(db, user.ID, hashed); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo cambiar la contraseña"})
+			return
+		}
+		session, refreshToken, err := sessions.Start(db, user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			log.Printf("[ERROR] No se pudo abrir la sesión de %s: %v", user.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Contraseña cambiada; inicie sesión nuevamente"})
+			return
+		}
+		respondTokens(c, user, session, refreshToken)
+	}
+}
+
+type ForgotPasswordInput struct {
+	Email     string `json:"email" binding:"required"`
+	CompanyID string `json:"company_id"`
+}
+
+// ForgotPassword envía un enlace para restablecer la contraseña. Siempre responde lo
+// mismo y en el mismo tiempo, exista o no la cuenta, para no revelar qué emails
+// están registrados.
+func ForgotPassword(db *gorm.DB, send Mailer, run Runner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input ForgotPasswordInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// El envío corre aparte: si la respuesta esperara al correo, su demora
+		// revelaría qué emails tienen cuenta
+		ip, now := c.ClientIP(), time.Now()
+		run(func() {
+			if err := sendPasswordReset(db, send, input, ip, now); err != nil {
+				log.Printf("[ERROR] Restablecimiento de contraseña para %s: %v", input.Email, err)
+			}
+		})
+		c.JSON(http.StatusAccepted, gin.H{"message": "Si la cuenta existe, enviaremos un enlace para restablecer la contraseña"})
+	}
+}
+
+func sendPasswordReset(db *gorm.DB, send Mailer, input ForgotPasswordInput, ip string, now time.Time) error {
+	q := db.Where("email = ? AND status = ?", strings.TrimSpace(input.Email), "Active")
+	if input.CompanyID != "" {
+		q = q.Where("company_id = ?", input.CompanyID)
+	}
+	var user models.User
+	if err := q.First(&user).Error; err != nil {
+		return nil // cuenta inexistente o inactiva: no se envía nada
+	}
+
+	var recent int64
+	if err := db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, now.Add(-time.Hour)).
+		Count(&recent).Error; err != nil {
+		return err
+	}
+	if recent >= maxPasswordResetsPerHour {
+		log.Printf("[WARN] Límite de restablecimientos alcanzado para el usuario %s", user.ID)
+		return nil
+	}
+
+	plain, hash, err := utils.GeneratePasswordResetToken()
+	if err != nil {
+		return err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Solo el último enlace enviado es válido
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			UpdateColumn("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			ID: uuid.New(), UserID: user.ID, TokenHash: hash,
+			ExpiresAt: now.Add(passwordResetTTL), RequestIP: ip, CreatedAt: now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", frontendURL(), plain)
+	body := fmt.Sprintf(`<p>Hola %s,</p>
+<p>Recibimos una solicitud para restablecer tu contraseña. El enlace es válido por %d minutos:</p>
+<p><a href="%s">%s</a></p>
+<p>Si no fuiste tú, ignora este correo; tu contraseña no cambiará.</p>`, user.Name, int(passwordResetTTL.Minutes()), link, link)
+	return send(user.Email, "Restablecer contraseña", body)
+}
+
+// frontendURL es la base de los enlaces enviados por email
+func frontendURL() string {
+	if u := os.Getenv("FRONTEND_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://localhost:5173"
+}
+
+type ResetPasswordInput struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ResetPassword fija una nueva contraseña con el token recibido por email y revoca
+// todas las sesiones del usuario
+func ResetPassword(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input ResetPasswordInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		now := time.Now()
+		var token models.PasswordResetToken
+		if err := db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashAPIKey(input.Token), now).
+			First(&token).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "El enlace es inválido o ya venció"})
+			return
+		}
+		var user models.User
+		if err := db.Where("id = ? AND status = ?", token.UserID, "Active").First(&user).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "El enlace es inválido o ya venció"})
+			return
+		}
+		hashed, err := utils.HashPassword(input.NewPassword, user.Email)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// El token se consume solo si nadie lo usó antes
+			res := tx.Model(&models.PasswordResetToken{}).
+				Where("id = ? AND used_at IS NULL", token.ID).
+				UpdateColumn("used_at", now)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return setPassword(tx, user.ID, hashed)
+		})
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "El enlace es inválido o ya venció"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo restablecer la contraseña"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Contraseña actualizada; inicie sesión con la nueva contraseña"})
+	}
+}
+
+// setPassword guarda el hash y revoca todas las sesiones del usuario
+func setPassword(db *gorm.DB, userID uuid.UUID, hashed string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("password", hashed).Error; err != nil {
+			return err
+		}
+		return sessions.RevokeAllForUser(tx, userID, sessions.ReasonPasswordChanged)
+	})
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func setTestPassword(t *testing.T, f *tenantFixture, user models.User, password string) {
+	t.Helper()
+	hashed, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err := f.db.Model(&user).UpdateColumn("password", string(hashed)).Error; err != nil {
+		t.Fatalf("No se pudo fijar la contraseña: %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	f := newTenantFixture(t)
+	setTestPassword(t, f, f.userA, "Actual2024clave")
+	other, _ := f.login(t, f.userA)
+
+	if w := f.do(f.tokenA, "POST", "/api/password/change", `{"current_password": "incorrecta", "new_password": "Nueva2025clave"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Contraseña actual incorrecta: esperado 401, fue %d", w.Code)
+	}
+	if w := f.do(f.tokenA, "POST", "/api/password/change", `{"current_password": "Actual2024clave", "new_password": "corta"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Contraseña débil: esperado 400, fue %d", w.Code)
+	}
+
+	w := f.do(f.tokenA, "POST", "/api/password/change", `{"current_password": "Actual2024clave", "new_password": "Nueva2025clave"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Cambio de contraseña: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	token, _ := decodeTokens(t, w.Body.Bytes())
+
+	// Las sesiones anteriores se revocan; la nueva sigue activa
+	for _, old := range []string{f.tokenA, other} {
+		if w := f.do(old, "GET", "/api/users", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Sesión anterior al cambio: esperado 401, fue %d", w.Code)
+		}
+	}
+	if w := f.do(token, "GET", "/api/users", ""); w.Code != http.StatusOK {
+		t.Errorf("Sesión nueva: esperado 200, fue %d", w.Code)
+	}
+	var stored models.User
+	f.db.First(&stored, "id = ?", f.userA.ID)
+	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("Nueva2025clave")) != nil {
+		t.Error("La contraseña nueva no quedó guardada")
+	}
+}
+
+var resetTokenRe = regexp.MustCompile(`token=(imp_[0-9a-f]+)`)
+
+func TestPasswordReset(t *testing.T) {
+	f := newTenantFixture(t)
+
+	// Un email desconocido recibe la misma respuesta y no genera correo
+	if w := f.do("", "POST", "/api/password/forgot", `{"email": "nadie@example.com"}`); w.Code != http.StatusAccepted {
+		t.Errorf("Email desconocido: esperado 202, fue %d", w.Code)
+	}
+	if len(f.mails) != 0 {
+		t.Fatalf("No se debe enviar correo a cuentas inexistentes: %+v", f.mails)
+	}
+
+	f.do("", "POST", "/api/password/forgot", `{"email": "a@example.com"}`)
+	if len(f.mails) != 1 || f.mails[0].to != "a@example.com" {
+		t.Fatalf("Esperado un correo para a@example.com: %+v", f.mails)
+	}
+	match := resetTokenRe.FindStringSubmatch(f.mails[0].body)
+	if match == nil {
+		t.Fatalf("El correo no tiene el enlace: %s", f.mails[0].body)
+	}
+	body := `{"token": "%s", "new_password": "%s"}`
+
+	if w := f.do("", "POST", "/api/password/reset", fmt.Sprintf(body, match[1], "debil")); w.Code != http.StatusBadRequest {
+		t.Errorf("Contraseña débil: esperado 400, fue %d", w.Code)
+	}
+	if w := f.do("", "POST", "/api/password/reset", fmt.Sprintf(body, match[1], "Restablecida2025")); w.Code != http.StatusOK {
+		t.Fatalf("Restablecer: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	if w := f.do(f.tokenA, "GET", "/api/users", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Las sesiones se revocan al restablecer: esperado 401, fue %d", w.Code)
+	}
+	// El token es de un solo uso
+	if w := f.do("", "POST", "/api/password/reset", fmt.Sprintf(body, match[1], "OtraClave2025")); w.Code != http.StatusBadRequest {
+		t.Errorf("Reuso del token: esperado 400, fue %d", w.Code)
+	}
+	var stored models.User
+	f.db.First(&stored, "id = ?", f.userA.ID)
+	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("Restablecida2025")) != nil {
+		t.Error("La contraseña restablecida no quedó guardada")
+	}
+}
+
+func TestPasswordReset_ExpiredAndRateLimited(t *testing.T) {
+	f := newTenantFixture(t)
+	for i := 0; i < maxPasswordResetsPerHour+2; i++ {
+		f.do("", "POST", "/api/password/forgot", `{"email": "b@example.com"}`)
+	}
+	if len(f.mails) != maxPasswordResetsPerHour {
+		t.Errorf("Esperados %d correos por hora, se enviaron %d", maxPasswordResetsPerHour, len(f.mails))
+	}
+
+	// Solo el último enlace es válido, y deja de serlo al vencer
+	last := resetTokenRe.FindStringSubmatch(f.mails[len(f.mails)-1].body)[1]
+	first := resetTokenRe.FindStringSubmatch(f.mails[0].body)[1]
+	body := `{"token": "%s", "new_password": "Restablecida2025"}`
+	if w := f.do("", "POST", "/api/password/reset", fmt.Sprintf(body, first)); w.Code != http.StatusBadRequest {
+		t.Errorf("Enlace reemplazado: esperado 400, fue %d", w.Code)
+	}
+	f.db.Model(&models.PasswordResetToken{}).Where("used_at IS NULL").UpdateColumn("expires_at", time.Now().Add(-time.Minute))
+	if w := f.do("", "POST", "/api/password/reset", fmt.Sprintf(body, last)); w.Code != http.StatusBadRequest {
+		t.Errorf("Enlace vencido: esperado 400, fue %d", w.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MaxRawRetentionDays es la retención máxima configurable (10 años)
+const MaxRawRetentionDays = 3650
+
+// RetentionInput es la retención de lecturas originales de la empresa. Las lecturas
+// más antiguas se borran (el job de rollups) y solo quedan sus agregados.
+type RetentionInput struct {
+	RawRetentionDays *int `json:"raw_retention_days" binding:"required"` // 0 = sin límite
+}
+
+// GetRetention retorna la retención de lecturas de la empresa del token
+func GetRetention(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var company models.Company
+		if err := db.First(&company, "id = ?", companyID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Empresa no encontrada"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"raw_retention_days": company.RawRetentionDays})
+	}
+}
+
+// UpdateRetention cambia la retención de lecturas de la empresa del token
+func UpdateRetention(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input RetentionInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		days := *input.RawRetentionDays
+		if days < 0 || days > MaxRawRetentionDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "raw_retention_days debe estar entre 0 (sin límite) y 3650"})
+			return
+		}
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var before models.Company
+		if err := db.First(&before, "id = ?", companyID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Empresa no encontrada"})
+			return
+		}
+		if err := db.Model(&models.Company{}).Where("id = ?", companyID).UpdateColumn("raw_retention_days", days).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo guardar la retención"})
+			return
+		}
+		after := before
+		after.RawRetentionDays = days
+		audit.Describe(c, audit.Target{Action: "company.retention_updated", Type: "company", ID: companyID.String(), Before: before, After: after})
+		c.JSON(http.StatusOK, gin.H{"raw_retention_days": days})
+	}
+}
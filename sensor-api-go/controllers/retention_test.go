@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	f := newTenantFixture(t)
+	if w := f.do(f.tokenA, "PUT", "/api/company/retention", `{"raw_retention_days": 90}`); w.Code != http.StatusOK {
+		t.Fatalf("Cambiar retención: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	for _, body := range []string{`{"raw_retention_days": -1}`, `{"raw_retention_days": 5000}`, `{}`} {
+		if w := f.do(f.tokenA, "PUT", "/api/company/retention", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: esperado 400, fue %d", body, w.Code)
+		}
+	}
+	var got map[string]int
+	json.Unmarshal(f.do(f.tokenA, "GET", "/api/company/retention", "").Body.Bytes(), &got)
+	if got["raw_retention_days"] != 90 {
+		t.Errorf("Retención de A: %v", got)
+	}
+	json.Unmarshal(f.do(f.tokenB, "GET", "/api/company/retention", "").Body.Bytes(), &got)
+	if got["raw_retention_days"] != 0 {
+		t.Errorf("La retención de A no debe afectar a B: %v", got)
+	}
+	if entries := listAudit(t, f, f.tokenA, "?action=company.retention_updated").Items; len(entries) != 1 {
+		t.Errorf("El cambio debe quedar en la auditoría: %+v", entries)
+	}
+}
+
+func TestCameraStatusDashboard_LongRangeIsSummarized(t *testing.T) {
+	f := newTenantFixture(t)
+	// Horas completas anteriores a la lectura del fixture (hace un minuto)
+	base := time.Now().UTC().Truncate(time.Hour).Add(-time.Hour)
+	for i := 1; i <= 30; i++ {
+		f.db.Create(&models.CameraReading{CompanyID: f.a, CameraID: 1, ZoneID: 1, Temperature: 20, Timestamp: base.Add(-time.Duration(i) * time.Hour)})
+	}
+
+	desde := url.QueryEscape(base.Add(-7 * 24 * time.Hour).Format(time.RFC3339))
+	var dashboard CameraDashboard
+	json.Unmarshal(f.do(f.tokenA, "GET", "/api/cameras/1/status?desde="+desde, "").Body.Bytes(), &dashboard)
+	if len(dashboard.Zonas) != 2 {
+		t.Fatalf("Esperadas 2 zonas: %+v", dashboard)
+	}
+	zone := dashboard.Zonas[0]
+	// 7 días en buckets de una hora: 31 promedios (las 30 lecturas y la del fixture)
+	// más la última lectura real
+	if len(zone.Readings) != 32 || zone.Readings[0].ID != 0 {
+		t.Errorf("Esperados 31 promedios por hora y la última lectura, fueron %d: %+v", len(zone.Readings), zone.Readings)
+	}
+	if zone.LastTemp == nil || *zone.LastTemp != 30 || zone.State != "Activo" {
+		t.Errorf("El estado debe usar la última lectura real: %+v", zone)
+	}
+}
@@ -0,0 +1,355 @@
+// controllers/sso.go
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/models"
+	"sensor-api-go/rbac"
+	"sensor-api-go/sessions"
+	"sensor-api-go/sso"
+	"sensor-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ssoNonceCookie ata el state del login OIDC al navegador que lo inició
+const ssoNonceCookie = "sso_nonce"
+
+// apiURL es la URL pública del API, para la redirect URI registrada en el proveedor
+func apiURL() string {
+	if u := os.Getenv("API_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://localhost:5000"
+}
+
+func ssoRedirectURL() string {
+	return apiURL() + "/api/sso/callback"
+}
+
+// loadSSO retorna la configuración SSO habilitada de la empresa
+func loadSSO(db *gorm.DB, companyID string) (models.CompanySSO, error) {
+	var cfg models.CompanySSO
+	if err := db.First(&cfg, "company_id = ?", companyID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cfg, sso.ErrNotConfigured
+		}
+		return cfg, err
+	}
+	if !cfg.Enabled {
+		return cfg, sso.ErrNotConfigured
+	}
+	return cfg, nil
+}
+
+// ssoEnforced indica si la empresa exige SSO y prohíbe el login con contraseña
+func ssoEnforced(db *gorm.DB, companyID uuid.UUID) (bool, error) {
+	cfg, err := loadSSO(db, companyID.String())
+	if errors.Is(err, sso.ErrNotConfigured) {
+		return false, nil
+	}
+	return cfg.EnforceSSO, err
+}
+
+// GetSSOStatus informa (sin autenticación) si la empresa ofrece login SSO
+func GetSSOStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg, err := loadSSO(db, c.Param("company_id"))
+		if err != nil && !errors.Is(err, sso.ErrNotConfigured) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo leer la configuración SSO"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"enabled": err == nil, "enforce_sso": err == nil && cfg.EnforceSSO})
+	}
+}
+
+// SSOLogin redirige el navegador al proveedor de identidad de la empresa
+func SSOLogin(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		companyID := c.Param("company_id")
+		cfg, err := loadSSO(db, companyID)
+		if err != nil {
+			redirectSSOError(c, err)
+			return
+		}
+		state, nonce, err := utils.GenerateSSOState(companyID)
+		if err != nil {
+			redirectSSOError(c, err)
+			return
+		}
+		authURL, err := sso.AuthURL(c.Request.Context(), cfg, ssoRedirectURL(), state, nonce)
+		if err != nil {
+			redirectSSOError(c, err)
+			return
+		}
+		setSSONonceCookie(c, nonce, int(utils.SSOStateTTL.Seconds()))
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+func setSSONonceCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	// Lax: la cookie viaja en la redirección de vuelta desde el proveedor
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoNonceCookie, value, maxAge, "/api/sso", "", secure, true)
+}
+
+// SSOCallback recibe el código del proveedor, valida el id_token, crea o vincula
+// al usuario y abre una sesión normal. Los tokens vuelven al frontend en el fragmento
+// de la URL, que el navegador no envía a ningún servidor.
+func SSOCallback(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if msg := c.Query("error"); msg != "" {
+			redirectSSOError(c, fmt.Errorf("el proveedor rechazó el login: %s", msg))
+			return
+		}
+		companyID, nonce, err := utils.ValidateSSOState(c.Query("state"))
+		if err != nil {
+			redirectSSOError(c, errors.New("el login SSO venció; intente nuevamente"))
+			return
+		}
+		cookie, err := c.Cookie(ssoNonceCookie)
+		if err != nil || cookie != nonce {
+			redirectSSOError(c, errors.New("el login SSO no se inició en este navegador"))
+			return
+		}
+		setSSONonceCookie(c, "", -1)
+
+		cfg, err := loadSSO(db, companyID)
+		if err != nil {
+			redirectSSOError(c, err)
+			return
+		}
+		identity, err := sso.Exchange(c.Request.Context(), cfg, ssoRedirectURL(), c.Query("code"), nonce)
+		if err != nil {
+			log.Printf("[WARN] Login SSO rechazado para la empresa %s: %v", companyID, err)
+			redirectSSOError(c, err)
+			return
+		}
+		user, err := provisionSSOUser(db, cfg, identity, c.ClientIP())
+		if err != nil {
+			log.Printf("[WARN] No se pudo provisionar %s en la empresa %s: %v", identity.Email, companyID, err)
+			redirectSSOError(c, err)
+			return
+		}
+		session, refreshToken, err := sessions.Start(db, user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			redirectSSOError(c, errors.New("no se pudo abrir la sesión"))
+			return
+		}
+		token, err := utils.GenerateJWT(user.ID.String(), user.Email, user.CompanyID.String(), user.Role, session.ID.String())
+		if err != nil {
+			redirectSSOError(c, errors.New("no se pudo emitir el token"))
+			return
+		}
+		describeLogin(c, user, session, "sso")
+		fragment := url.Values{
+			"token":         {token},
+			"refresh_token": {refreshToken},
+			"expires_in":    {fmt.Sprint(int(utils.AccessTTL().Seconds()))},
+			"company_id":    {user.CompanyID.String()},
+			"email":         {user.Email},
+		}
+		c.Redirect(http.StatusFound, frontendURL()+"/sso/callback#"+fragment.Encode())
+	}
+}
+
+func redirectSSOError(c *gin.Context, err error) {
+	fragment := url.Values{"error": {err.Error()}}
+	c.Redirect(http.StatusFound, frontendURL()+"/sso/callback#"+fragment.Encode())
+}
+
+var (
+	errSSOEmailTaken     = errors.New("el email ya pertenece a un usuario de otra empresa")
+	errSSOLinkPrivileged = errors.New("el usuario tiene un rol superior al que asigna el SSO; debe vincularse manualmente")
+)
+
+// provisionSSOUser busca al usuario por su identidad en el proveedor; si no existe lo
+// vincula por email dentro de la empresa o lo crea (just-in-time). Con RoleClaim
+// configurado el rol se sincroniza en cada login. No se vincula por email a un usuario
+// con un rol superior a los que la configuración puede asignar: quien configura el SSO
+// no debe poder tomar la cuenta de alguien con más privilegios.
+func provisionSSOUser(db *gorm.DB, cfg models.CompanySSO, identity sso.Identity, ip string) (models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.First(&user, "sso_subject = ?", identity.Subject).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = tx.First(&user, "email = ?", identity.Email).Error
+			if err == nil && (user.CompanyID != cfg.CompanyID || user.SSOSubject != nil) {
+				return errSSOEmailTaken
+			}
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			subject := identity.Subject
+			user = models.User{
+				ID: uuid.New(), CompanyID: cfg.CompanyID, Name: identity.Name, Email: identity.Email,
+				Role: identity.Role, Status: "Active", SSOSubject: &subject,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, audit.Entry{
+				CompanyID: cfg.CompanyID, Action: audit.ActionUserProvisioned, TargetType: "user", TargetID: user.ID.String(), IP: ip,
+				Details: map[string]interface{}{"email": user.Email, "role": user.Role, "issuer": cfg.Issuer},
+			})
+		case err != nil:
+			return err
+		}
+		if user.CompanyID != cfg.CompanyID {
+			return errSSOEmailTaken
+		}
+		if user.Status != "Active" {
+			return errors.New("usuario inactivo")
+		}
+		updates := map[string]interface{}{}
+		if user.SSOSubject == nil {
+			maxRole, err := sso.MaxRole(cfg)
+			if err != nil {
+				return err
+			}
+			if !rbac.CanManage(maxRole, user.Role) {
+				return errSSOLinkPrivileged
+			}
+			updates["sso_subject"] = identity.Subject
+		}
+		if cfg.RoleClaim != "" && user.Role != identity.Role {
+			updates["role"] = identity.Role
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		if err := tx.Model(&user).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+		if role, ok := updates["role"].(string); ok {
+			user.Role = role
+		}
+		return nil
+	})
+	return user, err
+}
+
+// SSOConfigInput es la configuración SSO editable. client_secret vacío conserva el actual.
+type SSOConfigInput struct {
+	Enabled        bool   `json:"enabled"`
+	Issuer         string `json:"issuer"`
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	AllowedDomains string `json:"allowed_domains"`
+	RoleClaim      string `json:"role_claim"`
+	RoleMapping    string `json:"role_mapping"`
+	DefaultRole    string `json:"default_role"`
+	EnforceSSO     bool   `json:"enforce_sso"`
+}
+
+// GetSSOConfig retorna la configuración SSO de la empresa del token (sin el secreto)
+func GetSSOConfig(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var cfg models.CompanySSO
+		err := db.First(&cfg, "company_id = ?", companyID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{"company_id": companyID, "enabled": false, "redirect_url": ssoRedirectURL()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"config": cfg, "has_client_secret": cfg.ClientSecret != "", "redirect_url": ssoRedirectURL()})
+	}
+}
+
+// UpdateSSOConfig crea o reemplaza la configuración SSO de la empresa del token
+func UpdateSSOConfig(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input SSOConfigInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		_, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var cfg models.CompanySSO
+		if err := db.First(&cfg, "company_id = ?", companyID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		previousIssuer := cfg.Issuer
+		before := cfg
+		cfg.CompanyID = companyID
+		cfg.Enabled = input.Enabled
+		cfg.Issuer = strings.TrimRight(strings.TrimSpace(input.Issuer), "/")
+		cfg.ClientID = strings.TrimSpace(input.ClientID)
+		if input.ClientSecret != "" {
+			cfg.ClientSecret = input.ClientSecret
+		}
+		cfg.AllowedDomains = input.AllowedDomains
+		cfg.RoleClaim = strings.TrimSpace(input.RoleClaim)
+		cfg.RoleMapping = input.RoleMapping
+		cfg.DefaultRole = rbac.Normalize(input.DefaultRole)
+		cfg.EnforceSSO = input.EnforceSSO
+		if status, err := validateSSOConfig(c, cfg, input.DefaultRole); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Save(&cfg).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo guardar la configuración SSO"})
+			return
+		}
+		sso.Forget(previousIssuer)
+		sso.Forget(cfg.Issuer)
+		audit.Describe(c, audit.Target{Action: "company.sso_updated", Type: "company", ID: companyID.String(), Before: before, After: cfg})
+		c.JSON(http.StatusOK, gin.H{"config": cfg, "has_client_secret": cfg.ClientSecret != "", "redirect_url": ssoRedirectURL()})
+	}
+}
+
+// validateSSOConfig revisa los campos obligatorios y que ningún rol asignable por el
+// proveedor supere al de quien configura
+func validateSSOConfig(c *gin.Context, cfg models.CompanySSO, defaultRole string) (int, error) {
+	if cfg.Enabled {
+		if err := sso.ValidateIssuer(cfg.Issuer); err != nil {
+			return http.StatusBadRequest, err
+		}
+		if cfg.ClientID == "" || cfg.ClientSecret == "" {
+			return http.StatusBadRequest, errors.New("client_id y client_secret son obligatorios")
+		}
+	}
+	if cfg.EnforceSSO && !cfg.Enabled {
+		return http.StatusBadRequest, errors.New("no se puede exigir SSO sin habilitarlo")
+	}
+	if defaultRole != "" && cfg.DefaultRole == "" {
+		return http.StatusBadRequest, fmt.Errorf("default_role: rol desconocido %q", defaultRole)
+	}
+	mapping, err := sso.ParseRoleMapping(cfg.RoleMapping)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	roles := []string{cfg.DefaultRole}
+	for _, role := range mapping {
+		roles = append(roles, role)
+	}
+	for _, role := range roles {
+		if role != "" && !rbac.CanManage(c.GetString("role"), role) {
+			return http.StatusForbidden, fmt.Errorf("no puede asignar el rol %s, superior al suyo", role)
+		}
+	}
+	return 0, nil
+}
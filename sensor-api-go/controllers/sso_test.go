@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/models"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// mockIdP es un proveedor OpenID Connect mínimo: discovery, JWKS y token endpoint.
+// Cada código emitido con issueCode retorna un id_token con los claims indicados.
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	codes  map[string]jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("No se pudo generar la llave del IdP: %v", err)
+	}
+	idp := &mockIdP{key: key, codes: map[string]jwt.MapClaims{}}
+	// El IdP de prueba escucha en http://127.0.0.1
+	t.Setenv("SSO_ALLOW_PRIVATE_ISSUERS", "1")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		enc := base64.RawURLEncoding
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "idp", "use": "sig", "alg": "RS256",
+			"n": enc.EncodeToString(key.N.Bytes()),
+			"e": enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id == "" {
+			id, secret = r.FormValue("client_id"), r.FormValue("client_secret")
+		}
+		idp.mu.Lock()
+		claims, ok := idp.codes[r.FormValue("code")]
+		delete(idp.codes, r.FormValue("code"))
+		idp.mu.Unlock()
+		if id != "instrumining" || secret != "s3cret" || !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "idp"
+		signed, _ := token.SignedString(key)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "at", "token_type": "Bearer", "expires_in": 300, "id_token": signed,
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// issueCode registra un código cuyo id_token lleva los claims estándar más extra
+func (idp *mockIdP) issueCode(code, sub, nonce string, extra jwt.MapClaims) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": idp.server.URL, "aud": "instrumining", "sub": sub, "nonce": nonce,
+		"iat": now.Unix(), "exp": now.Add(5 * time.Minute).Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	idp.mu.Lock()
+	idp.codes[code] = claims
+	idp.mu.Unlock()
+}
+
+// ssoStart inicia el login SSO y retorna el state, el nonce y la cookie del navegador
+func ssoStart(t *testing.T, f *tenantFixture) (string, string, *http.Cookie) {
+	t.Helper()
+	w := f.do("", "GET", "/api/sso/"+f.a.String()+"/login", "")
+	if w.Code != http.StatusFound {
+		t.Fatalf("Inicio SSO: esperado 302, fue %d: %s", w.Code, w.Body.String())
+	}
+	location, _ := url.Parse(w.Header().Get("Location"))
+	q := location.Query()
+	if q.Get("client_id") != "instrumining" || q.Get("redirect_uri") != ssoRedirectURL() || q.Get("state") == "" {
+		t.Fatalf("URL de autorización inesperada: %s", location)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != ssoNonceCookie || !cookies[0].HttpOnly {
+		t.Fatalf("Se espera la cookie HttpOnly %s, hay %v", ssoNonceCookie, cookies)
+	}
+	return q.Get("state"), q.Get("nonce"), cookies[0]
+}
+
+// ssoCallback simula la vuelta del proveedor y retorna el fragmento de la redirección al frontend
+func ssoCallback(t *testing.T, f *tenantFixture, code, state string, cookie *http.Cookie) url.Values {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "/api/sso/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("Callback SSO: esperado 302, fue %d: %s", w.Code, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, frontendURL()+"/sso/callback#") {
+		t.Fatalf("Redirección inesperada: %s", location)
+	}
+	fragment, _ := url.ParseQuery(location[strings.Index(location, "#")+1:])
+	return fragment
+}
+
+func configureSSO(t *testing.T, f *tenantFixture, idp *mockIdP, extra string) {
+	t.Helper()
+	body := fmt.Sprintf(`{"enabled": true, "issuer": "%s", "client_id": "instrumining", "client_secret": "s3cret",
+		"allowed_domains": "acme.com", "role_claim": "groups", "role_mapping": "{\"ops\": \"operator\", \"it-admins\": \"admin\"}",
+		"default_role": "viewer"%s}`, idp.server.URL, extra)
+	if w := f.do(f.tokenA, "PUT", "/api/company/sso", body); w.Code != http.StatusOK {
+		t.Fatalf("Configurar SSO: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSSO_LoginProvisionsUser(t *testing.T) {
+	f := newTenantFixture(t)
+	idp := newMockIdP(t)
+	if w := f.do("", "GET", "/api/sso/"+f.a.String(), ""); !strings.Contains(w.Body.String(), `"enabled":false`) {
+		t.Errorf("Sin configuración el SSO no debe estar habilitado: %s", w.Body.String())
+	}
+	configureSSO(t, f, idp, "")
+	if w := f.do(f.tokenA, "GET", "/api/company/sso", ""); strings.Contains(w.Body.String(), "s3cret") {
+		t.Error("El client_secret no debe retornarse")
+	}
+
+	state, nonce, cookie := ssoStart(t, f)
+	idp.issueCode("c1", "u-123", nonce, jwt.MapClaims{"email": "ana@acme.com", "email_verified": true, "name": "Ana", "groups": []string{"ops", "otros"}})
+	fragment := ssoCallback(t, f, "c1", state, cookie)
+	if fragment.Get("error") != "" || fragment.Get("token") == "" || fragment.Get("refresh_token") == "" {
+		t.Fatalf("El callback debe entregar los tokens: %v", fragment)
+	}
+	if w := f.do(fragment.Get("token"), "GET", "/api/users", ""); w.Code != http.StatusOK {
+		t.Errorf("El token del login SSO debe ser válido, fue %d", w.Code)
+	}
+	var user models.User
+	if err := f.db.First(&user, "email = ?", "ana@acme.com").Error; err != nil {
+		t.Fatalf("El usuario debe crearse en el primer login: %v", err)
+	}
+	if user.CompanyID != f.a || user.Role != "operator" || user.SSOSubject == nil || *user.SSOSubject != idp.server.URL+"#u-123" {
+		t.Errorf("Usuario provisionado inesperado: %+v", user)
+	}
+	var count int64
+	f.db.Model(&models.AuditLog{}).Where("action = ? AND target_id = ?", audit.ActionUserProvisioned, user.ID.String()).Count(&count)
+	if count != 1 {
+		t.Errorf("La creación debe quedar en la auditoría, hay %d registros", count)
+	}
+
+	// El segundo login reutiliza el usuario y sincroniza el rol desde el claim
+	state, nonce, cookie = ssoStart(t, f)
+	idp.issueCode("c2", "u-123", nonce, jwt.MapClaims{"email": "ana@acme.com", "groups": "it-admins"})
+	if fragment := ssoCallback(t, f, "c2", state, cookie); fragment.Get("token") == "" {
+		t.Fatalf("Segundo login SSO: %v", fragment)
+	}
+	f.db.First(&user, "id = ?", user.ID)
+	if user.Role != "admin" {
+		t.Errorf("El rol debe sincronizarse con el claim, es %s", user.Role)
+	}
+	f.db.Model(&models.User{}).Where("email = ?", "ana@acme.com").Count(&count)
+	if count != 1 {
+		t.Errorf("No se deben duplicar usuarios, hay %d", count)
+	}
+}
+
+func TestSSO_DoesNotLinkPrivilegedUsers(t *testing.T) {
+	f := newTenantFixture(t)
+	idp := newMockIdP(t)
+	configureSSO(t, f, idp, "")
+	boss := models.User{ID: uuid.New(), CompanyID: f.a, Name: "Jefa", Email: "jefa@acme.com", Role: "super-admin", Status: "Active"}
+	viewer := models.User{ID: uuid.New(), CompanyID: f.a, Name: "Vera", Email: "vera@acme.com", Role: "viewer", Status: "Active"}
+	f.db.Create(&boss)
+	f.db.Create(&viewer)
+
+	// La configuración asigna a lo más admin: no puede tomar la cuenta de una super-admin
+	state, nonce, cookie := ssoStart(t, f)
+	idp.issueCode("c1", "u-jefa", nonce, jwt.MapClaims{"email": boss.Email})
+	if fragment := ssoCallback(t, f, "c1", state, cookie); fragment.Get("error") == "" || fragment.Get("token") != "" {
+		t.Errorf("Vincular a un usuario con rol superior debe rechazarse, fue %v", fragment)
+	}
+	f.db.First(&boss, "id = ?", boss.ID)
+	if boss.SSOSubject != nil || boss.Role != "super-admin" {
+		t.Errorf("La cuenta rechazada no debe cambiar: %+v", boss)
+	}
+
+	state, nonce, cookie = ssoStart(t, f)
+	idp.issueCode("c2", "u-vera", nonce, jwt.MapClaims{"email": viewer.Email})
+	if fragment := ssoCallback(t, f, "c2", state, cookie); fragment.Get("token") == "" {
+		t.Fatalf("Un usuario con rol asignable debe vincularse: %v", fragment)
+	}
+	f.db.First(&viewer, "id = ?", viewer.ID)
+	if viewer.SSOSubject == nil {
+		t.Error("El usuario debe quedar vinculado a su identidad en el proveedor")
+	}
+}
+
+func TestSSO_RejectsInvalidLogins(t *testing.T) {
+	f := newTenantFixture(t)
+	idp := newMockIdP(t)
+	configureSSO(t, f, idp, "")
+
+	for _, tc := range []struct {
+		name   string
+		claims jwt.MapClaims
+		nonce  string
+		cookie bool
+	}{
+		{"dominio no permitido", jwt.MapClaims{"email": "eva@otra.com"}, "", true},
+		{"email sin verificar", jwt.MapClaims{"email": "eva@acme.com", "email_verified": false}, "", true},
+		{"nonce distinto", jwt.MapClaims{"email": "eva@acme.com"}, "otro", true},
+		{"sin cookie del navegador", jwt.MapClaims{"email": "eva@acme.com"}, "", false},
+		{"email de otra empresa", jwt.MapClaims{"email": "b@example.com"}, "", true},
+	} {
+		state, nonce, cookie := ssoStart(t, f)
+		if tc.nonce != "" {
+			nonce = tc.nonce
+		}
+		if !tc.cookie {
+			cookie = nil
+		}
+		idp.issueCode("code", "u-"+tc.name, nonce, tc.claims)
+		if fragment := ssoCallback(t, f, "code", state, cookie); fragment.Get("error") == "" || fragment.Get("token") != "" {
+			t.Errorf("%s: el login debe rechazarse, fue %v", tc.name, fragment)
+		}
+	}
+	if err := f.db.First(&models.User{}, "email = ?", "eva@acme.com").Error; err == nil {
+		t.Error("Un login rechazado no debe crear usuarios")
+	}
+
+	// Un state de otra empresa o alterado no sirve
+	state, _, cookie := ssoStart(t, f)
+	if fragment := ssoCallback(t, f, "code", state+"x", cookie); fragment.Get("error") == "" {
+		t.Error("Un state alterado debe rechazarse")
+	}
+}
+
+func TestSSO_ConfigAndEnforcement(t *testing.T) {
+	f := newTenantFixture(t)
+	idp := newMockIdP(t)
+	setTestPassword(t, f, f.userA, "Actual2024clave")
+
+	// El rol del token limita los roles que se pueden mapear
+	operator := f.userA
+	operator.Role = "operator"
+	operatorToken, _ := f.login(t, operator)
+	body := fmt.Sprintf(`{"enabled": true, "issuer": "%s", "client_id": "instrumining", "client_secret": "s3cret", "role_claim": "groups", "role_mapping": "{\"x\": \"super-admin\"}"}`, idp.server.URL)
+	if w := f.do(operatorToken, "PUT", "/api/company/sso", body); w.Code != http.StatusForbidden {
+		t.Errorf("Mapear a un rol superior al propio: esperado 403, fue %d", w.Code)
+	}
+	if w := f.do(f.tokenA, "PUT", "/api/company/sso", `{"enabled": true, "issuer": "no-es-url", "client_id": "a", "client_secret": "b"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Issuer inválido: esperado 400, fue %d", w.Code)
+	}
+	t.Setenv("SSO_ALLOW_PRIVATE_ISSUERS", "")
+	if w := f.do(f.tokenA, "PUT", "/api/company/sso", `{"enabled": true, "issuer": "http://169.254.169.254", "client_id": "a", "client_secret": "b"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Issuer en la red interna: esperado 400, fue %d", w.Code)
+	}
+	t.Setenv("SSO_ALLOW_PRIVATE_ISSUERS", "1")
+	if w := f.do(f.tokenA, "PUT", "/api/company/sso", `{"enabled": false, "enforce_sso": true}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Exigir SSO sin habilitarlo: esperado 400, fue %d", w.Code)
+	}
+
+	configureSSO(t, f, idp, `, "enforce_sso": true`)
+	w := f.do("", "POST", "/api/login", fmt.Sprintf(`{"email": "%s", "password": "Actual2024clave", "company_id": "%s"}`, f.userA.Email, f.a))
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "sso_required") {
+		t.Errorf("Con SSO obligatorio el login con contraseña: esperado 403, fue %d: %s", w.Code, w.Body.String())
+	}
+	if w := f.do("", "GET", "/api/sso/"+f.a.String(), ""); !strings.Contains(w.Body.String(), `"enforce_sso":true`) {
+		t.Errorf("El estado público debe informar que el SSO es obligatorio: %s", w.Body.String())
+	}
+	// La otra empresa no se ve afectada
+	if w := f.do("", "GET", "/api/sso/"+f.b.String()+"/login", ""); !strings.Contains(w.Header().Get("Location"), "error=") {
+		t.Errorf("Empresa sin SSO: se espera redirección con error, fue %s", w.Header().Get("Location"))
+	}
+}
+
+func TestSSO_EnforcementBlocksInvitationPassword(t *testing.T) {
+	f := newTenantFixture(t)
+	idp := newMockIdP(t)
+	createInvitation(t, f, "sso-invitado@example.com", "viewer")
+	link := inviteLink(t, f)
+	configureSSO(t, f, idp, `, "enforce_sso": true`)
+
+	w := f.do("", "POST", "/api/invitations/accept", fmt.Sprintf(`{"token": "%s", "password": "Bienvenido2025"}`, link))
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "sso_required") {
+		t.Errorf("Con SSO obligatorio aceptar con contraseña: esperado 403, fue %d: %s", w.Code, w.Body.String())
+	}
+	var user models.User
+	f.db.First(&user, "email = ?", "sso-invitado@example.com")
+	if user.Status != "Invited" || user.Password != "" {
+		t.Errorf("El invitado no debe quedar activo ni con contraseña: %+v", user)
+	}
+}
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sensor-api-go/models"
+	"sensor-api-go/stream"
+	"sensor-api-go/zones"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// HeartbeatInterval es cada cuánto se envía un heartbeat (y se revisa si alguna
+	// zona quedó inactiva)
+	HeartbeatInterval = 15 * time.Second
+	// streamRetry es la espera, en milisegundos, que se sugiere al cliente antes de reconectar
+	streamRetry = 3000
+)
+
+// Stream transmite por Server-Sent Events las lecturas nuevas, los cambios de
+// estado de las zonas y las transiciones de alertas de la cámara camera_id de la
+// empresa del token. Al conectar envía el estado de cada zona; con Last-Event-ID
+// (o last_event_id en la query) reenvía lo que el cliente se perdió.
+func Stream(db *gorm.DB, hub *stream.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cameraID, err := strconv.Atoi(c.Query("camera_id"))
+		if err != nil || cameraID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "camera_id inválido"})
+			return
+		}
+		tdb, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var resume *stream.Cursor
+		lastID := c.GetHeader("Last-Event-ID")
+		if lastID == "" {
+			lastID = c.Query("last_event_id")
+		}
+		if lastID != "" {
+			cursor, err := stream.ParseCursor(lastID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			resume = &cursor
+		}
+
+		sub, err := hub.Subscribe(companyID, cameraID)
+		if err != nil {
+			log.Printf("[ERROR] No se pudo iniciar el stream: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar el stream"})
+			return
+		}
+		defer sub.Close()
+
+		// Lo que pasó entre la última conexión del cliente y la suscripción
+		now := time.Now()
+		cursor, reset := sub.Start, false
+		var backlog []stream.Item
+		if resume != nil {
+			backlog, err = stream.Replay(db, companyID, cameraID, *resume, sub.Start, now)
+			if errors.Is(err, stream.ErrTooFarBehind) {
+				reset = true
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron recuperar los eventos"})
+				return
+			} else {
+				cursor = *resume
+			}
+		}
+		live, err := newLiveZones(db, tdb, companyID, cameraID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no") // nginx no debe acumular la respuesta
+		c.Status(http.StatusOK)
+		var retry uint = streamRetry // solo en el primer evento
+		send := func(name, id string, data interface{}) {
+			c.Render(-1, sse.Event{Event: name, Id: id, Data: data, Retry: retry})
+			c.Writer.Flush()
+			retry = 0
+		}
+		if reset {
+			send(stream.EventReset, cursor.String(), gin.H{"reason": "se perdieron eventos; recargue el estado"})
+		}
+		for _, z := range live.snapshot(now) {
+			send(stream.EventZone, "", z)
+		}
+		handle := func(item stream.Item) {
+			switch {
+			case item.Reading != nil:
+				r := *item.Reading
+				if r.ID <= cursor.Reading {
+					return
+				}
+				cursor.Reading = r.ID
+				send(stream.EventReading, cursor.String(), r)
+				if z, changed := live.update(r, time.Now()); changed {
+					send(stream.EventZone, cursor.String(), z)
+				}
+			case item.Event != nil:
+				e := item.Event
+				if e.ID <= cursor.Event {
+					return
+				}
+				cursor.Event = e.ID
+				send(e.Type, cursor.String(), json.RawMessage(e.Data))
+			}
+		}
+		for _, item := range backlog {
+			handle(item)
+		}
+
+		heartbeat := time.NewTicker(HeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case item, open := <-sub.C:
+				if !open {
+					// Cliente lento: reconecta y recupera lo perdido con Last-Event-ID
+					return
+				}
+				handle(item)
+			case now := <-heartbeat.C:
+				for _, z := range live.expire(now) {
+					send(stream.EventZone, "", z)
+				}
+				send(stream.EventHeartbeat, "", now.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+}
+
+// liveZones sigue la última lectura de cada zona de la cámara para avisar cuando
+// cambia su estado, con la misma regla que CameraSummaryDashboard
+type liveZones struct {
+	db         *gorm.DB
+	companyID  uuid.UUID
+	cameraID   int
+	registered map[int]models.Zone
+	last       map[int]models.CameraReading
+	state      map[int]string
+	order      []int
+}
+
+func newLiveZones(db, tdb *gorm.DB, companyID uuid.UUID, cameraID int) (*liveZones, error) {
+	zonas, last, err := lastZoneReadings(tdb, cameraID)
+	if err != nil {
+		return nil, err
+	}
+	registered, err := zones.ByCamera(db, companyID, cameraID)
+	if err != nil {
+		return nil, err
+	}
+	return &liveZones{db: db, companyID: companyID, cameraID: cameraID, registered: registered,
+		last: last, state: map[int]string{}, order: zonas}, nil
+}
+
+// snapshot retorna el estado actual de todas las zonas
+func (l *liveZones) snapshot(now time.Time) []ZoneStatus {
+	list := make([]ZoneStatus, 0, len(l.order))
+	for _, z := range l.order {
+		status := zoneStatus(z, l.registered, l.last[z], now)
+		l.state[z] = status.State
+		list = append(list, status)
+	}
+	return list
+}
+
+// update registra una lectura nueva y retorna el estado de su zona si cambió
+// (o si la zona es nueva). Las lecturas anteriores a la última de la zona no
+// cambian su estado.
+func (l *liveZones) update(r models.CameraReading, now time.Time) (ZoneStatus, bool) {
+	prev, known := l.last[r.ZoneID]
+	if known && r.Timestamp.Before(prev.Timestamp) {
+		return ZoneStatus{}, false
+	}
+	if !known {
+		l.order = append(l.order, r.ZoneID)
+		if _, ok := l.registered[r.ZoneID]; !ok {
+			if registered, err := zones.ByCamera(l.db, l.companyID, l.cameraID); err == nil {
+				l.registered = registered
+			}
+		}
+	}
+	l.last[r.ZoneID] = r
+	status := zoneStatus(r.ZoneID, l.registered, r, now)
+	if known && l.state[r.ZoneID] == status.State {
+		return status, false
+	}
+	l.state[r.ZoneID] = status.State
+	return status, true
+}
+
+// expire retorna las zonas que cambiaron de estado sin lecturas nuevas (quedaron inactivas)
+func (l *liveZones) expire(now time.Time) []ZoneStatus {
+	var changed []ZoneStatus
+	for _, z := range l.order {
+		status := zoneStatus(z, l.registered, l.last[z], now)
+		if l.state[z] != status.State {
+			l.state[z] = status.State
+			changed = append(changed, status)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sensor-api-go/ingest"
+	"sensor-api-go/models"
+	"sensor-api-go/stream"
+
+	"github.com/google/uuid"
+)
+
+type sseEvent struct {
+	name, id string
+	data     map[string]interface{}
+}
+
+// openStream conecta al stream y entrega sus eventos (sin los heartbeats) por el canal
+func openStream(t *testing.T, srv *httptest.Server, token, query, lastEventID string) (<-chan sseEvent, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/stream?"+query, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		cancel()
+		t.Fatalf("No se pudo abrir el stream: %v %v", err, resp)
+	}
+	events := make(chan sseEvent, 100)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		var ev sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			field, value, _ := strings.Cut(line, ":")
+			switch field {
+			case "event":
+				ev.name = value
+			case "id":
+				ev.id = value
+			case "data":
+				json.Unmarshal([]byte(value), &ev.data)
+			case "":
+				if ev.name != stream.EventHeartbeat && ev.name != "" {
+					events <- ev
+				}
+				ev = sseEvent{}
+			}
+		}
+	}()
+	return events, cancel
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatalf("El stream se cerró")
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatalf("No llegó ningún evento")
+	}
+	return sseEvent{}
+}
+
+func noEvent(t *testing.T, events <-chan sseEvent) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		t.Fatalf("Evento inesperado: %+v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (f *tenantFixture) saveReading(t *testing.T, company uuid.UUID, camera, zone int, temp float64) uint {
+	t.Helper()
+	ts := time.Now().UTC()
+	results, err := ingest.Save(f.db, []ingest.ReadingInput{{CompanyID: company, CameraID: &camera, ZoneID: &zone, Temperature: &temp, Timestamp: &ts}})
+	if err != nil || results[0].Status != ingest.StatusAccepted {
+		t.Fatalf("No se pudo guardar la lectura: %v %+v", err, results)
+	}
+	return results[0].ID
+}
+
+// poll reparte lo nuevo: el Hub entrega en la segunda pasada lo que vio en la primera
+func (f *tenantFixture) poll(t *testing.T) {
+	t.Helper()
+	for i := 0; i < 2; i++ {
+		if err := f.hub.Poll(time.Now()); err != nil {
+			t.Fatalf("Poll: %v", err)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	f := newTenantFixture(t)
+	// El stream consulta la base desde otra goroutine
+	sqlDB, _ := f.db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	srv := httptest.NewServer(f.router)
+	defer srv.Close()
+
+	if w := f.do(f.tokenA, "GET", "/api/stream?camera_id=x", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("camera_id inválido: esperado 400, fue %d", w.Code)
+	}
+	if w := f.do(f.tokenA, "GET", "/api/stream?camera_id=1&last_event_id=abc", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Last-Event-ID inválido: esperado 400, fue %d", w.Code)
+	}
+
+	eventsA, cancelA := openStream(t, srv, f.tokenA, "camera_id=1", "")
+	eventsB, cancelB := openStream(t, srv, f.tokenB, "camera_id=1", "")
+	defer cancelB()
+	// Al conectar llega el estado de cada zona de la cámara
+	for _, zone := range []float64{1, 2} {
+		ev := nextEvent(t, eventsA)
+		if ev.name != stream.EventZone || ev.data["zone_id"] != zone || ev.data["state"] != "Activo" {
+			t.Fatalf("Estado inicial de la zona %v: %+v", zone, ev)
+		}
+	}
+	if ev := nextEvent(t, eventsB); ev.name != stream.EventZone || ev.data["last_temp"] != 40.0 {
+		t.Fatalf("Estado inicial de B: %+v", ev)
+	}
+
+	// Solo llegan las lecturas de la empresa y cámara suscritas
+	idA := f.saveReading(t, f.a, 1, 1, 28)
+	f.saveReading(t, f.a, 2, 1, 10)
+	idB := f.saveReading(t, f.b, 1, 1, 41)
+	f.poll(t)
+	ev := nextEvent(t, eventsA)
+	if ev.name != stream.EventReading || ev.data["ID"] != float64(idA) || ev.data["Temperature"] != 28.0 {
+		t.Fatalf("Lectura de A: %+v", ev)
+	}
+	if cursor, err := stream.ParseCursor(ev.id); err != nil || cursor.Reading != idA {
+		t.Errorf("El id del evento debe ser el cursor de la lectura: %q", ev.id)
+	}
+	noEvent(t, eventsA)
+	if ev := nextEvent(t, eventsB); ev.data["ID"] != float64(idB) || ev.data["CompanyID"] != f.b.String() {
+		t.Fatalf("Lectura de B: %+v", ev)
+	}
+
+	// Una zona nueva llega con su estado
+	f.saveReading(t, f.a, 1, 3, 20)
+	f.poll(t)
+	if ev := nextEvent(t, eventsA); ev.name != stream.EventReading {
+		t.Fatalf("Esperada la lectura de la zona 3: %+v", ev)
+	}
+	if ev := nextEvent(t, eventsA); ev.name != stream.EventZone || ev.data["zone_id"] != 3.0 || ev.data["state"] != "Activo" {
+		t.Fatalf("Esperado el estado de la zona 3: %+v", ev)
+	}
+
+	// Transiciones de alertas registradas por el worker
+	alert := stream.Alert{Kind: "zone", AlertID: f.alertA.ID, CameraID: 1, Transition: models.TransitionFiring, State: models.AlertStateFiring}
+	if err := stream.RecordAlert(f.db, f.a, alert); err != nil {
+		t.Fatalf("RecordAlert: %v", err)
+	}
+	stream.RecordAlert(f.db, f.b, stream.Alert{Kind: "zone", CameraID: 1, Transition: models.TransitionFiring})
+	f.poll(t)
+	ev = nextEvent(t, eventsA)
+	if ev.name != stream.EventAlert || ev.data["alert_id"] != f.alertA.ID.String() || ev.data["state"] != models.AlertStateFiring {
+		t.Fatalf("Alerta de A: %+v", ev)
+	}
+	noEvent(t, eventsA)
+	lastID := ev.id
+
+	// Al reconectar con Last-Event-ID se reenvía lo perdido
+	cancelA()
+	missed := f.saveReading(t, f.a, 1, 2, 33)
+	f.poll(t)
+	eventsA, cancelA = openStream(t, srv, f.tokenA, "camera_id=1", lastID)
+	defer cancelA()
+	for i := 0; i < 3; i++ {
+		if ev := nextEvent(t, eventsA); ev.name != stream.EventZone {
+			t.Fatalf("Esperado el estado de las zonas al reconectar: %+v", ev)
+		}
+	}
+	if ev := nextEvent(t, eventsA); ev.name != stream.EventReading || ev.data["ID"] != float64(missed) {
+		t.Fatalf("Esperada la lectura perdida: %+v", ev)
+	}
+	noEvent(t, eventsA)
+}
+
+func TestLiveZonesExpire(t *testing.T) {
+	now := time.Now()
+	live := &liveZones{
+		last:  map[int]models.CameraReading{1: {ZoneID: 1, Temperature: 20, Timestamp: now.Add(-9 * time.Minute)}},
+		state: map[int]string{},
+		order: []int{1},
+	}
+	if s := live.snapshot(now); s[0].State != "Activo" {
+		t.Fatalf("Con lectura de hace 9 minutos la zona debe estar activa: %+v", s)
+	}
+	if changed := live.expire(now.Add(30 * time.Second)); len(changed) != 0 {
+		t.Errorf("Sin cambio de estado no se avisa: %+v", changed)
+	}
+	changed := live.expire(now.Add(2 * time.Minute))
+	if len(changed) != 1 || changed[0].State != "Inactivo" {
+		t.Errorf("Sin lecturas por 10 minutos la zona debe quedar inactiva: %+v", changed)
+	}
+	if _, changed := live.update(models.CameraReading{ZoneID: 1, Temperature: 21, Timestamp: now.Add(-20 * time.Minute)}, now); changed {
+		t.Errorf("Una lectura atrasada no cambia el estado")
+	}
+	if s, changed := live.update(models.CameraReading{ZoneID: 1, Temperature: 21, Timestamp: now}, now); !changed || s.State != "Activo" {
+		t.Errorf("Una lectura nueva reactiva la zona: %+v", s)
+	}
+}
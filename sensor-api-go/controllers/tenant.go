@@ -0,0 +1,24 @@
+// controllers/tenant.go
+
+package controllers
+
+import (
+	"net/http"
+	"sensor-api-go/tenant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// tenantDB retorna la conexión restringida a la empresa del token (claim company_id).
+// Todas las consultas de datos de una empresa deben pasar por aquí; si el token no
+// trae una empresa válida responde 401 y retorna ok = false.
+func tenantDB(c *gin.Context, db *gorm.DB) (*gorm.DB, uuid.UUID, bool) {
+	companyID, err := uuid.Parse(c.GetString("company_id"))
+	if err != nil || companyID == uuid.Nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "company_id inválido en el token"})
+		return nil, uuid.Nil, false
+	}
+	return tenant.DB(db, companyID), companyID, true
+}
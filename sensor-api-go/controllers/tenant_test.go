@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/ingest"
+	"sensor-api-go/middleware"
+	"sensor-api-go/models"
+	"sensor-api-go/rollup"
+	"sensor-api-go/sessions"
+	"sensor-api-go/stream"
+	"sensor-api-go/utils"
+	"sensor-api-go/zones"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// tenantFixture tiene dos empresas con la misma cámara 1 y los datos de cada una
+type tenantFixture struct {
+	db      *gorm.DB
+	router  *gin.Engine
+	hub     *stream.Hub
+	a, b    uuid.UUID
+	userA   models.User
+	userB   models.User
+	tokenA  string
+	tokenB  string
+	zoneA   models.Zone
+	zoneB   models.Zone
+	alertA  models.ZoneAlert
+	eventsA int
+	mails   []sentMail
+}
+
+type sentMail struct{ to, subject, body string }
+
+func newTenantFixture(t *testing.T) *tenantFixture {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	if err := db.AutoMigrate(&models.CameraReading{}, &models.Device{}, &models.Zone{}, &models.User{},
+		&models.ZoneAlert{}, &models.ZoneAlertEvent{}, &models.DeviceAlert{}, &models.DeviceAlertEvent{},
+		&models.Session{}, &models.RefreshToken{}, &models.PasswordResetToken{}, &models.Invitation{}, &models.MFARecoveryCode{},
+		&models.LoginFailure{}, &models.AuditLog{}, &models.CompanySSO{}, &models.Company{},
+		&models.ImportJob{}, &models.ImportError{}, &models.StreamEvent{}); err != nil {
+		t.Fatalf("No se pudo migrar: %v", err)
+	}
+	if err := rollup.Migrate(db); err != nil {
+		t.Fatalf("No se pudieron crear los rollups: %v", err)
+	}
+	f := &tenantFixture{db: db, a: uuid.New(), b: uuid.New()}
+	db.Create(&models.Company{ID: f.a, Name: "A"})
+	db.Create(&models.Company{ID: f.b, Name: "B"})
+	f.userA = models.User{ID: uuid.New(), CompanyID: f.a, Name: "A", Email: "a@example.com", Password: "x", Role: "admin", Status: "Active"}
+	f.userB = models.User{ID: uuid.New(), CompanyID: f.b, Name: "B", Email: "b@example.com", Password: "x", Role: "admin", Status: "Active"}
+	db.Create(&f.userA)
+	db.Create(&f.userB)
+	f.tokenA, _ = f.login(t, f.userA)
+	f.tokenB, _ = f.login(t, f.userB)
+
+	now := time.Now().UTC().Add(-time.Minute)
+	for _, tc := range []struct {
+		company uuid.UUID
+		zone    int
+		temp    float64
+	}{{f.a, 1, 30}, {f.a, 2, 31}, {f.b, 1, 40}} {
+		cam, zone, temp := 1, tc.zone, tc.temp
+		in := ingest.ReadingInput{CompanyID: tc.company, CameraID: &cam, ZoneID: &zone, Temperature: &temp, Timestamp: &now}
+		if _, err := ingest.Save(db, []ingest.ReadingInput{in}); err != nil {
+			t.Fatalf("No se pudo guardar la lectura: %v", err)
+		}
+	}
+	f.zoneA, _ = zones.Lookup(db, f.a, 1, 1)
+	f.zoneB, _ = zones.Lookup(db, f.b, 1, 1)
+
+	f.alertA = models.ZoneAlert{ID: uuid.New(), CompanyID: f.a, ZoneID: f.zoneA.ID, UpperThresh: 50, Recipient: "a@example.com", State: models.AlertStateOK}
+	db.Create(&f.alertA)
+	db.Create(&models.ZoneAlertEvent{ID: uuid.New(), CompanyID: f.a, AlertID: f.alertA.ID, ZoneID: f.zoneA.ID, Transition: models.TransitionFiring})
+	f.eventsA = 1
+
+	r := gin.New()
+	r.Use(middleware.RequestID(), audit.Middleware(db))
+	r.POST("/api/login", Login(db))
+	r.POST("/api/login/mfa", LoginMFA(db))
+	r.POST("/api/login/mfa/enroll", LoginMFAEnroll(db))
+	r.POST("/api/refresh", Refresh(db))
+	r.GET("/api/sso/callback", SSOCallback(db))
+	r.GET("/api/sso/:company_id", GetSSOStatus(db))
+	r.GET("/api/sso/:company_id/login", SSOLogin(db))
+	mailer := func(to, subject, body string) error {
+		f.mails = append(f.mails, sentMail{to, subject, body})
+		return nil
+	}
+	// El envío corre en la solicitud para que las pruebas vean el correo al responder
+	r.POST("/api/password/forgot", ForgotPassword(db, mailer, func(task func()) { task() }))
+	r.POST("/api/invitations/accept", AcceptInvitation(db))
+	r.POST("/api/password/reset", ResetPassword(db))
+	api := r.Group("/api", middleware.JWTAuthMiddleware(db))
+	api.POST("/logout", Logout(db))
+	api.POST("/password/change", ChangePassword(db))
+	api.GET("/mfa", GetMFAStatus(db))
+	api.POST("/mfa/enroll", StartMFAEnrollment(db))
+	api.POST("/mfa/enroll/verify", VerifyMFAEnrollment(db))
+	api.POST("/mfa/recovery-codes", RegenerateRecoveryCodes(db))
+	api.POST("/mfa/disable", DisableMFA(db))
+	api.PUT("/company/security", UpdateSecurityPolicy(db))
+	api.GET("/company/retention", GetRetention(db))
+	api.PUT("/company/retention", UpdateRetention(db))
+	api.GET("/company/locale", GetLocale(db))
+	api.PUT("/company/locale", UpdateLocale(db))
+	api.GET("/company/sso", GetSSOConfig(db))
+	api.PUT("/company/sso", UpdateSSOConfig(db))
+	api.DELETE("/users/:id/mfa", ResetUserMFA(db))
+	api.POST("/users/:id/unlock", UnlockUser(db))
+	api.GET("/camera-readings", GetCameraReadings(db))
+	api.GET("/cameras", ListUniqueCameras(db))
+	api.GET("/cameras/:camera_id/zonas", ListZonasByCamera(db))
+	api.GET("/cameras/:camera_id/status", CameraStatusDashboard(db))
+	api.GET("/cameras/:camera_id/zones/:zone_id/aggregate", AggregateZoneReadings(db))
+	api.GET("/cameras/:camera_id/export", ExportCameraReadings(db))
+	api.POST("/imports", CreateImport(db))
+	api.GET("/imports", ListImports(db))
+	api.GET("/imports/:id", GetImport(db))
+	api.GET("/imports/:id/errors", ImportErrorReport(db))
+	f.hub = stream.NewHub(db)
+	api.GET("/stream", Stream(db, f.hub))
+	api.GET("/devices", GetDevicesWithZones(db))
+	api.GET("/users", ListUsers(db))
+	api.PUT("/users/:id", UpdateUser(db))
+	api.DELETE("/users/:id", DeleteUser(db))
+	api.GET("/invitations", ListInvitations(db))
+	api.POST("/invitations", CreateInvitation(db, mailer))
+	api.POST("/invitations/:id/resend", ResendInvitation(db, mailer))
+	api.DELETE("/invitations/:id", RevokeInvitation(db))
+	api.GET("/zone-alerts", ListZoneAlerts(db))
+	api.POST("/zone-alerts", CreateZoneAlert(db))
+	api.PUT("/zone-alerts/:id", UpdateZoneAlert(db))
+	api.DELETE("/zone-alerts/:id", DeleteZoneAlert(db))
+	api.POST("/device-alerts", CreateDeviceAlert(db))
+	api.GET("/zones/:zone_id/alert-events", ListZoneAlertEvents(db))
+	api.GET("/audit-log", ListAuditLog(db))
+	api.GET("/audit-log/export", ExportAuditLog(db))
+	f.router = r
+	return f
+}
+
+// login abre una sesión para el usuario y retorna su access token y refresh token
+func (f *tenantFixture) login(t *testing.T, user models.User) (string, string) {
+	t.Helper()
+	session, refresh, err := sessions.Start(f.db, user, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("No se pudo abrir la sesión: %v", err)
+	}
+	token, err := utils.GenerateJWT(user.ID.String(), user.Email, user.CompanyID.String(), user.Role, session.ID.String())
+	if err != nil {
+		t.Fatalf("No se pudo generar el token: %v", err)
+	}
+	return token, refresh
+}
+
+func (f *tenantFixture) do(token, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func decodeList(t *testing.T, w *httptest.ResponseRecorder) []map[string]interface{} {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("Esperado status 200, pero fue %d: %s", w.Code, w.Body.String())
+	}
+	var list []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Respuesta no es una lista JSON: %v", err)
+	}
+	return list
+}
+
+// decodeItems es decodeList para los listados paginados ({"items", "next_cursor"})
+func decodeItems(t *testing.T, w *httptest.ResponseRecorder) []map[string]interface{} {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("Esperado status 200, pero fue %d: %s", w.Code, w.Body.String())
+	}
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || page.Items == nil {
+		t.Fatalf("Respuesta no es una página JSON: %v %s", err, w.Body.String())
+	}
+	return page.Items
+}
+
+func TestTenantIsolation_Reads(t *testing.T) {
+	f := newTenantFixture(t)
+
+	if got := decodeItems(t, f.do(f.tokenA, "GET", "/api/camera-readings", "")); len(got) != 2 {
+		t.Errorf("La empresa A debe ver sus 2 lecturas, vio %d", len(got))
+	}
+	if got := decodeItems(t, f.do(f.tokenB, "GET", "/api/camera-readings", "")); len(got) != 1 || got[0]["Temperature"] != 40.0 {
+		t.Errorf("La empresa B debe ver solo su lectura: %v", got)
+	}
+
+	// Ambas tienen la cámara 1, pero cada una ve solo sus zonas
+	w := f.do(f.tokenB, "GET", "/api/cameras/1/zonas", "")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[1]" {
+		t.Errorf("Zonas de la cámara 1 para B: esperado [1], fue %d %s", w.Code, w.Body.String())
+	}
+	var dashboard CameraDashboard
+	json.Unmarshal(f.do(f.tokenB, "GET", "/api/cameras/1/status", "").Body.Bytes(), &dashboard)
+	if len(dashboard.Zonas) != 1 || len(dashboard.Zonas[0].Readings) != 1 ||
+		dashboard.Zonas[0].ZoneUUID == nil || *dashboard.Zonas[0].ZoneUUID != f.zoneB.ID {
+		t.Errorf("Dashboard de B con datos ajenos: %+v", dashboard)
+	}
+
+	devices := decodeList(t, f.do(f.tokenB, "GET", "/api/devices", ""))
+	if len(devices) != 1 || len(devices[0]["zone_details"].([]interface{})) != 1 {
+		t.Errorf("B debe ver un dispositivo con una zona: %v", devices)
+	}
+
+	users := decodeItems(t, f.do(f.tokenA, "GET", "/api/users", ""))
+	if len(users) != 1 || users[0]["Email"] != "a@example.com" {
+		t.Errorf("A debe ver solo sus usuarios: %v", users)
+	}
+
+	if got := decodeItems(t, f.do(f.tokenB, "GET", "/api/zone-alerts", "")); len(got) != 0 {
+		t.Errorf("B no debe ver alertas de A: %v", got)
+	}
+	path := fmt.Sprintf("/api/zones/%s/alert-events", f.zoneA.ID)
+	if got := decodeItems(t, f.do(f.tokenB, "GET", path, "")); len(got) != 0 {
+		t.Errorf("B no debe ver eventos de A: %v", got)
+	}
+	if got := decodeItems(t, f.do(f.tokenA, "GET", path, "")); len(got) != f.eventsA {
+		t.Errorf("A debe ver sus eventos, vio %d", len(got))
+	}
+}
+
+func TestTenantIsolation_Writes(t *testing.T) {
+	f := newTenantFixture(t)
+	alertPath := "/api/zone-alerts/" + f.alertA.ID.String()
+	body := `{"zone_id": "%s", "upper_thresh": 10, "lower_thresh": 0, "recipient": "b@example.com"}`
+
+	// B no puede modificar ni borrar la alerta de A
+	if w := f.do(f.tokenB, "PUT", alertPath, fmt.Sprintf(body, f.zoneB.ID)); w.Code != http.StatusNotFound {
+		t.Errorf("PUT ajeno: esperado 404, fue %d", w.Code)
+	}
+	if w := f.do(f.tokenB, "DELETE", alertPath, ""); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE ajeno: esperado 404, fue %d", w.Code)
+	}
+	var stored models.ZoneAlert
+	if err := f.db.First(&stored, "id = ?", f.alertA.ID).Error; err != nil || stored.UpperThresh != 50 || stored.Recipient != "a@example.com" {
+		t.Errorf("La alerta de A fue alterada: %+v (%v)", stored, err)
+	}
+
+	// B no puede crear alertas sobre zonas o dispositivos de A
+	if w := f.do(f.tokenB, "POST", "/api/zone-alerts", fmt.Sprintf(body, f.zoneA.ID)); w.Code != http.StatusBadRequest {
+		t.Errorf("Alerta sobre zona ajena: esperado 400, fue %d", w.Code)
+	}
+	deviceBody := fmt.Sprintf(`{"device_id": "%s", "upper_thresh": 10, "lower_thresh": 0, "recipient": "b@example.com"}`, f.zoneA.DeviceID)
+	if w := f.do(f.tokenB, "POST", "/api/device-alerts", deviceBody); w.Code != http.StatusBadRequest {
+		t.Errorf("Alerta sobre dispositivo ajeno: esperado 400, fue %d", w.Code)
+	}
+
+	// Un zone_id numérico se resuelve en la cámara de la propia empresa
+	w := f.do(f.tokenB, "POST", "/api/zone-alerts", `{"zone_id": "1", "camera_id": 1, "upper_thresh": 10, "lower_thresh": 0, "recipient": "b@example.com"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Alerta con zona numérica: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var created models.ZoneAlert
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ZoneID != f.zoneB.ID || created.CompanyID != f.b {
+		t.Errorf("La alerta debe quedar en la zona y empresa de B: %+v", created)
+	}
+
+	// Los usuarios invitados quedan siempre en la empresa del token
+	userBody := fmt.Sprintf(`{"name": "X", "email": "x@example.com", "role": "admin", "company_id": "%s"}`, f.a)
+	if w := f.do(f.tokenB, "POST", "/api/invitations", userBody); w.Code != http.StatusOK {
+		t.Fatalf("Invitar usuario: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var invited models.User
+	f.db.First(&invited, "email = ?", "x@example.com")
+	if invited.CompanyID != f.b {
+		t.Errorf("El invitado debe quedar en la empresa de B, quedó en %s", invited.CompanyID)
+	}
+}
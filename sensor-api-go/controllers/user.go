@@ -1,76 +1,60 @@
 package controllers
 
 import (
+    "fmt"
     "net/http"
+    "strings"
+    "sensor-api-go/audit"
+    "sensor-api-go/middleware"
     "sensor-api-go/models"
+    "sensor-api-go/pagination"
+    "sensor-api-go/rbac"
+    "sensor-api-go/sessions"
+    "sensor-api-go/tenant"
     "github.com/gin-gonic/gin"
     "gorm.io/gorm"
-    "golang.org/x/crypto/bcrypt"
     "github.com/google/uuid"
 )
 
-type CreateUserInput struct {
-    Name      string `json:"name" binding:"required"`
-    Email     string `json:"email" binding:"required"`
-    Password  string `json:"password" binding:"required"`
-    Role      string `json:"role" binding:"required"`
-    Status    string `json:"status" binding:"required"` // "Active" o "Inactive"
-    CompanyID string `json:"company_id" binding:"required"`
+// assignableRole normaliza el rol pedido y verifica que quien hace la solicitud
+// pueda otorgarlo (nunca un rol superior al propio)
+func assignableRole(c *gin.Context, requested string) (string, int, error) {
+    role := rbac.Normalize(requested)
+    if role == "" {
+        return "", http.StatusBadRequest, fmt.Errorf("rol inválido %q; valores permitidos: %s", requested, strings.Join(rbac.Roles(), ", "))
+    }
+    if !rbac.CanManage(c.GetString("role"), role) {
+        return "", http.StatusForbidden, fmt.Errorf("no puede asignar el rol %s, superior al suyo", role)
+    }
+    return role, 0, nil
 }
 
-func CreateUser(db *gorm.DB) gin.HandlerFunc {
-    return func(c *gin.Context) {
-        var input CreateUserInput
-        if err := c.ShouldBindJSON(&input); err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-            return
-        }
-        // Hashear contraseña
-        hashed, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
-        if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear el usuario"})
-            return
-        }
-        // Parsear UUID de company_id
-        companyUUID, err := uuid.Parse(input.CompanyID)
-        if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "CompanyID inválido"})
-            return
-        }
-        user := models.User{
-            ID:        uuid.New(),
-            Name:      input.Name,
-            Email:     input.Email,
-            Password:  string(hashed),
-            Role:      input.Role,
-            Status:    input.Status,
-            CompanyID: companyUUID,
-        }
-        if err := db.Create(&user).Error; err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-            return
-        }
-        user.Password = "" // Nunca exponer el hash al frontend
-        c.JSON(http.StatusOK, user)
+func roleErrorCode(status int) string {
+    if status == http.StatusForbidden {
+        return middleware.ErrCodePermissionDenied
     }
+    return "invalid_role"
 }
 
-// ListUsers retorna todos los usuarios (sin exponer el password)
+// ListUsers retorna los usuarios de la empresa del token (sin exponer el password)
 func ListUsers(db *gorm.DB) gin.HandlerFunc {
     return func(c *gin.Context) {
-        var users []models.User
-        if err := db.Find(&users).Error; err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        tdb, _, ok := tenantDB(c, db)
+        if !ok {
+            return
+        }
+        users, next, ok := findPage(c, tdb.Model(&models.User{}), "created_at", "id", func(u models.User) pagination.Cursor {
+            return pagination.Cursor{Time: u.CreatedAt, ID: u.ID.String()}
+        })
+        if !ok {
             return
         }
 
         // Limpia el password antes de devolver
-        usersClean := make([]models.User, len(users))
-        for i, u := range users {
-            u.Password = ""
-            usersClean[i] = u
+        for i := range users {
+            users[i].Password = ""
         }
-        c.JSON(http.StatusOK, usersClean)
+        c.JSON(http.StatusOK, gin.H{"items": users, "next_cursor": next})
     }
 }
 
@@ -79,7 +63,7 @@ type UpdateUserInput struct {
     Name   *string `json:"name"`
     Email  *string `json:"email"`
     Role   *string `json:"role"`
-    Status *string `json:"status"`
+    Status *string `json:"status"` // "Active" o "Inactive"
 }
 
 // Actualiza un usuario existente
@@ -92,17 +76,26 @@ func UpdateUser(db *gorm.DB) gin.HandlerFunc {
             return
         }
 
+        tdb, _, ok := tenantDB(c, db)
+        if !ok {
+            return
+        }
         var user models.User
-        if err := db.First(&user, "id = ?", uid).Error; err != nil {
+        if err := tdb.First(&user, "id = ?", uid).Error; err != nil {
             c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
             return
         }
+        if !rbac.CanManage(c.GetString("role"), user.Role) {
+            c.JSON(http.StatusForbidden, gin.H{"error": "No puede modificar usuarios con un rol superior al suyo", "code": middleware.ErrCodePermissionDenied})
+            return
+        }
 
         var input UpdateUserInput
         if err := c.ShouldBindJSON(&input); err != nil {
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
             return
         }
+        before := user
 
         if input.Name != nil {
             user.Name = *input.Name
@@ -111,16 +104,41 @@ func UpdateUser(db *gorm.DB) gin.HandlerFunc {
             user.Email = *input.Email
         }
         if input.Role != nil {
-            user.Role = *input.Role
+            role, status, err := assignableRole(c, *input.Role)
+            if err != nil {
+                c.JSON(status, gin.H{"error": err.Error(), "code": roleErrorCode(status)})
+                return
+            }
+            user.Role = role
         }
         if input.Status != nil {
+            // "Invited" solo lo asigna la invitación y se deja al aceptarla
+            if *input.Status != "Active" && *input.Status != "Inactive" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("status inválido %q; valores permitidos: Active, Inactive", *input.Status)})
+                return
+            }
+            if user.Status == "Invited" && *input.Status != user.Status {
+                c.JSON(http.StatusConflict, gin.H{"error": "El usuario aún no acepta la invitación; reenvíela o revóquela"})
+                return
+            }
             user.Status = *input.Status
         }
 
-        if err := db.Save(&user).Error; err != nil {
+        err = db.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Scopes(tenant.Scope(user.CompanyID)).Save(&user).Error; err != nil {
+                return err
+            }
+            // Un usuario desactivado pierde sus sesiones de inmediato
+            if user.Status != "Active" {
+                return sessions.RevokeAllForUser(tx, user.ID, sessions.ReasonUserDeactivated)
+            }
+            return nil
+        })
+        if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo actualizar el usuario"})
             return
         }
+        audit.Describe(c, audit.Target{Type: "user", ID: user.ID.String(), Before: before, After: user})
 
         user.Password = "" // no exponer hash
         c.JSON(http.StatusOK, user)
@@ -137,10 +155,37 @@ func DeleteUser(db *gorm.DB) gin.HandlerFunc {
             return
         }
 
-        if err := db.Delete(&models.User{}, "id = ?", uid).Error; err != nil {
+        tdb, _, ok := tenantDB(c, db)
+        if !ok {
+            return
+        }
+        var user models.User
+        if err := tdb.First(&user, "id = ?", uid).Error; err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+            return
+        }
+        if !rbac.CanManage(c.GetString("role"), user.Role) {
+            c.JSON(http.StatusForbidden, gin.H{"error": "No puede eliminar usuarios con un rol superior al suyo", "code": middleware.ErrCodePermissionDenied})
+            return
+        }
+        var deleted int64
+        err = db.Transaction(func(tx *gorm.DB) error {
+            res := tx.Scopes(tenant.Scope(user.CompanyID)).Delete(&models.User{}, "id = ?", uid)
+            if res.Error != nil {
+                return res.Error
+            }
+            deleted = res.RowsAffected
+            return sessions.RevokeAllForUser(tx, uid, sessions.ReasonUserDeleted)
+        })
+        if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo eliminar el usuario"})
             return
         }
+        if deleted == 0 {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+            return
+        }
+        audit.Describe(c, audit.Target{Type: "user", ID: user.ID.String(), Before: user})
 
         c.JSON(http.StatusOK, gin.H{"message": "Usuario eliminado correctamente"})
     }
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+)
+
+func TestInviteUser_RoleEscalation(t *testing.T) {
+	f := newTenantFixture(t)
+	body := `{"name": "N", "email": "%s", "role": "%s"}`
+
+	// Un admin no puede invitar super-admins
+	if w := f.do(f.tokenA, "POST", "/api/invitations", fmt.Sprintf(body, "n1@example.com", "super-admin")); w.Code != http.StatusForbidden {
+		t.Errorf("Escalamiento de rol: esperado 403, fue %d", w.Code)
+	}
+	if w := f.do(f.tokenA, "POST", "/api/invitations", fmt.Sprintf(body, "n2@example.com", "root")); w.Code != http.StatusBadRequest {
+		t.Errorf("Rol desconocido: esperado 400, fue %d", w.Code)
+	}
+
+	// Los alias se guardan con el nombre canónico
+	if w := f.do(f.tokenA, "POST", "/api/invitations", fmt.Sprintf(body, "n3@example.com", "Manager")); w.Code != http.StatusOK {
+		t.Fatalf("Invitar operador: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var created models.User
+	f.db.First(&created, "email = ?", "n3@example.com")
+	if created.Role != "operator" {
+		t.Errorf("Esperado rol canónico operator, fue %q", created.Role)
+	}
+
+	// Un operador no puede degradar ni eliminar a un admin
+	op := models.User{ID: uuid.New(), CompanyID: f.a, Name: "Op", Email: "op@example.com", Password: "x", Role: "operator", Status: "Active"}
+	f.db.Create(&op)
+	operator, _ := f.login(t, op)
+	var admin models.User
+	f.db.First(&admin, "email = ?", "a@example.com")
+	if w := f.do(operator, "DELETE", "/api/users/"+admin.ID.String(), ""); w.Code != http.StatusForbidden {
+		t.Errorf("Operador eliminando admin: esperado 403, fue %d", w.Code)
+	}
+}
+
+func TestUpdateUser_ValidatesStatus(t *testing.T) {
+	f := newTenantFixture(t)
+	viewer := models.User{ID: uuid.New(), CompanyID: f.a, Name: "V", Email: "v@example.com", Password: "x", Role: "viewer", Status: "Active"}
+	invited := models.User{ID: uuid.New(), CompanyID: f.a, Name: "I", Email: "i@example.com", Role: "viewer", Status: "Invited"}
+	f.db.Create(&viewer)
+	f.db.Create(&invited)
+
+	for _, status := range []string{"Invited", "activo", ""} {
+		if w := f.do(f.tokenA, "PUT", "/api/users/"+viewer.ID.String(), fmt.Sprintf(`{"status": "%s"}`, status)); w.Code != http.StatusBadRequest {
+			t.Errorf("Status %q: esperado 400, fue %d", status, w.Code)
+		}
+	}
+	if w := f.do(f.tokenA, "PUT", "/api/users/"+invited.ID.String(), `{"status": "Active"}`); w.Code != http.StatusConflict {
+		t.Errorf("Activar un invitado sin aceptar: esperado 409, fue %d", w.Code)
+	}
+	if w := f.do(f.tokenA, "PUT", "/api/users/"+viewer.ID.String(), `{"status": "Inactive"}`); w.Code != http.StatusOK {
+		t.Fatalf("Desactivar: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	f.db.First(&viewer, "id = ?", viewer.ID)
+	if viewer.Status != "Inactive" {
+		t.Errorf("El status debe quedar Inactive, es %q", viewer.Status)
+	}
+}
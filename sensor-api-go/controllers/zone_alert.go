@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"sensor-api-go/audit"
 	"sensor-api-go/models"
+	"sensor-api-go/pagination"
+	"sensor-api-go/zones"
 	"strconv"
 	"time"
 
@@ -12,17 +16,82 @@ import (
 )
 
 type DeviceAlertInput struct {
-	DeviceID    string  `json:"device_id" binding:"required"`
-	UpperThresh float64 `json:"upper_thresh"`
-	LowerThresh float64 `json:"lower_thresh"`
-	Recipient   string  `json:"recipient" binding:"required,email"`
+	DeviceID        string  `json:"device_id" binding:"required"`
+	UpperThresh     float64 `json:"upper_thresh"`
+	LowerThresh     float64 `json:"lower_thresh"`
+	Recipient       string  `json:"recipient" binding:"required,email"`
+	Mode            string  `json:"mode" binding:"omitempty,oneof=any max avg"` // por defecto "any"
+	Hysteresis      float64 `json:"hysteresis" binding:"min=0"`
+	MinDurationSec  int     `json:"min_duration_seconds" binding:"min=0"`
+	MinConsecutive  int     `json:"min_consecutive" binding:"min=0"`
+	ReminderMinutes int     `json:"reminder_minutes" binding:"min=0"`
 }
 
 type ZoneAlertInput struct {
-	ZoneID      string  `json:"zone_id" binding:"required"`
-	UpperThresh float64 `json:"upper_thresh"`
-	LowerThresh float64 `json:"lower_thresh"`
-	Recipient   string  `json:"recipient" binding:"required,email"`
+	ZoneID          string  `json:"zone_id" binding:"required"` // UUID de la zona o número de zona de la cámara
+	CameraID        *int    `json:"camera_id"`                  // obligatorio si zone_id es numérico
+	UpperThresh     float64 `json:"upper_thresh"`
+	LowerThresh     float64 `json:"lower_thresh"`
+	Recipient       string  `json:"recipient" binding:"required,email"`
+	Hysteresis      float64 `json:"hysteresis" binding:"min=0"`           // banda de despeje en °C
+	MinDurationSec  int     `json:"min_duration_seconds" binding:"min=0"` // segundos seguidos fuera de rango
+	MinConsecutive  int     `json:"min_consecutive" binding:"min=0"`      // lecturas seguidas fuera de rango
+	ReminderMinutes int     `json:"reminder_minutes" binding:"min=0"`     // 0 = sin recordatorios
+}
+
+// validateThresholds revisa que umbrales e histéresis dejen una banda de despeje no vacía
+func validateThresholds(upper, lower, hysteresis float64) error {
+	if lower > upper {
+		return errors.New("lower_thresh no puede ser mayor que upper_thresh")
+	}
+	if lower+hysteresis > upper-hysteresis {
+		return errors.New("hysteresis demasiado grande: la alerta nunca podría resolverse")
+	}
+	return nil
+}
+
+// resolveZoneID obtiene el UUID registrado de la zona de la empresa. Acepta el UUID
+// directamente o el zone_id numérico de las lecturas junto con su camera_id.
+func resolveZoneID(tdb *gorm.DB, companyID uuid.UUID, input ZoneAlertInput) (uuid.UUID, int, error) {
+	if zoneUUID, err := uuid.Parse(input.ZoneID); err == nil {
+		var zone models.Zone
+		if err := tdb.First(&zone, "id = ?", zoneUUID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return uuid.Nil, http.StatusBadRequest, errors.New("zone_id no corresponde a una zona registrada")
+			}
+			return uuid.Nil, http.StatusInternalServerError, err
+		}
+		return zone.ID, 0, nil
+	}
+
+	zoneIndex, err := strconv.Atoi(input.ZoneID)
+	if err != nil {
+		return uuid.Nil, http.StatusBadRequest, errors.New("zone_id inválido")
+	}
+	if input.CameraID == nil {
+		return uuid.Nil, http.StatusBadRequest, errors.New("camera_id es obligatorio cuando zone_id es numérico")
+	}
+	zone, err := zones.Ensure(tdb, companyID, *input.CameraID, zoneIndex)
+	if err != nil {
+		return uuid.Nil, http.StatusInternalServerError, err
+	}
+	return zone.ID, 0, nil
+}
+
+// resolveDeviceID valida que el dispositivo exista en la empresa
+func resolveDeviceID(tdb *gorm.DB, deviceID string) (uuid.UUID, int, error) {
+	deviceUUID, err := uuid.Parse(deviceID)
+	if err != nil {
+		return uuid.Nil, http.StatusBadRequest, errors.New("device_id inválido")
+	}
+	var device models.Device
+	if err := tdb.First(&device, "id = ?", deviceUUID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, http.StatusBadRequest, errors.New("device_id no corresponde a un dispositivo registrado")
+		}
+		return uuid.Nil, http.StatusInternalServerError, err
+	}
+	return device.ID, 0, nil
 }
 
 // --- Device Alerts ---
@@ -34,36 +103,60 @@ func CreateDeviceAlert(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		deviceUUID, err := uuid.Parse(input.DeviceID)
+		if err := validateThresholds(input.UpperThresh, input.LowerThresh, input.Hysteresis); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.Mode == "" {
+			input.Mode = models.DeviceAlertModeAny
+		}
+		tdb, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		deviceUUID, status, err := resolveDeviceID(tdb, input.DeviceID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "device_id inválido"})
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
 		alert := models.DeviceAlert{
-			ID:          uuid.New(),
-			DeviceID:    deviceUUID,
-			UpperThresh: input.UpperThresh,
-			LowerThresh: input.LowerThresh,
-			Recipient:   input.Recipient,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-		}
-		if err := db.Create(&alert).Error; err != nil {
+			ID:              uuid.New(),
+			CompanyID:       companyID,
+			DeviceID:        deviceUUID,
+			UpperThresh:     input.UpperThresh,
+			LowerThresh:     input.LowerThresh,
+			Recipient:       input.Recipient,
+			Mode:            input.Mode,
+			Hysteresis:      input.Hysteresis,
+			MinDurationSec:  input.MinDurationSec,
+			MinConsecutive:  input.MinConsecutive,
+			ReminderMinutes: input.ReminderMinutes,
+			State:           models.AlertStateOK,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+		if err := tdb.Create(&alert).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		audit.Describe(c, audit.Target{Type: "device_alert", ID: alert.ID.String(), After: alert})
 		c.JSON(http.StatusOK, alert)
 	}
 }
 
 func ListDeviceAlerts(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var alerts []models.DeviceAlert
-		if err := db.Find(&alerts).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
 			return
 		}
-		c.JSON(http.StatusOK, alerts)
+		alerts, next, ok := findPage(c, tdb.Model(&models.DeviceAlert{}), "created_at", "id", func(a models.DeviceAlert) pagination.Cursor {
+			return pagination.Cursor{Time: a.CreatedAt, ID: a.ID.String()}
+		})
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": alerts, "next_cursor": next})
 	}
 }
 
@@ -75,8 +168,12 @@ func UpdateDeviceAlert(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
 			return
 		}
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
 		var alert models.DeviceAlert
-		if err := db.First(&alert, "id = ?", alertID).Error; err != nil {
+		if err := tdb.First(&alert, "id = ?", alertID).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Alerta no encontrada"})
 			return
 		}
@@ -86,24 +183,49 @@ func UpdateDeviceAlert(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err := validateThresholds(input.UpperThresh, input.LowerThresh, input.Hysteresis); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.Mode == "" {
+			input.Mode = models.DeviceAlertModeAny
+		}
 
-		deviceUUID, err := uuid.Parse(input.DeviceID)
+		deviceUUID, status, err := resolveDeviceID(tdb, input.DeviceID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "device_id inválido"})
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
 
+		before := alert
 		alert.DeviceID = deviceUUID
 		alert.UpperThresh = input.UpperThresh
 		alert.LowerThresh = input.LowerThresh
 		alert.Recipient = input.Recipient
+		alert.Mode = input.Mode
+		alert.Hysteresis = input.Hysteresis
+		alert.MinDurationSec = input.MinDurationSec
+		alert.MinConsecutive = input.MinConsecutive
+		alert.ReminderMinutes = input.ReminderMinutes
 		alert.UpdatedAt = time.Now()
 
-		if err := db.Save(&alert).Error; err != nil {
+		// Solo se escribe la configuración: el estado lo lleva el worker de alertas
+		columns := []string{"device_id", "upper_thresh", "lower_thresh", "recipient", "mode", "hysteresis", "min_duration_sec", "min_consecutive", "reminder_minutes", "updated_at"}
+		if alert.DeviceID != before.DeviceID {
+			columns = append(columns, resetAlertState(&alert.State, &alert.StateChangedAt, &alert.LastNotifiedAt)...)
+		}
+		if err := tdb.Model(&alert).Select(columns).Updates(&alert).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, alert)
+		// Se relee para responder con el estado actual del worker
+		var updated models.DeviceAlert
+		if err := tdb.First(&updated, "id = ?", alert.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.Describe(c, audit.Target{Type: "device_alert", ID: alert.ID.String(), Before: before, After: updated})
+		c.JSON(http.StatusOK, updated)
 	}
 }
 
@@ -115,14 +237,36 @@ func DeleteDeviceAlert(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
 			return
 		}
-		if err := db.Delete(&models.DeviceAlert{}, "id = ?", alertID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var alert models.DeviceAlert
+		if err := tdb.First(&alert, "id = ?", alertID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alerta no encontrada"})
+			return
+		}
+		res := tdb.Delete(&models.DeviceAlert{}, "id = ?", alertID)
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
 			return
 		}
+		if res.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alerta no encontrada"})
+			return
+		}
+		audit.Describe(c, audit.Target{Type: "device_alert", ID: alert.ID.String(), Before: alert})
 		c.JSON(http.StatusOK, gin.H{"message": "Alerta eliminada correctamente"})
 	}
 }
 
+// resetAlertState deja la alerta en OK al cambiar lo que vigila (el estado era del
+// objetivo anterior) y retorna las columnas que hay que escribir
+func resetAlertState(state *string, changedAt, notifiedAt **time.Time) []string {
+	*state, *changedAt, *notifiedAt = models.AlertStateOK, nil, nil
+	return []string{"state", "state_changed_at", "last_notified_at"}
+}
+
 // --- Zone Alerts ---
 
 func CreateZoneAlert(db *gorm.DB) gin.HandlerFunc {
@@ -132,48 +276,58 @@ func CreateZoneAlert(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err := validateThresholds(input.UpperThresh, input.LowerThresh, input.Hysteresis); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		// --- ADAPTACIÓN CLAVE: Soportar zone_id como UUID o numérico ---
-		var zoneUUID uuid.UUID
-		var err error
-		// Intenta parsear como UUID
-		zoneUUID, err = uuid.Parse(input.ZoneID)
+		tdb, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		zoneUUID, status, err := resolveZoneID(tdb, companyID, input)
 		if err != nil {
-			// Si falla, intenta parsear como int
-			if zoneInt, err2 := strconv.Atoi(input.ZoneID); err2 == nil {
-				// Genera un UUID determinista usando namespace (ejemplo)
-				zoneUUID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(strconv.Itoa(zoneInt)))
-			} else {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "zone_id inválido"})
-				return
-			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
 		}
 
 		alert := models.ZoneAlert{
-			ID:          uuid.New(),
-			ZoneID:      zoneUUID,
-			UpperThresh: input.UpperThresh,
-			LowerThresh: input.LowerThresh,
-			Recipient:   input.Recipient,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-		}
-		if err := db.Create(&alert).Error; err != nil {
+			ID:              uuid.New(),
+			CompanyID:       companyID,
+			ZoneID:          zoneUUID,
+			UpperThresh:     input.UpperThresh,
+			LowerThresh:     input.LowerThresh,
+			Recipient:       input.Recipient,
+			Hysteresis:      input.Hysteresis,
+			MinDurationSec:  input.MinDurationSec,
+			MinConsecutive:  input.MinConsecutive,
+			ReminderMinutes: input.ReminderMinutes,
+			State:           models.AlertStateOK,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+		if err := tdb.Create(&alert).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		audit.Describe(c, audit.Target{Type: "zone_alert", ID: alert.ID.String(), After: alert})
 		c.JSON(http.StatusOK, alert)
 	}
 }
 
 func ListZoneAlerts(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var alerts []models.ZoneAlert
-		if err := db.Find(&alerts).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
 			return
 		}
-		c.JSON(http.StatusOK, alerts)
+		alerts, next, ok := findPage(c, tdb.Model(&models.ZoneAlert{}), "created_at", "id", func(a models.ZoneAlert) pagination.Cursor {
+			return pagination.Cursor{Time: a.CreatedAt, ID: a.ID.String()}
+		})
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": alerts, "next_cursor": next})
 	}
 }
 
@@ -185,8 +339,12 @@ func UpdateZoneAlert(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
 			return
 		}
+		tdb, companyID, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
 		var alert models.ZoneAlert
-		if err := db.First(&alert, "id = ?", alertID).Error; err != nil {
+		if err := tdb.First(&alert, "id = ?", alertID).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Alerta no encontrada"})
 			return
 		}
@@ -196,31 +354,45 @@ func UpdateZoneAlert(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err := validateThresholds(input.UpperThresh, input.LowerThresh, input.Hysteresis); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		var zoneUUID uuid.UUID
-		// Intenta parsear como UUID
-		zoneUUID, err = uuid.Parse(input.ZoneID)
+		zoneUUID, status, err := resolveZoneID(tdb, companyID, input)
 		if err != nil {
-			// Si falla, intenta parsear como int
-			if zoneInt, err2 := strconv.Atoi(input.ZoneID); err2 == nil {
-				zoneUUID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(strconv.Itoa(zoneInt)))
-			} else {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "zone_id inválido"})
-				return
-			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
 		}
 
+		before := alert
 		alert.ZoneID = zoneUUID
 		alert.UpperThresh = input.UpperThresh
 		alert.LowerThresh = input.LowerThresh
 		alert.Recipient = input.Recipient
+		alert.Hysteresis = input.Hysteresis
+		alert.MinDurationSec = input.MinDurationSec
+		alert.MinConsecutive = input.MinConsecutive
+		alert.ReminderMinutes = input.ReminderMinutes
 		alert.UpdatedAt = time.Now()
 
-		if err := db.Save(&alert).Error; err != nil {
+		// Solo se escribe la configuración: el estado lo lleva el worker de alertas
+		columns := []string{"zone_id", "upper_thresh", "lower_thresh", "recipient", "hysteresis", "min_duration_sec", "min_consecutive", "reminder_minutes", "updated_at"}
+		if alert.ZoneID != before.ZoneID {
+			columns = append(columns, resetAlertState(&alert.State, &alert.StateChangedAt, &alert.LastNotifiedAt)...)
+		}
+		if err := tdb.Model(&alert).Select(columns).Updates(&alert).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, alert)
+		// Se relee para responder con el estado actual del worker
+		var updated models.ZoneAlert
+		if err := tdb.First(&updated, "id = ?", alert.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.Describe(c, audit.Target{Type: "zone_alert", ID: alert.ID.String(), Before: before, After: updated})
+		c.JSON(http.StatusOK, updated)
 	}
 }
 
@@ -232,10 +404,25 @@ func DeleteZoneAlert(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
 			return
 		}
-		if err := db.Delete(&models.ZoneAlert{}, "id = ?", alertID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
+			return
+		}
+		var alert models.ZoneAlert
+		if err := tdb.First(&alert, "id = ?", alertID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alerta no encontrada"})
+			return
+		}
+		res := tdb.Delete(&models.ZoneAlert{}, "id = ?", alertID)
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alerta no encontrada"})
 			return
 		}
+		audit.Describe(c, audit.Target{Type: "zone_alert", ID: alert.ID.String(), Before: alert})
 		c.JSON(http.StatusOK, gin.H{"message": "Alerta eliminada correctamente"})
 	}
 }
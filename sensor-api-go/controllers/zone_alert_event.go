@@ -5,13 +5,14 @@ package controllers
 import (
 	"net/http"
 	"sensor-api-go/models"
+	"sensor-api-go/pagination"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-// GET /api/zones/:zone_id/alert-events
+// GET /api/zones/:zone_id/alert-events (paginado por cursor, del más nuevo al más antiguo)
 func ListZoneAlertEvents(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		zoneIDStr := c.Param("zone_id")
@@ -20,11 +21,17 @@ func ListZoneAlertEvents(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "zone_id inválido"})
 			return
 		}
-		var events []models.ZoneAlertEvent
-		if err := db.Where("zone_id = ?", zoneID).Order("timestamp DESC").Find(&events).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron obtener los eventos"})
+		tdb, _, ok := tenantDB(c, db)
+		if !ok {
 			return
 		}
-		c.JSON(http.StatusOK, events)
+		q := tdb.Model(&models.ZoneAlertEvent{}).Where("zone_id = ?", zoneID)
+		events, next, ok := findPage(c, q, "timestamp", "id", func(e models.ZoneAlertEvent) pagination.Cursor {
+			return pagination.Cursor{Time: e.Timestamp, ID: e.ID.String()}
+		})
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": events, "next_cursor": next})
 	}
 }
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+)
+
+func TestUpdateZoneAlert_KeepsWorkerState(t *testing.T) {
+	f := newTenantFixture(t)
+	since := time.Now().Add(-time.Hour)
+	f.db.Model(&f.alertA).Updates(map[string]interface{}{"state": models.AlertStateFiring, "state_changed_at": since, "last_notified_at": since})
+
+	body := `{"zone_id": "%s", "camera_id": 1, "upper_thresh": 45, "recipient": "b@example.com"}`
+	if w := f.do(f.tokenA, "PUT", "/api/zone-alerts/"+f.alertA.ID.String(), fmt.Sprintf(body, f.zoneA.ID.String())); w.Code != http.StatusOK {
+		t.Fatalf("Editar umbral: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	var alert models.ZoneAlert
+	f.db.First(&alert, "id = ?", f.alertA.ID)
+	if alert.UpperThresh != 45 || alert.Recipient != "b@example.com" {
+		t.Errorf("La configuración no se guardó: %+v", alert)
+	}
+	if alert.State != models.AlertStateFiring || alert.StateChangedAt == nil || alert.LastNotifiedAt == nil {
+		t.Errorf("Editar la configuración no debe tocar el estado del worker: %+v", alert)
+	}
+
+	// Al cambiar de zona el estado era de la zona anterior
+	if w := f.do(f.tokenA, "PUT", "/api/zone-alerts/"+f.alertA.ID.String(), fmt.Sprintf(body, "2")); w.Code != http.StatusOK {
+		t.Fatalf("Cambiar de zona: esperado 200, fue %d: %s", w.Code, w.Body.String())
+	}
+	alert = models.ZoneAlert{}
+	f.db.First(&alert, "id = ?", f.alertA.ID)
+	if alert.ZoneID == f.zoneA.ID || alert.State != models.AlertStateOK || alert.StateChangedAt != nil || alert.LastNotifiedAt != nil {
+		t.Errorf("Al cambiar de zona el estado debe volver a OK: %+v", alert)
+	}
+}
@@ -0,0 +1,391 @@
+// csvimport/csvimport.go
+
+// Package csvimport importa lecturas históricas desde archivos CSV (las exportaciones
+// de los registradores de los clientes). El archivo se lee en lotes: cada fila se
+// interpreta según el Mapping, se valida como cualquier lectura entrante
+// (ingest.Validate), se descarta si ya existe la misma lectura (empresa, cámara,
+// zona y fecha) y el resto se guarda con ingest.Save. Las filas rechazadas quedan
+// en ImportError para el reporte de errores.
+package csvimport
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"sensor-api-go/export"
+	"sensor-api-go/ingest"
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	BatchSize  = 1000             // filas por lote (y por transacción)
+	MaxErrors  = 100000           // líneas rechazadas que se guardan; las demás solo se cuentan
+	StaleAfter = 15 * time.Minute // un job sin avances en este tiempo quedó interrumpido
+
+	RecoverInterval   = time.Minute    // cada cuánto Watch busca jobs interrumpidos
+	HeartbeatInterval = StaleAfter / 3 // cada cuánto Touch marca como vivo un job en cola
+)
+
+// ErrNotPending se retorna si el job ya no está pendiente al momento de procesarlo
+// (por ejemplo, Recover lo marcó como fallido mientras esperaba)
+var ErrNotPending = errors.New("la importación ya no está pendiente")
+
+// ErrorReportHeader son las columnas del reporte de errores
+var ErrorReportHeader = []string{"linea", "error", "contenido"}
+
+// ParseMapping decodifica y valida el mapeo en JSON; vacío es el mapeo automático
+func ParseMapping(s string) (Mapping, error) {
+	var m Mapping
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return m, fmt.Errorf("mapeo inválido: %v", err)
+	}
+	if _, err := m.delimiter(); err != nil {
+		return m, err
+	}
+	if m.Unit != "" {
+		if _, err := export.ParseUnit(m.Unit); err != nil {
+			return m, err
+		}
+	}
+	if _, err := export.LoadLocation(m.TimeZone); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// NewJob registra una importación pendiente
+func NewJob(db *gorm.DB, companyID uuid.UUID, createdBy *uuid.UUID, fileName string, size int64, m Mapping) (models.ImportJob, error) {
+	mapping, err := json.Marshal(m)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+	job := models.ImportJob{
+		ID:          uuid.New(),
+		CompanyID:   companyID,
+		CreatedByID: createdBy,
+		FileName:    fileName,
+		Mapping:     string(mapping),
+		Status:      models.ImportPending,
+		SizeBytes:   size,
+	}
+	return job, db.Create(&job).Error
+}
+
+// line es una fila válida pendiente de guardar
+type line struct {
+	number  int
+	content string
+	reading reading
+}
+
+// importer mantiene el estado de una importación en curso
+type importer struct {
+	db       *gorm.DB
+	job      *models.ImportJob
+	counter  *countingReader
+	errors   []models.ImportError
+	progress func(models.ImportJob)
+}
+
+// Run procesa el CSV de r para el job, actualizando su avance después de cada lote.
+// onProgress (opcional) recibe el job después de cada lote. Si el archivo no se
+// puede interpretar (encabezado, mapeo) o la base falla, el job queda en
+// ImportFailed con el motivo y se retorna el error.
+func Run(ctx context.Context, db *gorm.DB, job *models.ImportJob, r io.Reader, onProgress func(models.ImportJob)) error {
+	now := time.Now()
+	res := db.Model(job).Where("status = ?", models.ImportPending).
+		Updates(map[string]interface{}{"status": models.ImportRunning, "started_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotPending
+	}
+	job.Status, job.StartedAt = models.ImportRunning, &now
+	imp := &importer{db: db, job: job, counter: &countingReader{r: r}, progress: onProgress}
+	err := imp.run(ctx)
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.ReadBytes = imp.counter.n
+	if err != nil {
+		job.Status, job.Error = models.ImportFailed, err.Error()
+	} else {
+		job.Status = models.ImportDone
+	}
+	if saveErr := db.Save(job).Error; saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if onProgress != nil {
+		onProgress(*job)
+	}
+	return err
+}
+
+func (imp *importer) run(ctx context.Context) error {
+	var m Mapping
+	if err := json.Unmarshal([]byte(imp.job.Mapping), &m); err != nil {
+		return fmt.Errorf("mapeo inválido: %v", err)
+	}
+	var company models.Company
+	if err := imp.db.Select("id", "time_zone").First(&company, "id = ?", imp.job.CompanyID).Error; err != nil {
+		return fmt.Errorf("empresa no encontrada: %v", err)
+	}
+
+	buffered := bufio.NewReader(imp.counter)
+	delim, err := m.delimiter()
+	if err != nil {
+		return err
+	}
+	if delim == 0 {
+		first, err := buffered.Peek(4096)
+		if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+			return err
+		}
+		headerLine, _, _ := strings.Cut(string(first), "\n")
+		delim = detectDelimiter(headerLine)
+	}
+	reader := csv.NewReader(buffered)
+	reader.Comma = delim
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return errors.New("el archivo está vacío")
+	}
+	if err != nil {
+		return fmt.Errorf("encabezado inválido: %v", err)
+	}
+	p, err := newParser(m, header, company.TimeZone)
+	if err != nil {
+		return err
+	}
+
+	batch := make([]line, 0, BatchSize)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		imp.job.Rows++
+		number, _ := reader.FieldPos(0)
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			imp.reject(parseErr.StartLine, strings.Join(record, string(delim)), "fila CSV inválida: "+parseErr.Err.Error())
+		} else if err != nil {
+			return err
+		} else {
+			content := strings.Join(record, string(delim))
+			if r, err := p.parse(record); err != nil {
+				imp.reject(number, content, err.Error())
+			} else {
+				batch = append(batch, line{number: number, content: content, reading: r})
+			}
+		}
+		if len(batch) == BatchSize || len(imp.errors) >= BatchSize {
+			if err := imp.flush(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	return imp.flush(ctx, batch)
+}
+
+func (imp *importer) reject(number int, content, reason string) {
+	imp.job.Rejected++
+	if imp.job.Rejected <= MaxErrors {
+		imp.errors = append(imp.errors, models.ImportError{JobID: imp.job.ID, Line: number, Content: content, Reason: reason})
+	}
+}
+
+// dedupKey identifica una lectura: la misma zona no mide dos veces en el mismo instante
+type dedupKey struct {
+	camera, zone int
+	micros       int64
+}
+
+// flush valida el lote, descarta las lecturas repetidas y guarda el resto, los
+// errores y el avance del job
+func (imp *importer) flush(ctx context.Context, batch []line) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("importación cancelada: %v", err)
+	}
+	now := time.Now()
+	valid := make([]line, 0, len(batch))
+	for _, l := range batch {
+		l.reading.timestamp = l.reading.timestamp.UTC().Truncate(time.Microsecond)
+		cam, zone, temp, ts := l.reading.camera, l.reading.zone, l.reading.temperature, l.reading.timestamp
+		_, err := ingest.Validate(ingest.ReadingInput{CompanyID: imp.job.CompanyID, CameraID: &cam, ZoneID: &zone, Temperature: &temp, Timestamp: &ts}, now)
+		if err != nil {
+			imp.reject(l.number, l.content, err.Error())
+			continue
+		}
+		valid = append(valid, l)
+	}
+
+	existing, err := imp.existing(valid)
+	if err != nil {
+		return err
+	}
+	inputs := make([]ingest.ReadingInput, 0, len(valid))
+	for _, l := range valid {
+		key := dedupKey{l.reading.camera, l.reading.zone, l.reading.timestamp.UnixMicro()}
+		if existing[key] {
+			imp.job.Duplicates++
+			continue
+		}
+		existing[key] = true
+		cam, zone, temp, ts := l.reading.camera, l.reading.zone, l.reading.temperature, l.reading.timestamp
+		inputs = append(inputs, ingest.ReadingInput{CompanyID: imp.job.CompanyID, CameraID: &cam, ZoneID: &zone, Temperature: &temp, Timestamp: &ts})
+	}
+	if len(inputs) > 0 {
+		results, err := ingest.Save(imp.db, inputs)
+		if err != nil {
+			return fmt.Errorf("no se pudieron guardar las lecturas: %v", err)
+		}
+		accepted, _ := ingest.Count(results)
+		imp.job.Imported += accepted
+	}
+	if len(imp.errors) > 0 {
+		if err := imp.db.CreateInBatches(imp.errors, 500).Error; err != nil {
+			return fmt.Errorf("no se pudo guardar el reporte de errores: %v", err)
+		}
+		imp.errors = imp.errors[:0]
+	}
+
+	imp.job.ReadBytes = imp.counter.n
+	if err := imp.db.Save(imp.job).Error; err != nil {
+		return err
+	}
+	if imp.progress != nil {
+		imp.progress(*imp.job)
+	}
+	return nil
+}
+
+// existing retorna las lecturas del lote que ya están en la base. Los lotes
+// anteriores ya se guardaron, así que también detecta repetidas dentro del archivo.
+func (imp *importer) existing(batch []line) (map[dedupKey]bool, error) {
+	found := map[dedupKey]bool{}
+	if len(batch) == 0 {
+		return found, nil
+	}
+	from, to := batch[0].reading.timestamp, batch[0].reading.timestamp
+	cameras, zones := map[int]bool{}, map[int]bool{}
+	for _, l := range batch {
+		if l.reading.timestamp.Before(from) {
+			from = l.reading.timestamp
+		}
+		if l.reading.timestamp.After(to) {
+			to = l.reading.timestamp
+		}
+		cameras[l.reading.camera], zones[l.reading.zone] = true, true
+	}
+	var rows []models.CameraReading
+	err := imp.db.Model(&models.CameraReading{}).Select("camera_id", "zone_id", "timestamp").
+		Where("company_id = ? AND timestamp >= ? AND timestamp <= ?", imp.job.CompanyID, from, to).
+		Where("camera_id IN ? AND zone_id IN ?", keys(cameras), keys(zones)).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		found[dedupKey{r.CameraID, r.ZoneID, r.Timestamp.UnixMicro()}] = true
+	}
+	return found, nil
+}
+
+func keys(m map[int]bool) []int {
+	list := make([]int, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	return list
+}
+
+// WriteErrorReport escribe en CSV las líneas rechazadas del job, en orden
+func WriteErrorReport(db *gorm.DB, jobID uuid.UUID, w io.Writer) error {
+	rows, err := db.Model(&models.ImportError{}).Where("job_id = ?", jobID).Order("line").Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cw := csv.NewWriter(w)
+	cw.Write(ErrorReportHeader)
+	for rows.Next() {
+		var e models.ImportError
+		if err := db.ScanRows(rows, &e); err != nil {
+			return err
+		}
+		cw.Write([]string{strconv.Itoa(e.Line), e.Reason, e.Content})
+	}
+	cw.Flush()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Recover marca como fallidas las importaciones sin avances en StaleAfter (el proceso
+// que las tenía se reinició o se colgó) y retorna cuántas
+func Recover(db *gorm.DB, now time.Time) (int64, error) {
+	res := db.Model(&models.ImportJob{}).
+		Where("status IN ? AND updated_at < ?", []string{models.ImportPending, models.ImportRunning}, now.Add(-StaleAfter)).
+		Updates(map[string]interface{}{"status": models.ImportFailed, "error": "importación interrumpida; vuelva a subir el archivo", "finished_at": now})
+	return res.RowsAffected, res.Error
+}
+
+// Touch marca como vivo un job pendiente para que Recover no lo dé por interrumpido
+// mientras espera su turno
+func Touch(db *gorm.DB, id uuid.UUID) error {
+	return db.Model(&models.ImportJob{}).Where("id = ? AND status = ?", id, models.ImportPending).
+		UpdateColumn("updated_at", time.Now()).Error
+}
+
+// Watch ejecuta Recover cada RecoverInterval hasta que se cancele ctx
+func Watch(ctx context.Context, db *gorm.DB) {
+	ticker := time.NewTicker(RecoverInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := Recover(db, time.Now()); err != nil {
+			log.Printf("[IMPORT] No se pudieron revisar las importaciones interrumpidas: %v", err)
+		} else if n > 0 {
+			log.Printf("[IMPORT] %d importaciones interrumpidas marcadas como fallidas", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// countingReader cuenta los bytes leídos para informar el avance
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
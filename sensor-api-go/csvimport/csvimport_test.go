@@ -0,0 +1,164 @@
+package csvimport
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openDB(t *testing.T) (*gorm.DB, models.Company) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	err = db.AutoMigrate(&models.Company{}, &models.CameraReading{}, &models.Device{}, &models.Zone{},
+		&models.ImportJob{}, &models.ImportError{})
+	if err != nil {
+		t.Fatalf("No se pudo migrar: %v", err)
+	}
+	company := models.Company{Name: "A", TimeZone: "America/Santiago"}
+	db.Create(&company)
+	return db, company
+}
+
+func run(t *testing.T, db *gorm.DB, company models.Company, mapping Mapping, data string) models.ImportJob {
+	t.Helper()
+	job, err := NewJob(db, company.ID, nil, "datos.csv", int64(len(data)), mapping)
+	if err != nil {
+		t.Fatalf("NewJob: %v", err)
+	}
+	var updates int
+	if err := Run(context.Background(), db, &job, strings.NewReader(data), func(models.ImportJob) { updates++ }); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if updates == 0 {
+		t.Errorf("No se informó el avance")
+	}
+	return job
+}
+
+func TestRunMapsValidatesAndDeduplicates(t *testing.T) {
+	db, company := openDB(t)
+	// Una lectura que ya estaba en la base (14:00 UTC = 11:00 en Santiago)
+	existing := models.CameraReading{CompanyID: company.ID, CameraID: 3, ZoneID: 1, Temperature: 5, Timestamp: time.Date(2024, 1, 10, 14, 0, 0, 0, time.UTC)}
+	db.Create(&existing)
+
+	data := "Fecha;Zona;Temp (°F)\n" +
+		"10/01/2024 10:00;1;41\n" +
+		"10/01/2024 11:00;1;41\n" + // ya existe
+		"10/01/2024 10:00;1;41\n" + // repetida en el archivo
+		"10/01/2024 12:00;2;35,6\n" +
+		"ayer;1;40\n" +
+		"10/01/2024 13:00;1;\n" +
+		"10/01/2024 14:00;x;40\n" +
+		"01/01/2099 10:00;1;40\n"
+	camera := 3
+	job := run(t, db, company, Mapping{Temperature: "temp (°f)", Camera: &camera, Unit: "F"}, data)
+
+	if job.Status != models.ImportDone || job.Rows != 8 || job.Imported != 2 || job.Duplicates != 2 || job.Rejected != 4 {
+		t.Fatalf("Job inesperado: %+v", job)
+	}
+	if job.Progress() != 100 || job.ReadBytes != int64(len(data)) {
+		t.Errorf("Avance: %.1f%%, %d de %d bytes", job.Progress(), job.ReadBytes, len(data))
+	}
+	var imported []models.CameraReading
+	db.Where("company_id = ? AND id <> ?", company.ID, existing.ID).Order("timestamp").Find(&imported)
+	if len(imported) != 2 || !imported[0].Timestamp.Equal(time.Date(2024, 1, 10, 13, 0, 0, 0, time.UTC)) ||
+		imported[0].Temperature != 5 || imported[1].ZoneID != 2 || math.Abs(imported[1].Temperature-2) > 1e-9 {
+		t.Errorf("Lecturas importadas: %+v", imported)
+	}
+	var zones int64
+	db.Model(&models.Zone{}).Where("company_id = ? AND camera_id = 3", company.ID).Count(&zones)
+	if zones != 2 {
+		t.Errorf("Las zonas importadas deben quedar registradas, hay %d", zones)
+	}
+
+	var report bytes.Buffer
+	if err := WriteErrorReport(db, job.ID, &report); err != nil {
+		t.Fatalf("WriteErrorReport: %v", err)
+	}
+	rows, _ := csv.NewReader(&report).ReadAll()
+	if len(rows) != 5 || rows[1][0] != "6" || !strings.Contains(rows[1][1], "fecha inválida") || rows[1][2] != "ayer;1;40" ||
+		rows[4][0] != "9" || !strings.Contains(rows[4][1], "futuro") {
+		t.Errorf("Reporte de errores: %v", rows)
+	}
+
+	// Importar el mismo archivo de nuevo no duplica nada
+	again := run(t, db, company, Mapping{Temperature: "temp (°f)", Camera: &camera, Unit: "F"}, data)
+	if again.Imported != 0 || again.Duplicates != 4 {
+		t.Errorf("Segunda importación: %+v", again)
+	}
+}
+
+func TestRunFailsOnBadHeader(t *testing.T) {
+	db, company := openDB(t)
+	job, _ := NewJob(db, company.ID, nil, "datos.csv", 10, Mapping{})
+	err := Run(context.Background(), db, &job, strings.NewReader("a,b\n1,2\n"), nil)
+	var stored models.ImportJob
+	db.First(&stored, "id = ?", job.ID)
+	if err == nil || stored.Status != models.ImportFailed || !strings.Contains(stored.Error, "timestamp") {
+		t.Errorf("Sin columnas reconocibles el job debe fallar: %v, %+v", err, stored)
+	}
+
+	if _, err := ParseMapping(`{"timestamp": "Fecha", "otro": 1}`); err == nil {
+		t.Errorf("Un campo desconocido en el mapeo debe ser un error")
+	}
+	if _, err := ParseMapping(`{"delimiter": "#"}`); err == nil {
+		t.Errorf("Un delimitador no soportado debe ser un error")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	db, company := openDB(t)
+	stale, _ := NewJob(db, company.ID, nil, "viejo.csv", 1, Mapping{})
+	fresh, _ := NewJob(db, company.ID, nil, "nuevo.csv", 1, Mapping{})
+	db.Model(&stale).UpdateColumn("updated_at", time.Now().Add(-time.Hour))
+
+	if n, err := Recover(db, time.Now()); err != nil || n != 1 {
+		t.Fatalf("Recover: %d, %v", n, err)
+	}
+	for id, want := range map[uuid.UUID]string{stale.ID: models.ImportFailed, fresh.ID: models.ImportPending} {
+		var job models.ImportJob
+		db.First(&job, "id = ?", id)
+		if job.Status != want {
+			t.Errorf("Job %s: esperado %s, fue %s", job.FileName, want, job.Status)
+		}
+	}
+}
+
+func TestRun_SkipsRecoveredJob(t *testing.T) {
+	db, company := openDB(t)
+	queued, _ := NewJob(db, company.ID, nil, "en-cola.csv", 1, Mapping{})
+	db.Model(&queued).UpdateColumn("updated_at", time.Now().Add(-time.Hour))
+	if err := Touch(db, queued.ID); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	if n, _ := Recover(db, time.Now()); n != 0 {
+		t.Fatalf("Un job en cola con latido no debe fallarse, se fallaron %d", n)
+	}
+
+	db.Model(&queued).UpdateColumn("updated_at", time.Now().Add(-time.Hour))
+	if n, _ := Recover(db, time.Now()); n != 1 {
+		t.Fatalf("Sin latido el job debe fallarse, se fallaron %d", n)
+	}
+	err := Run(context.Background(), db, &queued, strings.NewReader("fecha,temperatura\n"), nil)
+	if !errors.Is(err, ErrNotPending) {
+		t.Fatalf("Run de un job ya fallado: esperado ErrNotPending, fue %v", err)
+	}
+	var job models.ImportJob
+	db.First(&job, "id = ?", queued.ID)
+	if job.Status != models.ImportFailed {
+		t.Errorf("El job debe seguir fallado, es %s", job.Status)
+	}
+}
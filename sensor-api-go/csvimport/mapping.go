@@ -0,0 +1,234 @@
+// csvimport/mapping.go
+
+package csvimport
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sensor-api-go/export"
+)
+
+// Mapping indica qué columna del CSV corresponde a cada campo de CameraReading.
+// Las columnas se nombran como en el encabezado (sin distinguir mayúsculas). Los
+// campos vacíos se buscan por nombres habituales (ver defaultColumns). Si el archivo
+// es de una sola cámara o zona, Camera y Zone fijan el valor para todas las filas.
+type Mapping struct {
+	Timestamp   string `json:"timestamp,omitempty"`
+	Temperature string `json:"temperature,omitempty"`
+	CameraID    string `json:"camera_id,omitempty"`
+	ZoneID      string `json:"zone_id,omitempty"`
+	Camera      *int   `json:"camera,omitempty"`
+	Zone        *int   `json:"zone,omitempty"`
+
+	TimeFormat string `json:"time_format,omitempty"` // layout de Go; vacío prueba los formatos de timeLayouts
+	TimeZone   string `json:"time_zone,omitempty"`   // para fechas sin zona; vacío usa la de la empresa
+	Unit       string `json:"unit,omitempty"`        // C o F; vacío es C
+	Delimiter  string `json:"delimiter,omitempty"`   // ",", ";" o "\t"; vacío lo detecta del encabezado
+}
+
+// defaultColumns son los nombres de columna que se reconocen sin mapeo explícito
+var defaultColumns = map[string][]string{
+	"timestamp":   {"timestamp", "fecha", "fecha_hora", "date", "datetime", "time", "hora"},
+	"temperature": {"temperature", "temperatura", "temp", "valor", "value"},
+	"camera_id":   {"camera_id", "camara", "cámara", "camera", "camara_id"},
+	"zone_id":     {"zone_id", "zona", "zone", "zona_id"},
+}
+
+// timeLayouts son los formatos de fecha que se prueban sin time_format. Las fechas
+// con barras se leen como día/mes/año; para mes/día use time_format.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02-01-2006 15:04:05",
+	"02-01-2006 15:04",
+}
+
+// columns son las posiciones de cada campo en la fila; -1 si el valor es fijo
+type columns struct {
+	timestamp, temperature, camera, zone int
+}
+
+// parser convierte filas del CSV en lecturas según el mapeo
+type parser struct {
+	cols     columns
+	mapping  Mapping
+	location *time.Location
+	unit     export.Unit
+}
+
+// newParser valida el mapeo contra el encabezado del archivo. defaultZone es la
+// zona horaria a usar si el mapeo no trae una.
+func newParser(m Mapping, header []string, defaultZone string) (*parser, error) {
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, dup := index[name]; !dup {
+			index[name] = i
+		}
+	}
+	find := func(field, explicit string, required bool) (int, error) {
+		if explicit != "" {
+			if i, ok := index[strings.ToLower(strings.TrimSpace(explicit))]; ok {
+				return i, nil
+			}
+			return 0, fmt.Errorf("la columna %q de %s no está en el encabezado", explicit, field)
+		}
+		for _, name := range defaultColumns[field] {
+			if i, ok := index[name]; ok {
+				return i, nil
+			}
+		}
+		if required {
+			return 0, fmt.Errorf("no se encontró la columna de %s; indíquela en el mapeo", field)
+		}
+		return -1, nil
+	}
+
+	p := &parser{mapping: m, unit: export.Celsius}
+	var err error
+	if p.cols.timestamp, err = find("timestamp", m.Timestamp, true); err != nil {
+		return nil, err
+	}
+	if p.cols.temperature, err = find("temperature", m.Temperature, true); err != nil {
+		return nil, err
+	}
+	if p.cols.camera, err = find("camera_id", m.CameraID, m.Camera == nil); err != nil {
+		return nil, err
+	}
+	if p.cols.zone, err = find("zone_id", m.ZoneID, m.Zone == nil); err != nil {
+		return nil, err
+	}
+	if m.Camera != nil && m.CameraID == "" {
+		p.cols.camera = -1
+	}
+	if m.Zone != nil && m.ZoneID == "" {
+		p.cols.zone = -1
+	}
+
+	zone := m.TimeZone
+	if zone == "" {
+		zone = defaultZone
+	}
+	if p.location, err = export.LoadLocation(zone); err != nil {
+		return nil, err
+	}
+	if m.Unit != "" {
+		if p.unit, err = export.ParseUnit(m.Unit); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// reading es una fila ya interpretada, antes de validarla con ingest.Validate
+type reading struct {
+	camera, zone int
+	temperature  float64
+	timestamp    time.Time
+}
+
+func (p *parser) parse(record []string) (reading, error) {
+	var r reading
+	field := func(i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var err error
+	if r.timestamp, err = p.parseTime(field(p.cols.timestamp)); err != nil {
+		return r, err
+	}
+	s := field(p.cols.temperature)
+	if s == "" {
+		return r, errors.New("temperatura vacía")
+	}
+	// Coma decimal (exportaciones en español con ";" como separador)
+	if strings.Contains(s, ",") && !strings.Contains(s, ".") {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	temp, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return r, fmt.Errorf("temperatura inválida %q", field(p.cols.temperature))
+	}
+	r.temperature = p.unit.ToCelsius(temp)
+
+	if r.camera, err = p.parseInt(p.cols.camera, p.mapping.Camera, field, "cámara"); err != nil {
+		return r, err
+	}
+	if r.zone, err = p.parseInt(p.cols.zone, p.mapping.Zone, field, "zona"); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func (p *parser) parseInt(col int, fixed *int, field func(int) string, name string) (int, error) {
+	if col < 0 {
+		return *fixed, nil
+	}
+	s := field(col)
+	if s == "" && fixed != nil {
+		return *fixed, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s inválida %q", name, s)
+	}
+	return n, nil
+}
+
+func (p *parser) parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, errors.New("fecha vacía")
+	}
+	if p.mapping.TimeFormat != "" {
+		t, err := time.ParseInLocation(p.mapping.TimeFormat, s, p.location)
+		if err != nil {
+			return t, fmt.Errorf("fecha inválida %q para el formato %q", s, p.mapping.TimeFormat)
+		}
+		return t, nil
+	}
+	// Segundos Unix
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, p.location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("fecha inválida %q; indique time_format en el mapeo", s)
+}
+
+// detectDelimiter elige el separador más frecuente del encabezado
+func detectDelimiter(header string) rune {
+	best, count := ',', strings.Count(header, ",")
+	for _, d := range []rune{';', '\t'} {
+		if n := strings.Count(header, string(d)); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+// delimiter retorna el separador del mapeo, o 0 para detectarlo
+func (m Mapping) delimiter() (rune, error) {
+	switch m.Delimiter {
+	case "":
+		return 0, nil
+	case ",", ";", "\t", "|":
+		return rune(m.Delimiter[0]), nil
+	case "\\t", "tab":
+		return '\t', nil
+	}
+	return 0, fmt.Errorf("delimitador inválido %q; use \",\", \";\", \"|\" o \"\\t\"", m.Delimiter)
+}
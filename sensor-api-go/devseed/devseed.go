@@ -0,0 +1,137 @@
+// devseed/devseed.go
+
+// Package devseed carga datos de demostración para el modo --dev: dos empresas con
+// usuarios de cada rol, cámaras con zonas, un día de lecturas y una alerta de zona.
+// Es idempotente: si la empresa de demostración ya existe no hace nada.
+package devseed
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"sensor-api-go/models"
+	"sensor-api-go/rbac"
+	"sensor-api-go/utils"
+	"sensor-api-go/zones"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Password es la contraseña de todos los usuarios de demostración
+const Password = "Demo2024clave"
+
+// Parámetros de las lecturas generadas
+const (
+	History  = 24 * time.Hour
+	Interval = 5 * time.Minute
+)
+
+// Company es una empresa de demostración con sus usuarios y cámaras
+type Company struct {
+	ID      uuid.UUID
+	Name    string
+	Domain  string // los usuarios son admin@, operator@ y viewer@ este dominio
+	Cameras int
+	Zones   int     // zonas por cámara
+	Base    float64 // temperatura típica de las zonas, dentro del rango válido del dashboard
+}
+
+// Companies son las empresas que crea Seed. Los IDs son fijos para que el modo
+// --dev se pueda reiniciar sin cambiar las URLs ni los tokens de prueba.
+var Companies = []Company{
+	{ID: uuid.MustParse("00000000-0000-4000-8000-00000000de01"), Name: "Planta Demo", Domain: "demo.local", Cameras: 2, Zones: 3, Base: 24},
+	{ID: uuid.MustParse("00000000-0000-4000-8000-00000000de02"), Name: "Bodega Demo", Domain: "bodega.demo.local", Cameras: 1, Zones: 2, Base: 31},
+}
+
+// Seed crea los datos de demostración si no existen. now es el final del
+// historial de lecturas, para que las zonas aparezcan activas.
+func Seed(db *gorm.DB, now time.Time) error {
+	for _, company := range Companies {
+		var existing int64
+		if err := db.Model(&models.Company{}).Where("id = ?", company.ID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			log.Printf("[DEV] La empresa %q ya existe, no se cargan datos", company.Name)
+			continue
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error { return seedCompany(tx, company, now.UTC()) }); err != nil {
+			return fmt.Errorf("empresa %q: %w", company.Name, err)
+		}
+		log.Printf("[DEV] Empresa %q (%s) creada; usuarios admin@%s, operator@%s y viewer@%s con contraseña %s",
+			company.Name, company.ID, company.Domain, company.Domain, company.Domain, Password)
+	}
+	return nil
+}
+
+func seedCompany(tx *gorm.DB, company Company, now time.Time) error {
+	if err := tx.Create(&models.Company{ID: company.ID, Name: company.Name}).Error; err != nil {
+		return err
+	}
+	for _, role := range []string{rbac.RoleAdmin, rbac.RoleOperator, rbac.RoleViewer} {
+		email := role + "@" + company.Domain
+		hash, err := utils.HashPassword(Password, email)
+		if err != nil {
+			return err
+		}
+		user := models.User{ID: uuid.New(), CompanyID: company.ID, Name: "Demo " + role, Email: email, Password: hash, Role: role, Status: "Active"}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+	}
+
+	var readings []models.CameraReading
+	for cam := 1; cam <= company.Cameras; cam++ {
+		for idx := 1; idx <= company.Zones; idx++ {
+			zone, err := zones.Ensure(tx, company.ID, cam, idx)
+			if err != nil {
+				return err
+			}
+			zone.Name = fmt.Sprintf("Cámara %d - Zona %d", cam, idx)
+			if err := tx.Model(&zone).Update("name", zone.Name).Error; err != nil {
+				return err
+			}
+			for at := now.Add(-History); !at.After(now); at = at.Add(Interval) {
+				readings = append(readings, models.CameraReading{
+					CompanyID:   company.ID,
+					CameraID:    cam,
+					ZoneID:      idx,
+					Temperature: temperature(company.Base, cam, idx, at),
+					Timestamp:   at,
+				})
+			}
+		}
+	}
+	if err := tx.CreateInBatches(readings, 500).Error; err != nil {
+		return err
+	}
+
+	// Una alerta sobre la primera zona, con el umbral cerca de la temperatura típica
+	zone, err := zones.Lookup(tx, company.ID, 1, 1)
+	if err != nil {
+		return err
+	}
+	alert := models.ZoneAlert{
+		ID:          uuid.New(),
+		CompanyID:   company.ID,
+		ZoneID:      zone.ID,
+		UpperThresh: company.Base + 3,
+		LowerThresh: company.Base - 3,
+		Hysteresis:  0.5,
+		Recipient:   "admin@" + company.Domain,
+		State:       models.AlertStateOK,
+	}
+	return tx.Create(&alert).Error
+}
+
+// temperature es una curva diaria suave, desfasada en cada zona, con una variación
+// corta. Es determinística: la misma hora da la misma lectura.
+func temperature(base float64, cam, zone int, at time.Time) float64 {
+	hours := float64(at.Unix()) / 3600
+	phase := float64(cam*7 + zone*3)
+	t := base + 1.5*math.Sin(2*math.Pi*(hours+phase)/24) + 0.3*math.Sin(hours*5+phase)
+	return math.Round(t*100) / 100
+}
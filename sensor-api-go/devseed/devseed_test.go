@@ -0,0 +1,54 @@
+package devseed
+
+import (
+	"testing"
+	"time"
+
+	"sensor-api-go/config"
+	"sensor-api-go/migrations"
+	"sensor-api-go/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSeed(t *testing.T) {
+	// Mismo camino que el modo --dev: SQLite en memoria y todas las migraciones
+	db := config.SetupDB(&config.Config{DBDriver: config.DriverSQLite, DBPath: ":memory:"})
+	if _, err := migrations.Up(db, migrations.All); err != nil {
+		t.Fatalf("Las migraciones deben correr en SQLite: %v", err)
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ { // idempotente
+		if err := Seed(db, now); err != nil {
+			t.Fatalf("Seed: %v", err)
+		}
+	}
+
+	var companies, users, zones, alerts, readings int64
+	db.Model(&models.Company{}).Count(&companies)
+	db.Model(&models.User{}).Count(&users)
+	db.Model(&models.Zone{}).Count(&zones)
+	db.Model(&models.ZoneAlert{}).Count(&alerts)
+	db.Model(&models.CameraReading{}).Count(&readings)
+	perZone := int64(History/Interval) + 1
+	if companies != 2 || users != 6 || zones != 8 || alerts != 2 || readings != 8*perZone {
+		t.Errorf("Datos inesperados: %d empresas, %d usuarios, %d zonas, %d alertas, %d lecturas",
+			companies, users, zones, alerts, readings)
+	}
+
+	var admin models.User
+	if err := db.Where("email = ?", "admin@demo.local").First(&admin).Error; err != nil {
+		t.Fatalf("Falta el admin de demostración: %v", err)
+	}
+	if admin.CompanyID != Companies[0].ID || bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(Password)) != nil {
+		t.Errorf("El admin debe pertenecer a la primera empresa y usar la contraseña de demostración")
+	}
+
+	// Las consultas por rango comparan bien las fechas guardadas en SQLite
+	var last models.CameraReading
+	db.Where("company_id = ? AND timestamp > ?", Companies[0].ID, now.Add(-Interval)).Order("timestamp DESC").First(&last)
+	if !last.Timestamp.Equal(now) {
+		t.Errorf("La última lectura debe ser %v, fue %v", now, last.Timestamp)
+	}
+}
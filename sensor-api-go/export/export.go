@@ -0,0 +1,182 @@
+// export/export.go
+
+// Package export escribe el historial de lecturas en CSV, Excel (xlsx) o Parquet.
+// Las filas se escriben de a una a medida que llegan de la base, así una exportación
+// de meses no carga el rango completo en memoria. Las fechas salen en la zona
+// horaria de la empresa y las temperaturas en su unidad.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	_ "time/tzdata" // zonas horarias embebidas: la imagen del servidor puede no traerlas
+
+	"sensor-api-go/aggregate"
+)
+
+// Format es el formato del archivo exportado
+type Format string
+
+const (
+	CSV     Format = "csv"
+	XLSX    Format = "xlsx"
+	Parquet Format = "parquet"
+)
+
+// Unit es la unidad de temperatura. Las lecturas se guardan en Celsius.
+type Unit string
+
+const (
+	Celsius    Unit = "C"
+	Fahrenheit Unit = "F"
+)
+
+// MaxXLSXRows es el máximo de filas de datos de una hoja de Excel (sin el encabezado)
+const MaxXLSXRows = 1048575
+
+var (
+	ErrInvalidFormat   = errors.New("formato inválido; use csv, xlsx o parquet")
+	ErrInvalidUnit     = errors.New("unidad inválida; use C o F")
+	ErrInvalidTimeZone = errors.New("zona horaria inválida; use un nombre IANA como America/Santiago")
+)
+
+// ParseFormat interpreta el formato; vacío es CSV
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return CSV, nil
+	case CSV, XLSX, Parquet:
+		return f, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+}
+
+// ContentType es el tipo MIME del formato
+func (f Format) ContentType() string {
+	switch f {
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ParseUnit interpreta la unidad: C/F o celsius/fahrenheit, sin distinguir mayúsculas
+func ParseUnit(s string) (Unit, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "c", "celsius":
+		return Celsius, nil
+	case "f", "fahrenheit":
+		return Fahrenheit, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidUnit, s)
+}
+
+// Convert pasa una temperatura en Celsius a la unidad
+func (u Unit) Convert(celsius float64) float64 {
+	if u == Fahrenheit {
+		return celsius*9/5 + 32
+	}
+	return celsius
+}
+
+// ToCelsius es la inversa de Convert, para lecturas importadas en la unidad
+func (u Unit) ToCelsius(v float64) float64 {
+	if u == Fahrenheit {
+		return (v - 32) * 5 / 9
+	}
+	return v
+}
+
+// Symbol es la unidad tal como se muestra: °C o °F
+func (u Unit) Symbol() string {
+	return "°" + string(u)
+}
+
+// LoadLocation carga una zona horaria IANA; vacía es UTC. Rechaza "Local", que
+// depende de la configuración del servidor.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTimeZone, name)
+	}
+	return loc, nil
+}
+
+// Columns describe el contenido de la exportación
+type Columns struct {
+	Funcs    []aggregate.Func // nil: lecturas sin agregar, con una sola columna de temperatura
+	Unit     Unit
+	Location *time.Location
+}
+
+// Aggregated indica si las filas son buckets agregados
+func (c Columns) Aggregated() bool {
+	return len(c.Funcs) > 0
+}
+
+// Header son los nombres de las columnas, iguales en los tres formatos
+func (c Columns) Header() []string {
+	header := []string{"fecha", "camara", "zona", "nombre_zona"}
+	if !c.Aggregated() {
+		header = append(header, "temperatura")
+	} else {
+		header = append(header, "lecturas")
+		for _, fn := range c.Funcs {
+			header = append(header, "temperatura_"+string(fn))
+		}
+	}
+	return append(header, "unidad")
+}
+
+// Row es una lectura o un bucket. Values está en Celsius, en el orden de
+// Columns.Funcs (una sola temperatura si no hay agregación); Count es la cantidad
+// de lecturas del bucket.
+type Row struct {
+	Time     time.Time
+	CameraID int
+	ZoneID   int
+	ZoneName string
+	Count    int
+	Values   []float64
+}
+
+// Writer escribe las filas en el formato elegido. Close completa el archivo; sin
+// Close, XLSX y Parquet quedan inválidos.
+type Writer interface {
+	Write(Row) error
+	Close() error
+}
+
+// NewWriter crea el Writer del formato sobre w
+func NewWriter(w io.Writer, f Format, cols Columns) (Writer, error) {
+	if cols.Location == nil {
+		cols.Location = time.UTC
+	}
+	if cols.Unit == "" {
+		cols.Unit = Celsius
+	}
+	switch f {
+	case CSV:
+		return newCSVWriter(w, cols)
+	case XLSX:
+		return newXLSXWriter(w, cols)
+	case Parquet:
+		return newParquetWriter(w, cols)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, f)
+}
+
+// wallClock retorna la hora local de t en loc como si fuera UTC. Excel no guarda
+// zona horaria: la celda debe mostrar la hora local de la empresa.
+func wallClock(t time.Time, loc *time.Location) time.Time {
+	l := t.In(loc)
+	return time.Date(l.Year(), l.Month(), l.Day(), l.Hour(), l.Minute(), l.Second(), l.Nanosecond(), time.UTC)
+}
@@ -0,0 +1,141 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sensor-api-go/aggregate"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestParse(t *testing.T) {
+	if f, err := ParseFormat(""); err != nil || f != CSV {
+		t.Errorf("Sin formato se usa CSV: %q, %v", f, err)
+	}
+	if f, err := ParseFormat("XLSX"); err != nil || f != XLSX {
+		t.Errorf("ParseFormat(XLSX): %q, %v", f, err)
+	}
+	if _, err := ParseFormat("pdf"); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("pdf debe ser inválido: %v", err)
+	}
+	if u, err := ParseUnit("fahrenheit"); err != nil || u != Fahrenheit || u.Convert(100) != 212 || u.Symbol() != "°F" {
+		t.Errorf("ParseUnit(fahrenheit): %q, %v", u, err)
+	}
+	if _, err := ParseUnit("K"); !errors.Is(err, ErrInvalidUnit) {
+		t.Errorf("K debe ser inválida: %v", err)
+	}
+	for _, bad := range []string{"Local", "Marte/Olympus"} {
+		if _, err := LoadLocation(bad); !errors.Is(err, ErrInvalidTimeZone) {
+			t.Errorf("%q debe ser inválida: %v", bad, err)
+		}
+	}
+}
+
+func sampleColumns(t *testing.T) (Columns, []Row) {
+	loc, err := LoadLocation("America/Santiago")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	at := time.Date(2024, 1, 15, 13, 0, 0, 0, time.UTC) // 10:00 en Santiago (verano, UTC-3)
+	cols := Columns{Funcs: []aggregate.Func{aggregate.Avg, aggregate.Max}, Unit: Fahrenheit, Location: loc}
+	rows := []Row{
+		{Time: at, CameraID: 1, ZoneID: 2, ZoneName: "Cámara 1 - Zona 2", Count: 3, Values: []float64{20, 30}},
+		{Time: at.Add(time.Hour), CameraID: 1, ZoneID: 2, ZoneName: "Cámara 1 - Zona 2", Count: 1, Values: []float64{0, 0}},
+	}
+	return cols, rows
+}
+
+func write(t *testing.T, f Format, cols Columns, rows []Row) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, f, cols)
+	if err != nil {
+		t.Fatalf("NewWriter(%s): %v", f, err)
+	}
+	for _, r := range rows {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("Write(%s): %v", f, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close(%s): %v", f, err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	cols, rows := sampleColumns(t)
+	got := string(write(t, CSV, cols, rows))
+	want := "fecha,camara,zona,nombre_zona,lecturas,temperatura_avg,temperatura_max,unidad\n" +
+		"2024-01-15T10:00:00-03:00,1,2,Cámara 1 - Zona 2,3,68,86,°F\n" +
+		"2024-01-15T11:00:00-03:00,1,2,Cámara 1 - Zona 2,1,32,32,°F\n"
+	if got != want {
+		t.Errorf("CSV inesperado:\n%s\nesperado:\n%s", got, want)
+	}
+}
+
+func TestCSV_EscapesFormulas(t *testing.T) {
+	cols, rows := sampleColumns(t)
+	rows = rows[:1]
+	rows[0].ZoneName = "=HYPERLINK(\"http://x\")"
+	got := string(write(t, CSV, cols, rows))
+	if !strings.Contains(got, `,"'=HYPERLINK(""http://x"")",`) {
+		t.Errorf("El nombre de la zona debe quedar como texto: %s", got)
+	}
+	for in, want := range map[string]string{"+1": "'+1", "-x": "'-x", "@SUM(A1)": "'@SUM(A1)", "\tx": "'\tx", "Zona 1": "Zona 1", "": ""} {
+		if got := CSVText(in); got != want {
+			t.Errorf("CSVText(%q) = %q, esperado %q", in, got, want)
+		}
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	cols, rows := sampleColumns(t)
+	f, err := excelize.OpenReader(bytes.NewReader(write(t, XLSX, cols, rows)))
+	if err != nil {
+		t.Fatalf("El xlsx no se puede abrir: %v", err)
+	}
+	defer f.Close()
+	sheet, err := f.GetRows(xlsxSheet)
+	if err != nil || len(sheet) != 3 {
+		t.Fatalf("Esperadas 3 filas, fueron %d (%v)", len(sheet), err)
+	}
+	if sheet[0][0] != "fecha (America/Santiago)" || sheet[1][0] != "2024-01-15 10:00:00" || sheet[1][5] != "68" || sheet[1][7] != "°F" {
+		t.Errorf("Filas inesperadas: %v", sheet)
+	}
+}
+
+func TestParquet(t *testing.T) {
+	cols, rows := sampleColumns(t)
+	data := write(t, Parquet, cols, rows)
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("El parquet no se puede abrir: %v", err)
+	}
+	if file.NumRows() != 2 {
+		t.Errorf("Esperadas 2 filas, fueron %d", file.NumRows())
+	}
+	if tz, _ := file.Lookup("time_zone"); tz != "America/Santiago" {
+		t.Errorf("Metadato time_zone: %q", tz)
+	}
+	type record struct {
+		Fecha  int64   `parquet:"fecha"`
+		Zona   int32   `parquet:"zona"`
+		Nombre string  `parquet:"nombre_zona"`
+		Avg    float64 `parquet:"temperatura_avg"`
+		Unidad string  `parquet:"unidad"`
+	}
+	records, err := parquet.Read[record](bytes.NewReader(data), int64(len(data)))
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Lectura: %d filas, error %v", len(records), err)
+	}
+	r := records[0]
+	if r.Fecha != rows[0].Time.UnixMilli() || r.Zona != 2 || !strings.HasPrefix(r.Nombre, "Cámara") || r.Avg != 68 || r.Unidad != "°F" {
+		t.Errorf("Fila inesperada: %+v", r)
+	}
+}
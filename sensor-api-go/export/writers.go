@@ -0,0 +1,216 @@
+// export/writers.go
+
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/xuri/excelize/v2"
+)
+
+// --- CSV ---
+
+// CSVText protege un texto libre para CSV: si empieza con =, +, -, @, tab o retorno
+// de carro, Excel lo interpretaría como fórmula, así que se antepone un apóstrofo
+func CSVText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+type csvWriter struct {
+	w    *csv.Writer
+	cols Columns
+}
+
+func newCSVWriter(w io.Writer, cols Columns) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), cols: cols}
+	return cw, cw.w.Write(cols.Header())
+}
+
+func (cw *csvWriter) Write(r Row) error {
+	record := []string{
+		r.Time.In(cw.cols.Location).Format(time.RFC3339),
+		strconv.Itoa(r.CameraID), strconv.Itoa(r.ZoneID), CSVText(r.ZoneName),
+	}
+	if cw.cols.Aggregated() {
+		record = append(record, strconv.Itoa(r.Count))
+	}
+	for _, v := range r.Values {
+		record = append(record, strconv.FormatFloat(cw.cols.Unit.Convert(v), 'f', -1, 64))
+	}
+	return cw.w.Write(append(record, cw.cols.Unit.Symbol()))
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// --- XLSX ---
+
+// xlsxWriter usa el StreamWriter de excelize, que pasa las filas a un archivo
+// temporal en vez de guardarlas en memoria
+type xlsxWriter struct {
+	out       io.Writer
+	file      *excelize.File
+	sheet     *excelize.StreamWriter
+	cols      Columns
+	row       int
+	dateStyle int
+}
+
+const xlsxSheet = "Lecturas"
+
+func newXLSXWriter(w io.Writer, cols Columns) (*xlsxWriter, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName("Sheet1", xlsxSheet); err != nil {
+		return nil, err
+	}
+	format := "yyyy-mm-dd hh:mm:ss"
+	dateStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &format})
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := f.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		return nil, err
+	}
+	sheet.SetColWidth(1, 1, 20)
+	sheet.SetColWidth(4, 4, 24)
+
+	header := cols.Header()
+	cells := make([]interface{}, len(header))
+	for i, h := range header {
+		cells[i] = h
+	}
+	// Excel no guarda la zona horaria de las fechas, así que va en el encabezado
+	cells[0] = "fecha (" + cols.Location.String() + ")"
+	if err := sheet.SetRow("A1", cells); err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{out: w, file: f, sheet: sheet, cols: cols, row: 1, dateStyle: dateStyle}, nil
+}
+
+func (xw *xlsxWriter) Write(r Row) error {
+	xw.row++
+	cells := []interface{}{
+		excelize.Cell{StyleID: xw.dateStyle, Value: wallClock(r.Time, xw.cols.Location)},
+		r.CameraID, r.ZoneID, r.ZoneName,
+	}
+	if xw.cols.Aggregated() {
+		cells = append(cells, r.Count)
+	}
+	for _, v := range r.Values {
+		cells = append(cells, xw.cols.Unit.Convert(v))
+	}
+	cells = append(cells, xw.cols.Unit.Symbol())
+	cell, err := excelize.CoordinatesToCellName(1, xw.row)
+	if err != nil {
+		return err
+	}
+	return xw.sheet.SetRow(cell, cells)
+}
+
+func (xw *xlsxWriter) Close() error {
+	defer xw.file.Close()
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.file.Write(xw.out)
+}
+
+// --- Parquet ---
+
+// parquetWriter arma el esquema según las columnas. La fecha es un TIMESTAMP en
+// UTC (como lo esperan las herramientas de análisis); la zona horaria y la unidad
+// de la empresa quedan en los metadatos del archivo.
+type parquetWriter struct {
+	w      *parquet.Writer
+	cols   Columns
+	index  map[string]int // columna -> posición en el esquema
+	header []string
+	buf    []parquet.Row
+}
+
+// parquetRowGroup son las filas por row group: acota la memoria del writer
+const parquetRowGroup = 50000
+
+func newParquetWriter(w io.Writer, cols Columns) (*parquetWriter, error) {
+	header := cols.Header()
+	group := parquet.Group{}
+	for _, name := range header {
+		switch name {
+		case "fecha":
+			group[name] = parquet.Timestamp(parquet.Millisecond)
+		case "camara", "zona":
+			group[name] = parquet.Int(32)
+		case "lecturas":
+			group[name] = parquet.Int(64)
+		case "nombre_zona", "unidad":
+			group[name] = parquet.String()
+		default:
+			group[name] = parquet.Leaf(parquet.DoubleType)
+		}
+	}
+	schema := parquet.NewSchema("lecturas", group)
+	index := make(map[string]int, len(header))
+	for _, name := range header {
+		leaf, _ := schema.Lookup(name)
+		index[name] = leaf.ColumnIndex
+	}
+	pw := parquet.NewWriter(w, schema,
+		parquet.Compression(&parquet.Snappy),
+		parquet.MaxRowsPerRowGroup(parquetRowGroup),
+		parquet.KeyValueMetadata("time_zone", cols.Location.String()),
+		parquet.KeyValueMetadata("temperature_unit", string(cols.Unit)),
+	)
+	return &parquetWriter{w: pw, cols: cols, index: index, header: header}, nil
+}
+
+func (pw *parquetWriter) Write(r Row) error {
+	row := make(parquet.Row, len(pw.header))
+	set := func(name string, v parquet.Value) {
+		i := pw.index[name]
+		row[i] = v.Level(0, 0, i)
+	}
+	set("fecha", parquet.Int64Value(r.Time.UnixMilli()))
+	set("camara", parquet.Int32Value(int32(r.CameraID)))
+	set("zona", parquet.Int32Value(int32(r.ZoneID)))
+	set("nombre_zona", parquet.ByteArrayValue([]byte(r.ZoneName)))
+	if pw.cols.Aggregated() {
+		set("lecturas", parquet.Int64Value(int64(r.Count)))
+		for i, fn := range pw.cols.Funcs {
+			set("temperatura_"+string(fn), parquet.DoubleValue(pw.cols.Unit.Convert(r.Values[i])))
+		}
+	} else {
+		set("temperatura", parquet.DoubleValue(pw.cols.Unit.Convert(r.Values[0])))
+	}
+	set("unidad", parquet.ByteArrayValue([]byte(pw.cols.Unit.Symbol())))
+
+	// Las filas se escriben en lotes chicos: WriteRows por fila es lento
+	pw.buf = append(pw.buf, row)
+	if len(pw.buf) >= 1000 {
+		return pw.flush()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) flush() error {
+	_, err := pw.w.WriteRows(pw.buf)
+	pw.buf = pw.buf[:0]
+	return err
+}
+
+func (pw *parquetWriter) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+	return pw.w.Close()
+}
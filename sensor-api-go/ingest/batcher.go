@@ -0,0 +1,131 @@
+// ingest/batcher.go
+
+package ingest
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Item es una lectura pendiente junto con la función que la confirma a su origen
+// (por ejemplo, el ACK QoS 1 de un mensaje MQTT). Ack puede ser nil.
+type Item struct {
+	Input ReadingInput
+	Ack   func()
+}
+
+// Batcher acumula lecturas y las guarda por lotes, cuando se llena el lote
+// o cuando pasa el intervalo de flush. Las lecturas solo se confirman (Ack)
+// después de que la transacción se guardó; si la base falla, el lote se
+// reintenta y se deja de consumir la cola, lo que aplica contrapresión al origen.
+type Batcher struct {
+	db       *gorm.DB
+	size     int
+	interval time.Duration
+	items    chan Item
+	hooks    []Hook
+}
+
+func NewBatcher(db *gorm.DB, size int, interval time.Duration, hooks ...Hook) *Batcher {
+	if size <= 0 {
+		size = 200
+	}
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	return &Batcher{
+		db:       db,
+		size:     size,
+		interval: interval,
+		items:    make(chan Item, size*2),
+		hooks:    hooks,
+	}
+}
+
+// Add encola una lectura; bloquea si la cola está llena
+func (b *Batcher) Add(ctx context.Context, item Item) error {
+	select {
+	case b.items <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run procesa la cola hasta que el contexto se cancela, guardando lo pendiente al salir
+func (b *Batcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	var pending []Item
+	for {
+		if len(pending) >= b.size {
+			if !b.flush(pending) {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				continue
+			}
+			pending = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			// Vaciar lo que ya estaba en la cola antes de salir
+		drain:
+			for {
+				select {
+				case it := <-b.items:
+					pending = append(pending, it)
+				default:
+					break drain
+				}
+			}
+			b.flush(pending)
+			return
+		case it := <-b.items:
+			pending = append(pending, it)
+		case <-ticker.C:
+			if b.flush(pending) {
+				pending = nil
+			}
+		}
+	}
+}
+
+// flush guarda el lote y confirma cada item. Retorna false si hay que reintentar.
+func (b *Batcher) flush(items []Item) bool {
+	if len(items) == 0 {
+		return true
+	}
+	inputs := make([]ReadingInput, len(items))
+	for i, it := range items {
+		inputs[i] = it.Input
+	}
+
+	results, err := Save(b.db, inputs, b.hooks...)
+	if err != nil {
+		log.Printf("[INGEST] Error guardando lote de %d lecturas, se reintentará: %v", len(items), err)
+		return false
+	}
+
+	accepted, rejected := Count(results)
+	for _, r := range results {
+		if r.Status == StatusRejected {
+			log.Printf("[INGEST] Lectura rechazada: %s", r.Error)
+		}
+	}
+	// Las rechazadas también se confirman: reenviarlas no las haría válidas
+	for _, it := range items {
+		if it.Ack != nil {
+			it.Ack()
+		}
+	}
+	log.Printf("[INGEST] Lote guardado: %d aceptadas, %d rechazadas", accepted, rejected)
+	return true
+}
@@ -0,0 +1,174 @@
+// ingest/readings.go
+
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"sensor-api-go/models"
+	"sensor-api-go/zones"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Límites de validación para lecturas entrantes
+const (
+	MaxBatchSize   = 1000
+	MinTemperature = -273.15 // cero absoluto
+	MaxTemperature = 3000.0
+	MaxClockSkew   = 5 * time.Minute // tolerancia para relojes adelantados en los gateways
+)
+
+const (
+	StatusAccepted = "accepted"
+	StatusRejected = "rejected"
+)
+
+// ReadingInput es una lectura tal como la envía un gateway (HTTP, MQTT, CSV...).
+// Los punteros permiten distinguir un campo ausente de un valor cero.
+// CompanyID nunca viene del cliente: lo asigna quien recibe la lectura según
+// la credencial (API key) o el topic MQTT.
+type ReadingInput struct {
+	CompanyID   uuid.UUID  `json:"-"`
+	CameraID    *int       `json:"camera_id"`
+	ZoneID      *int       `json:"zone_id"`
+	Temperature *float64   `json:"temperature"`
+	Timestamp   *time.Time `json:"timestamp"`
+}
+
+// Hook permite al llamador aplicar reglas extra a cada lectura ya validada
+// (por ejemplo, restringir las cámaras que puede reportar una API key)
+type Hook func(r *models.CameraReading) error
+
+// Result informa qué pasó con cada lectura del lote, en el mismo orden de entrada
+type Result struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     uint   `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Validate revisa una lectura y la convierte al modelo persistible
+func Validate(in ReadingInput, now time.Time) (models.CameraReading, error) {
+	if in.CompanyID == uuid.Nil {
+		return models.CameraReading{}, errors.New("lectura sin empresa asociada")
+	}
+	if in.CameraID == nil {
+		return models.CameraReading{}, errors.New("camera_id es obligatorio")
+	}
+	if *in.CameraID <= 0 {
+		return models.CameraReading{}, errors.New("camera_id debe ser positivo")
+	}
+	if in.ZoneID == nil {
+		return models.CameraReading{}, errors.New("zone_id es obligatorio")
+	}
+	if *in.ZoneID < 0 {
+		return models.CameraReading{}, errors.New("zone_id no puede ser negativo")
+	}
+	if in.Temperature == nil {
+		return models.CameraReading{}, errors.New("temperature es obligatorio")
+	}
+	temp := *in.Temperature
+	if math.IsNaN(temp) || math.IsInf(temp, 0) {
+		return models.CameraReading{}, errors.New("temperature no es un número válido")
+	}
+	if temp < MinTemperature || temp > MaxTemperature {
+		return models.CameraReading{}, fmt.Errorf("temperature fuera de rango (%.2f a %.2f)", MinTemperature, MaxTemperature)
+	}
+	if in.Timestamp == nil || in.Timestamp.IsZero() {
+		return models.CameraReading{}, errors.New("timestamp es obligatorio (RFC3339)")
+	}
+	if in.Timestamp.After(now.Add(MaxClockSkew)) {
+		return models.CameraReading{}, errors.New("timestamp está en el futuro")
+	}
+
+	return models.CameraReading{
+		CompanyID:   in.CompanyID,
+		CameraID:    *in.CameraID,
+		ZoneID:      *in.ZoneID,
+		Temperature: temp,
+		Timestamp:   in.Timestamp.UTC(),
+	}, nil
+}
+
+// Save valida el lote y guarda todas las lecturas válidas en una sola transacción.
+// Las lecturas inválidas se informan como rechazadas sin abortar el resto;
+// si la transacción falla se retorna el error y no se guarda nada.
+func Save(db *gorm.DB, inputs []ReadingInput, hooks ...Hook) ([]Result, error) {
+	now := time.Now()
+	results := make([]Result, len(inputs))
+	valid := make([]models.CameraReading, 0, len(inputs))
+	positions := make([]int, 0, len(inputs))
+
+	for i, in := range inputs {
+		results[i] = Result{Index: i}
+		reading, err := Validate(in, now)
+		for _, hook := range hooks {
+			if err != nil {
+				break
+			}
+			err = hook(&reading)
+		}
+		if err != nil {
+			results[i].Status = StatusRejected
+			results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, reading)
+		positions = append(positions, i)
+	}
+
+	if len(valid) > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.CreateInBatches(&valid, 200).Error; err != nil {
+				return err
+			}
+			return registerZones(tx, valid)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for j, pos := range positions {
+		results[pos].Status = StatusAccepted
+		results[pos].ID = valid[j].ID
+	}
+	return results, nil
+}
+
+// registerZones asegura que cada (empresa, cámara, zona) del lote exista en el registro de zonas
+func registerZones(tx *gorm.DB, readings []models.CameraReading) error {
+	type zoneKey struct {
+		companyID        uuid.UUID
+		cameraID, zoneID int
+	}
+	seen := map[zoneKey]bool{}
+	for _, r := range readings {
+		key := zoneKey{r.CompanyID, r.CameraID, r.ZoneID}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, err := zones.Ensure(tx, r.CompanyID, r.CameraID, r.ZoneID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count retorna cuántas lecturas fueron aceptadas y rechazadas
+func Count(results []Result) (accepted, rejected int) {
+	for _, r := range results {
+		if r.Status == StatusAccepted {
+			accepted++
+		} else {
+			rejected++
+		}
+	}
+	return accepted, rejected
+}
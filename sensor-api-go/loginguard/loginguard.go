@@ -0,0 +1,171 @@
+// loginguard/loginguard.go
+
+// Package loginguard protege el login contra fuerza bruta. Cuenta los intentos
+// fallidos por cuenta (en models.User) y por dirección IP (en models.LoginFailure):
+// después de unos pocos fallos seguidos cada intento debe esperar un tiempo que se
+// duplica, al llegar a LockAfter la cuenta se bloquea por LockDuration y una IP con
+// demasiados fallos en IPWindow no puede seguir intentando.
+package loginguard
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// DelayAfter es la cantidad de fallos seguidos que se permiten sin demora
+	DelayAfter = 3
+	// MaxDelay es la demora máxima entre intentos antes del bloqueo
+	MaxDelay = 30 * time.Second
+	// LockAfter es la cantidad de fallos seguidos que bloquean la cuenta
+	LockAfter = 10
+	// LockDuration es cuánto dura el bloqueo de una cuenta
+	LockDuration = 15 * time.Minute
+	// IPWindow y MaxIPFailures limitan los fallos desde una misma IP, en cualquier cuenta
+	IPWindow      = 15 * time.Minute
+	MaxIPFailures = 50
+)
+
+// BlockedError indica que el intento se rechazó sin verificar las credenciales
+type BlockedError struct {
+	Locked     bool // cuenta bloqueada (si no, demora progresiva o límite por IP)
+	RetryAfter time.Duration
+}
+
+func (e *BlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("cuenta bloqueada temporalmente por intentos fallidos; intente en %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("demasiados intentos fallidos; espere %s", e.RetryAfter.Round(time.Second))
+}
+
+// delay es la espera exigida tras failures fallos seguidos
+func delay(failures int) time.Duration {
+	if failures < DelayAfter {
+		return 0
+	}
+	d := time.Second << uint(failures-DelayAfter)
+	if d > MaxDelay || d <= 0 {
+		return MaxDelay
+	}
+	return d
+}
+
+// CheckIP rechaza la IP si acumula demasiados fallos recientes
+func CheckIP(db *gorm.DB, ip string, now time.Time) error {
+	var count int64
+	since := now.Add(-IPWindow)
+	if err := db.Model(&models.LoginFailure{}).Where("ip = ? AND created_at > ?", ip, since).Count(&count).Error; err != nil {
+		return err
+	}
+	if count < MaxIPFailures {
+		return nil
+	}
+	// Se libera cuando el fallo más antiguo de la ventana sale de ella
+	var oldest models.LoginFailure
+	if err := db.Where("ip = ? AND created_at > ?", ip, since).Order("created_at").First(&oldest).Error; err != nil {
+		return err
+	}
+	return &BlockedError{RetryAfter: oldest.CreatedAt.Add(IPWindow).Sub(now)}
+}
+
+// CheckAccount rechaza el intento si la cuenta está bloqueada o aún debe esperar
+func CheckAccount(user models.User, now time.Time) error {
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return &BlockedError{Locked: true, RetryAfter: user.LockedUntil.Sub(now)}
+	}
+	if user.LastFailedLoginAt != nil {
+		if next := user.LastFailedLoginAt.Add(delay(user.FailedLogins)); now.Before(next) {
+			return &BlockedError{RetryAfter: next.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// Fail registra un intento fallido. user es nil si el email no existe en la empresa.
+// Al llegar a LockAfter fallos seguidos bloquea la cuenta y lo deja en la auditoría.
+func Fail(db *gorm.DB, companyID uuid.UUID, email, ip string, user *models.User, now time.Time) error {
+	failure := models.LoginFailure{ID: uuid.New(), CompanyID: companyID, Email: email, IP: ip, CreatedAt: now}
+	if err := db.Create(&failure).Error; err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+	// Incremento atómico: los intentos en paralelo cuentan todos
+	if err := db.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumns(map[string]interface{}{
+		"failed_logins":        gorm.Expr("failed_logins + 1"),
+		"last_failed_login_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	lockedUntil := now.Add(LockDuration)
+	res := db.Model(&models.User{}).
+		Where("id = ? AND failed_logins >= ?", user.ID, LockAfter).
+		UpdateColumns(map[string]interface{}{"locked_until": lockedUntil, "failed_logins": 0, "last_failed_login_at": nil})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil
+	}
+	log.Printf("[SECURITY] Cuenta %s bloqueada hasta %s tras %d intentos fallidos (IP %s)", user.ID, lockedUntil.Format(time.RFC3339), LockAfter, ip)
+	return audit.Record(db, audit.Entry{
+		CompanyID:  user.CompanyID,
+		Action:     audit.ActionAccountLocked,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		IP:         ip,
+		Details:    map[string]interface{}{"email": user.Email, "failed_attempts": LockAfter, "locked_until": lockedUntil},
+	})
+}
+
+// Succeed limpia los fallos seguidos de la cuenta tras un login correcto
+func Succeed(db *gorm.DB, user models.User) error {
+	if user.FailedLogins == 0 && user.LastFailedLoginAt == nil {
+		return nil
+	}
+	return db.Model(&models.User{}).Where("id = ?", user.ID).
+		UpdateColumns(map[string]interface{}{"failed_logins": 0, "last_failed_login_at": nil}).Error
+}
+
+// ErrNotLocked indica que la cuenta no tenía bloqueo ni fallos pendientes
+var ErrNotLocked = errors.New("la cuenta no está bloqueada")
+
+// Unlock quita el bloqueo y los fallos de la cuenta y lo deja en la auditoría
+func Unlock(db *gorm.DB, user models.User, actorID uuid.UUID, actorEmail, ip string, now time.Time) error {
+	locked := user.LockedUntil != nil && now.Before(*user.LockedUntil)
+	if !locked && user.FailedLogins == 0 {
+		return ErrNotLocked
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumns(map[string]interface{}{
+			"locked_until": nil, "failed_logins": 0, "last_failed_login_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Entry{
+			CompanyID:  user.CompanyID,
+			ActorID:    &actorID,
+			ActorEmail: actorEmail,
+			Action:     audit.ActionAccountUnlocked,
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+			IP:         ip,
+			Details:    map[string]interface{}{"email": user.Email, "was_locked": locked},
+		})
+	})
+}
+
+// Purge elimina los intentos fallidos que ya no cuentan para ningún límite
+func Purge(db *gorm.DB, now time.Time) error {
+	return db.Where("created_at <= ?", now.Add(-IPWindow)).Delete(&models.LoginFailure{}).Error
+}
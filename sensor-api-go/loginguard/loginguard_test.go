@@ -0,0 +1,147 @@
+package loginguard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sensor-api-go/audit"
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) (*gorm.DB, models.User) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.LoginFailure{}, &models.AuditLog{}); err != nil {
+		t.Fatalf("No se pudo migrar: %v", err)
+	}
+	user := models.User{ID: uuid.New(), CompanyID: uuid.New(), Name: "A", Email: "a@example.com", Password: "x", Role: "viewer", Status: "Active"}
+	db.Create(&user)
+	return db, user
+}
+
+func reload(t *testing.T, db *gorm.DB, user models.User) models.User {
+	t.Helper()
+	var out models.User
+	if err := db.First(&out, "id = ?", user.ID).Error; err != nil {
+		t.Fatalf("No se pudo leer el usuario: %v", err)
+	}
+	return out
+}
+
+func TestDelay(t *testing.T) {
+	for _, tc := range []struct {
+		failures int
+		want     time.Duration
+	}{{0, 0}, {2, 0}, {3, time.Second}, {4, 2 * time.Second}, {7, 16 * time.Second}, {8, MaxDelay}, {60, MaxDelay}} {
+		if got := delay(tc.failures); got != tc.want {
+			t.Errorf("delay(%d) = %s, esperado %s", tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestFail_ProgressiveDelayAndLockout(t *testing.T) {
+	db, user := newTestDB(t)
+	now := time.Now()
+
+	for i := 0; i < DelayAfter; i++ {
+		if err := CheckAccount(reload(t, db, user), now); err != nil {
+			t.Fatalf("Intento %d: no debe haber demora, fue %v", i+1, err)
+		}
+		if err := Fail(db, user.CompanyID, user.Email, "10.0.0.1", &user, now); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+	}
+	var blocked *BlockedError
+	if err := CheckAccount(reload(t, db, user), now); !errors.As(err, &blocked) || blocked.Locked || blocked.RetryAfter != time.Second {
+		t.Fatalf("Tras %d fallos se espera 1s de demora, fue %v", DelayAfter, err)
+	}
+	if err := CheckAccount(reload(t, db, user), now.Add(time.Second)); err != nil {
+		t.Errorf("Pasada la demora se puede intentar, fue %v", err)
+	}
+
+	for i := DelayAfter; i < LockAfter; i++ {
+		now = now.Add(MaxDelay)
+		if err := Fail(db, user.CompanyID, user.Email, "10.0.0.1", &user, now); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+	}
+	locked := reload(t, db, user)
+	if err := CheckAccount(locked, now.Add(time.Minute)); !errors.As(err, &blocked) || !blocked.Locked {
+		t.Fatalf("Tras %d fallos la cuenta debe quedar bloqueada, fue %v", LockAfter, err)
+	}
+	if err := CheckAccount(locked, now.Add(LockDuration)); err != nil {
+		t.Errorf("El bloqueo debe vencer, fue %v", err)
+	}
+	var logs []models.AuditLog
+	db.Where("action = ? AND target_id = ?", audit.ActionAccountLocked, user.ID.String()).Find(&logs)
+	if len(logs) != 1 || logs[0].CompanyID != user.CompanyID || logs[0].ActorID != nil {
+		t.Errorf("Se espera un registro de auditoría del bloqueo, hay %+v", logs)
+	}
+
+	admin := uuid.New()
+	if err := Unlock(db, locked, admin, "admin@example.com", "10.0.0.2", now); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := CheckAccount(reload(t, db, user), now); err != nil {
+		t.Errorf("Tras desbloquear se puede intentar, fue %v", err)
+	}
+	if err := Unlock(db, reload(t, db, user), admin, "admin@example.com", "10.0.0.2", now); !errors.Is(err, ErrNotLocked) {
+		t.Errorf("Desbloquear una cuenta sin bloqueo: esperado ErrNotLocked, fue %v", err)
+	}
+	var unlock models.AuditLog
+	db.Where("action = ?", audit.ActionAccountUnlocked).First(&unlock)
+	if unlock.ActorID == nil || *unlock.ActorID != admin {
+		t.Errorf("El desbloqueo debe registrar quién lo hizo: %+v", unlock)
+	}
+}
+
+func TestSucceed_ResetsFailures(t *testing.T) {
+	db, user := newTestDB(t)
+	now := time.Now()
+	for i := 0; i < DelayAfter+1; i++ {
+		Fail(db, user.CompanyID, user.Email, "10.0.0.1", &user, now)
+	}
+	if err := Succeed(db, reload(t, db, user)); err != nil {
+		t.Fatalf("Succeed: %v", err)
+	}
+	if u := reload(t, db, user); u.FailedLogins != 0 || u.LastFailedLoginAt != nil {
+		t.Errorf("Un login correcto debe limpiar los fallos: %d", u.FailedLogins)
+	}
+}
+
+func TestCheckIP(t *testing.T) {
+	db, user := newTestDB(t)
+	start := time.Now()
+	for i := 0; i < MaxIPFailures; i++ {
+		// Emails distintos: el límite por IP no depende de la cuenta
+		Fail(db, user.CompanyID, "nadie@example.com", "10.0.0.9", nil, start.Add(time.Duration(i)*time.Second))
+	}
+	now := start.Add(time.Minute)
+	var blocked *BlockedError
+	if err := CheckIP(db, "10.0.0.9", now); !errors.As(err, &blocked) || blocked.RetryAfter != IPWindow-time.Minute {
+		t.Fatalf("La IP debe quedar limitada hasta que venza su fallo más antiguo, fue %v", err)
+	}
+	if err := CheckIP(db, "10.0.0.10", now); err != nil {
+		t.Errorf("Otra IP no debe quedar limitada, fue %v", err)
+	}
+	if err := CheckIP(db, "10.0.0.9", start.Add(IPWindow+time.Second)); err != nil {
+		t.Errorf("Fuera de la ventana la IP puede volver a intentar, fue %v", err)
+	}
+
+	if err := Purge(db, start.Add(IPWindow+time.Hour)); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	var count int64
+	db.Model(&models.LoginFailure{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Purge debe eliminar los fallos antiguos, quedan %d", count)
+	}
+}
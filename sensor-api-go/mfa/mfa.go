@@ -0,0 +1,112 @@
+// mfa/mfa.go
+
+// Package mfa implementa el segundo factor de los logins: códigos TOTP (RFC 6238,
+// compatibles con Google Authenticator, Authy, etc.) y códigos de recuperación.
+package mfa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// Issuer es el nombre que muestran las apps de autenticación
+const Issuer = "INSTRUMINING"
+
+const (
+	period = 30 // segundos por código
+	// skew acepta el código anterior y el siguiente, por desfase de reloj del teléfono
+	skew = 1
+	// RecoveryCodeCount es la cantidad de códigos de recuperación que se entregan
+	RecoveryCodeCount = 10
+)
+
+// Enrollment es un secreto recién generado y lo necesario para registrarlo en la app
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URL    string `json:"otpauth_url"` // URI de aprovisionamiento (contenido del QR)
+	QRCode string `json:"qr_code"`     // imagen PNG del QR como data URI
+}
+
+// NewEnrollment genera un secreto TOTP para la cuenta
+func NewEnrollment(account string) (Enrollment, error) {
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: Issuer, AccountName: account, Period: period})
+	if err != nil {
+		return Enrollment{}, err
+	}
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return Enrollment{}, err
+	}
+	return Enrollment{
+		Secret: key.Secret(),
+		URL:    key.URL(),
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// Verify valida un código TOTP. Retorna el paso de tiempo del código para que el
+// llamador lo guarde: un código con paso <= lastStep ya fue usado y se rechaza.
+func Verify(secret, code string, lastStep int64, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	current := now.Unix() / period
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*period, 0), totp.ValidateOpts{
+			Period: period, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes crea códigos de un solo uso con la forma XXXXX-XXXXX.
+// Retorna los valores en claro (se muestran una vez) y sus hashes.
+func GenerateRecoveryCodes() (plain, hashes []string, err error) {
+	for i := 0; i < RecoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf)[:10]
+		code := raw[:5] + "-" + raw[5:]
+		plain = append(plain, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return plain, hashes, nil
+}
+
+// HashRecoveryCode normaliza el código (mayúsculas, sin guiones ni espacios) y
+// retorna su hash. Tienen 50 bits de entropía y el login está limitado, así que
+// SHA-256 es suficiente.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// LooksLikeRecoveryCode distingue un código de recuperación de un código TOTP
+func LooksLikeRecoveryCode(code string) bool {
+	return len(strings.NewReplacer("-", "", " ", "").Replace(code)) == 10
+}
@@ -0,0 +1,50 @@
+package mfa
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestVerify(t *testing.T) {
+	enrollment, err := NewEnrollment("ana@example.com")
+	if err != nil {
+		t.Fatalf("NewEnrollment: %v", err)
+	}
+	if !strings.HasPrefix(enrollment.URL, "otpauth://totp/INSTRUMINING:ana@example.com?") ||
+		!strings.HasPrefix(enrollment.QRCode, "data:image/png;base64,") {
+		t.Errorf("Aprovisionamiento inesperado: %s", enrollment.URL)
+	}
+
+	now := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	code, _ := totp.GenerateCode(enrollment.Secret, now)
+	step, ok := Verify(enrollment.Secret, code, 0, now.Add(25*time.Second))
+	if !ok || step != now.Unix()/period {
+		t.Fatalf("El código vigente debe ser válido (paso %d)", step)
+	}
+	// El mismo código no se puede usar dos veces
+	if _, ok := Verify(enrollment.Secret, code, step, now); ok {
+		t.Error("Un código ya usado debe rechazarse")
+	}
+	// Fuera de la ventana de tolerancia
+	if _, ok := Verify(enrollment.Secret, code, 0, now.Add(2*time.Minute)); ok {
+		t.Error("Un código vencido debe rechazarse")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	plain, hashes, err := GenerateRecoveryCodes()
+	if err != nil || len(plain) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("Esperados %d códigos: %v %v", RecoveryCodeCount, plain, err)
+	}
+	code := plain[0]
+	if !LooksLikeRecoveryCode(code) || LooksLikeRecoveryCode("123456") {
+		t.Errorf("Clasificación de código incorrecta para %q", code)
+	}
+	// Se acepta en minúsculas y sin guion
+	if HashRecoveryCode(strings.ToLower(strings.Replace(code, "-", "", 1))) != hashes[0] {
+		t.Error("El hash debe ignorar mayúsculas y guiones")
+	}
+}
@@ -1,13 +1,19 @@
 package middleware
 
 import (
+    "log"
     "net/http"
     "strings"
+    "sensor-api-go/sessions"
     "sensor-api-go/utils"
     "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
 )
 
-func JWTAuthMiddleware() gin.HandlerFunc {
+// JWTAuthMiddleware valida el access token y que su sesión (claim sid) siga activa:
+// un logout o la desactivación del usuario invalidan el token aunque no haya vencido.
+func JWTAuthMiddleware(db *gorm.DB) gin.HandlerFunc {
     return func(c *gin.Context) {
         authHeader := c.GetHeader("Authorization")
         if authHeader == "" {
@@ -29,9 +35,27 @@ func JWTAuthMiddleware() gin.HandlerFunc {
             return
         }
 
+        sessionID, err := uuid.Parse(claims.SessionID)
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token without session, please log in again"})
+            return
+        }
+        active, err := sessions.IsActive(db, sessionID)
+        if err != nil {
+            log.Printf("[ERROR] No se pudo verificar la sesión %s: %v", sessionID, err)
+            c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "No se pudo verificar la sesión"})
+            return
+        }
+        if !active {
+            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session revoked or expired"})
+            return
+        }
+
         c.Set("user_id", claims.UserID)
         c.Set("company_id", claims.CompanyID)
         c.Set("role", claims.Role)
+        c.Set("email", claims.Email)
+        c.Set("session_id", claims.SessionID)
         c.Next()
     }
 }
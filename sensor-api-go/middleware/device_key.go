@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"sensor-api-go/models"
+	"sensor-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DeviceKeyAuthMiddleware autentica a los gateways con una API key de dispositivo.
+// Acepta "X-API-Key: <key>" o "Authorization: ApiKey <key>".
+func DeviceKeyAuthMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if key == "" {
+			parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+			if len(parts) == 2 && parts[0] == "ApiKey" {
+				key = strings.TrimSpace(parts[1])
+			}
+		}
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing API key"})
+			return
+		}
+
+		var apiKey models.DeviceAPIKey
+		if err := db.Where("key_hash = ? AND revoked_at IS NULL", utils.HashAPIKey(key)).First(&apiKey).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked API key"})
+			return
+		}
+
+		// Registrar último uso sin tocar updated_at
+		now := time.Now()
+		if err := db.Model(&apiKey).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": c.ClientIP(),
+		}).Error; err != nil {
+			log.Printf("[WARN] No se pudo registrar el uso de la API key %s: %v", apiKey.ID, err)
+		}
+
+		c.Set("device_key_id", apiKey.ID.String())
+		c.Set("company_id", apiKey.CompanyID.String())
+		c.Set("device_key", apiKey)
+		c.Next()
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter cuenta solicitudes por clave en ventanas fijas. Vive en memoria: con
+// varias instancias del API cada una aplica su propio límite.
+type RateLimiter struct {
+	mu      sync.Mutex
+	max     int
+	window  time.Duration
+	windows map[string]rateWindow
+	now     func() time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func NewRateLimiter(max int, window time.Duration) *RateLimiter {
+	return &RateLimiter{max: max, window: window, windows: map[string]rateWindow{}, now: time.Now}
+}
+
+// Allow registra una solicitud de key. Si supera el límite retorna false y cuánto
+// falta para que se abra la siguiente ventana.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	w := l.windows[key]
+	if now.Sub(w.start) >= l.window {
+		w = rateWindow{start: now}
+		// Limpieza oportunista de ventanas vencidas para que el mapa no crezca sin fin
+		for k, old := range l.windows {
+			if now.Sub(old.start) >= l.window {
+				delete(l.windows, k)
+			}
+		}
+	}
+	if w.count >= l.max {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	l.windows[key] = w
+	return true, 0
+}
+
+// RateLimit limita las solicitudes por IP. Responde 429 con Retry-After al exceder el límite.
+func RateLimit(limiter *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ok, retry := limiter.Allow(c.FullPath() + "|" + c.ClientIP()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Demasiadas solicitudes, intente más tarde"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	r := gin.New()
+	r.POST("/forgot", RateLimit(limiter), func(c *gin.Context) { c.Status(http.StatusAccepted) })
+	post := func(ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/forgot", nil)
+		req.RemoteAddr = ip + ":1234"
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := post("10.0.0.1"); w.Code != http.StatusAccepted {
+			t.Fatalf("Solicitud %d: esperado 202, fue %d", i+1, w.Code)
+		}
+	}
+	w := post("10.0.0.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Tercera solicitud: esperado 429 con Retry-After 60, fue %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := post("10.0.0.2"); w.Code != http.StatusAccepted {
+		t.Errorf("Otra IP no comparte el límite, fue %d", w.Code)
+	}
+
+	now = now.Add(time.Minute)
+	if w := post("10.0.0.1"); w.Code != http.StatusAccepted {
+		t.Errorf("Nueva ventana: esperado 202, fue %d", w.Code)
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"sensor-api-go/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Códigos de error de autorización, para que el frontend distinga el motivo
+const (
+	ErrCodeUnknownRole      = "unknown_role"
+	ErrCodePermissionDenied = "permission_denied"
+)
+
+// RequirePermission permite continuar solo si el rol del token tiene el permiso
+// según la política de rbac. Debe ir después de JWTAuthMiddleware.
+func RequirePermission(perm rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if rbac.Normalize(role) == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Rol no reconocido: " + role,
+				"code":  ErrCodeUnknownRole,
+			})
+			return
+		}
+		if !rbac.Can(role, perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":      "Su rol no tiene permiso para esta acción",
+				"code":       ErrCodePermissionDenied,
+				"permission": perm,
+			})
+			return
+		}
+		c.Next()
+	}
+}
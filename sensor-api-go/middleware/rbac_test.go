@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sensor-api-go/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequirePermission(t *testing.T) {
+	cases := []struct {
+		role     string
+		wantCode int
+		errCode  string
+	}{
+		{"operator", http.StatusOK, ""},
+		{"viewer", http.StatusForbidden, ErrCodePermissionDenied},
+		{"desconocido", http.StatusForbidden, ErrCodeUnknownRole},
+	}
+	for _, tc := range cases {
+		r := gin.New()
+		r.POST("/alerts", func(c *gin.Context) {
+			c.Set("role", tc.role)
+			c.Next()
+		}, RequirePermission(rbac.AlertsWrite), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/alerts", nil)
+		r.ServeHTTP(w, req)
+		if w.Code != tc.wantCode {
+			t.Errorf("rol %q: esperado %d, fue %d", tc.role, tc.wantCode, w.Code)
+			continue
+		}
+		if tc.errCode != "" {
+			var body map[string]string
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if body["code"] != tc.errCode {
+				t.Errorf("rol %q: esperado código %q, fue %q", tc.role, tc.errCode, body["code"])
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader lleva el identificador de la solicitud en ambos sentidos
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID asigna un identificador a cada solicitud (el que envía el proxy, si es
+// razonable, o uno nuevo), lo deja en el contexto como "request_id" y lo retorna en
+// la respuesta para poder cruzar logs y auditoría.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID acepta hasta 128 caracteres imprimibles sin espacios
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestID(t *testing.T) {
+	r := gin.New()
+	r.GET("/", RequestID(), func(c *gin.Context) { c.String(http.StatusOK, c.GetString("request_id")) })
+	get := func(header string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if header != "" {
+			req.Header.Set(RequestIDHeader, header)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("proxy-123"); w.Body.String() != "proxy-123" || w.Header().Get(RequestIDHeader) != "proxy-123" {
+		t.Errorf("Debe conservar el id del proxy, fue %q", w.Body.String())
+	}
+	w := get("")
+	if len(w.Body.String()) != 36 || w.Header().Get(RequestIDHeader) != w.Body.String() {
+		t.Errorf("Sin encabezado debe generar un UUID, fue %q", w.Body.String())
+	}
+	for _, bad := range []string{"con espacios", strings.Repeat("a", 129)} {
+		if w := get(bad); w.Body.String() == bad {
+			t.Errorf("Id inválido %q no debe aceptarse", bad)
+		}
+	}
+}
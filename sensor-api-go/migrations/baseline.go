@@ -0,0 +1,342 @@
+// migrations/baseline.go
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Copia congelada de los modelos al momento de versionar las migraciones. La
+// migración 1 usa estas structs y no las de models: si no, cada cambio posterior a
+// un modelo cambiaría en silencio lo que hace la baseline en una base nueva. No se
+// editan; los cambios de esquema van en migraciones nuevas.
+
+type v1Company struct {
+	ID                  uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key"`
+	Name                string    `gorm:"type:varchar(255);not null"`
+	RequireMFAForAdmins bool      `gorm:"not null;default:false"`
+}
+
+func (v1Company) TableName() string { return "companies" }
+
+// v1SQLiteCompany es companies en SQLite, que no tiene uuid_generate_v4(). SQLite se
+// soportó después de la baseline; la migración 10 quita el default en Postgres.
+type v1SQLiteCompany struct {
+	ID                  uuid.UUID `gorm:"type:uuid;primary_key"`
+	Name                string    `gorm:"type:varchar(255);not null"`
+	RequireMFAForAdmins bool      `gorm:"not null;default:false"`
+}
+
+func (v1SQLiteCompany) TableName() string { return "companies" }
+
+type v1User struct {
+	ID                uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CompanyID         uuid.UUID `gorm:"type:uuid;not null"`
+	Name              string    `gorm:"not null"`
+	Email             string    `gorm:"uniqueIndex;not null"`
+	Password          string    `gorm:"not null"`
+	Role              string    `gorm:"not null"`
+	Status            string    `gorm:"not null;default:Active"`
+	CreatedAt         time.Time
+	MFASecret         string
+	MFAEnabled        bool `gorm:"not null;default:false"`
+	MFAEnrolledAt     *time.Time
+	MFALastStep       int64
+	FailedLogins      int `gorm:"not null;default:0"`
+	LastFailedLoginAt *time.Time
+	LockedUntil       *time.Time
+	SSOSubject        *string `gorm:"uniqueIndex"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1CameraReading struct {
+	ID          uint      `gorm:"primaryKey"`
+	CompanyID   uuid.UUID `gorm:"type:uuid;index"`
+	CameraID    int       `gorm:"not null"`
+	ZoneID      int       `gorm:"not null"`
+	Temperature float64   `gorm:"not null"`
+	Timestamp   time.Time `gorm:"not null"`
+}
+
+func (v1CameraReading) TableName() string { return "camera_readings" }
+
+type v1Device struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CompanyID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_devices_company_camera"`
+	Name      string
+	CameraID  *int     `gorm:"uniqueIndex:idx_devices_company_camera"`
+	Zones     []v1Zone `gorm:"foreignKey:DeviceID"`
+	gorm.Model
+}
+
+func (v1Device) TableName() string { return "devices" }
+
+type v1Zone struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CompanyID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_zones_company_camera_zone"`
+	DeviceID  uuid.UUID `gorm:"type:uuid;not null"`
+	Name      string
+	CameraID  *int `gorm:"uniqueIndex:idx_zones_company_camera_zone"`
+	ZoneIndex *int `gorm:"uniqueIndex:idx_zones_company_camera_zone"`
+	gorm.Model
+}
+
+func (v1Zone) TableName() string { return "zones" }
+
+type v1ZoneAlert struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CompanyID       uuid.UUID `gorm:"type:uuid;index"`
+	ZoneID          uuid.UUID `gorm:"type:uuid;not null"`
+	UpperThresh     float64
+	LowerThresh     float64
+	Recipient       string  `gorm:"not null"`
+	Hysteresis      float64 `gorm:"not null;default:0"`
+	MinDurationSec  int     `gorm:"not null;default:0"`
+	MinConsecutive  int     `gorm:"not null;default:0"`
+	ReminderMinutes int     `gorm:"not null;default:0"`
+	State           string  `gorm:"not null;default:OK"`
+	StateChangedAt  *time.Time
+	LastNotifiedAt  *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (v1ZoneAlert) TableName() string { return "zone_alerts" }
+
+type v1ZoneAlertEvent struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CompanyID   uuid.UUID `gorm:"type:uuid;index"`
+	AlertID     uuid.UUID `gorm:"type:uuid;index"`
+	ZoneID      uuid.UUID `gorm:"type:uuid;index"`
+	CameraID    int
+	Temperature float64
+	Threshold   float64
+	Type        string
+	Transition  string
+	Timestamp   time.Time
+	Recipient   string
+	Sent        bool
+	Error       string
+}
+
+func (v1ZoneAlertEvent) TableName() string { return "zone_alert_events" }
+
+type v1DeviceAlert struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CompanyID       uuid.UUID `gorm:"type:uuid;index"`
+	DeviceID        uuid.UUID `gorm:"type:uuid;not null"`
+	UpperThresh     float64
+	LowerThresh     float64
+	Recipient       string  `gorm:"not null"`
+	Mode            string  `gorm:"not null;default:any"`
+	Hysteresis      float64 `gorm:"not null;default:0"`
+	MinDurationSec  int     `gorm:"not null;default:0"`
+	MinConsecutive  int     `gorm:"not null;default:0"`
+	ReminderMinutes int     `gorm:"not null;default:0"`
+	State           string  `gorm:"not null;default:OK"`
+	StateChangedAt  *time.Time
+	LastNotifiedAt  *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (v1DeviceAlert) TableName() string { return "device_alerts" }
+
+type v1DeviceAlertEvent struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	CompanyID   uuid.UUID  `gorm:"type:uuid;index"`
+	AlertID     uuid.UUID  `gorm:"type:uuid;index"`
+	DeviceID    uuid.UUID  `gorm:"type:uuid;index"`
+	ZoneID      *uuid.UUID `gorm:"type:uuid"`
+	CameraID    int
+	Mode        string
+	Temperature float64
+	Threshold   float64
+	Type        string
+	Transition  string
+	Timestamp   time.Time
+	Recipient   string
+	Sent        bool
+	Error       string
+}
+
+func (v1DeviceAlertEvent) TableName() string { return "device_alert_events" }
+
+type v1DeviceAPIKey struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CompanyID  uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name       string    `gorm:"not null"`
+	Prefix     string    `gorm:"not null"`
+	KeyHash    string    `gorm:"uniqueIndex;not null"`
+	CameraIDs  string    `gorm:"not null;default:''"`
+	CreatedBy  uuid.UUID `gorm:"type:uuid"`
+	RevokedAt  *time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (v1DeviceAPIKey) TableName() string { return "device_api_keys" }
+
+type v1Session struct {
+	ID            uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index"`
+	CompanyID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	ExpiresAt     time.Time `gorm:"not null"`
+	RevokedAt     *time.Time
+	RevokedReason string
+	UserAgent     string
+	IP            string
+	LastUsedAt    *time.Time
+	CreatedAt     time.Time
+}
+
+func (v1Session) TableName() string { return "sessions" }
+
+type v1RefreshToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	SessionID uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (v1RefreshToken) TableName() string { return "refresh_tokens" }
+
+type v1PasswordResetToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RequestIP string
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (v1PasswordResetToken) TableName() string { return "password_reset_tokens" }
+
+type v1Invitation struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CompanyID  uuid.UUID `gorm:"type:uuid;not null;index"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	Email      string    `gorm:"not null"`
+	Role       string    `gorm:"not null"`
+	InvitedBy  uuid.UUID `gorm:"type:uuid"`
+	TokenID    uuid.UUID `gorm:"type:uuid;not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	SentAt     time.Time
+	AcceptedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (v1Invitation) TableName() string { return "invitations" }
+
+type v1MFARecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	CodeHash  string    `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (v1MFARecoveryCode) TableName() string { return "mfa_recovery_codes" }
+
+type v1LoginFailure struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CompanyID uuid.UUID `gorm:"type:uuid;not null"`
+	Email     string    `gorm:"not null;index"`
+	IP        string    `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
+
+func (v1LoginFailure) TableName() string { return "login_failures" }
+
+type v1AuditLog struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	CompanyID  uuid.UUID  `gorm:"type:uuid;not null;index"`
+	ActorID    *uuid.UUID `gorm:"type:uuid;index"`
+	ActorEmail string
+	Action     string `gorm:"not null;index"`
+	TargetType string `gorm:"index"`
+	TargetID   string `gorm:"index"`
+	IP         string
+	Details    string
+	Changes    string
+	Method     string
+	Path       string
+	Status     int
+	RequestID  string    `gorm:"index"`
+	CreatedAt  time.Time `gorm:"not null;index"`
+}
+
+func (v1AuditLog) TableName() string { return "audit_logs" }
+
+type v1CompanySSO struct {
+	CompanyID      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Enabled        bool      `gorm:"not null;default:false"`
+	Issuer         string    `gorm:"not null"`
+	ClientID       string    `gorm:"not null"`
+	ClientSecret   string    `gorm:"not null"`
+	AllowedDomains string
+	RoleClaim      string
+	RoleMapping    string `gorm:"type:text"`
+	DefaultRole    string
+	EnforceSSO     bool `gorm:"not null;default:false"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (v1CompanySSO) TableName() string { return "company_sso" }
+
+// baselineModels son las tablas de la migración 1, en orden de creación
+var baselineModels = []interface{}{
+	&v1Company{},
+	&v1User{},
+	&v1CameraReading{},
+	&v1Device{},
+	&v1Zone{},
+	&v1ZoneAlert{},
+	&v1ZoneAlertEvent{},
+	&v1DeviceAlert{},
+	&v1DeviceAlertEvent{},
+	&v1DeviceAPIKey{},
+	&v1Session{},
+	&v1RefreshToken{},
+	&v1PasswordResetToken{},
+	&v1Invitation{},
+	&v1MFARecoveryCode{},
+	&v1LoginFailure{},
+	&v1AuditLog{},
+	&v1CompanySSO{},
+}
+
+// baseline crea las tablas; en una base existente solo agrega lo que falte
+func baseline(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "postgres" {
+		return tx.AutoMigrate(append([]interface{}{&v1SQLiteCompany{}}, baselineModels[1:]...)...)
+	}
+	// companies usa uuid_generate_v4() como default
+	if err := tx.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`).Error; err != nil {
+		return err
+	}
+	return tx.AutoMigrate(baselineModels...)
+}
+
+// baselineDown elimina las tablas de la baseline, en orden inverso (zones antes que
+// devices por la llave foránea)
+func baselineDown(tx *gorm.DB) error {
+	for i := len(baselineModels) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(baselineModels[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
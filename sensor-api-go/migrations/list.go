@@ -0,0 +1,195 @@
+// migrations/list.go
+
+package migrations
+
+import (
+	"sensor-api-go/models"
+	"sensor-api-go/rollup"
+	"sensor-api-go/tenant"
+	"sensor-api-go/zones"
+
+	"gorm.io/gorm"
+)
+
+// All son las migraciones del API en orden. Solo se agregan al final.
+//
+// Los cambios de esquema tienen Down. Los backfills de datos (3 y 4) no: no hay cómo
+// recuperar los datos que reemplazaron, así que "migrate down" se detiene en ellos.
+var All = []Migration{
+	{
+		// Esquema de los modelos al momento de versionar las migraciones (congelado en
+		// baseline.go). En una base existente solo agrega lo que falte.
+		Version: 1,
+		Name:    "baseline",
+		Up:      baseline,
+		Down:    baselineDown,
+	},
+	{
+		// Los índices únicos de cámara y zona ahora incluyen la empresa
+		Version: 2,
+		Name:    "drop_global_camera_zone_indexes",
+		Up:      dropGlobalCameraZoneIndexes,
+		Down:    restoreGlobalCameraZoneIndexes,
+	},
+	{
+		// Asigna empresa a los datos anteriores a company_id. Irreversible.
+		Version: 3,
+		Name:    "backfill_company_ids",
+		Up:      tenant.Backfill,
+	},
+	{
+		// Registra las zonas de las lecturas existentes y reasigna alertas antiguas.
+		// Irreversible.
+		Version: 4,
+		Name:    "backfill_zone_registry",
+		Up:      zones.Backfill,
+	},
+	{
+		// Tablas de rollup por minuto, hora y día, y retención de lecturas por empresa
+		Version: 5,
+		Name:    "reading_rollups",
+		Up:      readingRollupsUp,
+		Down:    readingRollupsDown,
+	},
+	{
+		Version: 6,
+		Name:    "camera_readings_company_time_index",
+		Up:      readingsTimeIndexUp,
+		Down:    readingsTimeIndexDown,
+	},
+	{
+		Version: 7,
+		Name:    "company_locale",
+		Up:      companyLocaleUp,
+		Down:    companyLocaleDown,
+	},
+	{
+		Version: 8,
+		Name:    "import_jobs",
+		Up:      importJobsUp,
+		Down:    importJobsDown,
+	},
+	{
+		// Transiciones de alertas para el stream en vivo (GET /api/stream)
+		Version: 9,
+		Name:    "stream_events",
+		Up:      streamEventsUp,
+		Down:    streamEventsDown,
+	},
+	{
+		// El UUID de las empresas lo asigna la aplicación (models.Company.BeforeCreate)
+		Version: 10,
+		Name:    "company_id_app_default",
+		Up:      companyIDDefaultUp,
+		Down:    companyIDDefaultDown,
+	},
+}
+
+func dropGlobalCameraZoneIndexes(tx *gorm.DB) error {
+	for _, idx := range []struct {
+		model interface{}
+		name  string
+	}{{&models.Device{}, "idx_devices_camera_id"}, {&models.Zone{}, "idx_zones_camera_zone"}} {
+		if tx.Migrator().HasIndex(idx.model, idx.name) {
+			if err := tx.Migrator().DropIndex(idx.model, idx.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restoreGlobalCameraZoneIndexes vuelve a los índices únicos sin empresa. Falla si
+// dos empresas ya comparten cámara o zona.
+func restoreGlobalCameraZoneIndexes(tx *gorm.DB) error {
+	for _, stmt := range []string{
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_devices_camera_id ON devices (camera_id)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_zones_camera_zone ON zones (camera_id, zone_index)",
+	} {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readingRollupsUp(tx *gorm.DB) error {
+	if err := tx.AutoMigrate(&models.Company{}); err != nil {
+		return err
+	}
+	return rollup.Migrate(tx)
+}
+
+func readingRollupsDown(tx *gorm.DB) error {
+	if err := rollup.Drop(tx); err != nil {
+		return err
+	}
+	if tx.Migrator().HasColumn(&models.Company{}, "raw_retention_days") {
+		return tx.Migrator().DropColumn(&models.Company{}, "raw_retention_days")
+	}
+	return nil
+}
+
+// readingsTimeIndex sostiene el listado de lecturas paginado por (timestamp, id)
+const readingsTimeIndex = "idx_camera_readings_company_time"
+
+func readingsTimeIndexUp(tx *gorm.DB) error {
+	if tx.Migrator().HasIndex(&models.CameraReading{}, readingsTimeIndex) {
+		return nil
+	}
+	return tx.Migrator().CreateIndex(&models.CameraReading{}, readingsTimeIndex)
+}
+
+func readingsTimeIndexDown(tx *gorm.DB) error {
+	if !tx.Migrator().HasIndex(&models.CameraReading{}, readingsTimeIndex) {
+		return nil
+	}
+	return tx.Migrator().DropIndex(&models.CameraReading{}, readingsTimeIndex)
+}
+
+func companyLocaleUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&models.Company{})
+}
+
+func companyLocaleDown(tx *gorm.DB) error {
+	for _, col := range []string{"time_zone", "temperature_unit"} {
+		if tx.Migrator().HasColumn(&models.Company{}, col) {
+			if err := tx.Migrator().DropColumn(&models.Company{}, col); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func importJobsUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&models.ImportJob{}, &models.ImportError{})
+}
+
+func importJobsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&models.ImportError{}, &models.ImportJob{})
+}
+
+func streamEventsUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&models.StreamEvent{})
+}
+
+func streamEventsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&models.StreamEvent{})
+}
+
+// companyIDDefaultUp quita el default uuid_generate_v4() de companies.id en Postgres.
+// En SQLite la baseline ya crea la columna sin default.
+func companyIDDefaultUp(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return tx.Exec("ALTER TABLE companies ALTER COLUMN id DROP DEFAULT").Error
+}
+
+func companyIDDefaultDown(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return tx.Exec("ALTER TABLE companies ALTER COLUMN id SET DEFAULT uuid_generate_v4()").Error
+}
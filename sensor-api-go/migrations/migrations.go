@@ -0,0 +1,218 @@
+// migrations/migrations.go
+
+// Package migrations aplica los cambios de esquema en orden. Cada migración tiene
+// una versión única y creciente, se ejecuta en una transacción y queda anotada en
+// la tabla schema_migrations. El API se niega a arrancar si falta alguna.
+//
+// Para cambiar el esquema se agrega una migración al final de All; nunca se edita
+// una que ya se aplicó en producción. La baseline usa una copia congelada de los
+// modelos; las posteriores que usan los modelos actuales deben tolerar que su
+// cambio ya exista (usar AutoMigrate, HasTable o HasColumn).
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration es un paso del esquema. Down nil indica que no se puede revertir
+// (por ejemplo, migraciones de datos).
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration es una migración aplicada
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName fija el nombre de la tabla de control
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+var (
+	// ErrPending indica que la base no tiene todas las migraciones del binario
+	ErrPending = errors.New("el esquema de la base está desactualizado")
+	// ErrIrreversible indica que la migración a revertir no tiene Down
+	ErrIrreversible = errors.New("la migración no se puede revertir")
+)
+
+// State es una migración conocida por el binario o registrada en la base
+type State struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool // aplicada en la base pero no existe en este binario
+}
+
+// Status retorna el estado de cada migración, en orden de versión
+func Status(db *gorm.DB, list []Migration) ([]State, error) {
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]State, 0, len(list))
+	known := map[int]bool{}
+	for _, m := range list {
+		known[m.Version] = true
+		s := State{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			at := row.AppliedAt
+			s.AppliedAt = &at
+		}
+		states = append(states, s)
+	}
+	for v, row := range applied {
+		if !known[v] {
+			at := row.AppliedAt
+			states = append(states, State{Version: v, Name: row.Name, AppliedAt: &at, Unknown: true})
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// Check retorna ErrPending si falta aplicar alguna migración. Si la base tiene
+// migraciones que este binario no conoce (un binario anterior) solo lo advierte.
+func Check(db *gorm.DB, list []Migration) error {
+	states, err := Status(db, list)
+	if err != nil {
+		return err
+	}
+	var pending []int
+	for _, s := range states {
+		switch {
+		case s.Unknown:
+			log.Printf("[WARN] La base tiene la migración %d (%s), que este binario no conoce", s.Version, s.Name)
+		case s.AppliedAt == nil:
+			pending = append(pending, s.Version)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: faltan las migraciones %v; ejecute \"migrate up\"", ErrPending, pending)
+	}
+	return nil
+}
+
+// Up aplica en orden las migraciones pendientes y retorna las que aplicó
+func Up(db *gorm.DB, list []Migration) ([]Migration, error) {
+	if err := validate(list); err != nil {
+		return nil, err
+	}
+	var done []Migration
+	err := withLock(db, func() error {
+		applied, err := appliedVersions(db)
+		if err != nil {
+			return err
+		}
+		for _, m := range list {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migración %d (%s): %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down revierte las últimas steps migraciones aplicadas, de la más nueva a la más antigua
+func Down(db *gorm.DB, list []Migration, steps int) ([]Migration, error) {
+	if err := validate(list); err != nil {
+		return nil, err
+	}
+	var done []Migration
+	err := withLock(db, func() error {
+		applied, err := appliedVersions(db)
+		if err != nil {
+			return err
+		}
+		for i := len(list) - 1; i >= 0 && len(done) < steps; i-- {
+			m := list[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == nil {
+				return fmt.Errorf("migración %d (%s): %w", m.Version, m.Name, ErrIrreversible)
+			}
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("revirtiendo migración %d (%s): %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// appliedVersions lee la tabla de control, creándola si no existe
+func appliedVersions(db *gorm.DB) (map[int]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// validate exige versiones positivas, únicas y en orden creciente
+func validate(list []Migration) error {
+	prev := 0
+	for _, m := range list {
+		if m.Version <= prev {
+			return fmt.Errorf("migración %d (%s) fuera de orden o repetida", m.Version, m.Name)
+		}
+		if m.Up == nil {
+			return fmt.Errorf("migración %d (%s) sin Up", m.Version, m.Name)
+		}
+		prev = m.Version
+	}
+	return nil
+}
+
+// lockID identifica el advisory lock de Postgres que evita migrar desde dos procesos a la vez
+const lockID = 728_104_551
+
+func withLock(db *gorm.DB, fn func() error) error {
+	if db.Dialector.Name() != "postgres" {
+		return fn()
+	}
+	// El lock pertenece a la conexión que lo toma; se mantiene mientras corre fn
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockID).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", lockID)
+		return fn()
+	})
+}
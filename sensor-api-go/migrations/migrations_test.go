@@ -0,0 +1,159 @@
+package migrations
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type widget struct {
+	ID   uint
+	Name string
+}
+
+type gadget struct {
+	ID uint
+}
+
+func testList() []Migration {
+	return []Migration{
+		{Version: 1, Name: "widgets", Up: func(tx *gorm.DB) error { return tx.AutoMigrate(&widget{}) }},
+		{
+			Version: 2, Name: "gadgets",
+			Up:   func(tx *gorm.DB) error { return tx.AutoMigrate(&gadget{}) },
+			Down: func(tx *gorm.DB) error { return tx.Migrator().DropTable(&gadget{}) },
+		},
+	}
+}
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	return db
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db := openDB(t)
+	list := testList()
+
+	if err := Check(db, list); !errors.Is(err, ErrPending) {
+		t.Fatalf("Base vacía: esperado ErrPending, fue %v", err)
+	}
+	done, err := Up(db, list)
+	if err != nil || len(done) != 2 {
+		t.Fatalf("Up: %d aplicadas, error %v", len(done), err)
+	}
+	if !db.Migrator().HasTable(&gadget{}) {
+		t.Error("Up debe crear las tablas")
+	}
+	if done, _ := Up(db, list); len(done) != 0 {
+		t.Errorf("Un segundo Up no debe aplicar nada, aplicó %d", len(done))
+	}
+	if err := Check(db, list); err != nil {
+		t.Errorf("Con todo aplicado Check debe pasar: %v", err)
+	}
+
+	// Una migración nueva en el binario deja el esquema desactualizado
+	list = append(list, Migration{Version: 3, Name: "nueva", Up: func(*gorm.DB) error { return nil }})
+	if err := Check(db, list); !errors.Is(err, ErrPending) {
+		t.Errorf("Con una migración nueva: esperado ErrPending, fue %v", err)
+	}
+	states, _ := Status(db, list)
+	if len(states) != 3 || states[0].AppliedAt == nil || states[2].AppliedAt != nil {
+		t.Errorf("Status inesperado: %+v", states)
+	}
+
+	// Un binario anterior no conoce la migración 2, pero puede arrancar
+	if err := Check(db, list[:1]); err != nil {
+		t.Errorf("Migraciones desconocidas solo se advierten: %v", err)
+	}
+	states, _ = Status(db, list[:1])
+	if len(states) != 2 || !states[1].Unknown {
+		t.Errorf("La migración 2 debe figurar como desconocida: %+v", states)
+	}
+
+	done, err = Down(db, list, 1)
+	if err != nil || len(done) != 1 || done[0].Version != 2 {
+		t.Fatalf("Down 1: %+v, error %v", done, err)
+	}
+	if db.Migrator().HasTable(&gadget{}) {
+		t.Error("Down debe revertir la última migración aplicada")
+	}
+	if _, err := Down(db, list, 1); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("Revertir una migración sin Down: esperado ErrIrreversible, fue %v", err)
+	}
+}
+
+func TestUpRollsBackFailedMigration(t *testing.T) {
+	db := openDB(t)
+	list := []Migration{{Version: 1, Name: "falla", Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&widget{}); err != nil {
+			return err
+		}
+		return errors.New("fallo a medias")
+	}}}
+	if _, err := Up(db, list); err == nil {
+		t.Fatal("Up debe retornar el error de la migración")
+	}
+	if db.Migrator().HasTable(&widget{}) {
+		t.Error("Los cambios de una migración fallida deben revertirse")
+	}
+	if err := Check(db, list); !errors.Is(err, ErrPending) {
+		t.Errorf("La migración fallida debe seguir pendiente: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := validate(All); err != nil {
+		t.Errorf("All debe estar en orden: %v", err)
+	}
+	up := func(*gorm.DB) error { return nil }
+	for _, list := range [][]Migration{
+		{{Version: 2, Name: "b", Up: up}, {Version: 1, Name: "a", Up: up}},
+		{{Version: 1, Name: "a", Up: up}, {Version: 1, Name: "b", Up: up}},
+		{{Version: 1, Name: "a"}},
+	} {
+		if err := validate(list); err == nil {
+			t.Errorf("Lista inválida aceptada: %+v", list)
+		}
+	}
+}
+
+func TestAllUpAndDown(t *testing.T) {
+	db := openDB(t)
+	if _, err := Up(db, All); err != nil {
+		t.Fatalf("Up de todas las migraciones: %v", err)
+	}
+	// Down revierte los cambios de esquema y se detiene en el primer backfill
+	done, err := Down(db, All, len(All))
+	if !errors.Is(err, ErrIrreversible) || len(done) != len(All)-4 {
+		t.Fatalf("Down: esperado ErrIrreversible tras revertir %d, fue %d y %v", len(All)-4, len(done), err)
+	}
+
+	// La baseline y el cambio de índices sí se revierten
+	db = openDB(t)
+	if _, err := Up(db, All[:2]); err != nil {
+		t.Fatalf("Up de la baseline: %v", err)
+	}
+	if !db.Migrator().HasTable("companies") || !db.Migrator().HasColumn(&v1User{}, "sso_subject") {
+		t.Fatal("La baseline debe crear las tablas de la copia congelada")
+	}
+	if db.Migrator().HasColumn(&v1Company{}, "raw_retention_days") {
+		t.Error("La baseline no debe incluir columnas de migraciones posteriores")
+	}
+	if _, err := Down(db, All[:2], 1); err != nil || !db.Migrator().HasIndex("devices", "idx_devices_camera_id") {
+		t.Fatalf("Down de la migración 2 debe restaurar los índices globales: %v", err)
+	}
+	if done, err := Down(db, All[:2], 1); err != nil || len(done) != 1 {
+		t.Fatalf("Down de la baseline: %d revertidas, error %v", len(done), err)
+	}
+	for _, table := range []string{"companies", "users", "zones", "devices", "company_sso"} {
+		if db.Migrator().HasTable(table) {
+			t.Errorf("Down de la baseline debe eliminar %s", table)
+		}
+	}
+}
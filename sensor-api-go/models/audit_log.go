@@ -0,0 +1,43 @@
+// models/audit_log.go
+
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrAuditLogImmutable se retorna al intentar editar o borrar un registro de auditoría
+var ErrAuditLogImmutable = errors.New("el registro de auditoría no se puede modificar")
+
+// AuditLog es un registro de una acción relevante para la seguridad. Solo se
+// insertan filas; nunca se editan ni se borran.
+type AuditLog struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	CompanyID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"company_id"`
+	ActorID    *uuid.UUID `gorm:"type:uuid;index" json:"actor_id,omitempty"` // nil cuando la acción la toma el sistema
+	ActorEmail string     `json:"actor_email,omitempty"`
+	Action     string     `gorm:"not null;index" json:"action"`
+	TargetType string     `gorm:"index" json:"target_type,omitempty"`
+	TargetID   string     `gorm:"index" json:"target_id,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	Details    string     `json:"details,omitempty"` // JSON con datos propios de la acción
+	Changes    string     `json:"changes,omitempty"` // JSON {"campo": {"before": ..., "after": ...}}
+
+	// Solicitud HTTP que originó la acción (vacío si la tomó un proceso de fondo)
+	Method    string `json:"method,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	RequestID string `gorm:"index" json:"request_id,omitempty"`
+
+	CreatedAt time.Time `gorm:"not null;index" json:"created_at"`
+}
+
+// BeforeUpdate impide editar registros desde GORM
+func (AuditLog) BeforeUpdate(*gorm.DB) error { return ErrAuditLogImmutable }
+
+// BeforeDelete impide borrar registros desde GORM
+func (AuditLog) BeforeDelete(*gorm.DB) error { return ErrAuditLogImmutable }
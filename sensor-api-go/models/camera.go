@@ -2,12 +2,15 @@ package models
 
 import (
     "time"
+
+    "github.com/google/uuid"
 )
 
 type CameraReading struct {
     ID          uint      `gorm:"primaryKey"`
+    CompanyID   uuid.UUID `gorm:"type:uuid;index;index:idx_camera_readings_company_time,priority:1"` // empresa dueña de la cámara
     CameraID    int       `gorm:"not null"`
     ZoneID      int       `gorm:"not null"`
     Temperature float64   `gorm:"not null"`
-    Timestamp   time.Time `gorm:"not null"`
+    Timestamp   time.Time `gorm:"not null;index:idx_camera_readings_company_time,priority:2"` // listado paginado por (timestamp, id)
 }
@@ -2,9 +2,27 @@ package models
 
 import (
     "github.com/google/uuid"
+    "gorm.io/gorm"
 )
 
 type Company struct {
-    ID   uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+    ID   uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
     Name string    `gorm:"type:varchar(255);not null" json:"name"`
+    // Política de seguridad: los admins deben usar 2FA para iniciar sesión
+    RequireMFAForAdmins bool `gorm:"not null;default:false" json:"require_mfa_for_admins"`
+    // Días que se guardan las lecturas originales; las más antiguas se borran y solo
+    // quedan sus rollups. 0 = sin límite
+    RawRetentionDays int `gorm:"not null;default:0" json:"raw_retention_days"`
+    // Zona horaria (IANA) y unidad de temperatura ("C" o "F") de las exportaciones
+    TimeZone        string `gorm:"type:varchar(64);not null;default:'UTC'" json:"time_zone"`
+    TemperatureUnit string `gorm:"type:varchar(1);not null;default:'C'" json:"temperature_unit"`
+}
+
+// BeforeCreate asigna el UUID en la aplicación, así la tabla también se puede crear
+// en SQLite. Antes lo generaba uuid_generate_v4() de Postgres (ver la migración 10).
+func (c *Company) BeforeCreate(*gorm.DB) error {
+    if c.ID == uuid.Nil {
+        c.ID = uuid.New()
+    }
+    return nil
 }
@@ -0,0 +1,48 @@
+// models/company_sso.go
+
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CompanySSO es la configuración OpenID Connect de una empresa. Los usuarios que
+// entran por SSO se crean en el primer login (sin contraseña local).
+type CompanySSO struct {
+	CompanyID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"company_id"`
+	Enabled      bool      `gorm:"not null;default:false" json:"enabled"`
+	Issuer       string    `gorm:"not null" json:"issuer"` // URL del proveedor (se usa su /.well-known/openid-configuration)
+	ClientID     string    `gorm:"not null" json:"client_id"`
+	ClientSecret string    `gorm:"not null" json:"-"`
+	// AllowedDomains son los dominios de email aceptados, separados por coma (vacío: cualquiera)
+	AllowedDomains string `json:"allowed_domains"`
+	// RoleClaim es el claim del id_token con los grupos o roles del usuario (p. ej. "groups")
+	RoleClaim string `json:"role_claim"`
+	// RoleMapping es un JSON {"valor del claim": "rol"}; gana el rol de mayor privilegio
+	RoleMapping string `gorm:"type:text" json:"role_mapping"`
+	// DefaultRole se asigna si ningún valor del claim está mapeado; vacío rechaza el login
+	DefaultRole string `json:"default_role"`
+	// EnforceSSO impide el login con contraseña a los usuarios de la empresa
+	EnforceSSO bool      `gorm:"not null;default:false" json:"enforce_sso"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName evita el plural "company_ssos"
+func (CompanySSO) TableName() string {
+	return "company_sso"
+}
+
+// Domains retorna los dominios permitidos normalizados
+func (s CompanySSO) Domains() []string {
+	var out []string
+	for _, d := range strings.Split(s.AllowedDomains, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			out = append(out, strings.TrimPrefix(d, "@"))
+		}
+	}
+	return out
+}
@@ -6,8 +6,10 @@ import (
 )
 
 type Device struct {
-    ID    uuid.UUID `gorm:"type:uuid;primaryKey"`
-    Name  string
-    Zones []Zone `gorm:"foreignKey:DeviceID" json:"zones"`
+    ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+    CompanyID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_devices_company_camera" json:"company_id"`
+    Name      string
+    CameraID  *int   `gorm:"uniqueIndex:idx_devices_company_camera" json:"camera_id"` // cámara física que representa el dispositivo
+    Zones     []Zone `gorm:"foreignKey:DeviceID" json:"zones"`
     gorm.Model
 }
@@ -5,12 +5,28 @@ import (
     "time"
 )
 
+// Modos de evaluación de una alerta de dispositivo sobre sus zonas
+const (
+    DeviceAlertModeAny = "any" // dispara si cualquier zona está fuera de rango
+    DeviceAlertModeMax = "max" // evalúa la máxima temperatura entre las zonas
+    DeviceAlertModeAvg = "avg" // evalúa el promedio de temperatura de las zonas
+)
+
 type DeviceAlert struct {
-    ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
-    DeviceID     uuid.UUID `gorm:"type:uuid;not null"`
-    UpperThresh  float64
-    LowerThresh  float64
-    Recipient    string    `gorm:"not null"` // correo al que se enviará alerta
-    CreatedAt    time.Time
-    UpdatedAt    time.Time
+    ID              uuid.UUID `gorm:"type:uuid;primaryKey"`
+    CompanyID       uuid.UUID `gorm:"type:uuid;index"`
+    DeviceID        uuid.UUID `gorm:"type:uuid;not null"`
+    UpperThresh     float64
+    LowerThresh     float64
+    Recipient       string  `gorm:"not null"`             // correo al que se enviará alerta
+    Mode            string  `gorm:"not null;default:any"` // any, max o avg
+    Hysteresis      float64 `gorm:"not null;default:0"`   // banda de despeje, igual que en ZoneAlert
+    MinDurationSec  int     `gorm:"not null;default:0"`
+    MinConsecutive  int     `gorm:"not null;default:0"`
+    ReminderMinutes int     `gorm:"not null;default:0"`
+    State           string  `gorm:"not null;default:OK"` // OK, FIRING o RESOLVED
+    StateChangedAt  *time.Time
+    LastNotifiedAt  *time.Time
+    CreatedAt       time.Time
+    UpdatedAt       time.Time
 }
@@ -0,0 +1,29 @@
+// models/device_alert_event.go
+
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeviceAlertEvent es el historial de transiciones de una DeviceAlert,
+// análogo a ZoneAlertEvent
+type DeviceAlertEvent struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	CompanyID   uuid.UUID  `gorm:"type:uuid;index" json:"company_id"`
+	AlertID     uuid.UUID  `gorm:"type:uuid;index" json:"alert_id"`
+	DeviceID    uuid.UUID  `gorm:"type:uuid;index" json:"device_id"`
+	ZoneID      *uuid.UUID `gorm:"type:uuid" json:"zone_id,omitempty"` // zona que gatilló (solo modo "any")
+	CameraID    int        `json:"camera_id"`
+	Mode        string     `json:"mode"`        // "any", "max", "avg"
+	Temperature float64    `json:"temperature"` // temperatura evaluada (de la zona o agregada)
+	Threshold   float64    `json:"threshold"`
+	Type        string     `json:"type"`       // "upper", "lower" u "ok" (al resolverse)
+	Transition  string     `json:"transition"` // "firing", "reminder", "resolved"
+	Timestamp   time.Time  `json:"timestamp"`
+	Recipient   string     `json:"recipient"`
+	Sent        bool       `json:"sent"`
+	Error       string     `json:"error"`
+}
@@ -0,0 +1,58 @@
+// models/device_api_key.go
+
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeviceAPIKey es una credencial máquina-a-máquina para los gateways de borde.
+// Solo se guarda el hash de la key; el valor en claro se muestra una única vez.
+type DeviceAPIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	CompanyID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"company_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"not null" json:"prefix"` // primeros caracteres, para identificar la key
+	KeyHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	CameraIDs  string     `gorm:"not null;default:''" json:"-"` // lista separada por comas, ej: "1,2,5"
+	CreatedBy  uuid.UUID  `gorm:"type:uuid" json:"created_by"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Cameras retorna las cámaras a las que está restringida la key
+func (k DeviceAPIKey) Cameras() []int {
+	cameras := []int{}
+	for _, part := range strings.Split(k.CameraIDs, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			cameras = append(cameras, id)
+		}
+	}
+	return cameras
+}
+
+// SetCameras guarda la lista de cámaras en el formato de la columna
+func (k *DeviceAPIKey) SetCameras(cameras []int) {
+	parts := make([]string, len(cameras))
+	for i, id := range cameras {
+		parts[i] = strconv.Itoa(id)
+	}
+	k.CameraIDs = strings.Join(parts, ",")
+}
+
+// AllowsCamera indica si la key puede reportar lecturas de esa cámara
+func (k DeviceAPIKey) AllowsCamera(cameraID int) bool {
+	for _, id := range k.Cameras() {
+		if id == cameraID {
+			return true
+		}
+	}
+	return false
+}
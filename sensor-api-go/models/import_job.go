@@ -0,0 +1,62 @@
+// models/import_job.go
+
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Estados de una importación
+const (
+	ImportPending = "pending"
+	ImportRunning = "running"
+	ImportDone    = "done"
+	ImportFailed  = "failed"
+)
+
+// ImportJob es una importación de lecturas históricas desde un CSV (paquete csvimport).
+// El avance se mide en bytes leídos del archivo; las cantidades se actualizan a
+// medida que se guarda cada lote.
+type ImportJob struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	CompanyID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"company_id"`
+	CreatedByID *uuid.UUID `gorm:"type:uuid" json:"created_by_id,omitempty"` // nil si se lanzó por CLI
+	FileName    string     `json:"file_name"`
+	Mapping     string     `json:"mapping"` // JSON de csvimport.Mapping
+	Status      string     `gorm:"not null;index" json:"status"`
+	Error       string     `json:"error,omitempty"` // por qué falló la importación completa
+
+	SizeBytes  int64 `json:"size_bytes"`
+	ReadBytes  int64 `json:"read_bytes"`
+	Rows       int   `json:"rows"`       // filas de datos leídas (sin el encabezado)
+	Imported   int   `json:"imported"`   // lecturas guardadas
+	Duplicates int   `json:"duplicates"` // ya existían (en la base o antes en el archivo)
+	Rejected   int   `json:"rejected"`   // inválidas, ver ImportError
+
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"not null;index" json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Progress es el porcentaje del archivo procesado (0 a 100)
+func (j ImportJob) Progress() float64 {
+	if j.Status == ImportDone {
+		return 100
+	}
+	if j.SizeBytes <= 0 {
+		return 0
+	}
+	return float64(j.ReadBytes) * 100 / float64(j.SizeBytes)
+}
+
+// ImportError es una línea rechazada de una importación, para el reporte de errores
+type ImportError struct {
+	ID      uint      `gorm:"primaryKey" json:"-"`
+	JobID   uuid.UUID `gorm:"type:uuid;not null;index" json:"job_id"`
+	Line    int       `gorm:"not null" json:"line"` // número de línea en el archivo (el encabezado es la 1)
+	Content string    `json:"content"`              // la fila original, separada por el delimitador del archivo
+	Reason  string    `gorm:"not null" json:"reason"`
+}
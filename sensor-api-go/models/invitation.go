@@ -0,0 +1,48 @@
+// models/invitation.go
+
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Estados de una invitación (calculados, no se guardan)
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Invitation es la invitación a un usuario creado con estado "Invited". El enlace
+// enviado por email va firmado y lleva TokenID; reenviar la invitación lo cambia.
+type Invitation struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	CompanyID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"company_id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Email      string     `gorm:"not null" json:"email"`
+	Role       string     `gorm:"not null" json:"role"`
+	InvitedBy  uuid.UUID  `gorm:"type:uuid" json:"invited_by"`
+	TokenID    uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	SentAt     time.Time  `json:"sent_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// State retorna el estado de la invitación en el instante now
+func (i Invitation) State(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case !now.Before(i.ExpiresAt):
+		return InvitationExpired
+	}
+	return InvitationPending
+}
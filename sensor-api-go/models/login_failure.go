@@ -0,0 +1,19 @@
+// models/login_failure.go
+
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginFailure es un intento de login fallido (contraseña o código 2FA incorrecto,
+// o email inexistente). Se usa para limitar los intentos por dirección IP.
+type LoginFailure struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CompanyID uuid.UUID `gorm:"type:uuid;not null"`
+	Email     string    `gorm:"not null;index"`
+	IP        string    `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,21 @@
+// models/password_reset.go
+
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordResetToken es un token de un solo uso enviado por email para restablecer
+// la contraseña. Solo se guarda su hash.
+type PasswordResetToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RequestIP string
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,32 @@
+// models/reading_rollup.go
+
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReadingRollup son las lecturas de una zona agregadas en un bucket de tiempo. Hay
+// una tabla por resolución (1 minuto, 1 hora, 1 día) con esta misma estructura;
+// el paquete rollup las mantiene y elige cuál consultar.
+type ReadingRollup struct {
+	CompanyID   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CameraID    int       `gorm:"primaryKey;autoIncrement:false"`
+	ZoneID      int       `gorm:"primaryKey;autoIncrement:false"`
+	BucketStart time.Time `gorm:"primaryKey"` // inicio del bucket, en UTC
+	Readings    int       `gorm:"not null"`
+	SumTemp     float64   `gorm:"not null"`
+	MinTemp     float64   `gorm:"not null"`
+	MaxTemp     float64   `gorm:"not null"`
+}
+
+// RollupState es el avance del job de rollups. Las lecturas con ID hasta
+// LastReadingID ya están sumadas en las tablas de rollup.
+type RollupState struct {
+	Name          string `gorm:"primaryKey"`
+	LastReadingID uint   `gorm:"not null;default:0"`
+	SeenMaxID     uint   `gorm:"not null;default:0"` // mayor ID visto en la pasada anterior
+	RunAt         *time.Time
+}
@@ -0,0 +1,35 @@
+// models/session.go
+
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session es una sesión de usuario en el servidor. Los access tokens llevan su ID
+// (claim sid) y dejan de ser válidos en cuanto la sesión se revoca.
+type Session struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	CompanyID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"company_id"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"` // límite absoluto, aunque se renueve
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+	UserAgent     string     `json:"user_agent,omitempty"`
+	IP            string     `json:"ip,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// RefreshToken es un token de un solo uso para renovar el access token de una sesión.
+// Cada uso lo marca y emite uno nuevo; presentar uno ya usado revoca la sesión.
+type RefreshToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	SessionID uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
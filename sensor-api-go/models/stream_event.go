@@ -0,0 +1,22 @@
+// models/stream_event.go
+
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StreamEvent es un evento del stream en vivo (paquete stream) que no sale de
+// camera_readings: las transiciones de alertas que registra el worker de alertas,
+// que corre en otro proceso. El ID creciente permite reanudar el stream con
+// Last-Event-ID; las filas se borran después de stream.Retention.
+type StreamEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	CompanyID uuid.UUID `gorm:"type:uuid;not null;index"`
+	CameraID  int       `gorm:"not null"`
+	Type      string    `gorm:"not null"` // nombre del evento SSE, ver stream.EventAlert
+	Data      string    `gorm:"not null"` // JSON del evento
+	CreatedAt time.Time `gorm:"not null;index"`
+}
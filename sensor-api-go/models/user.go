@@ -12,6 +12,21 @@ type User struct {
     Email     string    `gorm:"uniqueIndex;not null"`
     Password  string    `gorm:"not null"`
     Role      string    `gorm:"not null"`
-    Status    string    `gorm:"not null;default:Active"`         // Nuevo campo ("Active" o "Inactive")
+    Status    string    `gorm:"not null;default:Active"`         // "Active", "Inactive" o "Invited" (pendiente de aceptar la invitación)
     CreatedAt time.Time
+
+    // Segundo factor (TOTP). El secreto se guarda al iniciar la activación y
+    // MFAEnabled pasa a true solo después de verificar el primer código.
+    MFASecret     string     `json:"-"`
+    MFAEnabled    bool       `gorm:"not null;default:false"`
+    MFAEnrolledAt *time.Time
+    MFALastStep   int64      `json:"-"` // paso del último código aceptado, evita reusarlo
+
+    // Intentos fallidos seguidos y bloqueo temporal por fuerza bruta (paquete loginguard)
+    FailedLogins      int        `gorm:"not null;default:0" json:"-"`
+    LastFailedLoginAt *time.Time `json:"-"`
+    LockedUntil       *time.Time
+
+    // Identidad en el proveedor OIDC de la empresa ("issuer#sub"); nil si no entra por SSO
+    SSOSubject *string `gorm:"uniqueIndex" json:"-"`
 }
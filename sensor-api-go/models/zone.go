@@ -5,9 +5,14 @@ import (
     "gorm.io/gorm"
 )
 
+// Zone es una zona de medición de una cámara. El registro de zonas (paquete zones)
+// mapea el trío (CompanyID, CameraID, ZoneIndex) de las lecturas a este UUID.
 type Zone struct {
-    ID       uuid.UUID `gorm:"type:uuid;primaryKey"`
-    DeviceID uuid.UUID `gorm:"type:uuid;not null" json:"device_id"`
-    Name     string
+    ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+    CompanyID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_zones_company_camera_zone" json:"company_id"`
+    DeviceID  uuid.UUID `gorm:"type:uuid;not null" json:"device_id"`
+    Name      string
+    CameraID  *int `gorm:"uniqueIndex:idx_zones_company_camera_zone" json:"camera_id"`  // camera_id de camera_readings
+    ZoneIndex *int `gorm:"uniqueIndex:idx_zones_company_camera_zone" json:"zone_index"` // zone_id numérico de camera_readings
     gorm.Model
 }
@@ -5,12 +5,27 @@ import (
     "time"
 )
 
+// Estados del ciclo de vida de una alerta (ver paquete alerts)
+const (
+    AlertStateOK       = "OK"
+    AlertStateFiring   = "FIRING"
+    AlertStateResolved = "RESOLVED"
+)
+
 type ZoneAlert struct {
-    ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
-    ZoneID       uuid.UUID `gorm:"type:uuid;not null"`
-    UpperThresh  float64
-    LowerThresh  float64
-    Recipient    string    `gorm:"not null"` // correo al que se enviará alerta
-    CreatedAt    time.Time
-    UpdatedAt    time.Time
+    ID              uuid.UUID `gorm:"type:uuid;primaryKey"`
+    CompanyID       uuid.UUID `gorm:"type:uuid;index"`
+    ZoneID          uuid.UUID `gorm:"type:uuid;not null"`
+    UpperThresh     float64
+    LowerThresh     float64
+    Recipient       string  `gorm:"not null"`            // correo al que se enviará alerta
+    Hysteresis      float64 `gorm:"not null;default:0"`  // banda de despeje: para resolver debe volver a [Lower+H, Upper-H]
+    MinDurationSec  int     `gorm:"not null;default:0"`  // segundos seguidos fuera de rango antes de disparar
+    MinConsecutive  int     `gorm:"not null;default:0"`  // lecturas seguidas fuera de rango antes de disparar (0 o 1 = una)
+    ReminderMinutes int     `gorm:"not null;default:0"`  // 0 = sin recordatorios mientras siga disparada
+    State           string  `gorm:"not null;default:OK"` // OK, FIRING o RESOLVED
+    StateChangedAt  *time.Time
+    LastNotifiedAt  *time.Time
+    CreatedAt       time.Time
+    UpdatedAt       time.Time
 }
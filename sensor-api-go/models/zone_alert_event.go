@@ -8,13 +8,23 @@ import (
 	"github.com/google/uuid"
 )
 
+// Transiciones que generan un evento (y una notificación)
+const (
+	TransitionFiring   = "firing"
+	TransitionReminder = "reminder"
+	TransitionResolved = "resolved"
+)
+
 type ZoneAlertEvent struct {
 	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	CompanyID   uuid.UUID `gorm:"type:uuid;index" json:"company_id"`
+	AlertID     uuid.UUID `gorm:"type:uuid;index" json:"alert_id"`
 	ZoneID      uuid.UUID `gorm:"type:uuid;index" json:"zone_id"`
 	CameraID    int       `json:"camera_id"`
 	Temperature float64   `json:"temperature"`
 	Threshold   float64   `json:"threshold"`
-	Type        string    `json:"type"` // "upper", "lower"
+	Type        string    `json:"type"`       // "upper", "lower" u "ok" (al resolverse)
+	Transition  string    `json:"transition"` // "firing", "reminder", "resolved"
 	Timestamp   time.Time `json:"timestamp"`
 	Recipient   string    `json:"recipient"`
 	Sent        bool      `json:"sent"`
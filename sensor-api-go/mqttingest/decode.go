@@ -0,0 +1,192 @@
+// mqttingest/decode.go
+
+package mqttingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sensor-api-go/ingest"
+
+	"github.com/google/uuid"
+)
+
+// ParseTopic compara un topic con un patrón MQTT ("+" un nivel, "#" el resto)
+// y extrae el valor de cada "+" usando como nombre el nivel literal anterior.
+// Ej: patrón "tenant/+/camera/+/zone/+" y topic "tenant/acme/camera/3/zone/2"
+// → {"tenant": "acme", "camera": "3", "zone": "2"}
+func ParseTopic(pattern, topic string) (map[string]string, bool) {
+	pp := strings.Split(pattern, "/")
+	tp := strings.Split(topic, "/")
+	fields := map[string]string{}
+
+	for i, p := range pp {
+		if p == "#" {
+			return fields, true
+		}
+		if i >= len(tp) {
+			return nil, false
+		}
+		switch {
+		case p == "+":
+			name := fmt.Sprintf("level%d", i)
+			if i > 0 && pp[i-1] != "+" && pp[i-1] != "#" {
+				name = pp[i-1]
+			}
+			fields[name] = tp[i]
+		case p != tp[i]:
+			return nil, false
+		}
+	}
+	if len(tp) != len(pp) {
+		return nil, false
+	}
+	return fields, true
+}
+
+// jsonReading es el payload JSON; acepta nombres largos o abreviados
+type jsonReading struct {
+	CameraID    *int            `json:"camera_id"`
+	ZoneID      *int            `json:"zone_id"`
+	Temperature *float64        `json:"temperature"`
+	Temp        *float64        `json:"t"`
+	Timestamp   json.RawMessage `json:"timestamp"`
+	TS          json.RawMessage `json:"ts"`
+}
+
+// Decode convierte un mensaje en lecturas. La empresa sale del nivel "tenant"
+// del topic (UUID de la empresa); cámara y zona salen del topic y, si el topic
+// no las trae, del payload. El payload puede ser:
+//   - JSON: {"temperature": 31.2, "timestamp": "2025-01-01T10:00:00Z"} o un arreglo de esos
+//   - compacto: "31.2" o "31.2,1735725600" (temperatura y epoch en segundos o milisegundos)
+//
+// Sin timestamp se usa la hora de recepción.
+func Decode(fields map[string]string, payload []byte, received time.Time) ([]ingest.ReadingInput, error) {
+	var companyID uuid.UUID
+	if v, ok := fields["tenant"]; ok {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("tenant inválido en el topic: %q", v)
+		}
+		companyID = id
+	}
+	topicCamera, err := optionalInt(fields, "camera")
+	if err != nil {
+		return nil, err
+	}
+	topicZone, err := optionalInt(fields, "zone")
+	if err != nil {
+		return nil, err
+	}
+
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		return nil, errors.New("payload vacío")
+	}
+
+	var raw []jsonReading
+	switch payload[0] {
+	case '[':
+		if err := json.Unmarshal(payload, &raw); err != nil {
+			return nil, fmt.Errorf("JSON inválido: %v", err)
+		}
+	case '{':
+		var one jsonReading
+		if err := json.Unmarshal(payload, &one); err != nil {
+			return nil, fmt.Errorf("JSON inválido: %v", err)
+		}
+		raw = []jsonReading{one}
+	default:
+		one, err := decodeCompact(string(payload))
+		if err != nil {
+			return nil, err
+		}
+		raw = []jsonReading{one}
+	}
+
+	inputs := make([]ingest.ReadingInput, 0, len(raw))
+	for _, r := range raw {
+		in := ingest.ReadingInput{
+			CompanyID:   companyID,
+			CameraID:    r.CameraID,
+			ZoneID:      r.ZoneID,
+			Temperature: r.Temperature,
+		}
+		if topicCamera != nil {
+			in.CameraID = topicCamera
+		}
+		if topicZone != nil {
+			in.ZoneID = topicZone
+		}
+		if in.Temperature == nil {
+			in.Temperature = r.Temp
+		}
+		tsRaw := r.Timestamp
+		if len(tsRaw) == 0 {
+			tsRaw = r.TS
+		}
+		ts, err := parseTimestamp(tsRaw, received)
+		if err != nil {
+			return nil, err
+		}
+		in.Timestamp = &ts
+		inputs = append(inputs, in)
+	}
+	return inputs, nil
+}
+
+func decodeCompact(s string) (jsonReading, error) {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' })
+	if len(parts) == 0 || len(parts) > 2 {
+		return jsonReading{}, fmt.Errorf("payload compacto inválido: %q", s)
+	}
+	temp, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return jsonReading{}, fmt.Errorf("temperatura inválida: %q", parts[0])
+	}
+	r := jsonReading{Temperature: &temp}
+	if len(parts) == 2 {
+		r.Timestamp = json.RawMessage(parts[1])
+	}
+	return r, nil
+}
+
+// parseTimestamp acepta RFC3339 (string) o epoch en segundos/milisegundos (número)
+func parseTimestamp(raw json.RawMessage, received time.Time) (time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return received, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("timestamp inválido: %q", s)
+		}
+		return ts, nil
+	}
+	epoch, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp inválido: %s", raw)
+	}
+	if epoch > 1e12 {
+		return time.UnixMilli(int64(epoch)), nil
+	}
+	return time.Unix(int64(epoch), 0), nil
+}
+
+func optionalInt(fields map[string]string, name string) (*int, error) {
+	v, ok := fields[name]
+	if !ok {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s inválido en el topic: %q", name, v)
+	}
+	return &n, nil
+}
@@ -0,0 +1,151 @@
+// mqttingest/subscriber.go
+
+package mqttingest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"sensor-api-go/config"
+	"sensor-api-go/ingest"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Run se conecta al broker, se suscribe a los topics configurados con QoS 1
+// y guarda las lecturas por lotes hasta que ctx se cancela.
+//
+// Usa sesión persistente (clean session = false) y ACK manual: un mensaje solo
+// se confirma al broker cuando sus lecturas ya están guardadas, así que si el
+// proceso se cae antes, el broker lo reenvía al reconectar (entrega al menos una vez).
+func Run(ctx context.Context, cfg *config.MQTTConfig, db *gorm.DB) error {
+	batchCtx, stopBatcher := context.WithCancel(context.Background())
+	defer stopBatcher()
+
+	batcher := ingest.NewBatcher(db, cfg.BatchSize, cfg.FlushInterval)
+	batcherDone := make(chan struct{})
+	go func() {
+		batcher.Run(batchCtx)
+		close(batcherDone)
+	}()
+
+	var defaultCompany uuid.UUID
+	if cfg.CompanyID != "" {
+		id, err := uuid.Parse(cfg.CompanyID)
+		if err != nil {
+			return fmt.Errorf("MQTT_COMPANY_ID inválido: %w", err)
+		}
+		defaultCompany = id
+	}
+
+	handler := func(_ mqtt.Client, msg mqtt.Message) {
+		handleMessage(batchCtx, batcher, cfg.Topics, defaultCompany, msg)
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.BrokerURL).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetCleanSession(false).
+		SetAutoAckDisabled(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("[MQTT] Conexión perdida: %v", err)
+		}).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			// Re-suscribir en cada (re)conexión; con sesión persistente es idempotente
+			filters := make(map[string]byte, len(cfg.Topics))
+			for _, t := range cfg.Topics {
+				filters[t] = 1
+			}
+			token := c.SubscribeMultiple(filters, handler)
+			if token.Wait() && token.Error() != nil {
+				log.Printf("[MQTT] Error suscribiendo a %v: %v", cfg.Topics, token.Error())
+				return
+			}
+			log.Printf("[MQTT] Conectado a %s, suscrito a %v", cfg.BrokerURL, cfg.Topics)
+		})
+
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("no se pudo conectar al broker MQTT: %w", err)
+		}
+	case <-ctx.Done():
+		client.Disconnect(250)
+		return nil
+	}
+
+	<-ctx.Done()
+	log.Println("[MQTT] Deteniendo ingesta...")
+	// Primero dejar de recibir, luego guardar lo pendiente
+	client.Disconnect(250)
+	stopBatcher()
+	<-batcherDone
+	return nil
+}
+
+// handleMessage decodifica un mensaje y encola sus lecturas. El ACK se envía
+// cuando la última lectura del mensaje queda guardada. Los mensajes sin empresa
+// (ni en el topic ni por defecto) se descartan: que un gateway solo pueda publicar
+// en el tenant de su empresa lo garantizan las ACL del broker.
+func handleMessage(ctx context.Context, batcher *ingest.Batcher, patterns []string, defaultCompany uuid.UUID, msg mqtt.Message) {
+	fields, ok := matchTopic(patterns, msg.Topic())
+	if !ok {
+		log.Printf("[MQTT] Topic no reconocido, se descarta: %s", msg.Topic())
+		msg.Ack()
+		return
+	}
+	inputs, err := Decode(fields, msg.Payload(), time.Now())
+	if err != nil {
+		log.Printf("[MQTT] Payload inválido en %s, se descarta: %v", msg.Topic(), err)
+		msg.Ack()
+		return
+	}
+	if len(inputs) == 0 {
+		msg.Ack()
+		return
+	}
+	if inputs[0].CompanyID == uuid.Nil {
+		if defaultCompany == uuid.Nil {
+			log.Printf("[MQTT] Mensaje sin empresa en %s, se descarta (configure el nivel tenant o MQTT_COMPANY_ID)", msg.Topic())
+			msg.Ack()
+			return
+		}
+		for i := range inputs {
+			inputs[i].CompanyID = defaultCompany
+		}
+	}
+
+	remaining := int32(len(inputs))
+	ack := func() {
+		if atomic.AddInt32(&remaining, -1) == 0 {
+			msg.Ack()
+		}
+	}
+	for _, in := range inputs {
+		if err := batcher.Add(ctx, ingest.Item{Input: in, Ack: ack}); err != nil {
+			// Deteniéndose: sin ACK, el broker reenviará el mensaje
+			return
+		}
+	}
+}
+
+func matchTopic(patterns []string, topic string) (map[string]string, bool) {
+	for _, p := range patterns {
+		if fields, ok := ParseTopic(p, topic); ok {
+			return fields, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,169 @@
+package mqttingest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"sensor-api-go/config"
+	"sensor-api-go/models"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	server "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParseTopic(t *testing.T) {
+	fields, ok := ParseTopic("tenant/+/camera/+/zone/+", "tenant/acme/camera/3/zone/2")
+	if !ok {
+		t.Fatalf("Esperado que el topic coincida")
+	}
+	if fields["tenant"] != "acme" || fields["camera"] != "3" || fields["zone"] != "2" {
+		t.Errorf("Campos inesperados: %v", fields)
+	}
+
+	if _, ok := ParseTopic("tenant/+/camera/+/zone/+", "tenant/acme/camera/3"); ok {
+		t.Errorf("No debería coincidir un topic más corto")
+	}
+	if _, ok := ParseTopic("site/+/camera/#", "site/a/camera/1/zone/2"); !ok {
+		t.Errorf("Esperado que # coincida con el resto del topic")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	fields := map[string]string{"camera": "3", "zone": "2"}
+	received := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		payload string
+		temp    float64
+		ts      time.Time
+	}{
+		"json":              {`{"temperature": 31.5, "timestamp": "2025-01-01T09:00:00Z"}`, 31.5, time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)},
+		"json abreviado":    {`{"t": 30, "ts": 1735722000}`, 30, time.Unix(1735722000, 0)},
+		"compacto":          {`29.75`, 29.75, received},
+		"compacto con hora": {`29.75,1735722000000`, 29.75, time.UnixMilli(1735722000000)},
+	}
+	for name, tc := range cases {
+		inputs, err := Decode(fields, []byte(tc.payload), received)
+		if err != nil {
+			t.Fatalf("%s: error inesperado: %v", name, err)
+		}
+		if len(inputs) != 1 {
+			t.Fatalf("%s: esperada 1 lectura, hubo %d", name, len(inputs))
+		}
+		in := inputs[0]
+		if *in.CameraID != 3 || *in.ZoneID != 2 || *in.Temperature != tc.temp || !in.Timestamp.Equal(tc.ts) {
+			t.Errorf("%s: lectura inesperada: cam=%d zona=%d temp=%v ts=%v", name, *in.CameraID, *in.ZoneID, *in.Temperature, in.Timestamp)
+		}
+	}
+
+	if _, err := Decode(fields, []byte("hola"), received); err == nil {
+		t.Errorf("Esperado error con payload inválido")
+	}
+
+	companyID := uuid.New()
+	inputs, err := Decode(map[string]string{"tenant": companyID.String(), "camera": "1", "zone": "1"}, []byte("20"), received)
+	if err != nil || inputs[0].CompanyID != companyID {
+		t.Errorf("Esperada la empresa del topic: %v %+v", err, inputs)
+	}
+	if _, err := Decode(map[string]string{"tenant": "acme"}, []byte("20"), received); err == nil {
+		t.Errorf("Esperado error con tenant que no es UUID")
+	}
+}
+
+// TestRun_EmbeddedBroker levanta un broker MQTT embebido y verifica que las
+// lecturas publicadas terminen en la base.
+func TestRun_EmbeddedBroker(t *testing.T) {
+	addr := freeAddr(t)
+	broker := server.New(&server.Options{InlineClient: true})
+	_ = broker.AddHook(new(auth.AllowHook), nil)
+	if err := broker.AddListener(listeners.NewTCP(listeners.Config{ID: "t1", Address: addr})); err != nil {
+		t.Fatalf("No se pudo crear el listener: %v", err)
+	}
+	go broker.Serve()
+	defer broker.Close()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // una sola conexión para compartir la base en memoria
+	db.AutoMigrate(&models.CameraReading{}, &models.Device{}, &models.Zone{})
+
+	cfg := &config.MQTTConfig{
+		BrokerURL:     "tcp://" + addr,
+		ClientID:      "ingest-test",
+		Topics:        []string{"tenant/+/camera/+/zone/+"},
+		BatchSize:     10,
+		FlushInterval: 100 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg, db) }()
+
+	pub := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(cfg.BrokerURL).SetClientID("publisher"))
+	if token := pub.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatalf("No se pudo conectar el publicador: %v", token.Error())
+	}
+	defer pub.Disconnect(100)
+
+	// Esperar a que el suscriptor esté conectado antes de publicar
+	deadline := time.Now().Add(5 * time.Second)
+	companyID := uuid.New()
+	for len(broker.Topics.Subscribers(fmt.Sprintf("tenant/%s/camera/1/zone/1", companyID)).Subscriptions) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("El suscriptor no se conectó a tiempo")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	payloads := []string{
+		`{"temperature": 31.5}`,
+		`[{"t": 32}, {"t": 33}]`,
+		`34.5`,
+		`no-es-una-lectura`,
+	}
+	for i, p := range payloads {
+		topic := fmt.Sprintf("tenant/%s/camera/1/zone/%d", companyID, i+1)
+		if token := pub.Publish(topic, 1, false, p); token.Wait() && token.Error() != nil {
+			t.Fatalf("No se pudo publicar: %v", token.Error())
+		}
+	}
+
+	var count int64
+	deadline = time.Now().Add(5 * time.Second)
+	for count < 4 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		db.Model(&models.CameraReading{}).Count(&count)
+	}
+	if count != 4 {
+		t.Errorf("Esperadas 4 lecturas guardadas, hay %d", count)
+	}
+	var foreign int64
+	db.Model(&models.CameraReading{}).Where("company_id <> ?", companyID).Count(&foreign)
+	if foreign != 0 {
+		t.Errorf("Todas las lecturas deben quedar en la empresa del topic, %d no", foreign)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run terminó con error: %v", err)
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("No se pudo reservar un puerto: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
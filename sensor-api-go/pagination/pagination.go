@@ -0,0 +1,128 @@
+// pagination/pagination.go
+
+// Package pagination implementa la paginación por cursor (keyset) de los listados:
+// los resultados se ordenan de más nuevo a más antiguo por (tiempo, id) y cada
+// página retorna un cursor opaco para pedir la siguiente.
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// ErrInvalidCursor se retorna cuando el cursor no fue emitido por la API
+var ErrInvalidCursor = errors.New("cursor inválido")
+
+// Cursor es la posición del último elemento entregado
+type Cursor struct {
+	Time time.Time
+	ID   string
+}
+
+// Encode serializa el cursor para entregarlo al cliente
+func (c Cursor) Encode() string {
+	raw := c.Time.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode interpreta un cursor emitido por Encode
+func Decode(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Time: t, ID: id}, nil
+}
+
+// Key es el ID del cursor listo para comparar en SQL: numérico si la columna es
+// un entero autoincremental (lecturas), texto si es un UUID
+func (c Cursor) Key() interface{} {
+	if n, err := strconv.ParseUint(c.ID, 10, 64); err == nil {
+		return n
+	}
+	return c.ID
+}
+
+// Page son los parámetros ?limit= y ?cursor= de la solicitud
+type Page struct {
+	Limit int
+	After *Cursor
+}
+
+// FromQuery lee limit (por defecto DefaultLimit, como máximo MaxLimit) y cursor
+func FromQuery(c *gin.Context) (Page, error) {
+	page := Page{Limit: DefaultLimit}
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return page, fmt.Errorf("limit inválido %q", s)
+		}
+		if n > MaxLimit {
+			return page, fmt.Errorf("limit no puede ser mayor que %d", MaxLimit)
+		}
+		page.Limit = n
+	}
+	if s := c.Query("cursor"); s != "" {
+		cursor, err := Decode(s)
+		if err != nil {
+			return page, err
+		}
+		page.After = &cursor
+	}
+	return page, nil
+}
+
+// Scope ordena por (timeCol, idCol) descendente, salta hasta el cursor y pide un
+// elemento de más para saber si hay otra página
+func (p Page) Scope(timeCol, idCol string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if p.After != nil {
+			db = db.Where(fmt.Sprintf("(%s < ? OR (%s = ? AND %s < ?))", timeCol, timeCol, idCol),
+				p.After.Time, p.After.Time, p.After.Key())
+		}
+		return db.Order(timeCol + " DESC").Order(idCol + " DESC").Limit(p.Limit + 1)
+	}
+}
+
+// Trim recorta el elemento extra que pidió Scope y retorna el cursor de la página
+// siguiente ("" si no hay más)
+func Trim[T any](p Page, items []T, key func(T) Cursor) ([]T, string) {
+	if len(items) <= p.Limit {
+		return items, ""
+	}
+	items = items[:p.Limit]
+	return items, key(items[len(items)-1]).Encode()
+}
+
+// SetNextLink agrega el encabezado Link rel="next" con la misma URL y el cursor nuevo
+func SetNextLink(c *gin.Context, next string) {
+	if next == "" {
+		return
+	}
+	u := url.URL{Path: c.Request.URL.Path}
+	q := c.Request.URL.Query()
+	q.Set("cursor", next)
+	u.RawQuery = q.Encode()
+	c.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, u.String()))
+}
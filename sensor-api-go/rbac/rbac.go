@@ -0,0 +1,116 @@
+// rbac/rbac.go
+
+// Package rbac define los roles, los permisos y la política que los relaciona.
+// Las rutas la aplican con middleware.RequirePermission.
+package rbac
+
+import "strings"
+
+// Roles, de menor a mayor privilegio
+const (
+	RoleViewer     = "viewer"
+	RoleOperator   = "operator"
+	RoleAdmin      = "admin"
+	RoleSuperAdmin = "super-admin"
+)
+
+// Permission es una acción sobre un recurso, con la forma "recurso:acción"
+type Permission string
+
+const (
+	ReadingsRead    Permission = "readings:read"
+	DevicesRead     Permission = "devices:read"
+	DevicesWrite    Permission = "devices:write"
+	AlertsRead      Permission = "alerts:read"
+	AlertsWrite     Permission = "alerts:write"
+	UsersRead       Permission = "users:read"
+	UsersWrite      Permission = "users:write"
+	DeviceKeysRead  Permission = "device_keys:read"
+	DeviceKeysWrite Permission = "device_keys:write"
+	CompaniesRead   Permission = "companies:read"
+	CompaniesWrite  Permission = "companies:write"
+	SecurityWrite   Permission = "security:write"  // políticas de seguridad de la propia empresa
+	AuditRead       Permission = "audit:read"      // registro de auditoría de la propia empresa
+	RetentionWrite  Permission = "retention:write" // retención de lecturas de la propia empresa
+	SettingsWrite   Permission = "settings:write"  // zona horaria y unidades de la propia empresa
+	ReadingsImport  Permission = "readings:import" // importación de lecturas históricas desde CSV
+)
+
+// policy es la tabla de permisos de cada rol. Cada rol incluye explícitamente
+// los permisos del anterior para que la tabla se lea sin seguir herencias.
+var policy = map[string][]Permission{
+	RoleViewer: {
+		ReadingsRead, DevicesRead, AlertsRead,
+	},
+	RoleOperator: {
+		ReadingsRead, DevicesRead, AlertsRead,
+		AlertsWrite,
+	},
+	RoleAdmin: {
+		ReadingsRead, DevicesRead, AlertsRead,
+		AlertsWrite,
+		DevicesWrite, UsersRead, UsersWrite, DeviceKeysRead, DeviceKeysWrite, CompaniesRead, SecurityWrite, AuditRead, RetentionWrite, SettingsWrite, ReadingsImport,
+	},
+	RoleSuperAdmin: {
+		ReadingsRead, DevicesRead, AlertsRead,
+		AlertsWrite,
+		DevicesWrite, UsersRead, UsersWrite, DeviceKeysRead, DeviceKeysWrite, CompaniesRead, SecurityWrite, AuditRead, RetentionWrite, SettingsWrite, ReadingsImport,
+		CompaniesWrite,
+	},
+}
+
+// rank ordena los roles para impedir que alguien otorgue más privilegios de los que tiene
+var rank = map[string]int{
+	RoleViewer:     1,
+	RoleOperator:   2,
+	RoleAdmin:      3,
+	RoleSuperAdmin: 4,
+}
+
+// aliases acepta los nombres de rol usados antes (frontend y scripts)
+var aliases = map[string]string{
+	"administrator": RoleAdmin,
+	"manager":       RoleOperator,
+	"superadmin":    RoleSuperAdmin,
+	"super_admin":   RoleSuperAdmin,
+	"super admin":   RoleSuperAdmin,
+}
+
+// Normalize retorna el nombre canónico del rol, o "" si no es un rol conocido
+func Normalize(role string) string {
+	r := strings.ToLower(strings.TrimSpace(role))
+	if alias, ok := aliases[r]; ok {
+		return alias
+	}
+	if _, ok := policy[r]; ok {
+		return r
+	}
+	return ""
+}
+
+// Can indica si el rol tiene el permiso. Un rol desconocido no tiene ninguno.
+func Can(role string, perm Permission) bool {
+	for _, p := range policy[Normalize(role)] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// CanManage indica si actor puede asignar el rol target (o administrar a un usuario
+// con ese rol): nunca por encima del propio
+func CanManage(actor, target string) bool {
+	a, t := rank[Normalize(actor)], rank[Normalize(target)]
+	return a > 0 && t > 0 && t <= a
+}
+
+// IsAdmin indica si el rol es admin o superior (a quienes aplica la política de 2FA)
+func IsAdmin(role string) bool {
+	return rank[Normalize(role)] >= rank[RoleAdmin]
+}
+
+// Roles retorna los roles en orden de privilegio
+func Roles() []string {
+	return []string{RoleViewer, RoleOperator, RoleAdmin, RoleSuperAdmin}
+}
@@ -0,0 +1,69 @@
+package rbac
+
+import "testing"
+
+func TestCan(t *testing.T) {
+	cases := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{"viewer", ReadingsRead, true},
+		{"viewer", AlertsWrite, false},
+		{"Operator", AlertsWrite, true},
+		{"operator", UsersRead, false},
+		{"Manager", AlertsWrite, true}, // alias de operator
+		{"Administrator", UsersWrite, true},
+		{"admin", DeviceKeysWrite, true},
+		{"admin", CompaniesWrite, false},
+		{"super-admin", CompaniesWrite, true},
+		{"admin", SecurityWrite, true},
+		{"operator", SecurityWrite, false},
+		{"admin", AuditRead, true},
+		{"operator", AuditRead, false},
+		{"admin", RetentionWrite, true},
+		{"operator", RetentionWrite, false},
+		{"admin", SettingsWrite, true},
+		{"viewer", SettingsWrite, false},
+		{"admin", ReadingsImport, true},
+		{"operator", ReadingsImport, false},
+		{"", ReadingsRead, false},
+		{"root", ReadingsRead, false},
+	}
+	for _, tc := range cases {
+		if got := Can(tc.role, tc.perm); got != tc.want {
+			t.Errorf("Can(%q, %s) = %v, esperado %v", tc.role, tc.perm, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyIsCumulative(t *testing.T) {
+	roles := Roles()
+	for i := 1; i < len(roles); i++ {
+		for _, p := range policy[roles[i-1]] {
+			if !Can(roles[i], p) {
+				t.Errorf("%s debe incluir %s de %s", roles[i], p, roles[i-1])
+			}
+		}
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	for role, want := range map[string]bool{"admin": true, "Administrator": true, "super-admin": true, "operator": false, "root": false} {
+		if got := IsAdmin(role); got != want {
+			t.Errorf("IsAdmin(%q) = %v, esperado %v", role, got, want)
+		}
+	}
+}
+
+func TestCanManage(t *testing.T) {
+	if !CanManage("admin", "viewer") || !CanManage("admin", "Administrator") {
+		t.Errorf("admin debe poder asignar roles hasta el suyo")
+	}
+	if CanManage("admin", "super-admin") || CanManage("operator", "admin") {
+		t.Errorf("No se debe poder asignar un rol superior")
+	}
+	if CanManage("admin", "root") || CanManage("", "viewer") {
+		t.Errorf("Roles desconocidos no pueden asignarse ni asignar")
+	}
+}
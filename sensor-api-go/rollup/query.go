@@ -0,0 +1,138 @@
+// rollup/query.go
+
+package rollup
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"sensor-api-go/aggregate"
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrRawExpired indica que la consulta necesita lecturas originales que ya se
+// borraron por la retención de la empresa
+var ErrRawExpired = errors.New("el rango incluye lecturas que ya se eliminaron por la retención de la empresa; use un bucket de al menos 1h y sin percentiles")
+
+// Query es una serie agregada de una zona
+type Query struct {
+	CompanyID uuid.UUID
+	CameraID  int
+	ZoneID    int
+	From      time.Time
+	To        time.Time
+	Bucket    time.Duration
+	Funcs     []aggregate.Func
+}
+
+// Result es la serie con el rango efectivo, alineado a los buckets, y la fuente
+// de los datos: "raw" (lecturas originales) o la resolución de rollup usada
+type Result struct {
+	From   time.Time
+	To     time.Time
+	Source string
+	Points []aggregate.Point
+}
+
+// Series calcula la serie con la resolución más gruesa que divide el bucket y
+// todavía cubre el rango; las lecturas que el job aún no procesó se suman desde
+// la tabla original. Los percentiles siempre usan las lecturas originales.
+func Series(db *gorm.DB, q Query, now time.Time) (Result, error) {
+	from := aggregate.Truncate(q.From, q.Bucket)
+	to := aggregate.Truncate(q.To, q.Bucket)
+	if to.Before(q.To) {
+		to = to.Add(q.Bucket)
+	}
+	result := Result{From: from, To: to}
+
+	var company models.Company
+	if err := db.Select("id", "raw_retention_days").First(&company, "id = ?", q.CompanyID).Error; err != nil {
+		return result, err
+	}
+	readings := db.Model(&models.CameraReading{}).
+		Where("company_id = ? AND camera_id = ? AND zone_id = ?", q.CompanyID, q.CameraID, q.ZoneID)
+
+	res, ok := pick(q.Bucket, from, now)
+	if !ok || aggregate.HasPercentile(q.Funcs) {
+		if from.Before(RawCutoff(company, now)) {
+			return result, ErrRawExpired
+		}
+		points, err := aggregate.Series(readings, from, to, q.Bucket, q.Funcs)
+		result.Source, result.Points = "raw", points
+		return result, err
+	}
+
+	var state models.RollupState
+	if err := db.Limit(1).Find(&state, "name = ?", stateName).Error; err != nil {
+		return result, err
+	}
+	var rollups []models.ReadingRollup
+	err := db.Table(res.Table).
+		Where("company_id = ? AND camera_id = ? AND zone_id = ? AND bucket_start >= ? AND bucket_start < ?",
+			q.CompanyID, q.CameraID, q.ZoneID, from, to).
+		Find(&rollups).Error
+	if err != nil {
+		return result, err
+	}
+	buckets := map[time.Time]*aggregate.Stats{}
+	add := func(t time.Time, s aggregate.Stats) {
+		start := aggregate.Truncate(t, q.Bucket)
+		if buckets[start] == nil {
+			buckets[start] = &aggregate.Stats{}
+		}
+		buckets[start].Merge(s)
+	}
+	for _, r := range rollups {
+		add(r.BucketStart, aggregate.Stats{Count: r.Readings, Sum: r.SumTemp, Min: r.MinTemp, Max: r.MaxTemp})
+	}
+	// Las lecturas aún sin procesar se recorren sin cargarlas: si el job está
+	// atrasado pueden ser muchas
+	rows, err := readings.Select("timestamp", "temperature").
+		Where("id > ? AND timestamp >= ? AND timestamp < ?", state.LastReadingID, from, to).
+		Rows()
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ts time.Time
+		var temp float64
+		if err := rows.Scan(&ts, &temp); err != nil {
+			return result, err
+		}
+		add(ts, aggregate.Stats{Count: 1, Sum: temp, Min: temp, Max: temp})
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	starts := make([]time.Time, 0, len(buckets))
+	for start := range buckets {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	result.Source = res.Name
+	result.Points = make([]aggregate.Point, 0, len(starts))
+	for _, start := range starts {
+		result.Points = append(result.Points, buckets[start].Point(start, q.Funcs))
+	}
+	return result, nil
+}
+
+// pick elige la resolución más gruesa que divide el bucket y cuyos datos cubren from
+func pick(bucket time.Duration, from, now time.Time) (Resolution, bool) {
+	for _, res := range Resolutions {
+		if bucket%res.Size != 0 {
+			continue
+		}
+		if res.Retention > 0 && from.Before(now.Add(-res.Retention)) {
+			continue
+		}
+		return res, true
+	}
+	return Resolution{}, false
+}
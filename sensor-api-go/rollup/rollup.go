@@ -0,0 +1,239 @@
+// rollup/rollup.go
+
+// Package rollup mantiene las tablas de lecturas agregadas por minuto, hora y día,
+// borra las lecturas originales que superan la retención de cada empresa y elige
+// la resolución adecuada al consultar un rango.
+//
+// El job avanza por ID de lectura: cada lectura se suma exactamente una vez a los
+// tres rollups (en la misma transacción que mueve LastReadingID), así las lecturas
+// que llegan tarde (gateways que reenvían, importaciones) también quedan incluidas
+// y se pueden borrar las originales sin recalcular nada.
+package rollup
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"sensor-api-go/aggregate"
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Resolution es una tabla de rollup
+type Resolution struct {
+	Name      string
+	Size      time.Duration
+	Table     string
+	Retention time.Duration // 0 = se guarda siempre
+}
+
+var (
+	Minute = Resolution{Name: "1m", Size: time.Minute, Table: "camera_readings_1m", Retention: 30 * 24 * time.Hour}
+	Hour   = Resolution{Name: "1h", Size: time.Hour, Table: "camera_readings_1h"}
+	Day    = Resolution{Name: "1d", Size: 24 * time.Hour, Table: "camera_readings_1d"}
+)
+
+// Resolutions de la más gruesa a la más fina, el orden en que se prueban al consultar
+var Resolutions = []Resolution{Day, Hour, Minute}
+
+const (
+	BatchSize     = 5000 // lecturas por transacción
+	Interval      = time.Minute
+	PruneInterval = time.Hour
+	stateName     = "camera_readings"
+)
+
+// Migrate crea las tablas de rollup y la de estado del job
+func Migrate(tx *gorm.DB) error {
+	for _, res := range Resolutions {
+		if err := tx.Table(res.Table).AutoMigrate(&models.ReadingRollup{}); err != nil {
+			return err
+		}
+	}
+	return tx.AutoMigrate(&models.RollupState{})
+}
+
+// Drop elimina las tablas creadas por Migrate
+func Drop(tx *gorm.DB) error {
+	for _, res := range Resolutions {
+		if err := tx.Migrator().DropTable(res.Table); err != nil {
+			return err
+		}
+	}
+	return tx.Migrator().DropTable(&models.RollupState{})
+}
+
+func loadState(db *gorm.DB) (models.RollupState, error) {
+	state := models.RollupState{Name: stateName}
+	err := db.Where(models.RollupState{Name: stateName}).FirstOrCreate(&state).Error
+	return state, err
+}
+
+// Process suma a los rollups las lecturas nuevas y retorna cuántas procesó.
+//
+// Solo procesa hasta el mayor ID visto en la pasada anterior: en Postgres los IDs
+// se asignan al insertar pero las transacciones pueden confirmarse en otro orden,
+// y una lectura con ID menor que aún no era visible se perdería. Con una pasada de
+// margen (Interval) las transacciones en curso ya terminaron.
+func Process(db *gorm.DB, now time.Time) (int, error) {
+	state, err := loadState(db)
+	if err != nil {
+		return 0, err
+	}
+	processed := 0
+	for state.LastReadingID < state.SeenMaxID {
+		var readings []models.CameraReading
+		err := db.Where("id > ? AND id <= ?", state.LastReadingID, state.SeenMaxID).
+			Order("id").Limit(BatchSize).Find(&readings).Error
+		if err != nil {
+			return processed, err
+		}
+		next := state.SeenMaxID
+		if len(readings) == BatchSize {
+			next = readings[len(readings)-1].ID
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, res := range Resolutions {
+				if err := merge(tx, res, readings); err != nil {
+					return err
+				}
+			}
+			return tx.Model(&models.RollupState{}).Where("name = ?", stateName).
+				Update("last_reading_id", next).Error
+		})
+		if err != nil {
+			return processed, err
+		}
+		state.LastReadingID = next
+		processed += len(readings)
+	}
+
+	var maxID uint
+	if err := db.Model(&models.CameraReading{}).Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+		return processed, err
+	}
+	err = db.Model(&models.RollupState{}).Where("name = ?", stateName).
+		Updates(map[string]interface{}{"seen_max_id": maxID, "run_at": now.UTC()}).Error
+	return processed, err
+}
+
+type bucketKey struct {
+	companyID uuid.UUID
+	cameraID  int
+	zoneID    int
+	start     time.Time
+}
+
+// merge agrupa las lecturas en buckets de la resolución y los suma a los existentes
+func merge(tx *gorm.DB, res Resolution, readings []models.CameraReading) error {
+	groups := map[bucketKey]*aggregate.Stats{}
+	var keys []bucketKey
+	for _, r := range readings {
+		key := bucketKey{r.CompanyID, r.CameraID, r.ZoneID, aggregate.Truncate(r.Timestamp, res.Size)}
+		if groups[key] == nil {
+			groups[key] = &aggregate.Stats{}
+			keys = append(keys, key)
+		}
+		groups[key].Add(r.Temperature)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	rows := make([]models.ReadingRollup, 0, len(keys))
+	for _, key := range keys {
+		s := groups[key]
+		rows = append(rows, models.ReadingRollup{
+			CompanyID: key.companyID, CameraID: key.cameraID, ZoneID: key.zoneID, BucketStart: key.start,
+			Readings: s.Count, SumTemp: s.Sum, MinTemp: s.Min, MaxTemp: s.Max,
+		})
+	}
+	t := res.Table
+	upsert := clause.OnConflict{
+		Columns: []clause.Column{{Name: "company_id"}, {Name: "camera_id"}, {Name: "zone_id"}, {Name: "bucket_start"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "readings"}, Value: gorm.Expr(t + ".readings + excluded.readings")},
+			{Column: clause.Column{Name: "sum_temp"}, Value: gorm.Expr(t + ".sum_temp + excluded.sum_temp")},
+			{Column: clause.Column{Name: "min_temp"}, Value: gorm.Expr("CASE WHEN excluded.min_temp < " + t + ".min_temp THEN excluded.min_temp ELSE " + t + ".min_temp END")},
+			{Column: clause.Column{Name: "max_temp"}, Value: gorm.Expr("CASE WHEN excluded.max_temp > " + t + ".max_temp THEN excluded.max_temp ELSE " + t + ".max_temp END")},
+		},
+	}
+	return tx.Table(t).Clauses(upsert).CreateInBatches(rows, 500).Error
+}
+
+// Prune borra las lecturas originales más antiguas que la retención de su empresa
+// (solo las que ya están en los rollups) y los rollups por minuto vencidos
+func Prune(db *gorm.DB, now time.Time) (rawDeleted, rollupsDeleted int64, err error) {
+	state, err := loadState(db)
+	if err != nil {
+		return 0, 0, err
+	}
+	var companies []models.Company
+	if err := db.Where("raw_retention_days > 0").Find(&companies).Error; err != nil {
+		return 0, 0, err
+	}
+	for _, company := range companies {
+		cutoff := RawCutoff(company, now)
+		res := db.Where("company_id = ? AND timestamp < ? AND id <= ?", company.ID, cutoff, state.LastReadingID).
+			Delete(&models.CameraReading{})
+		if res.Error != nil {
+			return rawDeleted, rollupsDeleted, res.Error
+		}
+		rawDeleted += res.RowsAffected
+	}
+	for _, r := range Resolutions {
+		if r.Retention == 0 {
+			continue
+		}
+		res := db.Table(r.Table).Where("bucket_start < ?", now.Add(-r.Retention).UTC()).Delete(&models.ReadingRollup{})
+		if res.Error != nil {
+			return rawDeleted, rollupsDeleted, res.Error
+		}
+		rollupsDeleted += res.RowsAffected
+	}
+	return rawDeleted, rollupsDeleted, nil
+}
+
+// RawCutoff es el instante desde el cual la empresa conserva las lecturas
+// originales; cero si no tiene límite
+func RawCutoff(company models.Company, now time.Time) time.Time {
+	if company.RawRetentionDays <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-time.Duration(company.RawRetentionDays) * 24 * time.Hour).UTC()
+}
+
+// Run ejecuta el job cada Interval (y la limpieza cada PruneInterval) hasta que
+// se cancele ctx
+func Run(ctx context.Context, db *gorm.DB) {
+	var lastPrune time.Time
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		if n, err := Process(db, now); err != nil {
+			log.Printf("[ROLLUP] Error procesando lecturas: %v", err)
+		} else if n > 0 {
+			log.Printf("[ROLLUP] %d lecturas agregadas", n)
+		}
+		if now.Sub(lastPrune) >= PruneInterval {
+			raw, rollups, err := Prune(db, now)
+			if err != nil {
+				log.Printf("[ROLLUP] Error aplicando la retención: %v", err)
+			} else {
+				lastPrune = now
+				if raw > 0 || rollups > 0 {
+					log.Printf("[ROLLUP] Retención: %d lecturas y %d rollups por minuto eliminados", raw, rollups)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
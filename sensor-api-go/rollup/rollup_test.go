@@ -0,0 +1,189 @@
+package rollup
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"sensor-api-go/aggregate"
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	if err := db.AutoMigrate(&models.CameraReading{}, &models.Company{}); err != nil {
+		t.Fatalf("No se pudo migrar: %v", err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatalf("No se pudieron crear los rollups: %v", err)
+	}
+	return db
+}
+
+func reading(company uuid.UUID, zone int, temp float64, at time.Time) models.CameraReading {
+	return models.CameraReading{CompanyID: company, CameraID: 1, ZoneID: zone, Temperature: temp, Timestamp: at}
+}
+
+// process corre dos pasadas: la primera solo anota el mayor ID visible
+func process(t *testing.T, db *gorm.DB, now time.Time) int {
+	t.Helper()
+	total := 0
+	for i := 0; i < 2; i++ {
+		n, err := Process(db, now)
+		if err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+		total += n
+	}
+	return total
+}
+
+func TestProcessMergesNewAndLateReadings(t *testing.T) {
+	db := openDB(t)
+	company := uuid.New()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	db.Create(&[]models.CameraReading{
+		reading(company, 1, 10, day.Add(10*time.Hour)),
+		reading(company, 1, 20, day.Add(10*time.Hour+30*time.Second)),
+		reading(company, 1, 30, day.Add(11*time.Hour)),
+		reading(company, 2, 99, day.Add(10*time.Hour)),
+	})
+
+	if n, _ := Process(db, day); n != 0 {
+		t.Errorf("La primera pasada solo anota el mayor ID, procesó %d", n)
+	}
+	if n := process(t, db, day); n != 4 {
+		t.Errorf("Esperadas 4 lecturas procesadas, fueron %d", n)
+	}
+
+	var minute models.ReadingRollup
+	db.Table(Minute.Table).Where("zone_id = 1 AND bucket_start = ?", day.Add(10*time.Hour)).First(&minute)
+	if minute.Readings != 2 || minute.SumTemp != 30 || minute.MinTemp != 10 || minute.MaxTemp != 20 {
+		t.Errorf("Rollup por minuto inesperado: %+v", minute)
+	}
+
+	// Una lectura que llega tarde se suma al bucket existente
+	db.Create(&[]models.CameraReading{reading(company, 1, 5, day.Add(10*time.Hour+10*time.Second))})
+	if n := process(t, db, day); n != 1 {
+		t.Errorf("Solo la lectura nueva debe procesarse, fueron %d", n)
+	}
+	var hour, dayRollup models.ReadingRollup
+	db.Table(Hour.Table).Where("zone_id = 1 AND bucket_start = ?", day.Add(10*time.Hour)).First(&hour)
+	db.Table(Day.Table).Where("zone_id = 1 AND bucket_start = ?", day).First(&dayRollup)
+	if hour.Readings != 3 || hour.SumTemp != 35 || hour.MinTemp != 5 || hour.MaxTemp != 20 {
+		t.Errorf("Rollup por hora inesperado: %+v", hour)
+	}
+	if dayRollup.Readings != 4 || dayRollup.MaxTemp != 30 {
+		t.Errorf("Rollup por día inesperado: %+v", dayRollup)
+	}
+	var rows int64
+	db.Table(Minute.Table).Count(&rows)
+	if rows != 3 {
+		t.Errorf("Esperados 3 buckets por minuto, fueron %d", rows)
+	}
+}
+
+func TestPruneAndSeries(t *testing.T) {
+	db := openDB(t)
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	company := models.Company{ID: uuid.New(), Name: "A", RawRetentionDays: 7}
+	other := models.Company{ID: uuid.New(), Name: "B"}
+	db.Create(&company)
+	db.Create(&other)
+
+	// Una lectura cada 10 minutos durante 10 días, para las dos empresas
+	var readings []models.CameraReading
+	for at := now.Add(-10 * 24 * time.Hour); at.Before(now); at = at.Add(10 * time.Minute) {
+		temp := float64(at.Hour())
+		readings = append(readings, reading(company.ID, 1, temp, at), reading(other.ID, 1, temp, at))
+	}
+	db.CreateInBatches(readings, 500)
+	process(t, db, now)
+
+	query := Query{CompanyID: company.ID, CameraID: 1, ZoneID: 1, From: now.Add(-9 * 24 * time.Hour), To: now, Bucket: 24 * time.Hour, Funcs: []aggregate.Func{aggregate.Avg, aggregate.Max}}
+	before, err := Series(db, query, now)
+	if err != nil || before.Source != "1d" {
+		t.Fatalf("Antes de podar: fuente %q, error %v", before.Source, err)
+	}
+
+	raw, _, err := Prune(db, now)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	var left, otherLeft int64
+	db.Model(&models.CameraReading{}).Where("company_id = ?", company.ID).Count(&left)
+	db.Model(&models.CameraReading{}).Where("company_id = ?", other.ID).Count(&otherLeft)
+	if raw == 0 || left != 7*24*6 || otherLeft != 10*24*6 {
+		t.Errorf("Retención: %d borradas, quedan %d (A) y %d (B sin límite)", raw, left, otherLeft)
+	}
+
+	// Los rollups no cambian al podar las lecturas originales
+	after, err := Series(db, query, now)
+	if err != nil || len(after.Points) != len(before.Points) {
+		t.Fatalf("Después de podar: %d puntos (antes %d), error %v", len(after.Points), len(before.Points), err)
+	}
+	for i := range after.Points {
+		if after.Points[i].Count != before.Points[i].Count || after.Points[i].Values[aggregate.Avg] != before.Points[i].Values[aggregate.Avg] {
+			t.Errorf("Punto %d cambió: %+v -> %+v", i, before.Points[i], after.Points[i])
+		}
+	}
+
+	// Percentiles o buckets que no divide ningún rollup necesitan las lecturas podadas
+	query.Funcs = []aggregate.Func{"p95"}
+	if _, err := Series(db, query, now); !errors.Is(err, ErrRawExpired) {
+		t.Errorf("p95 fuera de la retención: esperado ErrRawExpired, fue %v", err)
+	}
+	query.Funcs, query.Bucket = []aggregate.Func{aggregate.Avg}, 90*time.Second
+	if _, err := Series(db, query, now); !errors.Is(err, ErrRawExpired) {
+		t.Errorf("Bucket de 90s fuera de la retención: esperado ErrRawExpired, fue %v", err)
+	}
+}
+
+func TestSeriesMatchesRaw(t *testing.T) {
+	db := openDB(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	company := models.Company{ID: uuid.New(), Name: "A"}
+	db.Create(&company)
+	var readings []models.CameraReading
+	for i := 0; i < 600; i++ {
+		at := now.Add(-time.Duration(i) * 17 * time.Second)
+		readings = append(readings, reading(company.ID, 1, math.Sin(float64(i))*10+20, at))
+	}
+	db.CreateInBatches(readings, 500)
+	process(t, db, now)
+	// Lecturas que el job todavía no procesó también cuentan
+	db.Create(&[]models.CameraReading{reading(company.ID, 1, 50, now.Add(-time.Minute))})
+
+	for _, bucket := range []time.Duration{time.Minute, 15 * time.Minute, time.Hour} {
+		funcs := []aggregate.Func{aggregate.Avg, aggregate.Min, aggregate.Max}
+		query := Query{CompanyID: company.ID, CameraID: 1, ZoneID: 1, From: now.Add(-3 * time.Hour), To: now, Bucket: bucket, Funcs: funcs}
+		got, err := Series(db, query, now)
+		if err != nil {
+			t.Fatalf("Series %v: %v", bucket, err)
+		}
+		want, _ := aggregate.Series(db.Model(&models.CameraReading{}), got.From, got.To, bucket, funcs)
+		if got.Source == "raw" || len(got.Points) != len(want) {
+			t.Fatalf("Bucket %v: fuente %q, %d puntos (raw %d)", bucket, got.Source, len(got.Points), len(want))
+		}
+		for i := range want {
+			g, w := got.Points[i], want[i]
+			if !g.Start.Equal(w.Start) || g.Count != w.Count || math.Abs(g.Values[aggregate.Avg]-w.Values[aggregate.Avg]) > 1e-9 ||
+				g.Values[aggregate.Min] != w.Values[aggregate.Min] || g.Values[aggregate.Max] != w.Values[aggregate.Max] {
+				t.Errorf("Bucket %v, punto %d: rollup %+v, raw %+v", bucket, i, g, w)
+			}
+		}
+	}
+
+	query := Query{CompanyID: company.ID, CameraID: 1, ZoneID: 1, From: now.Add(-time.Hour), To: now, Bucket: 5 * time.Minute, Funcs: []aggregate.Func{"p95"}}
+	if got, err := Series(db, query, now); err != nil || got.Source != "raw" {
+		t.Errorf("Los percentiles deben calcularse sobre las lecturas: %q, %v", got.Source, err)
+	}
+}
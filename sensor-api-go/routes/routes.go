@@ -1,45 +1,132 @@
 package routes
 
 import (
+	"sensor-api-go/audit"
 	"sensor-api-go/controllers"
 	"sensor-api-go/middleware"
+	"sensor-api-go/rbac"
+	"sensor-api-go/stream"
+	"sensor-api-go/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(r *gin.Engine, db *gorm.DB) {
+func SetupRoutes(r *gin.Engine, db *gorm.DB, hub *stream.Hub) {
+	// Límite por IP de las rutas de restablecimiento de contraseña
+	passwordResetLimiter := middleware.NewRateLimiter(5, 15*time.Minute)
+	invitationLimiter := middleware.NewRateLimiter(10, 15*time.Minute)
+	mfaLimiter := middleware.NewRateLimiter(10, 5*time.Minute)
+
 	// Endpoint público para health check
 	r.GET("/api/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
+	// Llaves públicas para validar los JWT (solo llaves asimétricas)
+	r.GET("/.well-known/jwks.json", controllers.JWKS())
 
-	api := r.Group("/api")
+	// Toda solicitud que modifica datos queda en el registro de auditoría
+	api := r.Group("/api", audit.Middleware(db))
 	{
 		api.POST("/login", controllers.Login(db))
+		api.POST("/refresh", controllers.Refresh(db))
+		api.POST("/logout", middleware.JWTAuthMiddleware(db), controllers.Logout(db))
+
+		// Segundo paso del login con 2FA (usa el mfa_token que retorna /login)
+		api.POST("/login/mfa", middleware.RateLimit(mfaLimiter), controllers.LoginMFA(db))
+		api.POST("/login/mfa/enroll", middleware.RateLimit(mfaLimiter), controllers.LoginMFAEnroll(db))
+
+		// 2FA del propio usuario
+		api.GET("/mfa", middleware.JWTAuthMiddleware(db), controllers.GetMFAStatus(db))
+		api.POST("/mfa/enroll", middleware.JWTAuthMiddleware(db), controllers.StartMFAEnrollment(db))
+		api.POST("/mfa/enroll/verify", middleware.JWTAuthMiddleware(db), middleware.RateLimit(mfaLimiter), controllers.VerifyMFAEnrollment(db))
+		api.POST("/mfa/recovery-codes", middleware.JWTAuthMiddleware(db), middleware.RateLimit(mfaLimiter), controllers.RegenerateRecoveryCodes(db))
+		api.POST("/mfa/disable", middleware.JWTAuthMiddleware(db), middleware.RateLimit(mfaLimiter), controllers.DisableMFA(db))
+
+		// Login SSO (OpenID Connect) con el proveedor de cada empresa
+		api.GET("/sso/callback", controllers.SSOCallback(db))
+		api.GET("/sso/:company_id", controllers.GetSSOStatus(db))
+		api.GET("/sso/:company_id/login", middleware.RateLimit(mfaLimiter), controllers.SSOLogin(db))
+		api.GET("/company/sso", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.CompaniesRead), controllers.GetSSOConfig(db))
+		api.PUT("/company/sso", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.SecurityWrite), controllers.UpdateSSOConfig(db))
+
+		// Políticas de seguridad de la empresa del token
+		api.GET("/company/security", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.CompaniesRead), controllers.GetSecurityPolicy(db))
+		api.PUT("/company/security", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.SecurityWrite), controllers.UpdateSecurityPolicy(db))
+
+		// Retención de lecturas originales (las más antiguas solo quedan en los rollups)
+		api.GET("/company/retention", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.CompaniesRead), controllers.GetRetention(db))
+		api.PUT("/company/retention", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.RetentionWrite), controllers.UpdateRetention(db))
+
+		// Zona horaria y unidad de temperatura de las exportaciones
+		api.GET("/company/locale", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.CompaniesRead), controllers.GetLocale(db))
+		api.PUT("/company/locale", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.SettingsWrite), controllers.UpdateLocale(db))
+
+		// Contraseñas: cambio (con la actual) y restablecimiento por email
+		api.POST("/password/change", middleware.JWTAuthMiddleware(db), controllers.ChangePassword(db))
+		api.POST("/password/forgot", middleware.RateLimit(passwordResetLimiter), controllers.ForgotPassword(db, utils.SendEmail, controllers.Background))
+		api.POST("/password/reset", middleware.RateLimit(passwordResetLimiter), controllers.ResetPassword(db))
 
-		api.GET("/camera-readings", middleware.JWTAuthMiddleware(), controllers.GetCameraReadings(db))
-		api.GET("/cameras", middleware.JWTAuthMiddleware(), controllers.ListUniqueCameras(db))
-		api.GET("/cameras/:camera_id/status", middleware.JWTAuthMiddleware(), controllers.CameraStatusDashboard(db))
-		api.GET("/cameras/:camera_id/zonas", middleware.JWTAuthMiddleware(), controllers.ListZonasByCamera(db))
+		api.GET("/camera-readings", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsRead), controllers.GetCameraReadings(db))
+		api.GET("/cameras", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsRead), controllers.ListUniqueCameras(db))
+		api.GET("/cameras/:camera_id/status", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsRead), controllers.CameraStatusDashboard(db))
+		api.GET("/cameras/:camera_id/zonas", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsRead), controllers.ListZonasByCamera(db))
+		api.GET("/cameras/:camera_id/zones/:zone_id/aggregate", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsRead), controllers.AggregateZoneReadings(db))
+		api.GET("/cameras/:camera_id/export", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsRead), controllers.ExportCameraReadings(db))
+		// Lecturas, estado de zonas y alertas en vivo (Server-Sent Events)
+		api.GET("/stream", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsRead), controllers.Stream(db, hub))
+
+		// Importación de lecturas históricas desde CSV (en segundo plano)
+		api.POST("/imports", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsImport), controllers.CreateImport(db))
+		api.GET("/imports", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsImport), controllers.ListImports(db))
+		api.GET("/imports/:id", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsImport), controllers.GetImport(db))
+		api.GET("/imports/:id/errors", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.ReadingsImport), controllers.ImportErrorReport(db))
 		api.GET("/companies", controllers.ListCompanies(db))
-		api.GET("/users", middleware.JWTAuthMiddleware(), controllers.ListUsers(db))
-		api.POST("/users", middleware.JWTAuthMiddleware(), controllers.CreateUser(db))
-		api.GET("/devices", middleware.JWTAuthMiddleware(), controllers.GetDevicesWithZones(db))
+		api.GET("/users", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.UsersRead), controllers.ListUsers(db))
+		api.PUT("/users/:id", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.UsersWrite), controllers.UpdateUser(db))
+		api.DELETE("/users/:id", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.UsersWrite), controllers.DeleteUser(db))
+		api.DELETE("/users/:id/mfa", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.UsersWrite), controllers.ResetUserMFA(db))
+		api.POST("/users/:id/unlock", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.UsersWrite), controllers.UnlockUser(db))
+
+		// Invitaciones: el invitado elige su contraseña desde el enlace enviado por email
+		api.GET("/invitations", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.UsersRead), controllers.ListInvitations(db))
+		api.POST("/invitations", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.UsersWrite), controllers.CreateInvitation(db, utils.SendEmail))
+		api.POST("/invitations/:id/resend", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.UsersWrite), controllers.ResendInvitation(db, utils.SendEmail))
+		api.DELETE("/invitations/:id", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.UsersWrite), controllers.RevokeInvitation(db))
+		api.POST("/invitations/accept", middleware.RateLimit(invitationLimiter), controllers.AcceptInvitation(db))
+
+		// Registro de auditoría de la empresa del token (filtros por query, exportación CSV)
+		api.GET("/audit-log", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AuditRead), controllers.ListAuditLog(db))
+		api.GET("/audit-log/export", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AuditRead), controllers.ExportAuditLog(db))
+
+		api.GET("/devices", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.DevicesRead), controllers.GetDevicesWithZones(db))
+
+		// Ingesta de lecturas desde gateways (autenticados con API key de dispositivo)
+		api.POST("/ingest/readings", middleware.DeviceKeyAuthMiddleware(db), controllers.IngestReadings(db))
+
+		// API keys de dispositivos
+		api.GET("/device-keys", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.DeviceKeysRead), controllers.ListDeviceKeys(db))
+		api.POST("/device-keys", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.DeviceKeysWrite), controllers.CreateDeviceKey(db))
+		api.POST("/device-keys/:id/rotate", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.DeviceKeysWrite), controllers.RotateDeviceKey(db))
+		api.DELETE("/device-keys/:id", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.DeviceKeysWrite), controllers.RevokeDeviceKey(db))
 
 		// Device Alerts
-		api.GET("/device-alerts", middleware.JWTAuthMiddleware(), controllers.ListDeviceAlerts(db))
-		api.POST("/device-alerts", middleware.JWTAuthMiddleware(), controllers.CreateDeviceAlert(db))
-		api.PUT("/device-alerts/:id", middleware.JWTAuthMiddleware(), controllers.UpdateDeviceAlert(db))
-		api.DELETE("/device-alerts/:id", middleware.JWTAuthMiddleware(), controllers.DeleteDeviceAlert(db))
+		api.GET("/device-alerts", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AlertsRead), controllers.ListDeviceAlerts(db))
+		api.POST("/device-alerts", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AlertsWrite), controllers.CreateDeviceAlert(db))
+		api.PUT("/device-alerts/:id", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AlertsWrite), controllers.UpdateDeviceAlert(db))
+		api.DELETE("/device-alerts/:id", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AlertsWrite), controllers.DeleteDeviceAlert(db))
 
 		// Zone Alerts
-		api.GET("/zone-alerts", middleware.JWTAuthMiddleware(), controllers.ListZoneAlerts(db))
-		api.POST("/zone-alerts", middleware.JWTAuthMiddleware(), controllers.CreateZoneAlert(db))
-		api.PUT("/zone-alerts/:id", middleware.JWTAuthMiddleware(), controllers.UpdateZoneAlert(db))
-		api.DELETE("/zone-alerts/:id", middleware.JWTAuthMiddleware(), controllers.DeleteZoneAlert(db))
+		api.GET("/zone-alerts", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AlertsRead), controllers.ListZoneAlerts(db))
+		api.POST("/zone-alerts", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AlertsWrite), controllers.CreateZoneAlert(db))
+		api.PUT("/zone-alerts/:id", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AlertsWrite), controllers.UpdateZoneAlert(db))
+		api.DELETE("/zone-alerts/:id", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AlertsWrite), controllers.DeleteZoneAlert(db))
 
 		// Historial de eventos de alerta de zona
-		api.GET("/zones/:zone_id/alert-events", middleware.JWTAuthMiddleware(), controllers.ListZoneAlertEvents(db))
+		api.GET("/zones/:zone_id/alert-events", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AlertsRead), controllers.ListZoneAlertEvents(db))
+
+		// Historial de eventos de alerta de dispositivo
+		api.GET("/devices/:device_id/alert-events", middleware.JWTAuthMiddleware(db), middleware.RequirePermission(rbac.AlertsRead), controllers.ListDeviceAlertEvents(db))
 	}
 }
@@ -5,98 +5,76 @@ import (
     "fmt"
     "os"
     "strings"
-    "time"
 
-    "golang.org/x/crypto/bcrypt"
-    "gorm.io/driver/postgres"
-    "gorm.io/gorm"
+    "sensor-api-go/config"
+    "sensor-api-go/migrations"
+    "sensor-api-go/models"
+    "sensor-api-go/rbac"
+    "sensor-api-go/utils"
+
     "github.com/google/uuid"
+    "github.com/joho/godotenv"
+    "gorm.io/gorm"
 )
 
-type Company struct {
-    ID   uuid.UUID `gorm:"type:uuid;primaryKey"`
-    Name string    `gorm:"not null;unique"`
-}
-
-type User struct {
-    ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
-    CompanyID uuid.UUID `gorm:"type:uuid;not null"`
-    Email     string    `gorm:"uniqueIndex;not null"`
-    Password  string    `gorm:"not null"`
-    Role      string    `gorm:"not null"`
-    CreatedAt time.Time
-}
-
+// Crea (si no existe) la empresa y un usuario activo en ella, usando los mismos
+// modelos y reglas de contraseña que el API. Uso: go run scripts/create_user.go
 func main() {
+    _ = godotenv.Load()
     reader := bufio.NewReader(os.Stdin)
+    ask := func(prompt string) string {
+        fmt.Print(prompt)
+        value, _ := reader.ReadString('\n')
+        return strings.TrimSpace(value)
+    }
 
-    fmt.Print("Nombre de la empresa: ")
-    companyName, _ := reader.ReadString('\n')
-    companyName = strings.TrimSpace(companyName)
-
-    fmt.Print("Email del usuario: ")
-    email, _ := reader.ReadString('\n')
-    email = strings.TrimSpace(email)
-
-    fmt.Print("Contraseña: ")
-    password, _ := reader.ReadString('\n')
-    password = strings.TrimSpace(password)
-
-    fmt.Print("Rol (admin/user): ")
-    role, _ := reader.ReadString('\n')
-    role = strings.TrimSpace(role)
+    companyName := ask("Nombre de la empresa: ")
+    name := ask("Nombre del usuario: ")
+    email := ask("Email del usuario: ")
+    password := ask("Contraseña: ")
+    role := rbac.Normalize(ask("Rol (" + strings.Join(rbac.Roles(), "/") + ", por defecto admin): "))
     if role == "" {
-        role = "admin"
+        role = rbac.RoleAdmin
     }
 
-    hashedPwd, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    hashedPwd, err := utils.HashPassword(password, email)
     if err != nil {
-        panic(err)
+        fmt.Println("Contraseña no válida:", err)
+        os.Exit(1)
     }
 
-    dsn := fmt.Sprintf(
-        "host=%s user=%s password=%s dbname=%s port=%s sslmode=require",
-        os.Getenv("DB_HOST"),
-        os.Getenv("DB_USER"),
-        os.Getenv("DB_PASSWORD"),
-        os.Getenv("DB_NAME"),
-        os.Getenv("DB_PORT"),
-    )
-    db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-    if err != nil {
-        panic("Failed to connect to database: " + err.Error())
+    db := config.SetupDB(config.LoadConfig())
+    if err := migrations.Check(db, migrations.All); err != nil {
+        fmt.Println(err)
+        os.Exit(1)
     }
 
     // 1. Busca la empresa por nombre
-    var company Company
+    var company models.Company
     if err := db.Where("name = ?", companyName).First(&company).Error; err != nil {
-        if err == gorm.ErrRecordNotFound {
-            // No existe, la crea
-            company = Company{
-                ID:   uuid.New(),
-                Name: companyName,
-            }
-            if err := db.Create(&company).Error; err != nil {
-                panic("No se pudo crear la empresa: " + err.Error())
-            }
-            fmt.Printf("Empresa '%s' creada con ID: %s\n", companyName, company.ID.String())
-        } else {
+        if err != gorm.ErrRecordNotFound {
             panic("Error buscando empresa: " + err.Error())
         }
+        // No existe, la crea
+        company = models.Company{ID: uuid.New(), Name: companyName}
+        if err := db.Create(&company).Error; err != nil {
+            panic("No se pudo crear la empresa: " + err.Error())
+        }
+        fmt.Printf("Empresa '%s' creada con ID: %s\n", companyName, company.ID.String())
     } else {
         fmt.Printf("Empresa encontrada: %s (ID: %s)\n", company.Name, company.ID.String())
     }
 
     // 2. Crea el usuario
-    user := User{
+    user := models.User{
         ID:        uuid.New(),
         CompanyID: company.ID,
+        Name:      name,
         Email:     email,
-        Password:  string(hashedPwd),
+        Password:  hashedPwd,
         Role:      role,
-        CreatedAt: time.Now(),
+        Status:    "Active",
     }
-
     if err := db.Create(&user).Error; err != nil {
         panic("No se pudo crear el usuario: " + err.Error())
     }
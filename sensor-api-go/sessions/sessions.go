@@ -0,0 +1,200 @@
+// sessions/sessions.go
+
+// Package sessions maneja las sesiones de usuario en el servidor: el login abre
+// una sesión con un refresh token rotativo, los access tokens (cortos) llevan su
+// ID y JWTAuthMiddleware los rechaza en cuanto la sesión se revoca.
+package sessions
+
+import (
+	"errors"
+	"time"
+
+	"sensor-api-go/config"
+	"sensor-api-go/models"
+	"sensor-api-go/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Motivos de revocación que quedan registrados en la sesión
+const (
+	ReasonLogout          = "logout"
+	ReasonTokenReuse      = "refresh_token_reuse"
+	ReasonUserDeactivated = "user_deactivated"
+	ReasonUserDeleted     = "user_deleted"
+	ReasonPasswordChanged = "password_changed"
+	ReasonMFAReset        = "mfa_reset"
+)
+
+var (
+	ErrInvalidToken   = errors.New("refresh token inválido o vencido")
+	ErrTokenReused    = errors.New("refresh token ya usado; la sesión fue revocada")
+	ErrSessionRevoked = errors.New("sesión revocada o vencida")
+	ErrUserInactive   = errors.New("usuario inactivo")
+)
+
+// Duraciones por defecto; Configure las toma de la configuración JWT
+var (
+	refreshTTL = 7 * 24 * time.Hour
+	sessionTTL = 30 * 24 * time.Hour
+)
+
+// Configure fija la vigencia de los refresh tokens y de las sesiones
+func Configure(cfg config.JWTConfig) {
+	if cfg.RefreshTTL > 0 {
+		refreshTTL = cfg.RefreshTTL
+	}
+	if cfg.SessionTTL > 0 {
+		sessionTTL = cfg.SessionTTL
+	}
+}
+
+// Start abre una sesión para el usuario y retorna su primer refresh token en claro
+func Start(db *gorm.DB, user models.User, userAgent, ip string) (models.Session, string, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		CompanyID: user.CompanyID,
+		ExpiresAt: now.Add(sessionTTL),
+		UserAgent: userAgent,
+		IP:        ip,
+		CreatedAt: now,
+	}
+	var plain string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		var err error
+		plain, err = issueRefreshToken(tx, session, now)
+		return err
+	})
+	if err != nil {
+		return models.Session{}, "", err
+	}
+	return session, plain, nil
+}
+
+// Refresh canjea un refresh token por uno nuevo de la misma sesión. Retorna la sesión
+// y el usuario actual, para emitir el access token con su rol y email vigentes.
+// Un token ya usado indica que fue robado (o filtrado): se revoca toda la sesión.
+func Refresh(db *gorm.DB, plain string, now time.Time) (models.Session, models.User, string, error) {
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", utils.HashAPIKey(plain)).First(&token).Error; err != nil {
+		return models.Session{}, models.User{}, "", ErrInvalidToken
+	}
+	if token.UsedAt != nil {
+		if err := Revoke(db, token.SessionID, ReasonTokenReuse); err != nil {
+			return models.Session{}, models.User{}, "", err
+		}
+		return models.Session{}, models.User{}, "", ErrTokenReused
+	}
+	if !now.Before(token.ExpiresAt) {
+		return models.Session{}, models.User{}, "", ErrInvalidToken
+	}
+
+	var (
+		session models.Session
+		user    models.User
+		next    string
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Marcar como usado solo si nadie lo hizo antes: dos refresh simultáneos con
+		// el mismo token no pueden ganar ambos
+		res := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			UpdateColumn("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrTokenReused
+		}
+		if err := tx.First(&session, "id = ?", token.SessionID).Error; err != nil {
+			return ErrSessionRevoked
+		}
+		if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+			return ErrSessionRevoked
+		}
+		if err := tx.First(&user, "id = ? AND company_id = ?", session.UserID, session.CompanyID).Error; err != nil {
+			return ErrUserInactive
+		}
+		if user.Status != "Active" {
+			return ErrUserInactive
+		}
+		if err := tx.Model(&session).UpdateColumn("last_used_at", now).Error; err != nil {
+			return err
+		}
+		var err error
+		next, err = issueRefreshToken(tx, session, now)
+		return err
+	})
+	switch {
+	case errors.Is(err, ErrTokenReused):
+		if rerr := Revoke(db, token.SessionID, ReasonTokenReuse); rerr != nil {
+			return models.Session{}, models.User{}, "", rerr
+		}
+		return models.Session{}, models.User{}, "", err
+	case errors.Is(err, ErrUserInactive):
+		if rerr := Revoke(db, token.SessionID, ReasonUserDeactivated); rerr != nil {
+			return models.Session{}, models.User{}, "", rerr
+		}
+		return models.Session{}, models.User{}, "", err
+	case err != nil:
+		return models.Session{}, models.User{}, "", err
+	}
+	return session, user, next, nil
+}
+
+// issueRefreshToken crea un refresh token para la sesión; nunca vence después que ella
+func issueRefreshToken(tx *gorm.DB, session models.Session, now time.Time) (string, error) {
+	plain, hash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	expires := now.Add(refreshTTL)
+	if session.ExpiresAt.Before(expires) {
+		expires = session.ExpiresAt
+	}
+	token := models.RefreshToken{ID: uuid.New(), SessionID: session.ID, TokenHash: hash, ExpiresAt: expires, CreatedAt: now}
+	if err := tx.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// IsActive indica si la sesión existe, no fue revocada y no ha vencido
+func IsActive(db *gorm.DB, sessionID uuid.UUID) (bool, error) {
+	var count int64
+	err := db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Revoke revoca una sesión; revocar una sesión ya revocada no cambia su motivo
+func Revoke(db *gorm.DB, sessionID uuid.UUID, reason string) error {
+	return db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		UpdateColumns(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason}).Error
+}
+
+// RevokeAllForUser revoca todas las sesiones abiertas del usuario
+func RevokeAllForUser(db *gorm.DB, userID uuid.UUID, reason string) error {
+	return db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		UpdateColumns(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason}).Error
+}
+
+// Purge elimina las sesiones vencidas y sus refresh tokens
+func Purge(db *gorm.DB, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&models.Session{}).Select("id").Where("expires_at <= ?", now)
+		if err := tx.Where("session_id IN (?)", expired).Delete(&models.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("expires_at <= ?", now).Delete(&models.Session{}).Error
+	})
+}
@@ -0,0 +1,302 @@
+// sso/sso.go
+
+// Package sso implementa el login OpenID Connect (flujo authorization code) con el
+// proveedor de identidad configurado por cada empresa en models.CompanySSO.
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"sensor-api-go/models"
+	"sensor-api-go/rbac"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrNotConfigured   = errors.New("la empresa no tiene SSO configurado")
+	ErrNonceMismatch   = errors.New("el id_token no corresponde a este login")
+	ErrEmailUnverified = errors.New("el proveedor no verificó el email")
+	ErrDomainNotAllow  = errors.New("el dominio del email no está permitido")
+	ErrNoRole          = errors.New("el usuario no tiene un rol asignado en el proveedor")
+	ErrUnsafeIssuer    = errors.New("el issuer debe ser una URL https de un host público")
+)
+
+// Identity es el usuario autenticado por el proveedor
+type Identity struct {
+	Subject string // "issuer#sub", estable aunque cambie el email
+	Email   string
+	Name    string
+	Role    string // rol local según RoleClaim/RoleMapping/DefaultRole
+}
+
+// providers guarda los proveedores ya descubiertos, por issuer
+var (
+	mu        sync.Mutex
+	providers = map[string]*oidc.Provider{}
+)
+
+// allowPrivateIssuers permite issuers http o en la red interna, solo para desarrollo
+// local y pruebas (SSO_ALLOW_PRIVATE_ISSUERS=1)
+func allowPrivateIssuers() bool {
+	return os.Getenv("SSO_ALLOW_PRIVATE_ISSUERS") == "1"
+}
+
+// ValidateIssuer exige https y rechaza hosts loopback, link-local o privados: el API
+// consulta esa URL, y quien configura el SSO no debe poder apuntarla a la red interna
+func ValidateIssuer(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("issuer debe ser una URL absoluta")
+	}
+	if allowPrivateIssuers() {
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return ErrUnsafeIssuer
+		}
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	if u.Scheme != "https" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrUnsafeIssuer
+	}
+	if ip := net.ParseIP(host); ip != nil && !PublicIP(ip) {
+		return ErrUnsafeIssuer
+	}
+	return nil
+}
+
+// PublicIP indica si la IP es enrutable en internet (no loopback, privada, link-local,
+// multicast ni sin especificar)
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// httpClient conecta solo a IPs públicas, validadas al momento de conectar: un nombre
+// que resuelve a la red interna (o cambia de resolución después de validarlo) no pasa
+var httpClient = &http.Client{
+	Timeout: 15 * time.Second,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				if allowPrivateIssuers() {
+					return nil
+				}
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+					return ErrUnsafeIssuer
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+func provider(ctx context.Context, issuer string) (*oidc.Provider, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if p, ok := providers[issuer]; ok {
+		return p, nil
+	}
+	if err := ValidateIssuer(issuer); err != nil {
+		return nil, err
+	}
+	// El proveedor conserva el cliente del contexto para descargar las llaves (JWKS)
+	p, err := oidc.NewProvider(oidc.ClientContext(ctx, httpClient), issuer)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo descubrir el proveedor %s: %w", issuer, err)
+	}
+	providers[issuer] = p
+	return p, nil
+}
+
+// Forget descarta el proveedor descubierto (p. ej. al cambiar la configuración)
+func Forget(issuer string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(providers, issuer)
+}
+
+func oauthConfig(p *oidc.Provider, cfg models.CompanySSO, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  redirectURL,
+		Endpoint:     p.Endpoint(),
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	}
+}
+
+// AuthURL retorna la URL del proveedor a la que se redirige el navegador
+func AuthURL(ctx context.Context, cfg models.CompanySSO, redirectURL, state, nonce string) (string, error) {
+	if !cfg.Enabled {
+		return "", ErrNotConfigured
+	}
+	p, err := provider(ctx, cfg.Issuer)
+	if err != nil {
+		return "", err
+	}
+	return oauthConfig(p, cfg, redirectURL).AuthCodeURL(state, oidc.Nonce(nonce)), nil
+}
+
+// Exchange canjea el código de autorización, valida el id_token (firma, issuer,
+// audiencia, vencimiento y nonce) y aplica las reglas de la empresa
+func Exchange(ctx context.Context, cfg models.CompanySSO, redirectURL, code, nonce string) (Identity, error) {
+	if !cfg.Enabled {
+		return Identity{}, ErrNotConfigured
+	}
+	p, err := provider(ctx, cfg.Issuer)
+	if err != nil {
+		return Identity{}, err
+	}
+	token, err := oauthConfig(p, cfg, redirectURL).Exchange(oidc.ClientContext(ctx, httpClient), code)
+	if err != nil {
+		return Identity{}, fmt.Errorf("el proveedor rechazó el código: %w", err)
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, errors.New("el proveedor no retornó id_token")
+	}
+	idToken, err := p.Verifier(&oidc.Config{ClientID: cfg.ClientID}).Verify(ctx, raw)
+	if err != nil {
+		return Identity{}, fmt.Errorf("id_token inválido: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return Identity{}, ErrNonceMismatch
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, err
+	}
+	return identityFromClaims(cfg, idToken.Issuer, idToken.Subject, claims)
+}
+
+func identityFromClaims(cfg models.CompanySSO, issuer, subject string, claims map[string]interface{}) (Identity, error) {
+	email, _ := claims["email"].(string)
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return Identity{}, errors.New("el id_token no incluye email")
+	}
+	// Sin email_verified se confía en el proveedor; con false se rechaza
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return Identity{}, ErrEmailUnverified
+	}
+	if !DomainAllowed(cfg, email) {
+		return Identity{}, ErrDomainNotAllow
+	}
+	role, err := MapRole(cfg, claims)
+	if err != nil {
+		return Identity{}, err
+	}
+	name, _ := claims["name"].(string)
+	if name == "" {
+		name = email
+	}
+	return Identity{Subject: issuer + "#" + subject, Email: email, Name: name, Role: role}, nil
+}
+
+// DomainAllowed indica si el dominio del email está en AllowedDomains (vacío: todos)
+func DomainAllowed(cfg models.CompanySSO, email string) bool {
+	domains := cfg.Domains()
+	if len(domains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range domains {
+		if domain == d {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRoleMapping valida el JSON de RoleMapping: cada valor debe ser un rol conocido
+func ParseRoleMapping(raw string) (map[string]string, error) {
+	mapping := map[string]string{}
+	if strings.TrimSpace(raw) == "" {
+		return mapping, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+		return nil, fmt.Errorf("role_mapping debe ser un objeto JSON {\"valor\": \"rol\"}: %w", err)
+	}
+	for value, role := range mapping {
+		canonical := rbac.Normalize(role)
+		if canonical == "" {
+			return nil, fmt.Errorf("role_mapping: rol desconocido %q", role)
+		}
+		mapping[value] = canonical
+	}
+	return mapping, nil
+}
+
+// MaxRole retorna el rol de mayor privilegio que la configuración puede asignar
+// (DefaultRole o algún valor de RoleMapping), o "" si no asigna ninguno
+func MaxRole(cfg models.CompanySSO) (string, error) {
+	mapping, err := ParseRoleMapping(cfg.RoleMapping)
+	if err != nil {
+		return "", err
+	}
+	best := rbac.Normalize(cfg.DefaultRole)
+	for _, role := range mapping {
+		if best == "" || rbac.CanManage(role, best) {
+			best = role
+		}
+	}
+	return best, nil
+}
+
+// MapRole elige el rol de mayor privilegio entre los valores mapeados del claim
+// RoleClaim (texto o lista); si ninguno está mapeado usa DefaultRole
+func MapRole(cfg models.CompanySSO, claims map[string]interface{}) (string, error) {
+	mapping, err := ParseRoleMapping(cfg.RoleMapping)
+	if err != nil {
+		return "", err
+	}
+	var values []string
+	switch v := claims[cfg.RoleClaim].(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	best := ""
+	for _, v := range values {
+		role, ok := mapping[v]
+		if ok && (best == "" || rbac.CanManage(role, best)) {
+			best = role
+		}
+	}
+	if best == "" {
+		best = rbac.Normalize(cfg.DefaultRole)
+	}
+	if best == "" {
+		return "", ErrNoRole
+	}
+	return best, nil
+}
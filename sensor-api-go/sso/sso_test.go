@@ -0,0 +1,89 @@
+package sso
+
+import (
+	"errors"
+	"testing"
+
+	"sensor-api-go/models"
+)
+
+func TestMapRole(t *testing.T) {
+	cfg := models.CompanySSO{RoleClaim: "groups", RoleMapping: `{"ops": "operator", "it": "Administrator"}`, DefaultRole: "viewer"}
+	for _, tc := range []struct {
+		claim interface{}
+		want  string
+	}{
+		{[]interface{}{"ops", "it"}, "admin"},
+		{[]interface{}{"it", "ops"}, "admin"},
+		{"ops", "operator"},
+		{[]interface{}{"otros"}, "viewer"},
+		{nil, "viewer"},
+	} {
+		got, err := MapRole(cfg, map[string]interface{}{"groups": tc.claim})
+		if err != nil || got != tc.want {
+			t.Errorf("MapRole(%v) = %q, %v; esperado %q", tc.claim, got, err, tc.want)
+		}
+	}
+
+	cfg.DefaultRole = ""
+	if _, err := MapRole(cfg, map[string]interface{}{"groups": "otros"}); !errors.Is(err, ErrNoRole) {
+		t.Errorf("Sin rol mapeado ni por defecto: esperado ErrNoRole, fue %v", err)
+	}
+	if _, err := ParseRoleMapping(`{"x": "dios"}`); err == nil {
+		t.Error("Un rol desconocido en el mapeo debe rechazarse")
+	}
+}
+
+func TestDomainAllowed(t *testing.T) {
+	cfg := models.CompanySSO{AllowedDomains: "acme.com, @Minera.cl"}
+	for email, want := range map[string]bool{
+		"ana@acme.com":     true,
+		"ana@MINERA.cl":    true,
+		"ana@otra.com":     false,
+		"ana@sub.acme.com": false,
+		"sin-arroba":       false,
+	} {
+		if got := DomainAllowed(cfg, email); got != want {
+			t.Errorf("DomainAllowed(%q) = %v, esperado %v", email, got, want)
+		}
+	}
+	if !DomainAllowed(models.CompanySSO{}, "cualquiera@x.com") {
+		t.Error("Sin dominios configurados se acepta cualquiera")
+	}
+}
+
+func TestValidateIssuer(t *testing.T) {
+	t.Setenv("SSO_ALLOW_PRIVATE_ISSUERS", "")
+	for issuer, ok := range map[string]bool{
+		"https://login.acme.com/realms/x": true,
+		"https://8.8.8.8":                 true,
+		"http://login.acme.com":           false,
+		"https://localhost:8443":          false,
+		"https://idp.localhost":           false,
+		"https://127.0.0.1":               false,
+		"https://[::1]":                   false,
+		"https://169.254.169.254":         false,
+		"https://10.1.2.3":                false,
+		"https://192.168.0.10":            false,
+		"file:///etc/passwd":              false,
+		"no-es-url":                       false,
+	} {
+		if err := ValidateIssuer(issuer); (err == nil) != ok {
+			t.Errorf("ValidateIssuer(%q) = %v, esperado aceptado=%v", issuer, err, ok)
+		}
+	}
+	t.Setenv("SSO_ALLOW_PRIVATE_ISSUERS", "1")
+	if err := ValidateIssuer("http://127.0.0.1:8080"); err != nil {
+		t.Errorf("En desarrollo se acepta un issuer local: %v", err)
+	}
+}
+
+func TestMaxRole(t *testing.T) {
+	cfg := models.CompanySSO{RoleMapping: `{"ops": "operator", "it": "admin"}`, DefaultRole: "viewer"}
+	if got, err := MaxRole(cfg); err != nil || got != "admin" {
+		t.Errorf("MaxRole = %q, %v; esperado admin", got, err)
+	}
+	if got, _ := MaxRole(models.CompanySSO{}); got != "" {
+		t.Errorf("Sin roles configurados MaxRole debe ser vacío, fue %q", got)
+	}
+}
@@ -0,0 +1,202 @@
+// stream/hub.go
+
+package stream
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// bufferSize es la cantidad de filas que un suscriptor puede tener pendientes; si
+// se llena (cliente lento) se le cierra el stream y al reconectar recupera lo
+// perdido con Last-Event-ID
+const bufferSize = 256
+
+type topic struct {
+	companyID uuid.UUID
+	cameraID  int
+}
+
+// Hub lee de la base las lecturas y eventos nuevos y los reparte a los suscriptores.
+// Hay uno por proceso del API.
+type Hub struct {
+	db      *gorm.DB
+	mu      sync.Mutex
+	subs    map[topic]map[*Subscription]struct{}
+	started bool
+	pos     Cursor // hasta dónde se repartió
+	seen    Cursor // mayores IDs vistos en la lectura anterior
+}
+
+// Subscription recibe en C las filas de una empresa y cámara. C se cierra si el
+// suscriptor no alcanza a consumirlas.
+type Subscription struct {
+	C     <-chan Item
+	Start Cursor // posición del Hub al suscribirse: lo anterior se obtiene con Replay
+
+	c      chan Item
+	topic  topic
+	hub    *Hub
+	closed bool
+}
+
+func NewHub(db *gorm.DB) *Hub {
+	return &Hub{db: db, subs: map[topic]map[*Subscription]struct{}{}}
+}
+
+// Subscribe suscribe a las filas nuevas de la cámara de la empresa
+func (h *Hub) Subscribe(companyID uuid.UUID, cameraID int) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.start(); err != nil {
+		return nil, err
+	}
+	c := make(chan Item, bufferSize)
+	sub := &Subscription{C: c, Start: h.pos, c: c, topic: topic{companyID, cameraID}, hub: h}
+	if h.subs[sub.topic] == nil {
+		h.subs[sub.topic] = map[*Subscription]struct{}{}
+	}
+	h.subs[sub.topic][sub] = struct{}{}
+	return sub, nil
+}
+
+// Close termina la suscripción
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+func (h *Hub) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.c)
+	delete(h.subs[s.topic], s)
+	if len(h.subs[s.topic]) == 0 {
+		delete(h.subs, s.topic)
+	}
+}
+
+// start fija la posición inicial en los IDs actuales: el stream no repite la historia
+func (h *Hub) start() error {
+	if h.started {
+		return nil
+	}
+	max, err := h.maxIDs()
+	if err != nil {
+		return err
+	}
+	h.pos, h.seen, h.started = max, max, true
+	return nil
+}
+
+func (h *Hub) maxIDs() (Cursor, error) {
+	var c Cursor
+	if err := h.db.Model(&models.CameraReading{}).Select("COALESCE(MAX(id), 0)").Scan(&c.Reading).Error; err != nil {
+		return c, err
+	}
+	err := h.db.Model(&models.StreamEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&c.Event).Error
+	return c, err
+}
+
+// Poll reparte las filas nuevas. Como rollup.Process, solo llega hasta los mayores
+// IDs vistos en la lectura anterior: en Postgres los IDs se asignan antes del
+// commit y una fila con ID menor aún no visible se perdería.
+func (h *Hub) Poll(now time.Time) error {
+	h.mu.Lock()
+	if err := h.start(); err != nil {
+		h.mu.Unlock()
+		return err
+	}
+	from, to, idle := h.pos, h.seen, len(h.subs) == 0
+	h.mu.Unlock()
+
+	next := to
+	var readings []models.CameraReading
+	var events []models.StreamEvent
+	// Sin suscriptores no hace falta leer las filas, solo avanzar
+	if !idle && from.Reading < to.Reading {
+		err := h.db.Where("id > ? AND id <= ? AND timestamp >= ?", from.Reading, to.Reading, now.Add(-MaxReadingAge).UTC()).
+			Order("id").Limit(MaxPerPoll).Find(&readings).Error
+		if err != nil {
+			return err
+		}
+		if len(readings) == MaxPerPoll {
+			next.Reading = readings[len(readings)-1].ID
+		}
+	}
+	if !idle && from.Event < to.Event {
+		err := h.db.Where("id > ? AND id <= ?", from.Event, to.Event).
+			Order("id").Limit(MaxPerPoll).Find(&events).Error
+		if err != nil {
+			return err
+		}
+		if len(events) == MaxPerPoll {
+			next.Event = events[len(events)-1].ID
+		}
+	}
+	max, err := h.maxIDs()
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range readings {
+		r := &readings[i]
+		h.deliver(topic{r.CompanyID, r.CameraID}, Item{Reading: r})
+	}
+	for i := range events {
+		e := &events[i]
+		h.deliver(topic{e.CompanyID, e.CameraID}, Item{Event: e})
+	}
+	h.pos = next
+	h.seen = max
+	return nil
+}
+
+func (h *Hub) deliver(t topic, item Item) {
+	for sub := range h.subs[t] {
+		select {
+		case sub.c <- item:
+		default:
+			log.Printf("[STREAM] Suscriptor de la cámara %d sin consumir eventos, se cierra su stream", t.cameraID)
+			h.remove(sub)
+		}
+	}
+}
+
+// Run lee la base cada PollInterval (y borra los eventos antiguos cada hora)
+// hasta que se cancele ctx
+func (h *Hub) Run(ctx context.Context) {
+	var lastPrune time.Time
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		if err := h.Poll(now); err != nil {
+			log.Printf("[STREAM] Error leyendo eventos nuevos: %v", err)
+		}
+		if now.Sub(lastPrune) >= time.Hour {
+			if _, err := Prune(h.db, now); err != nil {
+				log.Printf("[STREAM] Error borrando eventos antiguos: %v", err)
+			} else {
+				lastPrune = now
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// stream/stream.go
+
+// Package stream transmite en vivo los eventos de una cámara: lecturas nuevas y
+// transiciones de alertas. Las lecturas llegan por varios procesos (API, MQTT) y
+// las alertas las evalúa el worker, así que el Hub no depende de quién escribe:
+// lee la base cada PollInterval, igual que el job de rollups, avanzando por el ID
+// de camera_readings y de stream_events, y reparte cada fila a los clientes
+// suscritos a su empresa y cámara.
+//
+// La posición de un cliente es un Cursor (último ID de lectura y de evento que
+// recibió); viaja como id de cada evento SSE y vuelve en Last-Event-ID al
+// reconectar, con lo que Replay entrega lo que se perdió.
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Nombres de los eventos SSE
+const (
+	EventReading   = "reading"   // una lectura nueva (models.CameraReading)
+	EventZone      = "zone"      // cambio de estado de una zona (Activo/Inactivo)
+	EventAlert     = "alert"     // una transición de alerta (Alert)
+	EventHeartbeat = "heartbeat" // mantiene viva la conexión a través de proxies
+	EventReset     = "reset"     // se perdieron eventos: el cliente debe recargar el estado
+)
+
+const (
+	PollInterval  = time.Second
+	MaxPerPoll    = 5000           // filas por tabla en cada lectura de la base
+	MaxReplay     = 1000           // eventos que se reenvían al reconectar; con más se envía reset
+	MaxReadingAge = time.Hour      // lecturas más antiguas (historial importado, gateways atrasados) no se transmiten
+	Retention     = 24 * time.Hour // antigüedad de los stream_events que se borran
+)
+
+// ErrTooFarBehind indica que el cliente perdió más de MaxReplay eventos
+var ErrTooFarBehind = errors.New("demasiados eventos perdidos para reenviarlos")
+
+// Alert es una transición de alerta de zona o de dispositivo tal como se transmite
+type Alert struct {
+	Kind        string     `json:"kind"` // "zone" o "device"
+	AlertID     uuid.UUID  `json:"alert_id"`
+	EventID     uuid.UUID  `json:"event_id"` // ZoneAlertEvent o DeviceAlertEvent
+	ZoneID      *uuid.UUID `json:"zone_id,omitempty"`
+	DeviceID    *uuid.UUID `json:"device_id,omitempty"`
+	CameraID    int        `json:"camera_id"`
+	Transition  string     `json:"transition"` // "firing", "reminder", "resolved"
+	State       string     `json:"state"`      // estado de la alerta después de la transición
+	Type        string     `json:"type"`       // "upper", "lower" u "ok"
+	Temperature float64    `json:"temperature"`
+	Threshold   float64    `json:"threshold"`
+	Timestamp   time.Time  `json:"timestamp"`
+}
+
+// RecordAlert registra la transición para el stream. Se llama en la misma
+// transacción que guarda el evento de la alerta.
+func RecordAlert(tx *gorm.DB, companyID uuid.UUID, a Alert) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.StreamEvent{CompanyID: companyID, CameraID: a.CameraID, Type: EventAlert, Data: string(data)}).Error
+}
+
+// Cursor es la posición en el stream: el último ID de camera_readings y de
+// stream_events entregado
+type Cursor struct {
+	Reading uint
+	Event   uint
+}
+
+// String es el id SSE del cursor: "<lectura>-<evento>"
+func (c Cursor) String() string {
+	return fmt.Sprintf("%d-%d", c.Reading, c.Event)
+}
+
+// ParseCursor interpreta un Last-Event-ID
+func ParseCursor(s string) (Cursor, error) {
+	r, e, ok := strings.Cut(strings.TrimSpace(s), "-")
+	reading, err1 := strconv.ParseUint(r, 10, 64)
+	event, err2 := strconv.ParseUint(e, 10, 64)
+	if !ok || err1 != nil || err2 != nil {
+		return Cursor{}, fmt.Errorf("Last-Event-ID inválido %q", s)
+	}
+	return Cursor{Reading: uint(reading), Event: uint(event)}, nil
+}
+
+// Item es una fila para un suscriptor: una lectura o un evento
+type Item struct {
+	Reading *models.CameraReading
+	Event   *models.StreamEvent
+}
+
+// Replay retorna lo que la cámara recibió después de from y hasta to (la posición
+// del Hub al suscribirse): primero las lecturas y luego los eventos, cada uno en
+// orden de ID. Si son más de MaxReplay retorna ErrTooFarBehind.
+func Replay(db *gorm.DB, companyID uuid.UUID, cameraID int, from, to Cursor, now time.Time) ([]Item, error) {
+	var items []Item
+	if from.Reading < to.Reading {
+		var readings []models.CameraReading
+		err := db.Where("company_id = ? AND camera_id = ? AND id > ? AND id <= ? AND timestamp >= ?",
+			companyID, cameraID, from.Reading, to.Reading, now.Add(-MaxReadingAge).UTC()).
+			Order("id").Limit(MaxReplay + 1).Find(&readings).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := range readings {
+			items = append(items, Item{Reading: &readings[i]})
+		}
+	}
+	if from.Event < to.Event {
+		var events []models.StreamEvent
+		err := db.Where("company_id = ? AND camera_id = ? AND id > ? AND id <= ?", companyID, cameraID, from.Event, to.Event).
+			Order("id").Limit(MaxReplay + 1).Find(&events).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := range events {
+			items = append(items, Item{Event: &events[i]})
+		}
+	}
+	if len(items) > MaxReplay {
+		return nil, ErrTooFarBehind
+	}
+	return items, nil
+}
+
+// Prune borra los stream_events anteriores a Retention
+func Prune(db *gorm.DB, now time.Time) (int64, error) {
+	res := db.Where("created_at < ?", now.Add(-Retention)).Delete(&models.StreamEvent{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,106 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newStreamTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	if err := db.AutoMigrate(&models.CameraReading{}, &models.StreamEvent{}); err != nil {
+		t.Fatalf("No se pudo migrar: %v", err)
+	}
+	return db
+}
+
+func TestParseCursor(t *testing.T) {
+	c, err := ParseCursor(Cursor{Reading: 42, Event: 7}.String())
+	if err != nil || c.Reading != 42 || c.Event != 7 {
+		t.Errorf("ParseCursor: %+v %v", c, err)
+	}
+	for _, bad := range []string{"", "42", "a-1", "1-", "-1-2"} {
+		if _, err := ParseCursor(bad); err == nil {
+			t.Errorf("%q debe ser inválido", bad)
+		}
+	}
+}
+
+func TestHubDeliversOnlyNewRowsOfTheTopic(t *testing.T) {
+	db := newStreamTestDB(t)
+	company, other := uuid.New(), uuid.New()
+	now := time.Now().UTC()
+	db.Create(&models.CameraReading{CompanyID: company, CameraID: 1, Temperature: 1, Timestamp: now})
+
+	hub := NewHub(db)
+	sub, err := hub.Subscribe(company, 1)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if sub.Start.Reading != 1 {
+		t.Errorf("El stream empieza después de las lecturas existentes: %+v", sub.Start)
+	}
+	db.Create(&models.CameraReading{CompanyID: company, CameraID: 1, Temperature: 2, Timestamp: now})
+	db.Create(&models.CameraReading{CompanyID: other, CameraID: 1, Temperature: 3, Timestamp: now})
+	db.Create(&models.CameraReading{CompanyID: company, CameraID: 1, Temperature: 4, Timestamp: now.Add(-2 * MaxReadingAge)})
+	RecordAlert(db, company, Alert{Kind: "zone", CameraID: 1, Transition: models.TransitionFiring})
+
+	// La primera pasada solo ve los IDs nuevos; la segunda los reparte
+	hub.Poll(now)
+	if len(sub.C) != 0 {
+		t.Fatalf("Las filas se reparten una pasada después de verlas")
+	}
+	hub.Poll(now)
+	if len(sub.C) != 2 {
+		t.Fatalf("Esperadas la lectura nueva y la alerta, hay %d", len(sub.C))
+	}
+	if item := <-sub.C; item.Reading == nil || item.Reading.Temperature != 2 {
+		t.Errorf("Lectura inesperada: %+v", item)
+	}
+	if item := <-sub.C; item.Event == nil || item.Event.Type != EventAlert {
+		t.Errorf("Evento inesperado: %+v", item)
+	}
+
+	items, err := Replay(db, company, 1, sub.Start, Cursor{Reading: 4, Event: 1}, now)
+	if err != nil || len(items) != 2 {
+		t.Errorf("Replay debe entregar lo mismo que el Hub: %+v %v", items, err)
+	}
+	sub.Close()
+	sub.Close()
+}
+
+func TestHubDropsSlowSubscribersAndReplayLimit(t *testing.T) {
+	db := newStreamTestDB(t)
+	company := uuid.New()
+	hub := NewHub(db)
+	sub, _ := hub.Subscribe(company, 1)
+	now := time.Now().UTC()
+	readings := make([]models.CameraReading, MaxReplay+1)
+	for i := range readings {
+		readings[i] = models.CameraReading{CompanyID: company, CameraID: 1, Timestamp: now}
+	}
+	db.CreateInBatches(readings, 100)
+	hub.Poll(now)
+	hub.Poll(now)
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != bufferSize {
+		t.Errorf("El suscriptor lento recibe lo que cabe y su canal se cierra: %d", n)
+	}
+	if _, err := Replay(db, company, 1, Cursor{}, Cursor{Reading: uint(len(readings))}, now); !errors.Is(err, ErrTooFarBehind) {
+		t.Errorf("Con más de MaxReplay filas perdidas Replay debe fallar: %v", err)
+	}
+	sub.Close()
+}
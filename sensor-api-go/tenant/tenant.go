@@ -0,0 +1,106 @@
+// tenant/tenant.go
+
+// Package tenant aísla los datos por empresa: cada modelo con company_id solo
+// se consulta a través de Scope con la empresa del token (claim company_id).
+package tenant
+
+import (
+	"log"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Scope restringe una consulta a las filas de la empresa. La columna se califica
+// con la tabla actual para que siga siendo válida en consultas con joins.
+func Scope(companyID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: "company_id"},
+			Value:  companyID,
+		})
+	}
+}
+
+// DB retorna una conexión restringida a la empresa, reutilizable en varias consultas
+func DB(db *gorm.DB, companyID uuid.UUID) *gorm.DB {
+	return db.Scopes(Scope(companyID)).Session(&gorm.Session{})
+}
+
+// Models son los modelos que pertenecen a una empresa
+var Models = []interface{}{
+	&models.CameraReading{},
+	&models.Device{},
+	&models.Zone{},
+	&models.ZoneAlert{},
+	&models.ZoneAlertEvent{},
+	&models.DeviceAlert{},
+	&models.DeviceAlertEvent{},
+}
+
+// orphanCondition identifica filas sin empresa: NULL si la columna se agregó
+// después, o el UUID nulo si se crearon sin asignarla
+const orphanCondition = "company_id IS NULL OR company_id = ?"
+
+// Backfill asigna empresa a las filas creadas antes de que existiera company_id.
+// Las lecturas toman la empresa de la API key que tiene su cámara; si hay una sola
+// empresa, todo lo que quede sin dueño es de ella. Las filas que no se pueden
+// asignar quedan sin empresa y no son visibles para ningún tenant.
+func Backfill(db *gorm.DB) error {
+	var keys []models.DeviceAPIKey
+	if err := db.Find(&keys).Error; err != nil {
+		return err
+	}
+	owners := map[int]map[uuid.UUID]bool{}
+	for _, k := range keys {
+		for _, cam := range k.Cameras() {
+			if owners[cam] == nil {
+				owners[cam] = map[uuid.UUID]bool{}
+			}
+			owners[cam][k.CompanyID] = true
+		}
+	}
+	for cam, companies := range owners {
+		if len(companies) != 1 {
+			log.Printf("[TENANT] La cámara %d tiene API keys de %d empresas, sus lecturas no se asignan", cam, len(companies))
+			continue
+		}
+		for companyID := range companies {
+			if err := db.Model(&models.CameraReading{}).
+				Where(orphanCondition, uuid.Nil).
+				Where("camera_id = ?", cam).
+				UpdateColumn("company_id", companyID).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	var companies []models.Company
+	if err := db.Limit(2).Find(&companies).Error; err != nil {
+		return err
+	}
+	for _, m := range Models {
+		q := db.Model(m).Where(orphanCondition, uuid.Nil)
+		if len(companies) == 1 {
+			res := q.UpdateColumn("company_id", companies[0].ID)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				log.Printf("[TENANT] %d filas de %T asignadas a la empresa %s", res.RowsAffected, m, companies[0].Name)
+			}
+			continue
+		}
+		var orphans int64
+		if err := q.Count(&orphans).Error; err != nil {
+			return err
+		}
+		if orphans > 0 {
+			log.Printf("[TENANT] %d filas de %T sin empresa; deben asignarse manualmente", orphans, m)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package tenant
+
+import (
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBackfill(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	if err := db.AutoMigrate(append([]interface{}{&models.DeviceAPIKey{}, &models.Company{}}, Models...)...); err != nil {
+		t.Fatalf("No se pudo migrar: %v", err)
+	}
+	acme, other := uuid.New(), uuid.New()
+	db.Create(&[]models.Company{{ID: acme, Name: "Acme"}, {ID: other, Name: "Otra"}})
+
+	key := models.DeviceAPIKey{ID: uuid.New(), CompanyID: acme, Name: "gw", Prefix: "imk_1", KeyHash: "h1"}
+	key.SetCameras([]int{1})
+	db.Create(&key)
+
+	now := time.Now()
+	db.Create(&[]models.CameraReading{
+		{CameraID: 1, ZoneID: 1, Temperature: 20, Timestamp: now},
+		{CameraID: 2, ZoneID: 1, Temperature: 20, Timestamp: now},
+	})
+
+	if err := Backfill(db); err != nil {
+		t.Fatalf("Backfill falló: %v", err)
+	}
+	var owned, orphan int64
+	db.Model(&models.CameraReading{}).Scopes(Scope(acme)).Count(&owned)
+	db.Model(&models.CameraReading{}).Where(orphanCondition, uuid.Nil).Count(&orphan)
+	if owned != 1 || orphan != 1 {
+		t.Errorf("Con dos empresas solo se asigna la cámara con API key: asignadas %d, sin dueño %d", owned, orphan)
+	}
+
+	// Con una sola empresa, todo lo que queda sin dueño es de ella
+	db.Where("id = ?", other).Delete(&models.Company{})
+	if err := Backfill(db); err != nil {
+		t.Fatalf("Backfill falló: %v", err)
+	}
+	db.Model(&models.CameraReading{}).Scopes(Scope(acme)).Count(&owned)
+	if owned != 2 {
+		t.Errorf("Esperadas 2 lecturas de la única empresa, hay %d", owned)
+	}
+}
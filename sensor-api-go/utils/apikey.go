@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Prefijo común de las API keys de dispositivos, para reconocerlas en logs y escáneres de secretos
+const apiKeyPrefix = "imk_"
+
+// GenerateAPIKey crea una key aleatoria y retorna el valor en claro, su prefijo visible y su hash
+func GenerateAPIKey() (plain, prefix, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	plain = apiKeyPrefix + hex.EncodeToString(buf)
+	prefix = plain[:len(apiKeyPrefix)+8]
+	return plain, prefix, HashAPIKey(plain), nil
+}
+
+// HashAPIKey calcula el hash con el que se guarda y busca una key.
+// Las keys tienen 256 bits de entropía, así que SHA-256 es suficiente (no hace falta bcrypt).
+func HashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// Prefijos de los tokens de un solo uso
+const (
+	refreshTokenPrefix       = "imr_"
+	passwordResetTokenPrefix = "imp_"
+)
+
+// GenerateRefreshToken crea un refresh token aleatorio y retorna el valor en claro y su hash.
+// Igual que las API keys, solo se guarda el hash.
+func GenerateRefreshToken() (plain, hash string, err error) {
+	return randomToken(refreshTokenPrefix)
+}
+
+// GeneratePasswordResetToken crea el token que se envía por email para restablecer la contraseña
+func GeneratePasswordResetToken() (plain, hash string, err error) {
+	return randomToken(passwordResetTokenPrefix)
+}
+
+func randomToken(prefix string) (plain, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	plain = prefix + hex.EncodeToString(buf)
+	return plain, HashAPIKey(plain), nil
+}
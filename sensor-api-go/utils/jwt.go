@@ -1,43 +1,306 @@
 package utils
 
 import (
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "math/big"
+    "os"
+    "sort"
+    "sync"
     "time"
+
+    "sensor-api-go/config"
+
     "github.com/golang-jwt/jwt"
 )
 
-var jwtKey = []byte("mi_super_secreto") // Cámbialo por uno seguro
-
 type Claims struct {
     UserID    string
     Email     string
     CompanyID string
     Role      string
+    SessionID string `json:"sid"` // sesión del servidor; se revoca en logout o al desactivar al usuario
     jwt.StandardClaims
 }
 
-func GenerateJWT(userID, email, companyID, role string) (string, error) {
-    // Duración de una semana
-    expirationTime := time.Now().Add(7 * 24 * time.Hour)
+// signingKey es una llave del keyset: el método queda fijo por llave para que
+// un token no pueda elegir otro algoritmo (p. ej. HS256 con una llave pública RSA)
+type signingKey struct {
+    kid    string
+    method jwt.SigningMethod
+    sign   interface{} // []byte, *rsa.PrivateKey o ed25519.PrivateKey
+    verify interface{} // []byte, *rsa.PublicKey o ed25519.PublicKey
+}
+
+type keySet struct {
+    active *signingKey
+    byKID  map[string]*signingKey
+    ttl    time.Duration
+}
+
+// Los access tokens duran poco: la sesión se mantiene con el refresh token
+const defaultAccessTTL = 15 * time.Minute
+
+var (
+    keysMu sync.RWMutex
+    // Hasta llamar a ConfigureJWT se usa el secreto de desarrollo (tests y scripts)
+    keys = devKeySet()
+)
+
+func devKeySet() *keySet {
+    k := &signingKey{kid: "default", method: jwt.SigningMethodHS256, sign: []byte(config.DefaultJWTSecret), verify: []byte(config.DefaultJWTSecret)}
+    return &keySet{active: k, byKID: map[string]*signingKey{k.kid: k}, ttl: defaultAccessTTL}
+}
+
+// ConfigureJWT carga las llaves de la configuración. Debe llamarse al iniciar el API.
+func ConfigureJWT(cfg config.JWTConfig) error {
+    if len(cfg.Secrets) == 0 && len(cfg.KeyFiles) == 0 {
+        return nil // desarrollo: se mantiene el secreto por defecto
+    }
+    set := &keySet{byKID: map[string]*signingKey{}, ttl: cfg.TTL}
+    for kid, secret := range cfg.Secrets {
+        set.byKID[kid] = &signingKey{kid: kid, method: jwt.SigningMethodHS256, sign: []byte(secret), verify: []byte(secret)}
+    }
+    for kid, path := range cfg.KeyFiles {
+        pem, err := os.ReadFile(path)
+        if err != nil {
+            return fmt.Errorf("llave JWT %q: %w", kid, err)
+        }
+        k, err := parsePrivateKey(kid, pem)
+        if err != nil {
+            return err
+        }
+        set.byKID[kid] = k
+    }
+    set.active = set.byKID[cfg.ActiveKID]
+    if set.active == nil {
+        return fmt.Errorf("JWT_ACTIVE_KID %q no corresponde a ninguna llave", cfg.ActiveKID)
+    }
+    if set.ttl <= 0 {
+        set.ttl = defaultAccessTTL
+    }
+
+    keysMu.Lock()
+    keys = set
+    keysMu.Unlock()
+    return nil
+}
+
+// parsePrivateKey detecta el tipo de llave del PEM: RSA (RS256) o Ed25519 (EdDSA)
+func parsePrivateKey(kid string, pem []byte) (*signingKey, error) {
+    if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+        return &signingKey{kid: kid, method: jwt.SigningMethodRS256, sign: rsaKey, verify: &rsaKey.PublicKey}, nil
+    }
+    edKey, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+    if err != nil {
+        return nil, fmt.Errorf("llave JWT %q: el PEM no es una llave privada RSA ni Ed25519", kid)
+    }
+    priv, ok := edKey.(ed25519.PrivateKey)
+    if !ok {
+        return nil, fmt.Errorf("llave JWT %q: tipo de llave no soportado", kid)
+    }
+    return &signingKey{kid: kid, method: jwt.SigningMethodEdDSA, sign: priv, verify: priv.Public()}, nil
+}
+
+func currentKeys() *keySet {
+    keysMu.RLock()
+    defer keysMu.RUnlock()
+    return keys
+}
+
+// AccessTTL es la duración de los access tokens emitidos
+func AccessTTL() time.Duration {
+    return currentKeys().ttl
+}
+
+func GenerateJWT(userID, email, companyID, role, sessionID string) (string, error) {
+    set := currentKeys()
+    expirationTime := time.Now().Add(set.ttl)
     claims := &Claims{
         UserID:    userID,
         Email:     email,
         CompanyID: companyID,
         Role:      role,
+        SessionID: sessionID,
         StandardClaims: jwt.StandardClaims{
             ExpiresAt: expirationTime.Unix(),
+            IssuedAt:  time.Now().Unix(),
         },
     }
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString(jwtKey)
+    token := jwt.NewWithClaims(set.active.method, claims)
+    token.Header["kid"] = set.active.kid
+    return token.SignedString(set.active.sign)
 }
 
 func ValidateJWT(tokenString string) (*Claims, error) {
     claims := &Claims{}
-    token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-        return jwtKey, nil
-    })
-    if err != nil || !token.Valid {
+    if err := parseSigned(tokenString, claims); err != nil {
         return nil, err
     }
     return claims, nil
 }
+
+// parseSigned valida la firma con la llave del kid del token y carga sus claims
+func parseSigned(tokenString string, claims jwt.Claims) error {
+    set := currentKeys()
+    token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+        // Los tokens emitidos antes de usar kid se validan con la llave "default"
+        kid, _ := token.Header["kid"].(string)
+        if kid == "" {
+            kid = "default"
+        }
+        key, ok := set.byKID[kid]
+        if !ok {
+            return nil, fmt.Errorf("kid desconocido: %q", kid)
+        }
+        if token.Method.Alg() != key.method.Alg() {
+            return nil, fmt.Errorf("algoritmo %s no corresponde a la llave %q", token.Method.Alg(), kid)
+        }
+        return key.verify, nil
+    })
+    if err != nil {
+        return err
+    }
+    if !token.Valid {
+        return errors.New("token inválido")
+    }
+    return nil
+}
+
+// Audiencias de los tokens de un solo propósito; nunca se aceptan como access token
+// porque no llevan sesión (claim sid)
+const (
+    inviteAudience    = "invite"
+    mfaAudience       = "mfa"        // segundo paso del login
+    mfaEnrollAudience = "mfa-enroll" // login de un admin que debe activar 2FA
+    ssoStateAudience  = "sso-state"  // ida y vuelta al proveedor OIDC de la empresa
+)
+
+// signScoped firma un token de un solo propósito: la audiencia indica el uso,
+// subject el recurso e id permite invalidarlo desde el servidor
+func signScoped(audience, subject, id string, expiresAt time.Time) (string, error) {
+    set := currentKeys()
+    claims := &jwt.StandardClaims{
+        Audience:  audience,
+        Subject:   subject,
+        Id:        id,
+        ExpiresAt: expiresAt.Unix(),
+        IssuedAt:  time.Now().Unix(),
+    }
+    token := jwt.NewWithClaims(set.active.method, claims)
+    token.Header["kid"] = set.active.kid
+    return token.SignedString(set.active.sign)
+}
+
+// parseScoped valida firma, vencimiento y audiencia; retorna subject e id
+func parseScoped(tokenString, audience string) (string, string, error) {
+    claims := &jwt.StandardClaims{}
+    if err := parseSigned(tokenString, claims); err != nil {
+        return "", "", err
+    }
+    if !claims.VerifyAudience(audience, true) || claims.Subject == "" || claims.Id == "" {
+        return "", "", fmt.Errorf("el token no es de tipo %q", audience)
+    }
+    return claims.Subject, claims.Id, nil
+}
+
+// GenerateInviteToken firma el enlace de una invitación. tokenID cambia cada vez que
+// se reenvía, así solo el último enlace enviado es válido.
+func GenerateInviteToken(invitationID, tokenID string, expiresAt time.Time) (string, error) {
+    return signScoped(inviteAudience, invitationID, tokenID, expiresAt)
+}
+
+// ValidateInviteToken verifica la firma y el vencimiento de un enlace de invitación.
+// Retorna el ID de la invitación y el ID del token.
+func ValidateInviteToken(tokenString string) (invitationID, tokenID string, err error) {
+    return parseScoped(tokenString, inviteAudience)
+}
+
+// MFAChallengeTTL es el tiempo para ingresar el código después de la contraseña
+const MFAChallengeTTL = 5 * time.Minute
+
+// GenerateMFAChallenge emite el token del segundo paso del login. Con enroll, el
+// usuario aún no tiene 2FA y solo puede usarlo para activarlo.
+func GenerateMFAChallenge(userID string, enroll bool) (string, error) {
+    audience := mfaAudience
+    if enroll {
+        audience = mfaEnrollAudience
+    }
+    nonce := make([]byte, 16)
+    if _, err := rand.Read(nonce); err != nil {
+        return "", err
+    }
+    return signScoped(audience, userID, hex.EncodeToString(nonce), time.Now().Add(MFAChallengeTTL))
+}
+
+// ValidateMFAChallenge retorna el usuario del desafío y si es de activación
+func ValidateMFAChallenge(tokenString string) (userID string, enroll bool, err error) {
+    if userID, _, err = parseScoped(tokenString, mfaAudience); err == nil {
+        return userID, false, nil
+    }
+    if userID, _, err = parseScoped(tokenString, mfaEnrollAudience); err == nil {
+        return userID, true, nil
+    }
+    return "", false, err
+}
+
+// SSOStateTTL es el tiempo para completar el login en el proveedor de identidad
+const SSOStateTTL = 10 * time.Minute
+
+// GenerateSSOState firma el parámetro state del login OIDC de la empresa. Retorna
+// también el nonce, que debe volver en el id_token y en la cookie del navegador.
+func GenerateSSOState(companyID string) (state, nonce string, err error) {
+    buf := make([]byte, 16)
+    if _, err := rand.Read(buf); err != nil {
+        return "", "", err
+    }
+    nonce = hex.EncodeToString(buf)
+    state, err = signScoped(ssoStateAudience, companyID, nonce, time.Now().Add(SSOStateTTL))
+    return state, nonce, err
+}
+
+// ValidateSSOState retorna la empresa y el nonce de un state emitido por GenerateSSOState
+func ValidateSSOState(tokenString string) (companyID, nonce string, err error) {
+    return parseScoped(tokenString, ssoStateAudience)
+}
+
+// JWK es una llave pública en formato JSON Web Key (RFC 7517)
+type JWK struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid"`
+    Use string `json:"use"`
+    Alg string `json:"alg"`
+    N   string `json:"n,omitempty"`   // RSA: módulo
+    E   string `json:"e,omitempty"`   // RSA: exponente
+    Crv string `json:"crv,omitempty"` // OKP: curva
+    X   string `json:"x,omitempty"`   // OKP: llave pública
+}
+
+// JWKS retorna las llaves públicas del keyset (las HS256 son secretas y no se publican)
+func JWKS() []JWK {
+    set := currentKeys()
+    out := []JWK{}
+    for _, k := range set.byKID {
+        switch pub := k.verify.(type) {
+        case *rsa.PublicKey:
+            out = append(out, JWK{
+                Kty: "RSA", Kid: k.kid, Use: "sig", Alg: k.method.Alg(),
+                N: b64(pub.N.Bytes()),
+                E: b64(big.NewInt(int64(pub.E)).Bytes()),
+            })
+        case ed25519.PublicKey:
+            out = append(out, JWK{Kty: "OKP", Kid: k.kid, Use: "sig", Alg: k.method.Alg(), Crv: "Ed25519", X: b64(pub)})
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Kid < out[j].Kid })
+    return out
+}
+
+func b64(b []byte) string {
+    return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sensor-api-go/config"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func useJWTConfig(t *testing.T, cfg config.JWTConfig) {
+	t.Helper()
+	if err := ConfigureJWT(cfg); err != nil {
+		t.Fatalf("ConfigureJWT: %v", err)
+	}
+	t.Cleanup(func() { keys = devKeySet() })
+}
+
+func writePEM(t *testing.T, name string, der []byte, blockType string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("No se pudo escribir el PEM: %v", err)
+	}
+	return path
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	old := config.JWTConfig{ActiveKID: "k1", Secrets: map[string]string{"k1": "secreto-uno"}, TTL: time.Hour}
+	useJWTConfig(t, old)
+	token, err := GenerateJWT("u1", "a@example.com", "c1", "admin", "s1")
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+
+	// Rotación: k2 firma, k1 sigue validando los tokens ya emitidos
+	useJWTConfig(t, config.JWTConfig{ActiveKID: "k2", Secrets: map[string]string{"k1": "secreto-uno", "k2": "secreto-dos"}, TTL: time.Hour})
+	if claims, err := ValidateJWT(token); err != nil || claims.UserID != "u1" || claims.SessionID != "s1" {
+		t.Errorf("El token de k1 debe seguir siendo válido: %v", err)
+	}
+	fresh, _ := GenerateJWT("u2", "b@example.com", "c1", "admin", "s2")
+	parsed, _, _ := new(jwt.Parser).ParseUnverified(fresh, &Claims{})
+	if parsed.Header["kid"] != "k2" {
+		t.Errorf("Esperado kid k2 en el token nuevo, fue %v", parsed.Header["kid"])
+	}
+
+	// Retirar k1 invalida sus tokens
+	useJWTConfig(t, config.JWTConfig{ActiveKID: "k2", Secrets: map[string]string{"k2": "secreto-dos"}, TTL: time.Hour})
+	if _, err := ValidateJWT(token); err == nil {
+		t.Errorf("Un token firmado con una llave retirada no debe validar")
+	}
+}
+
+func TestJWTAsymmetricKeysAndJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaPath := writePEM(t, "rsa.pem", x509.MarshalPKCS1PrivateKey(rsaKey), "RSA PRIVATE KEY")
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	edPath := writePEM(t, "ed.pem", edDER, "PRIVATE KEY")
+
+	cfg := config.JWTConfig{
+		ActiveKID: "rsa-1",
+		Secrets:   map[string]string{"hs-1": "secreto-hmac"},
+		KeyFiles:  map[string]string{"rsa-1": rsaPath, "ed-1": edPath},
+		TTL:       time.Hour,
+	}
+	for _, active := range []string{"rsa-1", "ed-1"} {
+		cfg.ActiveKID = active
+		useJWTConfig(t, cfg)
+		token, err := GenerateJWT("u1", "a@example.com", "c1", "admin", "s1")
+		if err != nil {
+			t.Fatalf("%s: GenerateJWT: %v", active, err)
+		}
+		if _, err := ValidateJWT(token); err != nil {
+			t.Errorf("%s: token propio inválido: %v", active, err)
+		}
+	}
+
+	jwks := JWKS()
+	if len(jwks) != 2 || jwks[0].Kid != "ed-1" || jwks[0].Kty != "OKP" || jwks[1].Kid != "rsa-1" || jwks[1].Kty != "RSA" {
+		t.Errorf("El JWKS debe tener solo las llaves públicas RSA y Ed25519: %+v", jwks)
+	}
+
+	// Confusión de algoritmo: HS256 firmado con la llave pública RSA como secreto
+	pubDER := x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: "x", StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()}})
+	forged.Header["kid"] = "rsa-1"
+	signed, _ := forged.SignedString(pubDER)
+	if _, err := ValidateJWT(signed); err == nil {
+		t.Errorf("Un token HS256 con kid RSA no debe validar")
+	}
+}
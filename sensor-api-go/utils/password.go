@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Reglas de contraseña. bcrypt ignora lo que pase de 72 bytes, así que se rechaza.
+const (
+	MinPasswordLength = 10
+	MaxPasswordBytes  = 72
+)
+
+// ValidatePassword revisa que la contraseña cumpla las reglas mínimas: largo, al menos
+// una letra y un dígito, y que no sea el propio email
+func ValidatePassword(password, email string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return errors.New("la contraseña debe tener al menos 10 caracteres")
+	}
+	if len(password) > MaxPasswordBytes {
+		return errors.New("la contraseña no puede superar los 72 bytes")
+	}
+	var letter, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return errors.New("la contraseña debe incluir letras y números")
+	}
+	if email != "" && strings.EqualFold(password, email) {
+		return errors.New("la contraseña no puede ser igual al email")
+	}
+	return nil
+}
+
+// HashPassword valida la contraseña y retorna su hash bcrypt
+func HashPassword(password, email string) (string, error) {
+	if err := ValidatePassword(password, email); err != nil {
+		return "", err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	cases := []struct {
+		password string
+		ok       bool
+	}{
+		{"Termo2024seguro", true},
+		{"corta1", false},
+		{"sololetraslargas", false},
+		{"12345678901234", false},
+		{"a1" + strings.Repeat("x", 71), false}, // más de 72 bytes
+		{"ana1@example.com", false},            // igual al email
+	}
+	for _, tc := range cases {
+		err := ValidatePassword(tc.password, "ANA1@example.com")
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: esperado ok=%v, error %v", tc.password, tc.ok, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// zones/registry.go
+
+// Package zones es el registro que relaciona las lecturas (company_id, camera_id,
+// zone_id numérico) con los dispositivos y zonas identificados por UUID que usan
+// las alertas. Los números de cámara son propios de cada empresa.
+package zones
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotRegistered indica que la zona no tiene (cámara, índice) asociado
+var ErrNotRegistered = errors.New("zona sin cámara/índice registrado")
+
+// Lookup busca la zona registrada para una cámara e índice de zona de la empresa
+func Lookup(db *gorm.DB, companyID uuid.UUID, cameraID, zoneIndex int) (models.Zone, error) {
+	var zone models.Zone
+	err := db.Where("company_id = ? AND camera_id = ? AND zone_index = ?", companyID, cameraID, zoneIndex).
+		First(&zone).Error
+	return zone, err
+}
+
+// Ensure retorna la zona registrada para (cámara, índice), creando el dispositivo
+// y la zona si todavía no existen. Es seguro ante llamadas concurrentes.
+func Ensure(db *gorm.DB, companyID uuid.UUID, cameraID, zoneIndex int) (models.Zone, error) {
+	if zone, err := Lookup(db, companyID, cameraID, zoneIndex); err == nil {
+		return zone, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return zone, err
+	}
+
+	device, err := ensureDevice(db, companyID, cameraID)
+	if err != nil {
+		return models.Zone{}, err
+	}
+
+	cam, idx := cameraID, zoneIndex
+	zone := models.Zone{
+		ID:        uuid.New(),
+		CompanyID: companyID,
+		DeviceID:  device.ID,
+		Name:      fmt.Sprintf("Zona %d", zoneIndex),
+		CameraID:  &cam,
+		ZoneIndex: &idx,
+	}
+	// Si otro proceso la creó al mismo tiempo, el índice único evita el duplicado
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&zone).Error; err != nil {
+		return models.Zone{}, err
+	}
+	return Lookup(db, companyID, cameraID, zoneIndex)
+}
+
+func ensureDevice(db *gorm.DB, companyID uuid.UUID, cameraID int) (models.Device, error) {
+	var device models.Device
+	err := db.Where("company_id = ? AND camera_id = ?", companyID, cameraID).First(&device).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return device, err
+	}
+
+	cam := cameraID
+	device = models.Device{
+		ID:        uuid.New(),
+		CompanyID: companyID,
+		Name:      fmt.Sprintf("Cámara %d", cameraID),
+		CameraID:  &cam,
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&device).Error; err != nil {
+		return device, err
+	}
+	err = db.Where("company_id = ? AND camera_id = ?", companyID, cameraID).First(&device).Error
+	return device, err
+}
+
+// ByCamera retorna las zonas registradas de una cámara, indexadas por su zone_id numérico
+func ByCamera(db *gorm.DB, companyID uuid.UUID, cameraID int) (map[int]models.Zone, error) {
+	var list []models.Zone
+	if err := db.Where("company_id = ? AND camera_id = ?", companyID, cameraID).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	byIndex := make(map[int]models.Zone, len(list))
+	for _, z := range list {
+		if z.ZoneIndex != nil {
+			byIndex[*z.ZoneIndex] = z
+		}
+	}
+	return byIndex, nil
+}
+
+// Readings restringe una consulta sobre camera_readings a las lecturas de la zona
+func Readings(db *gorm.DB, zone models.Zone) (*gorm.DB, error) {
+	if zone.CameraID == nil || zone.ZoneIndex == nil {
+		return nil, ErrNotRegistered
+	}
+	return db.Where("company_id = ? AND camera_id = ? AND zone_id = ?", zone.CompanyID, *zone.CameraID, *zone.ZoneIndex), nil
+}
+
+// Backfill registra todas las combinaciones (empresa, cámara, zona) presentes en camera_readings
+// y reasigna las alertas antiguas cuyo zone_id era un UUID derivado (SHA1) del número
+// de zona. Es idempotente: puede ejecutarse en cada arranque.
+func Backfill(db *gorm.DB) error {
+	type pair struct {
+		CompanyID uuid.UUID
+		CameraID  int
+		ZoneID    int
+	}
+	var pairs []pair
+	if err := db.Model(&models.CameraReading{}).
+		Where("company_id IS NOT NULL").
+		Distinct("company_id", "camera_id", "zone_id").
+		Find(&pairs).Error; err != nil {
+		return err
+	}
+	created := 0
+	for _, p := range pairs {
+		if _, err := Lookup(db, p.CompanyID, p.CameraID, p.ZoneID); err == nil {
+			continue
+		}
+		if _, err := Ensure(db, p.CompanyID, p.CameraID, p.ZoneID); err != nil {
+			return fmt.Errorf("registrando cámara %d zona %d: %w", p.CameraID, p.ZoneID, err)
+		}
+		created++
+	}
+	if created > 0 {
+		log.Printf("[ZONES] %d zonas registradas desde camera_readings", created)
+	}
+	return remapLegacyAlerts(db)
+}
+
+// LegacyZoneUUID es el UUID que CreateZoneAlert derivaba antes de un zone_id numérico
+func LegacyZoneUUID(zoneIndex int) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(strconv.Itoa(zoneIndex)))
+}
+
+// remapLegacyAlerts apunta las alertas con UUID derivado a la zona registrada, solo
+// cuando el número de zona corresponde a una única cámara de la empresa (si no, es ambiguo)
+func remapLegacyAlerts(db *gorm.DB) error {
+	var orphans []models.ZoneAlert
+	if err := db.Where("zone_id NOT IN (?)", db.Model(&models.Zone{}).Select("id")).
+		Find(&orphans).Error; err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	var registered []models.Zone
+	if err := db.Where("zone_index IS NOT NULL").Find(&registered).Error; err != nil {
+		return err
+	}
+	type legacyKey struct {
+		companyID uuid.UUID
+		zoneID    uuid.UUID
+	}
+	candidates := map[legacyKey][]models.Zone{}
+	for _, z := range registered {
+		key := legacyKey{z.CompanyID, LegacyZoneUUID(*z.ZoneIndex)}
+		candidates[key] = append(candidates[key], z)
+	}
+
+	for _, za := range orphans {
+		matches := candidates[legacyKey{za.CompanyID, za.ZoneID}]
+		switch len(matches) {
+		case 0:
+			log.Printf("[ZONES] Alerta %s apunta a una zona inexistente (%s)", za.ID, za.ZoneID)
+		case 1:
+			if err := db.Model(&models.ZoneAlert{}).Where("id = ?", za.ID).
+				UpdateColumn("zone_id", matches[0].ID).Error; err != nil {
+				return err
+			}
+			log.Printf("[ZONES] Alerta %s reasignada a la zona %s", za.ID, matches[0].ID)
+		default:
+			log.Printf("[ZONES] Alerta %s ambigua: la zona %d existe en %d cámaras, debe reconfigurarse", za.ID, *matches[0].ZoneIndex, len(matches))
+		}
+	}
+	return nil
+}
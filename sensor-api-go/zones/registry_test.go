@@ -0,0 +1,64 @@
+package zones
+
+import (
+	"testing"
+	"time"
+
+	"sensor-api-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBackfill(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("No se pudo abrir base en memoria: %v", err)
+	}
+	db.AutoMigrate(&models.CameraReading{}, &models.Device{}, &models.Zone{}, &models.ZoneAlert{})
+
+	now := time.Now()
+	company, other := uuid.New(), uuid.New()
+	db.Create(&[]models.CameraReading{
+		{CompanyID: company, CameraID: 1, ZoneID: 1, Temperature: 20, Timestamp: now},
+		{CompanyID: company, CameraID: 1, ZoneID: 1, Temperature: 21, Timestamp: now},
+		{CompanyID: company, CameraID: 1, ZoneID: 2, Temperature: 22, Timestamp: now},
+		{CompanyID: company, CameraID: 2, ZoneID: 2, Temperature: 23, Timestamp: now},
+		// La cámara 1 de otra empresa es otro dispositivo
+		{CompanyID: other, CameraID: 1, ZoneID: 1, Temperature: 24, Timestamp: now},
+	})
+
+	// Alertas creadas con el UUID derivado antiguo: la zona 1 existe en una sola cámara,
+	// la zona 2 en dos (ambigua)
+	unique := models.ZoneAlert{ID: uuid.New(), CompanyID: company, ZoneID: LegacyZoneUUID(1), UpperThresh: 50}
+	ambiguous := models.ZoneAlert{ID: uuid.New(), CompanyID: company, ZoneID: LegacyZoneUUID(2), UpperThresh: 50}
+	db.Create(&unique)
+	db.Create(&ambiguous)
+
+	for i := 0; i < 2; i++ { // idempotente
+		if err := Backfill(db); err != nil {
+			t.Fatalf("Backfill falló: %v", err)
+		}
+	}
+
+	var zoneCount, deviceCount int64
+	db.Model(&models.Zone{}).Count(&zoneCount)
+	db.Model(&models.Device{}).Count(&deviceCount)
+	if zoneCount != 4 || deviceCount != 3 {
+		t.Errorf("Esperadas 4 zonas y 3 dispositivos, hay %d y %d", zoneCount, deviceCount)
+	}
+
+	zone, err := Lookup(db, company, 1, 1)
+	if err != nil {
+		t.Fatalf("Zona (1,1) no registrada: %v", err)
+	}
+	db.First(&unique, "id = ?", unique.ID)
+	if unique.ZoneID != zone.ID {
+		t.Errorf("La alerta antigua debió reasignarse a %s, quedó en %s", zone.ID, unique.ZoneID)
+	}
+	db.First(&ambiguous, "id = ?", ambiguous.ID)
+	if ambiguous.ZoneID != LegacyZoneUUID(2) {
+		t.Errorf("La alerta ambigua no debe reasignarse, quedó en %s", ambiguous.ZoneID)
+	}
+}